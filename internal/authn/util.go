@@ -7,14 +7,14 @@ import (
 	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/sha256"
+	"net"
+	"net/url"
 	"slices"
-	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func Client(
@@ -29,6 +29,7 @@ func Client(
 	if !ok {
 		return nil, oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid client")
 	}
+	ctx.AddLogAttrs("client_id", clientID)
 
 	client, err := ctx.Client(clientID)
 	if err != nil {
@@ -39,6 +40,13 @@ func Client(
 		return nil, err
 	}
 
+	ctx.NotifyEvent(goidc.Event{
+		Type:     goidc.EventTypeClientAuthenticated,
+		ClientID: client.ID,
+		Details: map[string]any{
+			"authn_method": client.AuthnMethod,
+		},
+	})
 	return client, nil
 }
 
@@ -102,15 +110,20 @@ func authenticateWithClientSecretBasic(
 }
 
 func validateSecret(
-	_ *oidc.Context,
+	ctx *oidc.Context,
 	client *goidc.Client,
 	clientSecret string,
 ) oidc.Error {
-	err := bcrypt.CompareHashAndPassword([]byte(client.HashedSecret), []byte(clientSecret))
-	if err != nil {
-		return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid secret")
+	if ctx.SecretHasher.Compare(client.HashedSecret, clientSecret) {
+		return nil
 	}
-	return nil
+
+	// During a rotation, the previous secret is accepted until it expires.
+	if client.PreviousSecretIsActive() && ctx.SecretHasher.Compare(client.PreviousHashedSecret, clientSecret) {
+		return nil
+	}
+
+	return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid secret")
 }
 
 func authenticateWithPrivateKeyJWT(
@@ -123,6 +136,10 @@ func authenticateWithPrivateKeyJWT(
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid assertion_type")
 	}
 
+	if ctx.ClientAuthnSignatureAlgorithmIsRequired && client.AuthnSignatureAlgorithm == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidClient, "the client must register a signing algorithm for its assertions")
+	}
+
 	signatureAlgorithms := ctx.PrivateKeyJWTSignatureAlgorithms
 	if client.AuthnSignatureAlgorithm != "" {
 		signatureAlgorithms = []jose.SignatureAlgorithm{client.AuthnSignatureAlgorithm}
@@ -160,6 +177,10 @@ func authenticateWithClientSecretJWT(
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid assertion_type")
 	}
 
+	if ctx.ClientAuthnSignatureAlgorithmIsRequired && client.AuthnSignatureAlgorithm == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidClient, "the client must register a signing algorithm for its assertions")
+	}
+
 	signatureAlgorithms := ctx.ClientSecretJWTSignatureAlgorithms
 	if client.AuthnSignatureAlgorithm != "" {
 		signatureAlgorithms = []jose.SignatureAlgorithm{client.AuthnSignatureAlgorithm}
@@ -171,7 +192,11 @@ func authenticateWithClientSecretJWT(
 
 	claims := jwt.Claims{}
 	if err := assertion.Claims([]byte(client.Secret), &claims); err != nil {
-		return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid assertion")
+		// During a rotation, the previous secret is accepted until it expires.
+		if !client.PreviousSecretIsActive() || client.PreviousSecret == "" ||
+			assertion.Claims([]byte(client.PreviousSecret), &claims) != nil {
+			return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid assertion")
+		}
 	}
 
 	return areAssertionClaimsValid(ctx, client, claims, ctx.ClientSecretJWTAssertionLifetimeSecs)
@@ -191,8 +216,8 @@ func areAssertionClaimsValid(
 	err := claims.ValidateWithLeeway(jwt.Expected{
 		Issuer:      client.ID,
 		Subject:     client.ID,
-		AnyAudience: ctx.Audiences(),
-	}, time.Duration(0))
+		AnyAudience: ctx.AssertionAudiences(),
+	}, ctx.ClockSkewTolerance())
 	if err != nil {
 		return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid assertion")
 	}
@@ -259,10 +284,30 @@ func authenticateWithTLSCertificate(
 	if client.TLSSubjectAlternativeName != "" && !slices.Contains(clientCert.DNSNames, client.TLSSubjectAlternativeName) {
 		return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid alternative name")
 	}
+	if client.TLSSubjectAlternativeNameIp != "" && !slices.ContainsFunc(clientCert.IPAddresses, func(ip net.IP) bool {
+		return ip.Equal(net.ParseIP(client.TLSSubjectAlternativeNameIp))
+	}) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid alternative name ip")
+	}
+	if client.TLSSubjectAlternativeNameURI != "" && !slices.ContainsFunc(clientCert.URIs, func(uri *url.URL) bool {
+		return uri.String() == client.TLSSubjectAlternativeNameURI
+	}) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid alternative name uri")
+	}
+	if client.TLSSubjectAlternativeNameEmail != "" &&
+		!slices.Contains(clientCert.EmailAddresses, client.TLSSubjectAlternativeNameEmail) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid alternative name email")
+	}
 
 	return nil
 }
 
+// ClientID returns the ID of the client making the request without
+// authenticating it.
+func ClientID(ctx *oidc.Context, req ClientAuthnRequest) (string, bool) {
+	return getClientID(ctx, req)
+}
+
 func getClientID(
 	ctx *oidc.Context,
 	req ClientAuthnRequest,
@@ -1,6 +1,16 @@
 package authn
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -78,6 +88,102 @@ func TestGetAuthenticatedClient_WithSecretPostAuthn(t *testing.T) {
 	assert.NotNil(t, err, "The client should be authenticated")
 }
 
+func TestGetAuthenticatedClient_WithSecretPostAuthn_Argon2id(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.SecretHasher = goidc.NewArgon2idHasher()
+
+	clientSecret := "password"
+	hashedClientSecret, err := ctx.SecretHasher.Hash(clientSecret)
+	require.Nil(t, err)
+	client := &goidc.Client{
+		ID: "random_client_id",
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			AuthnMethod: goidc.ClientAuthnSecretPost,
+		},
+		HashedSecret: hashedClientSecret,
+	}
+	require.Nil(t, ctx.SaveClient(client))
+
+	req := ClientAuthnRequest{
+		ClientID:     client.ID,
+		ClientSecret: clientSecret,
+	}
+
+	// When.
+	_, err = Client(ctx, req)
+
+	// Then.
+	assert.Nil(t, err, "the client should be authenticated")
+
+	// Given.
+	req.ClientSecret = "invalid_secret"
+	// When.
+	_, err = Client(ctx, req)
+	// Then.
+	assert.NotNil(t, err, "the client should not be authenticated")
+}
+
+func TestGetAuthenticatedClient_WithSecretPostAuthn_LegacyBCryptUnderArgon2id(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.SecretHasher = goidc.NewArgon2idHasher()
+
+	req := ClientAuthnRequest{
+		ClientID:     oidc.TestClientID,
+		ClientSecret: oidc.TestClientSecret,
+	}
+
+	// When.
+	_, err := Client(ctx, req)
+
+	// Then.
+	assert.Nil(t, err, "the client hashed with bcrypt should still be authenticated")
+}
+
+func TestGetAuthenticatedClient_WithSecretPostAuthn_PreviousSecret(t *testing.T) {
+	testCases := []struct {
+		name                             string
+		previousSecretExpiresAtTimestamp int64
+		previousSecretIsValid            bool
+	}{
+		{"during the rotation window", time.Now().Unix() + 60, true},
+		{"after the rotation window", time.Now().Unix() - 1, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			hashedSecret, _ := bcrypt.GenerateFromPassword([]byte("new_secret"), bcrypt.MinCost)
+			hashedPreviousSecret, _ := bcrypt.GenerateFromPassword([]byte("old_secret"), bcrypt.MinCost)
+			client := &goidc.Client{
+				ID: "random_client_id",
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					AuthnMethod: goidc.ClientAuthnSecretPost,
+				},
+				HashedSecret:                     string(hashedSecret),
+				PreviousHashedSecret:             string(hashedPreviousSecret),
+				PreviousSecretExpiresAtTimestamp: testCase.previousSecretExpiresAtTimestamp,
+			}
+
+			ctx := oidc.NewTestContext(t)
+			require.Nil(t, ctx.SaveClient(client))
+
+			// When.
+			_, newSecretErr := Client(ctx, ClientAuthnRequest{ClientID: client.ID, ClientSecret: "new_secret"})
+			_, oldSecretErr := Client(ctx, ClientAuthnRequest{ClientID: client.ID, ClientSecret: "old_secret"})
+
+			// Then.
+			assert.Nil(t, newSecretErr, "the new secret should always be accepted")
+			if testCase.previousSecretIsValid {
+				assert.Nil(t, oldSecretErr, "the old secret should be accepted during the rotation")
+			} else {
+				assert.NotNil(t, oldSecretErr, "the old secret should be rejected after the rotation")
+			}
+		})
+	}
+}
+
 func TestGetAuthenticatedClient_WithBasicSecretAuthn(t *testing.T) {
 
 	// Given.
@@ -159,6 +265,68 @@ func TestGetAuthenticatedClient_WithPrivateKeyJWT_HappyPath(t *testing.T) {
 
 }
 
+func TestGetAuthenticatedClient_WithPrivateKeyJWT_ClockSkewTolerance(t *testing.T) {
+	testCases := []struct {
+		name            string
+		issuedAtInSecs  int64
+		isAuthenticated bool
+	}{
+		{"issued in the future within tolerance", 5, true},
+		{"issued in the future beyond tolerance", 30, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
+			client := &goidc.Client{
+				ID: "random_client_id",
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					AuthnMethod: goidc.ClientAuthnPrivateKeyJWT,
+					PublicJWKS:  oidc.RawJWKS(privateJWK.Public()),
+				},
+			}
+
+			ctx := oidc.NewTestContext(t)
+			require.Nil(t, ctx.SaveClient(client))
+			ctx.PrivateKeyJWTSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+			ctx.PrivateKeyJWTAssertionLifetimeSecs = 60
+			ctx.ClockSkewToleranceSecs = 10
+
+			issuedAt := time.Now().Unix() + testCase.issuedAtInSecs
+			signer, _ := jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.RS256, Key: privateJWK.Key},
+				(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
+			)
+			claims := map[string]any{
+				goidc.ClaimIssuer:   client.ID,
+				goidc.ClaimSubject:  client.ID,
+				goidc.ClaimAudience: ctx.Host,
+				goidc.ClaimIssuedAt: issuedAt,
+				goidc.ClaimExpiry:   issuedAt + 30,
+			}
+			assertion, _ := jwt.Signed(signer).Claims(claims).Serialize()
+			req := ClientAuthnRequest{
+				ClientAssertionType: goidc.AssertionTypeJWTBearer,
+				ClientAssertion:     assertion,
+			}
+
+			// When.
+			_, err := Client(ctx, req)
+
+			// Then.
+			if testCase.isAuthenticated {
+				assert.Nil(t, err)
+				return
+			}
+
+			var oauthErr oidc.Error
+			require.ErrorAs(t, err, &oauthErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidClient, oauthErr.Code())
+		})
+	}
+}
+
 func TestGetAuthenticatedClient_WithPrivateKeyJWT_ClientInformedSigningAlgorithms(t *testing.T) {
 
 	// Given.
@@ -203,6 +371,72 @@ func TestGetAuthenticatedClient_WithPrivateKeyJWT_ClientInformedSigningAlgorithm
 
 }
 
+func TestGetAuthenticatedClient_WithPrivateKeyJWT_SignatureAlgorithmIsRequired(t *testing.T) {
+	testCases := []struct {
+		name               string
+		registeredAlg      jose.SignatureAlgorithm
+		assertionKeyIsRSA  bool
+		shouldAuthenticate bool
+	}{
+		{"registered algorithm", jose.PS256, false, true},
+		{"non registered algorithm", jose.PS256, true, false},
+		{"no algorithm registered", "", false, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			privateJWK := oidc.PrivatePS256JWK(t, "ps256_key")
+			if testCase.assertionKeyIsRSA {
+				privateJWK = oidc.PrivateRS256JWK(t, "rsa256_key")
+			}
+			client := &goidc.Client{
+				ID: "random_client_id",
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					AuthnMethod:             goidc.ClientAuthnPrivateKeyJWT,
+					PublicJWKS:              oidc.RawJWKS(privateJWK.Public()),
+					AuthnSignatureAlgorithm: testCase.registeredAlg,
+				},
+			}
+
+			ctx := oidc.NewTestContext(t)
+			require.Nil(t, ctx.SaveClient(client))
+			ctx.PrivateKeyJWTSignatureAlgorithms = []jose.SignatureAlgorithm{jose.PS256, jose.RS256}
+			ctx.PrivateKeyJWTAssertionLifetimeSecs = 60
+			ctx.ClientAuthnSignatureAlgorithmIsRequired = true
+
+			createdAtTimestamp := time.Now().Unix()
+			signer, _ := jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+				(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
+			)
+			claims := map[string]any{
+				goidc.ClaimIssuer:   client.ID,
+				goidc.ClaimSubject:  client.ID,
+				goidc.ClaimAudience: ctx.Host,
+				goidc.ClaimIssuedAt: createdAtTimestamp,
+				goidc.ClaimExpiry:   createdAtTimestamp + ctx.PrivateKeyJWTAssertionLifetimeSecs - 10,
+			}
+			assertion, _ := jwt.Signed(signer).Claims(claims).Serialize()
+			req := ClientAuthnRequest{
+				ClientAssertionType: goidc.AssertionTypeJWTBearer,
+				ClientAssertion:     assertion,
+			}
+
+			// When.
+			_, err := Client(ctx, req)
+
+			// Then.
+			if testCase.shouldAuthenticate {
+				assert.Nil(t, err, "the client should be authenticated")
+			} else {
+				require.NotNil(t, err, "the client should not be authenticated")
+				assert.Equal(t, oidc.ErrorCodeInvalidClient, err.Code())
+			}
+		})
+	}
+}
+
 func TestGetAuthenticatedClient_WithPrivateKeyJWT_InvalidAudienceClaim(t *testing.T) {
 	// Given.
 	privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
@@ -244,6 +478,74 @@ func TestGetAuthenticatedClient_WithPrivateKeyJWT_InvalidAudienceClaim(t *testin
 	assert.Contains(t, err.Error(), "invalid assertion")
 }
 
+func TestGetAuthenticatedClient_WithPrivateKeyJWT_AssertionAudienceMode(t *testing.T) {
+	testCases := []struct {
+		mode        goidc.AssertionAudienceMode
+		endpoint    string
+		audience    string
+		shouldAuthn bool
+	}{
+		{"", "/token", "https://example.com", true},
+		{"", "/token", "https://example.com/token", true},
+		{goidc.AssertionAudienceModeIssuer, "/token", "https://example.com", true},
+		{goidc.AssertionAudienceModeIssuer, "/token", "https://example.com/token", false},
+		{goidc.AssertionAudienceModeEndpoint, "/token", "https://example.com", false},
+		{goidc.AssertionAudienceModeEndpoint, "/token", "https://example.com/token", true},
+		{goidc.AssertionAudienceModeEndpoint, "/par", "https://example.com/par", true},
+		{goidc.AssertionAudienceModeEndpoint, "/par", "https://example.com/token", true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.mode)+" "+testCase.endpoint+" "+testCase.audience, func(t *testing.T) {
+			// Given.
+			privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
+			client := &goidc.Client{
+				ID: "random_client_id",
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					AuthnMethod: goidc.ClientAuthnPrivateKeyJWT,
+					PublicJWKS:  oidc.RawJWKS(privateJWK.Public()),
+				},
+			}
+
+			ctx := oidc.NewTestContext(t)
+			ctx.Host = "https://example.com"
+			ctx.Req = httptest.NewRequest(http.MethodPost, testCase.endpoint, nil)
+			ctx.PrivateKeyJWTSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+			ctx.PrivateKeyJWTAssertionLifetimeSecs = 60
+			ctx.AssertionAudienceMode = testCase.mode
+			require.Nil(t, ctx.SaveClient(client))
+
+			createdAtTimestamp := time.Now().Unix()
+			signer, _ := jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+				(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
+			)
+			claims := map[string]any{
+				goidc.ClaimIssuer:   client.ID,
+				goidc.ClaimSubject:  client.ID,
+				goidc.ClaimAudience: testCase.audience,
+				goidc.ClaimIssuedAt: createdAtTimestamp,
+				goidc.ClaimExpiry:   createdAtTimestamp + ctx.PrivateKeyJWTAssertionLifetimeSecs - 10,
+			}
+			assertion, _ := jwt.Signed(signer).Claims(claims).Serialize()
+			req := ClientAuthnRequest{
+				ClientAssertionType: goidc.AssertionTypeJWTBearer,
+				ClientAssertion:     assertion,
+			}
+
+			// When.
+			_, err := Client(ctx, req)
+
+			// Then.
+			if testCase.shouldAuthn {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
 func TestGetAuthenticatedClient_WithPrivateKeyJWT_InvalidExpiryClaim(t *testing.T) {
 	// Given.
 	privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
@@ -483,6 +785,64 @@ func TestGetAuthenticatedClient_WithClientSecretJWT_HappyPath(t *testing.T) {
 
 }
 
+func TestGetAuthenticatedClient_WithClientSecretJWT_PreviousSecret(t *testing.T) {
+	testCases := []struct {
+		name                             string
+		previousSecretExpiresAtTimestamp int64
+		previousSecretIsValid            bool
+	}{
+		{"during the rotation window", time.Now().Unix() + 60, true},
+		{"after the rotation window", time.Now().Unix() - 1, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			previousSecret := "random_old_password1234567891011"
+			client := &goidc.Client{
+				ID:                               "random_client_id",
+				Secret:                           "random_new_password1234567891011",
+				PreviousSecret:                   previousSecret,
+				PreviousSecretExpiresAtTimestamp: testCase.previousSecretExpiresAtTimestamp,
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					AuthnMethod: goidc.ClientAuthnSecretJWT,
+				},
+			}
+
+			ctx := oidc.NewTestContext(t)
+			require.Nil(t, ctx.SaveClient(client))
+			ctx.ClientSecretJWTSignatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+			ctx.ClientSecretJWTAssertionLifetimeSecs = 60
+
+			now := time.Now().Unix()
+			signer, _ := jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.HS256, Key: []byte(previousSecret)},
+				(&jose.SignerOptions{}).WithType("jwt"),
+			)
+			assertion, _ := jwt.Signed(signer).Claims(map[string]any{
+				goidc.ClaimIssuer:   client.ID,
+				goidc.ClaimSubject:  client.ID,
+				goidc.ClaimAudience: ctx.Host,
+				goidc.ClaimIssuedAt: now,
+				goidc.ClaimExpiry:   now + 30,
+			}).Serialize()
+
+			// When.
+			_, err := Client(ctx, ClientAuthnRequest{
+				ClientAssertionType: goidc.AssertionTypeJWTBearer,
+				ClientAssertion:     assertion,
+			})
+
+			// Then.
+			if testCase.previousSecretIsValid {
+				assert.Nil(t, err, "the old secret should be accepted during the rotation")
+			} else {
+				assert.NotNil(t, err, "the old secret should be rejected after the rotation")
+			}
+		})
+	}
+}
+
 func TestGetAuthenticatedClient_WithClientSecretJWT_InvalidAssertionType(t *testing.T) {
 
 	// Given.
@@ -557,3 +917,108 @@ func TestGetAuthenticatedClient_WithDifferentClientIDs(t *testing.T) {
 	}
 	require.NotNil(t, err, "the request cannot contain different client IDs")
 }
+
+func TestGetAuthenticatedClient_WithTLSAuthn_URIAlternativeName(t *testing.T) {
+	// Given.
+	client := &goidc.Client{
+		ID: "random_client_id",
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			AuthnMethod:                  goidc.ClientAuthnTLS,
+			TLSSubjectAlternativeNameURI: "https://client.example.com/id",
+		},
+	}
+
+	ctx := oidc.NewTestContext(t)
+	require.Nil(t, ctx.SaveClient(client))
+	setClientCertificate(t, ctx, "https://client.example.com/id", "another.client.example.com")
+
+	req := ClientAuthnRequest{
+		ClientID: client.ID,
+	}
+
+	// When.
+	_, err := Client(ctx, req)
+
+	// Then.
+	assert.Nil(t, err, "The client should be authenticated")
+}
+
+func TestGetAuthenticatedClient_WithTLSAuthn_DNSAlternativeNameDoesNotMatch(t *testing.T) {
+	// Given.
+	client := &goidc.Client{
+		ID: "random_client_id",
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			AuthnMethod:               goidc.ClientAuthnTLS,
+			TLSSubjectAlternativeName: "client.example.com",
+		},
+	}
+
+	ctx := oidc.NewTestContext(t)
+	require.Nil(t, ctx.SaveClient(client))
+	setClientCertificate(t, ctx, "https://client.example.com/id", "another.client.example.com")
+
+	req := ClientAuthnRequest{
+		ClientID: client.ID,
+	}
+
+	// When.
+	_, err := Client(ctx, req)
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidClient, oauthErr.Code())
+}
+
+func TestGetAuthenticatedClient_WithTLSAuthn_MissingEmailAlternativeName(t *testing.T) {
+	// Given.
+	client := &goidc.Client{
+		ID: "random_client_id",
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			AuthnMethod:                    goidc.ClientAuthnTLS,
+			TLSSubjectAlternativeNameEmail: "client@example.com",
+		},
+	}
+
+	ctx := oidc.NewTestContext(t)
+	require.Nil(t, ctx.SaveClient(client))
+	setClientCertificate(t, ctx, "https://client.example.com/id", "client.example.com")
+
+	req := ClientAuthnRequest{
+		ClientID: client.ID,
+	}
+
+	// When.
+	_, err := Client(ctx, req)
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidClient, oauthErr.Code())
+}
+
+// setClientCertificate informs a self-signed certificate with the URI and DNS
+// subject alternative names in the client certificate header.
+func setClientCertificate(t *testing.T, ctx *oidc.Context, uri, dnsName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	parsedURI, err := url.Parse(uri)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "random_client_id"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{parsedURI},
+		DNSNames:     []string{dnsName},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	ctx.Req.Header.Set(goidc.HeaderClientCertificate, url.QueryEscape(string(certPEM)))
+}
@@ -1,6 +1,7 @@
 package authorize
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
@@ -8,7 +9,17 @@ import (
 
 func HandlerPush(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		if ctx.PARMaxRequestBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, ctx.PARMaxRequestBodyBytes)
+			// The form is parsed here so a body over the limit is reported
+			// instead of silently being treated as empty.
+			if err := r.ParseForm(); err != nil {
+				ctx.WriteError(parseFormError(err))
+				return
+			}
+		}
 
 		req := newPushedAuthorizationRequest(ctx.Request())
 		resp, err := pushAuthorization(ctx, req)
@@ -25,7 +36,7 @@ func HandlerPush(config *oidc.Configuration) http.HandlerFunc {
 
 func Handler(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
+		ctx := oidc.NewRequestContext(config, r, w)
 
 		req := newAuthorizationRequest(ctx.Request())
 
@@ -42,7 +53,7 @@ func Handler(config *oidc.Configuration) http.HandlerFunc {
 
 func HandlerCallback(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
+		ctx := oidc.NewRequestContext(config, r, w)
 
 		callbackID := ctx.Request().PathValue("callback")
 		err := continueAuth(ctx, callbackID)
@@ -57,3 +68,53 @@ func HandlerCallback(config *oidc.Configuration) http.HandlerFunc {
 	}
 
 }
+
+func HandlerDeviceAuthorization(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		req := newDeviceAuthorizationRequest(ctx.Request())
+		resp, err := initDeviceAuthorization(ctx, req)
+		if err != nil {
+			ctx.WriteError(err)
+			return
+		}
+
+		if err := ctx.Write(resp, http.StatusOK); err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
+
+// HandlerDevice handles the verification URI where users approve devices.
+// If the user code is not informed, a page asking for it is displayed.
+func HandlerDevice(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		userCode := ctx.Request().URL.Query().Get("user_code")
+		if userCode == "" {
+			if err := ctx.RenderHTML(deviceVerificationTemplate, nil); err != nil {
+				ctx.WriteError(err)
+			}
+			return
+		}
+
+		err := initDeviceAuth(ctx, userCode)
+		if err != nil {
+			err = ctx.ExecuteAuthorizeErrorPlugin(err)
+		}
+
+		if err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
+
+func parseFormError(err error) oidc.Error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "the request body is too large")
+	}
+	return oidc.NewError(oidc.ErrorCodeInvalidRequest, "could not parse the request body")
+}
@@ -1,8 +1,11 @@
 package authorize
 
 import (
+	"errors"
+	"net/http"
 	"time"
 
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/authn"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/strutil"
@@ -28,6 +31,10 @@ func pushAuthorization(
 		return pushedAuthorizationResponse{}, oauthErr
 	}
 
+	if oauthErr := bindAuthorizationCode(ctx, session); oauthErr != nil {
+		return pushedAuthorizationResponse{}, oauthErr
+	}
+
 	if err := ctx.SaveAuthnSession(session); err != nil {
 		return pushedAuthorizationResponse{}, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
 	}
@@ -37,6 +44,32 @@ func pushAuthorization(
 	}, nil
 }
 
+// bindAuthorizationCode binds the authorization code that will be issued for
+// the session to the DPoP key and to the client certificate presented during PAR.
+func bindAuthorizationCode(ctx *oidc.Context, session *goidc.AuthnSession) oidc.Error {
+	if !ctx.AuthorizationCodeBindingIsEnabled {
+		return nil
+	}
+
+	dpopJWT, ok := ctx.DPoPJWT()
+	if ctx.DPoPIsEnabled && ok {
+		// If dpop_jkt was also informed, it must match the key of the DPoP header.
+		if err := token.ValidateDPoPJWT(ctx, dpopJWT, token.DPoPJWTValidationOptions{
+			JWKThumbprint: session.DPoPJWKThumbprint,
+		}); err != nil {
+			return err
+		}
+		session.DPoPJWKThumbprint = token.DPoPJWKThumbprint(ctx, dpopJWT)
+	}
+
+	clientCert, ok := ctx.ClientCertificate()
+	if ctx.TLSBoundTokensIsEnabled && ok {
+		session.ClientCertificateThumbprint = token.ClientCertificateThumbprint(clientCert)
+	}
+
+	return nil
+}
+
 func initAuth(ctx *oidc.Context, req authorizationRequest) oidc.Error {
 	client, err := client(ctx, req)
 	if err != nil {
@@ -55,6 +88,10 @@ func initAuthNoRedirect(ctx *oidc.Context, client *goidc.Client, req authorizati
 	if err != nil {
 		return err
 	}
+
+	if shouldAuthenticateSilently(ctx, session) {
+		return authenticateSilently(ctx, session)
+	}
 	return authenticate(ctx, session)
 }
 
@@ -91,6 +128,7 @@ func client(
 	if req.ClientID == "" {
 		return nil, oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid client_id")
 	}
+	ctx.AddLogAttrs("client_id", req.ClientID)
 
 	client, err := ctx.Client(req.ClientID)
 	if err != nil {
@@ -102,14 +140,120 @@ func client(
 
 func authenticate(ctx *oidc.Context, session *goidc.AuthnSession) oidc.Error {
 	policy := ctx.Policy(session.PolicyID)
-	switch policy.Authenticate(ctx, session) {
+	switch executePolicy(ctx, policy, session) {
 	case goidc.StatusSuccess:
+		if authTimeIsStale(session) {
+			return reauthenticate(ctx, session)
+		}
+
+		if session.DeviceCode != "" {
+			return finishDeviceFlowSuccessfully(ctx, session)
+		}
 		return finishFlowSuccessfully(ctx, session)
 	case goidc.StatusInProgress:
+		if session.Prompt.Contains(goidc.PromptTypeNone) {
+			// The policy needs to interact with the user, but the client
+			// requested the authentication to happen without interaction.
+			session.Error = goidc.ErrInteractionRequired
+			return finishFlowWithFailure(ctx, session)
+		}
 		return stopFlowInProgress(ctx, session)
 	default:
+		if session.DeviceCode != "" {
+			return finishDeviceFlowWithFailure(ctx, session)
+		}
+		return finishFlowWithFailure(ctx, session)
+	}
+}
+
+// reauthenticate executes the policy once again so the user can authenticate
+// as the last authentication is older than the max age requested by the client.
+// If the user cannot be authenticated again, the flow fails with login_required.
+func reauthenticate(ctx *oidc.Context, session *goidc.AuthnSession) oidc.Error {
+	if session.ReauthenticationIsRequired || session.Prompt.Contains(goidc.PromptTypeNone) {
+		session.Error = goidc.ErrLoginRequired
 		return finishFlowWithFailure(ctx, session)
 	}
+
+	session.ReauthenticationIsRequired = true
+	session.Subject = ""
+	return authenticate(ctx, session)
+}
+
+// executePolicy runs the authentication policy. When the client requests
+// "prompt=none", anything the policy writes to the response is discarded,
+// so no page can be rendered to the user.
+func executePolicy(ctx *oidc.Context, policy goidc.AuthnPolicy, session *goidc.AuthnSession) goidc.AuthnStatus {
+	if !session.Prompt.Contains(goidc.PromptTypeNone) {
+		return policy.Authenticate(ctx, session)
+	}
+
+	resp := ctx.Resp
+	ctx.Resp = discardResponseWriter{header: http.Header{}}
+	defer func() { ctx.Resp = resp }()
+	return policy.Authenticate(ctx, session)
+}
+
+// discardResponseWriter is a response writer that ignores everything written to it.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w discardResponseWriter) WriteHeader(int) {}
+
+func shouldAuthenticateSilently(ctx *oidc.Context, session *goidc.AuthnSession) bool {
+	return ctx.SilentAuthnIsEnabled && session.Prompt == goidc.PromptTypeNone && session.IDTokenHint != ""
+}
+
+// authenticateSilently issues a new authorization response for the user
+// identified by the id_token_hint without any interaction, as long as the
+// user still has an active session.
+// The policy still runs with the subject already set, so it decides which
+// scopes and claims are granted, and the max age is enforced as usual.
+// Since "prompt=none" is requested, a policy that needs to interact with the
+// user makes the flow fail.
+func authenticateSilently(ctx *oidc.Context, session *goidc.AuthnSession) oidc.Error {
+	subject, err := idTokenHintSubject(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	if !ctx.UserSessionFunc(ctx, subject) {
+		return newRedirectionError(oidc.ErrorCodeLoginRequired, "the user must authenticate", session.AuthorizationParameters)
+	}
+
+	session.SetUserID(subject)
+	return authenticate(ctx, session)
+}
+
+// idTokenHintSubject validates the id_token_hint and returns the subject it identifies.
+// The hint must be an ID token previously issued by the server to the client, but it
+// may be expired.
+func idTokenHintSubject(
+	ctx *oidc.Context,
+	session *goidc.AuthnSession,
+) (
+	string,
+	oidc.Error,
+) {
+	var claims jwt.Claims
+	if err := ctx.ParseIDTokenHint(session.IDTokenHint, &claims); err != nil {
+		return "", newRedirectionError(oidc.ErrorCodeInvalidRequest, "invalid id_token_hint", session.AuthorizationParameters)
+	}
+
+	if claims.Issuer != ctx.Host || !claims.Audience.Contains(session.ClientID) || claims.Subject == "" {
+		return "", newRedirectionError(oidc.ErrorCodeInvalidRequest, "invalid id_token_hint", session.AuthorizationParameters)
+	}
+
+	return claims.Subject, nil
 }
 
 func finishFlowWithFailure(
@@ -120,6 +264,17 @@ func finishFlowWithFailure(
 		return newRedirectionError(oidc.ErrorCodeInternalError, err.Error(), session.AuthorizationParameters)
 	}
 
+	for sessionErr, code := range map[error]oidc.ErrorCode{
+		goidc.ErrUnmetAuthenticationRequirements: oidc.ErrorCodeUnmetAuthenticationRequirements,
+		goidc.ErrLoginRequired:                   oidc.ErrorCodeLoginRequired,
+		goidc.ErrConsentRequired:                 oidc.ErrorCodeConsentRequired,
+		goidc.ErrInteractionRequired:             oidc.ErrorCodeInteractionRequired,
+	} {
+		if errors.Is(session.Error, sessionErr) {
+			return newRedirectionError(code, session.Error.Error(), session.AuthorizationParameters)
+		}
+	}
+
 	if session.Error != nil {
 		return newRedirectionError(oidc.ErrorCodeAccessDenied, session.Error.Error(), session.AuthorizationParameters)
 	}
@@ -145,6 +300,21 @@ func finishFlowSuccessfully(ctx *oidc.Context, session *goidc.AuthnSession) oidc
 		return newRedirectionError(oidc.ErrorCodeInternalError, err.Error(), session.AuthorizationParameters)
 	}
 
+	setAuthnContextClaims(session)
+	enforceClaimsPlacement(ctx, session)
+	enforceOfflineAccessConsent(ctx, session)
+	if err := validateEssentialACR(ctx, session); err != nil {
+		return err
+	}
+
+	if err := enforceRequestedClaims(ctx, session); err != nil {
+		return newRedirectionError(oidc.ErrorCodeAccessDenied, err.Error(), session.AuthorizationParameters)
+	}
+
+	if err := validateClaimSources(session); err != nil {
+		return newRedirectionError(oidc.ErrorCodeInternalError, err.Error(), session.AuthorizationParameters)
+	}
+
 	if err := authorizeAuthnSession(ctx, session); err != nil {
 		return newRedirectionError(oidc.ErrorCodeInternalError, err.Error(), session.AuthorizationParameters)
 	}
@@ -174,6 +344,7 @@ func finishFlowSuccessfully(ctx *oidc.Context, session *goidc.AuthnSession) oidc
 	if strutil.ContainsOpenID(session.GrantedScopes) && session.ResponseType.Contains(goidc.ResponseTypeIDToken) {
 		idTokenOptions := token.IDTokenOptions{
 			Subject:                 session.Subject,
+			SessionID:               session.SessionID,
 			AdditionalIDTokenClaims: session.AdditionalIDTokenClaims,
 			AccessToken:             redirectParams.AccessToken,
 			AuthorizationCode:       session.AuthorizationCode,
@@ -208,6 +379,8 @@ func authorizeAuthnSession(
 	}
 	session.AuthorizationCode = code
 	session.ExpiresAtTimestamp = time.Now().Unix() + authorizationCodeLifetimeSecs
+	// The request_uri cannot be used for another authorization.
+	session.RequestURI = ""
 
 	if err := ctx.SaveAuthnSession(session); err != nil {
 		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
@@ -238,19 +411,28 @@ func newImplicitGrantOptions(
 	token.GrantOptions,
 	oidc.Error,
 ) {
-	tokenOptions, err := ctx.TokenOptions(client, session.Scopes)
+	tokenOptions, err := ctx.TokenOptions(client, goidc.GrantImplicit, session.Scopes, session.GrantedResources)
 	if err != nil {
 		return token.GrantOptions{}, newRedirectionError(oidc.ErrorCodeAccessDenied, err.Error(), session.AuthorizationParameters)
 	}
 
 	tokenOptions.AddTokenClaims(session.AdditionalTokenClaims)
-	return token.GrantOptions{
+	grantOptions := token.GrantOptions{
 		GrantType:                goidc.GrantImplicit,
 		GrantedScopes:            session.GrantedScopes,
 		Subject:                  session.Subject,
 		ClientID:                 session.ClientID,
+		SessionID:                session.SessionID,
 		TokenOptions:             tokenOptions,
 		AdditionalIDTokenClaims:  session.AdditionalIDTokenClaims,
 		AdditionalUserInfoClaims: session.AdditionalUserInfoClaims,
-	}, nil
+	}
+	if ctx.AuthorizationDetailsParameterIsEnabled {
+		grantOptions.GrantedAuthorizationDetails = session.GrantedAuthorizationDetails
+	}
+	if ctx.ResourceIndicatorsIsEnabled {
+		grantOptions.GrantedResources = session.GrantedResources
+	}
+
+	return grantOptions, nil
 }
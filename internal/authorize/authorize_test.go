@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/luikyv/go-oidc/internal/authn"
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/token"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -59,259 +61,421 @@ func TestInitAuth_PolicyEndsWithSuccess(t *testing.T) {
 	assert.Contains(t, ctx.Response().Header().Get("Location"), "id_token=", "missing id_token in the redirection")
 }
 
-func TestInitAuth_PolicyEndsWithSuccess_WithJAR(t *testing.T) {
+func TestInitAuth_LocalesAreAvailableToPolicy(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
-	ctx.JARIsEnabled = true
-	ctx.JARSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
-	ctx.JARLifetimeSecs = 60
-	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+	client, _ := ctx.Client(oidc.TestClientID)
+	var uiLocales, claimsLocales []string
+	policy := goidc.NewPolicy(
 		"policy_id",
 		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
-		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
-			return goidc.StatusSuccess
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			uiLocales = s.UILocales()
+			claimsLocales = s.ClaimsLocales()
+			return goidc.StatusInProgress
 		},
-	))
-
-	privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
-	client, _ := ctx.Client(oidc.TestClientID)
-	jwks, _ := json.Marshal(jose.JSONWebKeySet{
-		Keys: []jose.JSONWebKey{privateJWK.Public()},
-	})
-	client.PublicJWKS = jwks
-	require.Nil(t, ctx.SaveClient(client))
-
-	createdAtTimestamp := time.Now().Unix()
-	signer, _ := jose.NewSigner(
-		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
-		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
 	)
-	claims := map[string]any{
-		goidc.ClaimIssuer:   client.ID,
-		goidc.ClaimAudience: ctx.Host,
-		goidc.ClaimIssuedAt: createdAtTimestamp,
-		goidc.ClaimExpiry:   createdAtTimestamp + 10,
-		"client_id":         client.ID,
-		"redirect_uri":      client.RedirectURIS[0],
-		"scope":             client.Scopes,
-		"response_type":     goidc.ResponseTypeCode,
-	}
-	requestObject, _ := jwt.Signed(signer).Claims(claims).Serialize()
+	ctx.Policies = append(ctx.Policies, policy)
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
 		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
-			RequestObject: requestObject,
-			// These duplicated params are required for openid.
-			ResponseType: goidc.ResponseTypeCode,
-			Scopes:       client.Scopes,
+			RedirectURI:   client.RedirectURIS[0],
+			Scopes:        client.Scopes,
+			ResponseType:  goidc.ResponseTypeCode,
+			UILocales:     "pt-BR en",
+			ClaimsLocales: "ja",
 		},
 	})
 
 	// Then.
 	require.Nil(t, err)
-
-	sessions := oidc.AuthnSessions(t, ctx)
-	require.Len(t, sessions, 1, "the should be only one authentication session")
-
-	session := sessions[0]
-	assert.NotEmpty(t, session.AuthorizationCode, "the authorization code should be filled when the policy ends successfully")
-
-	assert.Contains(t, ctx.Response().Header().Get("Location"), fmt.Sprintf("code=%s", session.AuthorizationCode),
-		"missing code in the redirection")
+	assert.Equal(t, []string{"pt-BR", "en"}, uiLocales)
+	assert.Equal(t, []string{"ja"}, claimsLocales)
 }
 
-func TestInitAuth_PolicyEndsWithSuccess_WithJARM(t *testing.T) {
+func TestInitAuth_PolicyEndsWithSuccess_NonceInIDToken(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
-	ctx.JARMIsEnabled = true
-	ctx.JARMLifetimeSecs = 60
-	ctx.DefaultJARMSignatureKeyID = oidc.TestServerPrivateJWK.KeyID
-
 	client, _ := ctx.Client(oidc.TestClientID)
 	policy := goidc.NewPolicy(
 		"policy_id",
 		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
-		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(goidc.ScopeOpenID.ID)
 			return goidc.StatusSuccess
 		},
 	)
 	ctx.Policies = append(ctx.Policies, policy)
 
 	// When.
-	oauthErr := initAuth(ctx, authorizationRequest{
+	err := initAuth(ctx, authorizationRequest{
 		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
 			RedirectURI:  client.RedirectURIS[0],
 			Scopes:       client.Scopes,
-			ResponseType: goidc.ResponseTypeCode,
-			ResponseMode: goidc.ResponseModeJWT,
+			ResponseType: goidc.ResponseTypeCodeAndIDToken,
+			ResponseMode: goidc.ResponseModeFragment,
+			Nonce:        "random_nonce",
 		},
 	})
 
 	// Then.
-	require.Nil(t, oauthErr)
-
-	sessions := oidc.AuthnSessions(t, ctx)
-	assert.Len(t, sessions, 1, "the should be only one authentication session")
-
-	session := sessions[0]
-	assert.NotEmpty(t, session.AuthorizationCode, "the authorization code should be filled when the policy ends successfully")
-
-	redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
 	require.Nil(t, err)
 
-	responseObject := redirectURL.Query().Get("response")
-	require.NotEmpty(t, responseObject)
+	redirectURL, urlErr := url.Parse(ctx.Response().Header().Get("Location"))
+	require.Nil(t, urlErr)
+	params, urlErr := url.ParseQuery(redirectURL.Fragment)
+	require.Nil(t, urlErr)
 
-	claims := oidc.SafeClaims(t, responseObject, oidc.TestServerPrivateJWK)
-	assert.Equal(t, session.AuthorizationCode, claims["code"])
+	claims := oidc.UnsafeClaims(t, params.Get("id_token"), []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, "random_nonce", claims[goidc.ClaimNonce], "the nonce should be present in the ID token")
 }
 
-func TestInitAuth_ShouldNotFindClient(t *testing.T) {
+func TestInitAuth_HybridResponseTypeWithoutNonce(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
-
-	// When.
-	err := initAuth(ctx, authorizationRequest{ClientID: "invalid_client_id"})
-
-	// Then.
-	require.NotNil(t, err)
-	assert.Equal(t, oidc.ErrorCodeInvalidClient, err.Code())
-}
-
-func TestInitAuth_InvalidRedirectURI(t *testing.T) {
-	// Given
-	ctx := oidc.NewTestContext(t)
 	client, _ := ctx.Client(oidc.TestClientID)
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
 		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
-			RedirectURI: "https://invalid.com",
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCodeAndIDToken,
+			ResponseMode: goidc.ResponseModeFragment,
 		},
 	})
 
 	// Then.
-	require.NotNil(t, err, "the redirect URI should not be valid")
-
-	var oauthErr oidc.Error
-	require.ErrorAs(t, err, &oauthErr)
-	assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+	require.Nil(t, err, "the error should be redirected")
+	assert.Contains(t, ctx.Response().Header().Get("Location"), "error="+string(oidc.ErrorCodeInvalidRequest))
+	assert.Empty(t, oidc.AuthnSessions(t, ctx), "no authentication session should be created")
 }
 
-func TestInitAuth_InvalidScope(t *testing.T) {
+func TestInitAuth_PolicyEndsWithSuccess_WithSID(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
+	ctx.SIDClaimIsEnabled = true
+	ctx.IDTokenExpiresInSecs = 60
 	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
-		ClientID: oidc.TestClientID,
+		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
 			RedirectURI:  client.RedirectURIS[0],
-			Scopes:       "invalid_scope",
-			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCodeAndIDToken,
+			ResponseMode: goidc.ResponseModeFragment,
+			Nonce:        "random_nonce",
 		},
 	})
 
 	// Then.
-	assert.Nil(t, err)
-	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidScope)
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1, "the should be only one authentication session")
+	require.NotEmpty(t, sessions[0].SessionID, "the session ID should be generated")
+
+	redirectURL, parseErr := url.Parse(ctx.Response().Header().Get("Location"))
+	require.Nil(t, parseErr)
+	fragment, parseErr := url.ParseQuery(redirectURL.Fragment)
+	require.Nil(t, parseErr)
+
+	claims := oidc.UnsafeClaims(t, fragment.Get("id_token"), []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, sessions[0].SessionID, claims[goidc.ClaimSessionID])
 }
 
-func TestInitAuth_InvalidResponseType(t *testing.T) {
+func TestInitAuth_PolicyEndsWithSuccess_ClaimsPlacement(t *testing.T) {
 	// Given.
-	client := oidc.NewTestClient(t)
-	client.ResponseTypes = []goidc.ResponseType{goidc.ResponseTypeCode}
 	ctx := oidc.NewTestContext(t)
-	require.Nil(t, ctx.SaveClient(client))
+	ctx.ClaimsParameterIsEnabled = true
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			// The policy sets the claims to both the ID token and the userinfo.
+			for _, claim := range []string{goidc.ClaimEmail, goidc.ClaimAddress, "nickname"} {
+				s.SetClaimIDToken(claim, "random_value")
+				s.SetClaimUserInfo(claim, "random_value")
+			}
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
-		ClientID: oidc.TestClientID,
+		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
 			RedirectURI:  client.RedirectURIS[0],
 			Scopes:       client.Scopes,
-			ResponseType: goidc.ResponseTypeIDToken,
+			ResponseType: goidc.ResponseTypeCode,
+			Claims: &goidc.ClaimsObject{
+				IDToken: map[string]goidc.ClaimObjectInfo{
+					goidc.ClaimEmail: {},
+					"nickname":       {},
+				},
+				UserInfo: map[string]goidc.ClaimObjectInfo{
+					goidc.ClaimAddress: {},
+					"nickname":         {},
+				},
+			},
 		},
 	})
 
 	// Then.
-	assert.Nil(t, err)
-	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidRequest)
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1, "the should be only one authentication session")
+	session := sessions[0]
+
+	assert.Contains(t, session.AdditionalIDTokenClaims, goidc.ClaimEmail)
+	assert.NotContains(t, session.AdditionalIDTokenClaims, goidc.ClaimAddress,
+		"a claim requested only for the userinfo should not be in the ID token")
+	assert.Contains(t, session.AdditionalIDTokenClaims, "nickname")
+
+	assert.Contains(t, session.AdditionalUserInfoClaims, goidc.ClaimAddress)
+	assert.NotContains(t, session.AdditionalUserInfoClaims, goidc.ClaimEmail,
+		"a claim requested only for the ID token should not be in the userinfo")
+	assert.Contains(t, session.AdditionalUserInfoClaims, "nickname")
 }
 
-func TestInitAuth_WhenNoPolicyIsAvailable(t *testing.T) {
+func TestInitAuth_PolicyEndsWithSuccess_MissingEssentialClaim(t *testing.T) {
+	testCases := []struct {
+		policy           goidc.EssentialClaimsPolicy
+		shouldBeRejected bool
+	}{
+		{goidc.EssentialClaimsPolicyError, true},
+		{goidc.EssentialClaimsPolicyBestEffort, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.policy), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.ClaimsParameterIsEnabled = true
+			ctx.EssentialClaimsPolicy = testCase.policy
+			client, _ := ctx.Client(oidc.TestClientID)
+			policy := goidc.NewPolicy(
+				"policy_id",
+				func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+				func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+					s.GrantScopes(goidc.ScopeOpenID.ID)
+					return goidc.StatusSuccess
+				},
+			)
+			ctx.Policies = append(ctx.Policies, policy)
+
+			// When.
+			err := initAuth(ctx, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					Scopes:       client.Scopes,
+					ResponseType: goidc.ResponseTypeCode,
+					ResponseMode: goidc.ResponseModeQuery,
+					Claims: &goidc.ClaimsObject{
+						IDToken: map[string]goidc.ClaimObjectInfo{
+							goidc.ClaimAuthenticationContextReference: {IsEssential: true},
+						},
+					},
+				},
+			})
+
+			// Then.
+			require.Nil(t, err)
+			location := ctx.Response().Header().Get("Location")
+			if testCase.shouldBeRejected {
+				assert.Contains(t, location, oidc.ErrorCodeAccessDenied)
+			} else {
+				assert.Contains(t, location, "code=")
+			}
+		})
+	}
+}
+
+func TestInitAuth_PolicyEndsWithSuccess_ClaimValueNotAllowed(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
+	ctx.ClaimsParameterIsEnabled = true
 	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			s.SetACRClaimIDToken("urn:acr:low")
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
-		ClientID: oidc.TestClientID,
+		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
 			RedirectURI:  client.RedirectURIS[0],
 			Scopes:       client.Scopes,
 			ResponseType: goidc.ResponseTypeCode,
+			Claims: &goidc.ClaimsObject{
+				IDToken: map[string]goidc.ClaimObjectInfo{
+					goidc.ClaimAuthenticationContextReference: {Values: []string{"urn:acr:high"}},
+				},
+			},
 		},
 	})
 
 	// Then.
-	assert.Nil(t, err)
-	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidRequest, "no policy should be available")
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.NotContains(t, sessions[0].AdditionalIDTokenClaims, goidc.ClaimAuthenticationContextReference,
+		"a claim not matching the values requested should not be returned")
 }
 
-func TestInitAuth_ShouldEndWithError(t *testing.T) {
+func TestInitAuth_PolicyEndsWithSuccess_AuthnContext(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
 	client, _ := ctx.Client(oidc.TestClientID)
+	client.DefaultACRValues = string(goidc.ACRMaceIncommonIAPSilver)
+	require.Nil(t, ctx.SaveClient(client))
+
+	var requestedACRValues string
 	policy := goidc.NewPolicy(
 		"policy_id",
 		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
-		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
-			return goidc.StatusFailure
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			requestedACRValues = s.ACRValues
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			s.SetAchievedACR(goidc.ACRMaceIncommonIAPSilver)
+			s.SetAMR(goidc.AMRPassword, goidc.AMROneTimePassoword)
+			return goidc.StatusSuccess
 		},
 	)
 	ctx.Policies = append(ctx.Policies, policy)
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
-		ClientID: oidc.TestClientID,
+		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
 			RedirectURI:  client.RedirectURIS[0],
 			Scopes:       client.Scopes,
 			ResponseType: goidc.ResponseTypeCode,
-			ResponseMode: goidc.ResponseModeQuery,
 		},
 	})
 
 	// Then.
-	assert.Nil(t, err, "the error should be redirected")
-	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeAccessDenied, "no policy should be available")
+	require.Nil(t, err)
+	assert.Equal(t, string(goidc.ACRMaceIncommonIAPSilver), requestedACRValues,
+		"the default acr values of the client should be used")
 
 	sessions := oidc.AuthnSessions(t, ctx)
-	assert.Len(t, sessions, 0, "no authentication session should remain")
+	require.Len(t, sessions, 1)
+	assert.Equal(t, goidc.ACRMaceIncommonIAPSilver, sessions[0].AdditionalIDTokenClaims[goidc.ClaimAuthenticationContextReference])
+	assert.Equal(t, []goidc.AMR{goidc.AMRPassword, goidc.AMROneTimePassoword},
+		sessions[0].AdditionalIDTokenClaims[goidc.ClaimAuthenticationMethodReferences])
 }
 
-func TestInitAuth_ShouldEndInProgress(t *testing.T) {
+func TestInitAuth_PolicyEndsWithSuccess_EssentialACR(t *testing.T) {
+	testCases := []struct {
+		name          string
+		acrMatchFunc  goidc.ACRMatchFunc
+		shouldBeUnmet bool
+	}{
+		{"exact_match", nil, true},
+		{
+			"custom_match",
+			func(requested, achieved goidc.ACR) bool {
+				return requested == goidc.ACRMaceIncommonIAPBronze && achieved == goidc.ACRMaceIncommonIAPSilver
+			},
+			false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.ClaimsParameterIsEnabled = true
+			ctx.ACRMatchFunc = testCase.acrMatchFunc
+			client, _ := ctx.Client(oidc.TestClientID)
+			policy := goidc.NewPolicy(
+				"policy_id",
+				func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+				func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+					s.GrantScopes(goidc.ScopeOpenID.ID)
+					s.SetAchievedACR(goidc.ACRMaceIncommonIAPSilver)
+					return goidc.StatusSuccess
+				},
+			)
+			ctx.Policies = append(ctx.Policies, policy)
+
+			// When.
+			err := initAuth(ctx, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					Scopes:       client.Scopes,
+					ResponseType: goidc.ResponseTypeCode,
+					ResponseMode: goidc.ResponseModeQuery,
+					Claims: &goidc.ClaimsObject{
+						IDToken: map[string]goidc.ClaimObjectInfo{
+							goidc.ClaimAuthenticationContextReference: {
+								IsEssential: true,
+								Value:       string(goidc.ACRMaceIncommonIAPBronze),
+							},
+						},
+					},
+				},
+			})
+
+			// Then.
+			require.Nil(t, err)
+			location := ctx.Response().Header().Get("Location")
+			if testCase.shouldBeUnmet {
+				assert.Contains(t, location, oidc.ErrorCodeUnmetAuthenticationRequirements)
+			} else {
+				assert.Contains(t, location, "code=")
+			}
+		})
+	}
+}
+
+func TestInitAuth_PolicyEndsWithUnmetAuthenticationRequirements(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
 	client, _ := ctx.Client(oidc.TestClientID)
 	policy := goidc.NewPolicy(
 		"policy_id",
 		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
-		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
-			return goidc.StatusInProgress
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.Error = goidc.ErrUnmetAuthenticationRequirements
+			return goidc.StatusFailure
 		},
 	)
 	ctx.Policies = append(ctx.Policies, policy)
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
-		ClientID: oidc.TestClientID,
+		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
 			RedirectURI:  client.RedirectURIS[0],
 			Scopes:       client.Scopes,
@@ -322,41 +486,19 @@ func TestInitAuth_ShouldEndInProgress(t *testing.T) {
 
 	// Then.
 	require.Nil(t, err)
-	assert.Equal(t, http.StatusOK, ctx.Response().(*httptest.ResponseRecorder).Result().StatusCode,
-		"invalid status code for in progress status")
-
-	sessions := oidc.AuthnSessions(t, ctx)
-	assert.Len(t, sessions, 1, "there should be only one authentication session")
-
-	session := sessions[0]
-	assert.NotEmpty(t, session.CallbackID, "the callback ID was not filled")
-	assert.Empty(t, session.AuthorizationCode, "the authorization code cannot be generated if the flow is still in progress")
+	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeUnmetAuthenticationRequirements)
 }
 
-func TestInitAuth_WithPAR(t *testing.T) {
+func TestInitAuth_PolicyEndsWithSuccess_DistributedClaimWithoutEndpoint(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
 	client, _ := ctx.Client(oidc.TestClientID)
-	ctx.PARIsEnabled = true
-
-	requestURI := "urn:goidc:random_value"
-	require.Nil(t, ctx.SaveAuthnSession(
-		&goidc.AuthnSession{
-			ID: uuid.NewString(),
-			AuthorizationParameters: goidc.AuthorizationParameters{
-				RequestURI:   requestURI,
-				Scopes:       client.Scopes,
-				RedirectURI:  client.RedirectURIS[0],
-				ResponseType: goidc.ResponseTypeCode,
-			},
-			ClientID:           client.ID,
-			ExpiresAtTimestamp: time.Now().Unix() + 60,
-		},
-	))
 	policy := goidc.NewPolicy(
 		"policy_id",
 		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
-		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			s.SetDistributedClaimUserInfo("src1", "", "random_token", goidc.ClaimAddress)
 			return goidc.StatusSuccess
 		},
 	)
@@ -364,32 +506,1155 @@ func TestInitAuth_WithPAR(t *testing.T) {
 
 	// When.
 	err := initAuth(ctx, authorizationRequest{
-		ClientID: oidc.TestClientID,
+		ClientID: client.ID,
 		AuthorizationParameters: goidc.AuthorizationParameters{
-			RequestURI:   requestURI,
-			ResponseType: goidc.ResponseTypeCode,
+			RedirectURI:  client.RedirectURIS[0],
 			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
 		},
 	})
 
 	// Then.
-	require.Nil(t, err)
-
-	sessions := oidc.AuthnSessions(t, ctx)
-	assert.Len(t, sessions, 1, "the should be only one authentication session")
-
-	session := sessions[0]
-	assert.NotEmpty(t, session.AuthorizationCode, "the authorization code should be filled when the policy ends successfully")
-
-	assert.Contains(t, ctx.Response().Header().Get("Location"), fmt.Sprintf("code=%s", session.AuthorizationCode),
-		"missing code in the redirection")
+	assert.Nil(t, err, "the error should be redirected")
+	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInternalError)
 }
 
-func TestContinueAuthentication(t *testing.T) {
-
+func TestInitAuth_PolicyEndsWithSuccess_WithJAR(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
-	policy := goidc.NewPolicy(
+	ctx.JARIsEnabled = true
+	ctx.JARSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+	ctx.JARLifetimeSecs = 60
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	))
+
+	privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
+	client, _ := ctx.Client(oidc.TestClientID)
+	jwks, _ := json.Marshal(jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{privateJWK.Public()},
+	})
+	client.PublicJWKS = jwks
+	require.Nil(t, ctx.SaveClient(client))
+
+	createdAtTimestamp := time.Now().Unix()
+	signer, _ := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
+	)
+	claims := map[string]any{
+		goidc.ClaimIssuer:   client.ID,
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: createdAtTimestamp,
+		goidc.ClaimExpiry:   createdAtTimestamp + 10,
+		"client_id":         client.ID,
+		"redirect_uri":      client.RedirectURIS[0],
+		"scope":             client.Scopes,
+		"response_type":     goidc.ResponseTypeCode,
+	}
+	requestObject, _ := jwt.Signed(signer).Claims(claims).Serialize()
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestObject: requestObject,
+			// These duplicated params are required for openid.
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1, "the should be only one authentication session")
+
+	session := sessions[0]
+	assert.NotEmpty(t, session.AuthorizationCode, "the authorization code should be filled when the policy ends successfully")
+
+	assert.Contains(t, ctx.Response().Header().Get("Location"), fmt.Sprintf("code=%s", session.AuthorizationCode),
+		"missing code in the redirection")
+}
+
+func TestInitAuth_WithJAR_LoginHintIsVisibleToPolicy(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JARIsEnabled = true
+	ctx.JARSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+	ctx.JARLifetimeSecs = 60
+	var loginHint, tenant string
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			loginHint = as.LoginHint()
+			tenant, _ = as.AuthorizationParam("p_tenant")
+			return goidc.StatusInProgress
+		},
+	))
+
+	privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
+	client, _ := ctx.Client(oidc.TestClientID)
+	client.PublicJWKS = oidc.RawJWKS(privateJWK.Public())
+	require.Nil(t, ctx.SaveClient(client))
+
+	createdAtTimestamp := time.Now().Unix()
+	signer, _ := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
+	)
+	requestObject, _ := jwt.Signed(signer).Claims(map[string]any{
+		goidc.ClaimIssuer:   client.ID,
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: createdAtTimestamp,
+		goidc.ClaimExpiry:   createdAtTimestamp + 10,
+		"client_id":         client.ID,
+		"redirect_uri":      client.RedirectURIS[0],
+		"scope":             client.Scopes,
+		"response_type":     goidc.ResponseTypeCode,
+		"login_hint":        "user@example.com",
+		"p_tenant":          "random_tenant",
+	}).Serialize()
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestObject: requestObject,
+			ResponseType:  goidc.ResponseTypeCode,
+			Scopes:        client.Scopes,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, "user@example.com", loginHint)
+	assert.Equal(t, "random_tenant", tenant)
+}
+
+func TestInitAuth_PolicyEndsWithSuccess_WithJARByReference(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JARIsEnabled = true
+	ctx.JARByReferenceIsEnabled = true
+	ctx.JARSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+	ctx.JARLifetimeSecs = 60
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	))
+
+	privateJWK := oidc.PrivateRS256JWK(t, "rsa256_key")
+	client, _ := ctx.Client(oidc.TestClientID)
+	client.PublicJWKS = oidc.RawJWKS(privateJWK.Public())
+
+	createdAtTimestamp := time.Now().Unix()
+	signer, _ := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
+	)
+	requestObject, _ := jwt.Signed(signer).Claims(map[string]any{
+		goidc.ClaimIssuer:   client.ID,
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: createdAtTimestamp,
+		goidc.ClaimExpiry:   createdAtTimestamp + 10,
+		"client_id":         client.ID,
+		"redirect_uri":      client.RedirectURIS[0],
+		"scope":             client.Scopes,
+		"response_type":     goidc.ResponseTypeCode,
+	}).Serialize()
+
+	reqURI := setUpRequestObjectServer(t, requestObject)
+	client.RequestURIS = []string{reqURI}
+	require.Nil(t, ctx.SaveClient(client))
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   reqURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.NotEmpty(t, sessions[0].AuthorizationCode)
+	assert.Empty(t, sessions[0].RequestURI)
+}
+
+func TestInitAuth_PolicyEndsWithSuccess_WithJARM(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JARMIsEnabled = true
+	ctx.JARMLifetimeSecs = 60
+	ctx.DefaultJARMSignatureKeyID = oidc.TestServerPrivateJWK.KeyID
+
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	// When.
+	oauthErr := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeJWT,
+		},
+	})
+
+	// Then.
+	require.Nil(t, oauthErr)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	assert.Len(t, sessions, 1, "the should be only one authentication session")
+
+	session := sessions[0]
+	assert.NotEmpty(t, session.AuthorizationCode, "the authorization code should be filled when the policy ends successfully")
+
+	redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+	require.Nil(t, err)
+
+	responseObject := redirectURL.Query().Get("response")
+	require.NotEmpty(t, responseObject)
+
+	claims := oidc.SafeClaims(t, responseObject, oidc.TestServerPrivateJWK)
+	assert.Equal(t, session.AuthorizationCode, claims["code"])
+}
+
+func TestInitAuth_PolicyEndsWithSuccess_JARMClientWithoutResponseMode(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JARMIsEnabled = true
+	ctx.JARMLifetimeSecs = 60
+	ctx.DefaultJARMSignatureKeyID = oidc.TestServerPrivateJWK.KeyID
+	ctx.JARMSignatureKeyIDs = []string{oidc.TestServerPrivateJWK.KeyID}
+
+	client, _ := ctx.Client(oidc.TestClientID)
+	client.JARMSignatureAlgorithm = jose.SignatureAlgorithm(oidc.TestServerPrivateJWK.Algorithm)
+	require.Nil(t, ctx.SaveClient(client))
+
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	// When.
+	oauthErr := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+
+	// Then.
+	require.Nil(t, oauthErr)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1, "the should be only one authentication session")
+
+	redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+	require.Nil(t, err)
+	assert.Empty(t, redirectURL.Query().Get("code"), "the code should only be sent inside the response object")
+
+	responseObject := redirectURL.Query().Get("response")
+	require.NotEmpty(t, responseObject, "the response should default to query.jwt")
+
+	claims := oidc.SafeClaims(t, responseObject, oidc.TestServerPrivateJWK)
+	assert.Equal(t, sessions[0].AuthorizationCode, claims["code"])
+}
+
+func TestInitAuth_PromptCreate(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.AccountCreationIsEnabled = true
+	ctx.AccountCreationPolicy = goidc.NewPolicy(
+		"account_creation_policy",
+		nil,
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusInProgress
+		},
+	)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			Prompt:       goidc.PromptTypeCreate,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "account_creation_policy", sessions[0].PolicyID)
+	assert.True(t, sessions[0].AccountCreationIsRequested)
+}
+
+func TestInitAuth_InvalidPromptCreate(t *testing.T) {
+	testCases := []struct {
+		name                     string
+		accountCreationIsEnabled bool
+		prompt                   goidc.PromptType
+	}{
+		{"create_not_supported", false, goidc.PromptTypeCreate},
+		{"create_with_none", true, "none create"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.AccountCreationIsEnabled = testCase.accountCreationIsEnabled
+			client, _ := ctx.Client(oidc.TestClientID)
+
+			// When.
+			err := initAuth(ctx, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					Scopes:       client.Scopes,
+					ResponseType: goidc.ResponseTypeCode,
+					ResponseMode: goidc.ResponseModeQuery,
+					Prompt:       testCase.prompt,
+				},
+			})
+
+			// Then.
+			require.Nil(t, err)
+			assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidRequest)
+			assert.Empty(t, oidc.AuthnSessions(t, ctx))
+		})
+	}
+}
+
+func TestInitAuth_PromptNone(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status goidc.AuthnStatus
+		err    error
+		code   oidc.ErrorCode
+	}{
+		{"in_progress", goidc.StatusInProgress, nil, oidc.ErrorCodeInteractionRequired},
+		{"login_required", goidc.StatusFailure, goidc.ErrLoginRequired, oidc.ErrorCodeLoginRequired},
+		{"consent_required", goidc.StatusFailure, goidc.ErrConsentRequired, oidc.ErrorCodeConsentRequired},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			client, _ := ctx.Client(oidc.TestClientID)
+			policy := goidc.NewPolicy(
+				"policy_id",
+				func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+				func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+					_ = ctx.RenderHTML("<html>login page</html>", nil)
+					s.Error = testCase.err
+					return testCase.status
+				},
+			)
+			ctx.Policies = append(ctx.Policies, policy)
+
+			// When.
+			err := initAuth(ctx, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					Scopes:       client.Scopes,
+					ResponseType: goidc.ResponseTypeCode,
+					ResponseMode: goidc.ResponseModeQuery,
+					Prompt:       goidc.PromptTypeNone,
+				},
+			})
+
+			// Then.
+			require.Nil(t, err)
+			resp := ctx.Response().(*httptest.ResponseRecorder)
+			assert.Equal(t, http.StatusSeeOther, resp.Code)
+			assert.Contains(t, resp.Header().Get("Location"), "error="+string(testCase.code))
+			assert.NotContains(t, resp.Body.String(), "login page", "no page should be rendered")
+			assert.Empty(t, oidc.AuthnSessions(t, ctx))
+		})
+	}
+}
+
+func TestInitAuth_MaxAge_FreshAuthentication(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	authTime := time.Now().Unix() - 10
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			s.SetAuthTime(authTime)
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+	maxAge := 60
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:     client.RedirectURIS[0],
+			Scopes:          client.Scopes,
+			ResponseType:    goidc.ResponseTypeCode,
+			ResponseMode:    goidc.ResponseModeQuery,
+			MaxAuthnAgeSecs: &maxAge,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+	assert.Contains(t, ctx.Response().Header().Get("Location"), "code=")
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, int(authTime), sessions[0].AdditionalIDTokenClaims[goidc.ClaimAuthenticationTime],
+		"auth_time must be returned when max_age is requested")
+}
+
+func TestInitAuth_MaxAge_StaleAuthentication(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			if s.ReauthenticationIsRequired {
+				// Display the login page.
+				return goidc.StatusInProgress
+			}
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			s.SetAuthTime(time.Now().Unix() - 120)
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+	maxAge := 60
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:     client.RedirectURIS[0],
+			Scopes:          client.Scopes,
+			ResponseType:    goidc.ResponseTypeCode,
+			ResponseMode:    goidc.ResponseModeQuery,
+			MaxAuthnAgeSecs: &maxAge,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+	assert.Empty(t, ctx.Response().Header().Get("Location"), "the user should be asked to authenticate again")
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].ReauthenticationIsRequired)
+	assert.Empty(t, sessions[0].Subject)
+}
+
+func TestInitAuth_MaxAge_AuthenticationRemainsStale(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(goidc.ScopeOpenID.ID)
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+	maxAge := 60
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:     client.RedirectURIS[0],
+			Scopes:          client.Scopes,
+			ResponseType:    goidc.ResponseTypeCode,
+			ResponseMode:    goidc.ResponseModeQuery,
+			MaxAuthnAgeSecs: &maxAge,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+	assert.Contains(t, ctx.Response().Header().Get("Location"), "error="+string(oidc.ErrorCodeLoginRequired))
+	assert.Empty(t, oidc.AuthnSessions(t, ctx))
+}
+
+func TestInitAuth_ShouldNotFindClient(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{ClientID: "invalid_client_id"})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidClient, err.Code())
+}
+
+func TestInitAuth_InvalidRedirectURI(t *testing.T) {
+	// Given
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI: "https://invalid.com",
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err, "the redirect URI should not be valid")
+
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+}
+
+func TestInitAuth_InvalidScope(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       "invalid_scope",
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+
+	// Then.
+	assert.Nil(t, err)
+	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidScope)
+}
+
+func TestInitAuth_UnknownScopesAreRejected(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       oidc.TestScope1.ID + " unknown_scope",
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+
+	// Then.
+	assert.Nil(t, err)
+	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidScope)
+	assert.Empty(t, oidc.AuthnSessions(t, ctx))
+}
+
+func TestInitAuth_UnknownScopesAreIgnored(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.IgnoreUnknownScopes = true
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.GrantScopes(s.Scopes)
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       oidc.TestScope1.ID + " unknown_scope",
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1, "the should be only one authentication session")
+	assert.Equal(t, oidc.TestScope1.ID, sessions[0].Scopes)
+	assert.Equal(t, oidc.TestScope1.ID, sessions[0].GrantedScopes)
+}
+
+func TestInitAuth_InvalidResponseType(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	client.ResponseTypes = []goidc.ResponseType{goidc.ResponseTypeCode}
+	ctx := oidc.NewTestContext(t)
+	require.Nil(t, ctx.SaveClient(client))
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeIDToken,
+		},
+	})
+
+	// Then.
+	assert.Nil(t, err)
+	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidRequest)
+}
+
+func TestInitAuth_WhenNoPolicyIsAvailable(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+
+	// Then.
+	assert.Nil(t, err)
+	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeInvalidRequest, "no policy should be available")
+}
+
+func TestInitAuth_ShouldEndWithError(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusFailure
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	})
+
+	// Then.
+	assert.Nil(t, err, "the error should be redirected")
+	assert.Contains(t, ctx.Response().Header().Get("Location"), oidc.ErrorCodeAccessDenied, "no policy should be available")
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	assert.Len(t, sessions, 0, "no authentication session should remain")
+}
+
+func TestInitAuth_ShouldEndInProgress(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusInProgress
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, ctx.Response().(*httptest.ResponseRecorder).Result().StatusCode,
+		"invalid status code for in progress status")
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	assert.Len(t, sessions, 1, "there should be only one authentication session")
+
+	session := sessions[0]
+	assert.NotEmpty(t, session.CallbackID, "the callback ID was not filled")
+	assert.Empty(t, session.AuthorizationCode, "the authorization code cannot be generated if the flow is still in progress")
+}
+
+func TestInitAuth_WithPAR(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+
+	requestURI := "urn:goidc:random_value"
+	require.Nil(t, ctx.SaveAuthnSession(
+		&goidc.AuthnSession{
+			ID: uuid.NewString(),
+			AuthorizationParameters: goidc.AuthorizationParameters{
+				RequestURI:   requestURI,
+				Scopes:       client.Scopes,
+				RedirectURI:  client.RedirectURIS[0],
+				ResponseType: goidc.ResponseTypeCode,
+			},
+			ClientID:                     client.ID,
+			ExpiresAtTimestamp:           time.Now().Unix() + 60,
+			RequestURIExpiresAtTimestamp: time.Now().Unix() + 60,
+		},
+	))
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   requestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	assert.Len(t, sessions, 1, "the should be only one authentication session")
+
+	session := sessions[0]
+	assert.NotEmpty(t, session.AuthorizationCode, "the authorization code should be filled when the policy ends successfully")
+
+	assert.Contains(t, ctx.Response().Header().Get("Location"), fmt.Sprintf("code=%s", session.AuthorizationCode),
+		"missing code in the redirection")
+}
+
+func TestInitAuth_ClientRequiresPAR(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.PARIsEnabled = true
+	client, _ := ctx.Client(oidc.TestClientID)
+	parIsRequired := true
+	client.PARIsRequired = &parIsRequired
+	require.Nil(t, ctx.SaveClient(client))
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr, "the request should be rejected since it was not pushed")
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+	assert.Empty(t, oidc.AuthnSessions(t, ctx))
+}
+
+func TestInitAuth_ClientDoesNotRequirePAR(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.PARIsEnabled = true
+	ctx.PARIsRequired = true
+	client, _ := ctx.Client(oidc.TestClientID)
+	parIsRequired := false
+	client.PARIsRequired = &parIsRequired
+	require.Nil(t, ctx.SaveClient(client))
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	))
+
+	// When.
+	err := initAuth(ctx, authorizationRequest{
+		ClientID: oidc.TestClientID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.NotEmpty(t, sessions[0].AuthorizationCode)
+}
+
+func TestInitAuth_WithPARAndPageRefresh(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+	ctx.ParLifetimeSecs = 60
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusInProgress
+		},
+	))
+
+	parResp, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+	require.Nil(t, err)
+
+	req := authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   parResp.RequestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	}
+	require.Nil(t, initAuth(ctx, req))
+
+	// When.
+	err = initAuth(ctx, req)
+
+	// Then.
+	require.Nil(t, err, "refreshing the authorization page should not fail")
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, parResp.RequestURI, sessions[0].RequestURI)
+}
+
+func TestInitAuth_WithPARAlreadyUsed(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+	ctx.ParLifetimeSecs = 60
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	))
+
+	parResp, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+	require.Nil(t, err)
+
+	req := authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   parResp.RequestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	}
+	require.Nil(t, initAuth(ctx, req))
+
+	// When.
+	err = initAuth(ctx, req)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestInitAuth_WithPARExpiredDuringFlow(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+
+	requestURI := "urn:goidc:random_value"
+	require.Nil(t, ctx.SaveAuthnSession(
+		&goidc.AuthnSession{
+			ID: uuid.NewString(),
+			AuthorizationParameters: goidc.AuthorizationParameters{
+				RequestURI:   requestURI,
+				Scopes:       client.Scopes,
+				RedirectURI:  client.RedirectURIS[0],
+				ResponseType: goidc.ResponseTypeCode,
+			},
+			ClientID: client.ID,
+			// The authentication session is still valid, but the request_uri is not.
+			ExpiresAtTimestamp:           time.Now().Unix() + 60,
+			RequestURIExpiresAtTimestamp: time.Now().Unix() - 1,
+		},
+	))
+
+	// When.
+	err := initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   requestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+	assert.Empty(t, oidc.AuthnSessions(t, ctx))
+}
+
+func TestInitAuth_PARIsRequired(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+	ctx.PARIsRequired = true
+
+	// When.
+	err := initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+	assert.Empty(t, oidc.AuthnSessions(t, ctx), "no session should be created")
+}
+
+func TestInitAuth_PARIsRequiredAndRequestWasPushed(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+	ctx.PARIsRequired = true
+	ctx.ParLifetimeSecs = 60
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	parResp, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+	require.Nil(t, err)
+
+	// When.
+	err = initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   parResp.RequestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1, "the should be only one authentication session")
+	assert.NotEmpty(t, sessions[0].AuthorizationCode)
+}
+
+func TestInitAuth_WithPARAndResources(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+	ctx.ParLifetimeSecs = 60
+	ctx.ResourceIndicatorsIsEnabled = true
+	ctx.Resources = []string{"https://resource1.com", "https://resource2.com"}
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	parResp, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			Resources:    goidc.Resources{"https://resource1.com"},
+		},
+	})
+	require.Nil(t, err)
+
+	// When.
+	err = initAuth(ctx, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   parResp.RequestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1, "the should be only one authentication session")
+	assert.Equal(t, goidc.Resources{"https://resource1.com"}, sessions[0].GrantedResources)
+}
+
+func TestInitAuth_WithPARShouldNotBroadenResources(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARIsEnabled = true
+	ctx.ParLifetimeSecs = 60
+	ctx.ResourceIndicatorsIsEnabled = true
+	ctx.Resources = []string{"https://resource1.com", "https://resource2.com"}
+
+	parResp, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			Resources:    goidc.Resources{"https://resource1.com"},
+		},
+	})
+	require.Nil(t, err)
+
+	// When.
+	err = initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI:   parResp.RequestURI,
+			ResponseType: goidc.ResponseTypeCode,
+			Scopes:       client.Scopes,
+			Resources:    goidc.Resources{"https://resource1.com", "https://resource2.com"},
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidTarget, err.Code())
+}
+
+func TestContinueAuthentication(t *testing.T) {
+
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	policy := goidc.NewPolicy(
 		"policy_id",
 		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
 		func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
@@ -442,7 +1707,7 @@ func TestPushAuthorization(t *testing.T) {
 	require.Len(t, sessions, 1, "the should be only one authentication session")
 
 	session := sessions[0]
-	assert.Equal(t, requestURI, session.RequestURI, "the request URI informed is not the same in the session")
+	assert.Equal(t, requestURI.RequestURI, session.RequestURI, "the request URI informed is not the same in the session")
 }
 
 func TestPushAuthorization_WithJAR(t *testing.T) {
@@ -494,7 +1759,107 @@ func TestPushAuthorization_WithJAR(t *testing.T) {
 	require.Len(t, sessions, 1, "the should be only one authentication session")
 
 	session := sessions[0]
-	assert.Equal(t, requestURI, session.RequestURI, "the request URI informed is not the same in the session")
+	assert.Equal(t, requestURI.RequestURI, session.RequestURI, "the request URI informed is not the same in the session")
+}
+
+func TestPushAuthorization_WithAuthorizationDetailValidator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		detail  goidc.AuthorizationDetail
+		isValid bool
+	}{
+		{
+			"valid_detail",
+			goidc.AuthorizationDetail{
+				"type":             "payment_initiation",
+				"instructedAmount": map[string]any{"currency": "EUR", "amount": "123.50"},
+				"creditorAccount":  map[string]any{"iban": "DE02100100109307118603"},
+			},
+			true,
+		},
+		{
+			"missing_required_field",
+			goidc.AuthorizationDetail{
+				"type":             "payment_initiation",
+				"instructedAmount": map[string]any{"currency": "EUR", "amount": "123.50"},
+			},
+			false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := setUpPaymentInitiationDetails(t)
+			client, _ := ctx.Client(oidc.TestClientID)
+
+			// When.
+			_, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+				ClientAuthnRequest: authn.ClientAuthnRequest{
+					ClientID:     oidc.TestClientID,
+					ClientSecret: oidc.TestClientSecret,
+				},
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:          client.RedirectURIS[0],
+					Scopes:               client.Scopes,
+					ResponseType:         goidc.ResponseTypeCode,
+					AuthorizationDetails: []goidc.AuthorizationDetail{testCase.detail},
+				},
+			})
+
+			// Then.
+			if testCase.isValid {
+				require.Nil(t, err)
+				return
+			}
+
+			require.NotNil(t, err)
+			assert.Equal(t, oidc.ErrorCodeInvalidAuthorizationDetails, err.Code())
+			assert.Empty(t, oidc.AuthnSessions(t, ctx))
+		})
+	}
+}
+
+func TestInitAuth_WithAuthorizationDetailValidator(t *testing.T) {
+	// Given.
+	ctx := setUpPaymentInitiationDetails(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	// When.
+	err := initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			AuthorizationDetails: []goidc.AuthorizationDetail{
+				{"type": "payment_initiation"},
+			},
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidAuthorizationDetails, err.Code())
+}
+
+func setUpPaymentInitiationDetails(t *testing.T) *oidc.Context {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.AuthorizationDetailsParameterIsEnabled = true
+	ctx.AuthorizationDetailTypes = []string{"payment_initiation"}
+	ctx.AuthorizationDetailValidators = map[string]goidc.AuthorizationDetailValidationFunc{
+		"payment_initiation": func(_ goidc.Context, detail goidc.AuthorizationDetail) error {
+			for _, field := range []string{"instructedAmount", "creditorAccount"} {
+				if _, ok := detail[field]; !ok {
+					return fmt.Errorf("%s is required", field)
+				}
+			}
+			return nil
+		},
+	}
+	return ctx
 }
 
 func TestPushAuthorization_ShouldRejectUnauthenticatedClient(t *testing.T) {
@@ -517,3 +1882,547 @@ func TestPushAuthorization_ShouldRejectUnauthenticatedClient(t *testing.T) {
 	require.ErrorAs(t, err, &oauthErr)
 	assert.Equal(t, oidc.ErrorCodeInvalidClient, oauthErr.Code())
 }
+
+func TestPushAuthorization_ShouldRejectTooManyAuthorizationDetails(t *testing.T) {
+	// Given.
+	ctx := setUpPaymentInitiationDetails(t)
+	ctx.PARMaxAuthorizationDetails = 1
+	client, _ := ctx.Client(oidc.TestClientID)
+	detail := goidc.AuthorizationDetail{
+		"type":             "payment_initiation",
+		"instructedAmount": map[string]any{"currency": "EUR", "amount": "123.50"},
+		"creditorAccount":  map[string]any{"iban": "DE02100100109307118603"},
+	}
+
+	// When.
+	_, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:          client.RedirectURIS[0],
+			Scopes:               client.Scopes,
+			ResponseType:         goidc.ResponseTypeCode,
+			AuthorizationDetails: []goidc.AuthorizationDetail{detail, detail},
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+	assert.Empty(t, oidc.AuthnSessions(t, ctx))
+}
+
+func TestPushAuthorization_ShouldRejectTooLongScope(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	ctx.PARMaxScopeLength = len(client.Scopes) - 1
+
+	// When.
+	_, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestHandlerPush_ShouldRejectTooLargeBody(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.PARIsEnabled = true
+	ctx.PARMaxRequestBodyBytes = 10
+	config := ctx.Configuration
+
+	body := url.Values{
+		"client_id":     {oidc.TestClientID},
+		"client_secret": {oidc.TestClientSecret},
+		"response_type": {string(goidc.ResponseTypeCode)},
+	}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/par", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerPush(&config)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(oidc.ErrorCodeInvalidRequest))
+	assert.Empty(t, oidc.AuthnSessions(t, ctx))
+}
+
+func TestInitAuth_WithClaimsParameter(t *testing.T) {
+	var cases = []struct {
+		Name                   string
+		ClaimsParamIsEnabled   bool
+		UnsupportedIsRejected  bool
+		ShouldBeValid          bool
+		ShouldKeepClaimsObject bool
+	}{
+		{"claims_parameter_enabled", true, false, true, true},
+		{"claims_parameter_disabled_and_ignored", false, false, true, false},
+		{"claims_parameter_disabled_and_rejected", false, true, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.ClaimsParameterIsEnabled = c.ClaimsParamIsEnabled
+			ctx.UnsupportedClaimsParameterIsRejected = c.UnsupportedIsRejected
+			client, _ := ctx.Client(oidc.TestClientID)
+			policy := goidc.NewPolicy(
+				"policy_id",
+				func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+				func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+					return goidc.StatusInProgress
+				},
+			)
+			ctx.Policies = append(ctx.Policies, policy)
+
+			// When.
+			err := initAuthNoRedirect(ctx, client, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					Scopes:       client.Scopes,
+					ResponseType: goidc.ResponseTypeCode,
+					Claims: &goidc.ClaimsObject{
+						IDToken: map[string]goidc.ClaimObjectInfo{
+							goidc.ClaimEmail: {IsEssential: true},
+						},
+					},
+				},
+			})
+
+			// Then.
+			if !c.ShouldBeValid {
+				require.NotNil(t, err)
+				assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+				return
+			}
+
+			require.Nil(t, err)
+			sessions := oidc.AuthnSessions(t, ctx)
+			require.Len(t, sessions, 1, "the should be only one authentication session")
+			if c.ShouldKeepClaimsObject {
+				assert.NotNil(t, sessions[0].Claims)
+			} else {
+				assert.Nil(t, sessions[0].Claims)
+			}
+		})
+	}
+}
+
+func TestInitAuth_SilentAuthn(t *testing.T) {
+	testCases := []struct {
+		name              string
+		hasUserSession    bool
+		shouldBeSilent    bool
+		expectedErrorCode oidc.ErrorCode
+	}{
+		{"user session found", true, true, ""},
+		{"user session not found", false, false, oidc.ErrorCodeLoginRequired},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.IDTokenExpiresInSecs = 60
+			ctx.SilentAuthnIsEnabled = true
+			ctx.UserSessionFunc = func(ctx goidc.Context, subject string) bool {
+				return testCase.hasUserSession && subject == "random_user"
+			}
+			client, _ := ctx.Client(oidc.TestClientID)
+			// The policy only requires user interaction when the user is unknown.
+			policy := goidc.NewPolicy(
+				"policy_id",
+				func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+				func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+					if s.Subject == "" {
+						return goidc.StatusInProgress
+					}
+					s.GrantScopes(oidc.TestScope1.ID)
+					return goidc.StatusSuccess
+				},
+			)
+			ctx.Policies = append(ctx.Policies, policy)
+
+			idTokenHint, err := token.MakeIDToken(ctx, client, token.IDTokenOptions{Subject: "random_user"})
+			require.Nil(t, err)
+
+			// When.
+			err = initAuthNoRedirect(ctx, client, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					Scopes:       client.Scopes,
+					ResponseType: goidc.ResponseTypeCode,
+					ResponseMode: goidc.ResponseModeQuery,
+					Prompt:       goidc.PromptTypeNone,
+					IDTokenHint:  idTokenHint,
+				},
+			})
+
+			// Then.
+			if !testCase.shouldBeSilent {
+				var redirectErr redirectionError
+				require.ErrorAs(t, err, &redirectErr)
+				assert.Equal(t, testCase.expectedErrorCode, redirectErr.Code())
+				return
+			}
+
+			require.Nil(t, err)
+
+			sessions := oidc.AuthnSessions(t, ctx)
+			require.Len(t, sessions, 1)
+			assert.Equal(t, "random_user", sessions[0].Subject)
+			assert.Equal(t, oidc.TestScope1.ID, sessions[0].GrantedScopes, "only the scopes granted by the policy should be granted")
+			assert.NotEmpty(t, sessions[0].AuthorizationCode)
+			assert.Contains(t, ctx.Response().Header().Get("Location"), "code="+sessions[0].AuthorizationCode)
+		})
+	}
+}
+
+func TestInitAuth_SilentAuthn_SymmetricIDTokenHint(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.IDTokenExpiresInSecs = 60
+	ctx.SilentAuthnIsEnabled = true
+	ctx.IDTokenSymmetricSignatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+	ctx.UserSessionFunc = func(ctx goidc.Context, subject string) bool {
+		return subject == "random_user"
+	}
+	client, _ := ctx.Client(oidc.TestClientID)
+	client.Secret = "random_secret_with_at_least_32_bytes"
+	client.IDTokenSignatureAlgorithm = jose.HS256
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			if s.Subject == "" {
+				return goidc.StatusInProgress
+			}
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	idTokenHint, err := token.MakeIDToken(ctx, client, token.IDTokenOptions{Subject: "random_user"})
+	require.Nil(t, err)
+
+	// When.
+	err = initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+			Prompt:       goidc.PromptTypeNone,
+			IDTokenHint:  idTokenHint,
+		},
+	})
+
+	// Then.
+	require.Nil(t, err)
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "random_user", sessions[0].Subject)
+}
+
+func TestInitAuth_SilentAuthn_PolicyRequiresInteraction(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.IDTokenExpiresInSecs = 60
+	ctx.SilentAuthnIsEnabled = true
+	ctx.UserSessionFunc = func(ctx goidc.Context, subject string) bool {
+		return true
+	}
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusInProgress
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	idTokenHint, err := token.MakeIDToken(ctx, client, token.IDTokenOptions{Subject: "random_user"})
+	require.Nil(t, err)
+
+	// When.
+	err = initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+			Prompt:       goidc.PromptTypeNone,
+			IDTokenHint:  idTokenHint,
+		},
+	})
+
+	// Then.
+	var redirectErr redirectionError
+	require.ErrorAs(t, err, &redirectErr)
+	assert.Equal(t, oidc.ErrorCodeInteractionRequired, redirectErr.Code())
+}
+
+func TestInitAuth_SilentAuthn_MaxAgeExceeded(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.IDTokenExpiresInSecs = 60
+	ctx.SilentAuthnIsEnabled = true
+	ctx.UserSessionFunc = func(ctx goidc.Context, subject string) bool {
+		return true
+	}
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.AuthTime = time.Now().Unix() - 600
+			s.GrantScopes(s.Scopes)
+			return goidc.StatusSuccess
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	idTokenHint, err := token.MakeIDToken(ctx, client, token.IDTokenOptions{Subject: "random_user"})
+	require.Nil(t, err)
+	maxAge := 60
+
+	// When.
+	err = initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:     client.RedirectURIS[0],
+			Scopes:          client.Scopes,
+			ResponseType:    goidc.ResponseTypeCode,
+			ResponseMode:    goidc.ResponseModeQuery,
+			Prompt:          goidc.PromptTypeNone,
+			IDTokenHint:     idTokenHint,
+			MaxAuthnAgeSecs: &maxAge,
+		},
+	})
+
+	// Then.
+	var redirectErr redirectionError
+	require.ErrorAs(t, err, &redirectErr)
+	assert.Equal(t, oidc.ErrorCodeLoginRequired, redirectErr.Code())
+}
+
+func TestInitAuth_SilentAuthn_InvalidIDTokenHint(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.SilentAuthnIsEnabled = true
+	ctx.UserSessionFunc = func(ctx goidc.Context, subject string) bool {
+		return true
+	}
+	client, _ := ctx.Client(oidc.TestClientID)
+	policy := goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusInProgress
+		},
+	)
+	ctx.Policies = append(ctx.Policies, policy)
+
+	// When.
+	err := initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:  client.RedirectURIS[0],
+			Scopes:       client.Scopes,
+			ResponseType: goidc.ResponseTypeCode,
+			ResponseMode: goidc.ResponseModeQuery,
+			Prompt:       goidc.PromptTypeNone,
+			IDTokenHint:  "invalid_id_token",
+		},
+	})
+
+	// Then.
+	var redirectErr redirectionError
+	require.ErrorAs(t, err, &redirectErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, redirectErr.Code())
+}
+
+func TestInitAuth_FAPI2ClientOnOpenIDServer(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.PARIsEnabled = true
+	ctx.PkceIsEnabled = true
+	ctx.CodeChallengeMethods = []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256, goidc.CodeChallengeMethodPlain}
+	client := oidc.NewTestClient(t)
+	client.Profile = goidc.ProfileFAPI2
+	require.Nil(t, ctx.SaveClient(client))
+
+	// When.
+	err := initAuthNoRedirect(ctx, client, authorizationRequest{
+		ClientID: client.ID,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RedirectURI:         client.RedirectURIS[0],
+			Scopes:              client.Scopes,
+			ResponseType:        goidc.ResponseTypeCode,
+			CodeChallenge:       "ZObPYv2iA-CObk06I1Z0q5zWRG7gbGjZEWLX5ZC6rjQ",
+			CodeChallengeMethod: goidc.CodeChallengeMethodSHA256,
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err, "the fapi 2.0 client should be required to use par")
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+	assert.Empty(t, oidc.AuthnSessions(t, ctx), "no session should be created")
+}
+
+func TestInitAuth_FAPI2ClientWithPAR(t *testing.T) {
+	testCases := []struct {
+		name                string
+		codeChallenge       string
+		codeChallengeMethod goidc.CodeChallengeMethod
+		shouldBeValid       bool
+	}{
+		{"s256 code challenge", "ZObPYv2iA-CObk06I1Z0q5zWRG7gbGjZEWLX5ZC6rjQ", goidc.CodeChallengeMethodSHA256, true},
+		{"plain code challenge", "random_code_challenge", goidc.CodeChallengeMethodPlain, false},
+		{"no code challenge", "", "", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.PARIsEnabled = true
+			ctx.ParLifetimeSecs = 60
+			ctx.PkceIsEnabled = true
+			ctx.CodeChallengeMethods = []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256, goidc.CodeChallengeMethodPlain}
+			client := oidc.NewTestClient(t)
+			client.Profile = goidc.ProfileFAPI2
+			require.Nil(t, ctx.SaveClient(client))
+			policy := goidc.NewPolicy(
+				"policy_id",
+				func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+				func(ctx goidc.Context, as *goidc.AuthnSession) goidc.AuthnStatus {
+					return goidc.StatusSuccess
+				},
+			)
+			ctx.Policies = append(ctx.Policies, policy)
+
+			parResp, err := pushAuthorization(ctx, pushedAuthorizationRequest{
+				ClientAuthnRequest: authn.ClientAuthnRequest{
+					ClientID:     oidc.TestClientID,
+					ClientSecret: oidc.TestClientSecret,
+				},
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:         client.RedirectURIS[0],
+					Scopes:              client.Scopes,
+					ResponseType:        goidc.ResponseTypeCode,
+					CodeChallenge:       testCase.codeChallenge,
+					CodeChallengeMethod: testCase.codeChallengeMethod,
+				},
+			})
+			require.Nil(t, err)
+
+			// When.
+			err = initAuthNoRedirect(ctx, client, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RequestURI:   parResp.RequestURI,
+					ResponseType: goidc.ResponseTypeCode,
+					Scopes:       client.Scopes,
+				},
+			})
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+		})
+	}
+}
+
+func TestInitAuth_OfflineAccessConsent(t *testing.T) {
+	testCases := []struct {
+		name                    string
+		promptConsentIsRequired bool
+		prompt                  goidc.PromptType
+		grantOfflineAccess      bool
+		offlineAccessIsGranted  bool
+	}{
+		{"without consent", false, "", false, false},
+		{"with consent", false, "", true, true},
+		{"with consent and without prompt", true, "", true, false},
+		{"with consent and prompt", true, goidc.PromptTypeConsent, true, true},
+		{"with consent and multiple prompts", true, goidc.PromptTypeLogin + " " + goidc.PromptTypeConsent, true, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.Scopes = append(ctx.Scopes, goidc.ScopeOfflineAccess)
+			ctx.OfflineAccessConsentIsRequired = true
+			ctx.OfflineAccessPromptConsentIsRequired = testCase.promptConsentIsRequired
+
+			client, _ := ctx.Client(oidc.TestClientID)
+			client.Scopes += " " + goidc.ScopeOfflineAccess.ID
+			require.Nil(t, ctx.SaveClient(client))
+
+			scopes := goidc.ScopeOpenID.ID + " " + goidc.ScopeOfflineAccess.ID
+			policy := goidc.NewPolicy(
+				"policy_id",
+				func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+				func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+					s.GrantScopes(scopes)
+					if testCase.grantOfflineAccess {
+						s.GrantOfflineAccess()
+					}
+					return goidc.StatusSuccess
+				},
+			)
+			ctx.Policies = append(ctx.Policies, policy)
+
+			// When.
+			err := initAuth(ctx, authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					Scopes:       scopes,
+					ResponseType: goidc.ResponseTypeCode,
+					ResponseMode: goidc.ResponseModeQuery,
+					Prompt:       testCase.prompt,
+				},
+			})
+
+			// Then.
+			require.Nil(t, err)
+
+			sessions := oidc.AuthnSessions(t, ctx)
+			require.Len(t, sessions, 1)
+			if testCase.offlineAccessIsGranted {
+				assert.Equal(t, scopes, sessions[0].GrantedScopes)
+			} else {
+				assert.Equal(t, goidc.ScopeOpenID.ID, sessions[0].GrantedScopes, "offline_access should not be granted without consent")
+			}
+		})
+	}
+}
@@ -1,9 +1,22 @@
 package authorize
 
+import "time"
+
 const (
 	protectedParamPrefix          string = "p_"
 	callbackIDLength              int    = 20
 	requestURILength              int    = 20
 	authorizationCodeLength       int    = 30
 	authorizationCodeLifetimeSecs int64  = 60
+	deviceCodeLength              int    = 40
+	// RFC 8628. "...The user code SHOULD be... limited to a character set
+	// that is easy to type... e.g. "BCDFGHJKLMNPQRSTVWXZ" (base-20)..."
+	userCodeCharset string = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  int    = 8
+	// requestObjectMaxSizeBytes limits the size of request objects fetched by reference.
+	requestObjectMaxSizeBytes int64 = 100 * 1024
+	requestObjectFetchTimeout       = 5 * time.Second
+	parRequestURIPrefix             = "urn:ietf:params:oauth:request_uri:"
+	// requestObjectJWTType is the "typ" header of request objects as defined in RFC 9101.
+	requestObjectJWTType = "oauth-authz-req+jwt"
 )
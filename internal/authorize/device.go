@@ -0,0 +1,242 @@
+package authorize
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// initDeviceAuthorization creates a device session as described in RFC 8628.
+// The device must then poll the token endpoint while the user approves the
+// request at the verification URI.
+func initDeviceAuthorization(
+	ctx *oidc.Context,
+	req deviceAuthorizationRequest,
+) (
+	deviceAuthorizationResponse,
+	oidc.Error,
+) {
+	client, err := authn.Client(ctx, req.ClientAuthnRequest)
+	if err != nil {
+		return deviceAuthorizationResponse{}, err
+	}
+
+	if err := validateDeviceAuthorizationRequest(ctx, req, client); err != nil {
+		return deviceAuthorizationResponse{}, err
+	}
+
+	session, err := newDeviceSession(ctx, req, client)
+	if err != nil {
+		return deviceAuthorizationResponse{}, err
+	}
+
+	if err := ctx.SaveDeviceSession(session); err != nil {
+		return deviceAuthorizationResponse{}, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	userCode := formatUserCode(session.UserCode)
+	verificationURI := ctx.BaseURL() + goidc.EndpointDevice
+	return deviceAuthorizationResponse{
+		DeviceCode:              session.DeviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		ExpiresIn:               ctx.DeviceCodeLifetimeSecs,
+		Interval:                session.PollingIntervalSecs,
+	}, nil
+}
+
+func validateDeviceAuthorizationRequest(
+	ctx *oidc.Context,
+	req deviceAuthorizationRequest,
+	client *goidc.Client,
+) oidc.Error {
+	if !client.IsGrantTypeAllowed(goidc.GrantDeviceCode) {
+		return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "invalid grant type")
+	}
+
+	return validateScopes(ctx, goidc.AuthorizationParameters{Scopes: req.Scopes}, client)
+}
+
+func newDeviceSession(
+	ctx *oidc.Context,
+	req deviceAuthorizationRequest,
+	client *goidc.Client,
+) (
+	*goidc.DeviceSession,
+	oidc.Error,
+) {
+	deviceCode, err := strutil.Random(deviceCodeLength)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	userCode, err := strutil.RandomWithCharset(userCodeLength, userCodeCharset)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	scopes := req.Scopes
+	if ctx.IgnoreUnknownScopes {
+		scopes = client.AllowedScopes(ctx.Scopes, req.Scopes)
+	}
+
+	now := time.Now().Unix()
+	return &goidc.DeviceSession{
+		ID:                  uuid.NewString(),
+		DeviceCode:          deviceCode,
+		UserCode:            userCode,
+		ClientID:            client.ID,
+		Scopes:              scopes,
+		Status:              goidc.DeviceSessionStatusPending,
+		PollingIntervalSecs: ctx.DevicePollingIntervalSecs,
+		CreatedAtTimestamp:  now,
+		ExpiresAtTimestamp:  now + ctx.DeviceCodeLifetimeSecs,
+	}, nil
+}
+
+// initDeviceAuth starts the authentication of the user who wants to approve
+// the device identified by the user code.
+// The authentication follows the same policies as the authorization endpoint.
+func initDeviceAuth(ctx *oidc.Context, userCode string) oidc.Error {
+	deviceSession, err := ctx.DeviceSessionByUserCode(normalizeUserCode(userCode))
+	if err != nil {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid user code")
+	}
+
+	if deviceSession.IsExpired() {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "the user code is expired")
+	}
+
+	if deviceSession.Status != goidc.DeviceSessionStatusPending {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "the user code was already used")
+	}
+
+	client, err := ctx.Client(deviceSession.ClientID)
+	if err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	session := newAuthnSession(goidc.AuthorizationParameters{Scopes: deviceSession.Scopes}, client)
+	session.DeviceCode = deviceSession.DeviceCode
+	if err := initAuthnSessionWithPolicy(ctx, client, session); err != nil {
+		// Errors related to device sessions cannot be redirected.
+		return oidc.NewError(err.Code(), err.Error())
+	}
+
+	return authenticate(ctx, session)
+}
+
+// finishDeviceFlowSuccessfully marks the device session as approved, so the
+// device can exchange its device code for tokens.
+func finishDeviceFlowSuccessfully(ctx *oidc.Context, session *goidc.AuthnSession) oidc.Error {
+	deviceSession, err := deviceSessionToFinish(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	if err := validateClaimSources(session); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	enforceOfflineAccessConsent(ctx, session)
+	deviceSession.Authorize(session)
+	if err := ctx.SaveDeviceSession(deviceSession); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	if err := ctx.RenderHTML(deviceAuthorizedTemplate, nil); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+	return nil
+}
+
+// finishDeviceFlowWithFailure marks the device session as denied, so the
+// device is informed the next time it polls the token endpoint.
+func finishDeviceFlowWithFailure(ctx *oidc.Context, session *goidc.AuthnSession) oidc.Error {
+	deviceSession, err := deviceSessionToFinish(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	deviceSession.Status = goidc.DeviceSessionStatusDenied
+	if err := ctx.SaveDeviceSession(deviceSession); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	if session.Error != nil {
+		return oidc.NewError(oidc.ErrorCodeAccessDenied, session.Error.Error())
+	}
+	return oidc.NewError(oidc.ErrorCodeAccessDenied, "access denied")
+}
+
+// deviceSessionToFinish deletes the authentication session, since it's no
+// longer needed, and returns the device session it was approving.
+func deviceSessionToFinish(
+	ctx *oidc.Context,
+	session *goidc.AuthnSession,
+) (
+	*goidc.DeviceSession,
+	oidc.Error,
+) {
+	if err := ctx.DeleteAuthnSession(session.ID); err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	deviceSession, err := ctx.DeviceSessionByDeviceCode(session.DeviceCode)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid device code")
+	}
+
+	if deviceSession.IsExpired() {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest, "the user code is expired")
+	}
+
+	return deviceSession, nil
+}
+
+// normalizeUserCode removes the characters that are not part of the user code
+// charset, e.g. dashes and spaces, so users can type it more freely.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if !strings.ContainsRune(userCodeCharset, r) {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
+
+// formatUserCode splits the user code in two halves to make it easier to read,
+// e.g. "BCDFGHJK" becomes "BCDF-GHJK".
+func formatUserCode(userCode string) string {
+	half := len(userCode) / 2
+	return userCode[:half] + "-" + userCode[half:]
+}
+
+var deviceVerificationTemplate string = `
+	<!-- This HTML document is displayed at the verification URI when the user code is not informed. -->
+	<html>
+	<body>
+		<form method="get">
+			<label for="user_code">Enter the code displayed on your device</label>
+			<input type="text" id="user_code" name="user_code" autocomplete="off"/>
+			<input type="submit" value="Continue"/>
+		</form>
+	</body>
+	</html>
+`
+
+var deviceAuthorizedTemplate string = `
+	<!-- This HTML document is displayed once the user approves the device. -->
+	<html>
+	<body>
+		<p>The device was authorized. You can return to it now.</p>
+	</body>
+	</html>
+`
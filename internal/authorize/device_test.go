@@ -0,0 +1,254 @@
+package authorize
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitDeviceAuthorization(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+
+	// When.
+	resp, err := initDeviceAuthorization(ctx, deviceAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Scopes: goidc.ScopeOpenID.ID,
+	})
+
+	// Then.
+	require.Nil(t, err)
+	assert.NotEmpty(t, resp.DeviceCode)
+	assert.Regexp(t, "^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$", resp.UserCode)
+	assert.Equal(t, ctx.BaseURL()+goidc.EndpointDevice, resp.VerificationURI)
+	assert.Equal(t, resp.VerificationURI+"?user_code="+resp.UserCode, resp.VerificationURIComplete)
+	assert.Equal(t, ctx.DeviceCodeLifetimeSecs, resp.ExpiresIn)
+	assert.Equal(t, ctx.DevicePollingIntervalSecs, resp.Interval)
+
+	sessions := oidc.DeviceSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, resp.DeviceCode, sessions[0].DeviceCode)
+	assert.Equal(t, goidc.DeviceSessionStatusPending, sessions[0].Status)
+	assert.Equal(t, goidc.ScopeOpenID.ID, sessions[0].Scopes)
+}
+
+func TestInitDeviceAuthorization_GrantTypeNotAllowed(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.DeviceGrantIsEnabled = true
+
+	// When.
+	_, err := initDeviceAuthorization(ctx, deviceAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeUnauthorizedClient, err.Code())
+	assert.Empty(t, oidc.DeviceSessions(t, ctx))
+}
+
+func TestInitDeviceAuthorization_InvalidScope(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+
+	// When.
+	_, err := initDeviceAuthorization(ctx, deviceAuthorizationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Scopes: "invalid_scope",
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidScope, err.Code())
+}
+
+func TestInitDeviceAuth_PolicyEndsWithSuccess(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.SetUserID("random_user_id")
+			s.GrantScopes(s.Scopes)
+			return goidc.StatusSuccess
+		},
+	))
+	deviceSession := newTestDeviceSession(t, ctx)
+
+	// When.
+	err := initDeviceAuth(ctx, "bcdf-ghjk")
+
+	// Then.
+	require.Nil(t, err)
+	assert.Empty(t, oidc.AuthnSessions(t, ctx), "the authentication session should be deleted")
+	assert.Equal(t, goidc.DeviceSessionStatusAuthorized, deviceSession.Status)
+	assert.Equal(t, "random_user_id", deviceSession.Subject)
+	assert.Equal(t, goidc.ScopeOpenID.ID, deviceSession.GrantedScopes)
+}
+
+func TestInitDeviceAuth_PolicyEndsWithFailure(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			s.Error = errors.New("the user denied the device")
+			return goidc.StatusFailure
+		},
+	))
+	deviceSession := newTestDeviceSession(t, ctx)
+
+	// When.
+	err := initDeviceAuth(ctx, "BCDFGHJK")
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeAccessDenied, err.Code())
+	assert.Empty(t, oidc.AuthnSessions(t, ctx))
+	assert.Equal(t, goidc.DeviceSessionStatusDenied, deviceSession.Status)
+}
+
+func TestInitDeviceAuth_PolicyEndsInProgress(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+	ctx.Policies = append(ctx.Policies, goidc.NewPolicy(
+		"policy_id",
+		func(ctx goidc.Context, c *goidc.Client, s *goidc.AuthnSession) bool { return true },
+		func(ctx goidc.Context, s *goidc.AuthnSession) goidc.AuthnStatus {
+			return goidc.StatusInProgress
+		},
+	))
+	deviceSession := newTestDeviceSession(t, ctx)
+
+	// When.
+	err := initDeviceAuth(ctx, "BCDFGHJK")
+
+	// Then.
+	require.Nil(t, err)
+	sessions := oidc.AuthnSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, deviceSession.DeviceCode, sessions[0].DeviceCode)
+	assert.NotEmpty(t, sessions[0].CallbackID)
+	assert.Equal(t, goidc.DeviceSessionStatusPending, deviceSession.Status)
+}
+
+func TestInitDeviceAuth_InvalidUserCode(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+	newTestDeviceSession(t, ctx)
+
+	// When.
+	err := initDeviceAuth(ctx, "invalid_user_code")
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestInitDeviceAuth_ExpiredUserCode(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+	deviceSession := newTestDeviceSession(t, ctx)
+	deviceSession.ExpiresAtTimestamp = time.Now().Unix() - 1
+
+	// When.
+	err := initDeviceAuth(ctx, "BCDFGHJK")
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestHandlerDevice_UserCodeNotInformed(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointDevice, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerDevice(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `name="user_code"`)
+}
+
+func TestHandlerDeviceAuthorization(t *testing.T) {
+	// Given.
+	ctx := setUpDeviceGrant(t)
+	form := url.Values{
+		"client_id":     {oidc.TestClientID},
+		"client_secret": {oidc.TestClientSecret},
+		"scope":         {goidc.ScopeOpenID.ID},
+	}
+	req := httptest.NewRequest(http.MethodPost, goidc.EndpointDeviceAuthorization, nil)
+	req.PostForm = form
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerDeviceAuthorization(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"device_code"`)
+	assert.Len(t, oidc.DeviceSessions(t, ctx), 1)
+}
+
+func setUpDeviceGrant(t *testing.T) *oidc.Context {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.DeviceGrantIsEnabled = true
+	ctx.DeviceCodeLifetimeSecs = 600
+	ctx.DevicePollingIntervalSecs = 5
+	ctx.GrantTypes = append(ctx.GrantTypes, goidc.GrantDeviceCode)
+
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.GrantTypes = append(client.GrantTypes, goidc.GrantDeviceCode)
+	require.Nil(t, ctx.SaveClient(client))
+
+	return ctx
+}
+
+func newTestDeviceSession(t *testing.T, ctx *oidc.Context) *goidc.DeviceSession {
+	t.Helper()
+
+	now := time.Now().Unix()
+	session := &goidc.DeviceSession{
+		ID:                  "random_device_session_id",
+		DeviceCode:          "random_device_code",
+		UserCode:            "BCDFGHJK",
+		ClientID:            oidc.TestClientID,
+		Scopes:              goidc.ScopeOpenID.ID,
+		Status:              goidc.DeviceSessionStatusPending,
+		PollingIntervalSecs: 5,
+		CreatedAtTimestamp:  now,
+		ExpiresAtTimestamp:  now + 600,
+	}
+	require.Nil(t, ctx.SaveDeviceSession(session))
+
+	return session
+}
@@ -1,6 +1,12 @@
 package authorize
 
 import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
@@ -86,6 +92,11 @@ func jarFromSignedRequestObject(
 		return authorizationRequest{}, oidc.NewError(oidc.ErrorCodeInvalidResquestObject, "invalid kid header")
 	}
 
+	if ctx.JARTypeIsRequired && parsedToken.Headers[0].ExtraHeaders["typ"] != requestObjectJWTType {
+		return authorizationRequest{}, oidc.NewError(oidc.ErrorCodeInvalidResquestObject,
+			"invalid typ header. it should be "+requestObjectJWTType)
+	}
+
 	// Verify that the key ID belongs to the client.
 	jwk, oauthErr := client.PublicKey(parsedToken.Headers[0].KeyID)
 	if oauthErr != nil {
@@ -94,10 +105,18 @@ func jarFromSignedRequestObject(
 
 	var claims jwt.Claims
 	var jarReq authorizationRequest
-	if err := parsedToken.Claims(jwk.Key, &claims, &jarReq); err != nil {
+	var rawClaims map[string]any
+	if err := parsedToken.Claims(jwk.Key, &claims, &jarReq, &rawClaims); err != nil {
 		return authorizationRequest{}, oidc.NewError(oidc.ErrorCodeInvalidResquestObject, "could not extract claims")
 	}
 
+	jarReq.protectedParams = make(map[string]any)
+	for param, value := range rawClaims {
+		if strings.HasPrefix(param, protectedParamPrefix) {
+			jarReq.protectedParams[param] = value
+		}
+	}
+
 	// Validate that the "exp" claims is present and it's not too far in the future.
 	if claims.Expiry == nil || int64(time.Until(claims.Expiry.Time()).Seconds()) > ctx.JARLifetimeSecs {
 		return authorizationRequest{}, oidc.NewError(oidc.ErrorCodeInvalidResquestObject, "invalid exp claim")
@@ -106,10 +125,126 @@ func jarFromSignedRequestObject(
 	err = claims.ValidateWithLeeway(jwt.Expected{
 		Issuer:      client.ID,
 		AnyAudience: []string{ctx.Host},
-	}, time.Duration(0))
+	}, ctx.ClockSkewTolerance())
 	if err != nil {
 		return authorizationRequest{}, oidc.NewError(oidc.ErrorCodeInvalidResquestObject, "invalid claims")
 	}
 
 	return jarReq, nil
 }
+
+var requestObjectHTTPClient = &http.Client{Timeout: requestObjectFetchTimeout}
+
+// requestObjectCache keeps the request objects fetched by reference indexed
+// by their request URI.
+var requestObjectCache = struct {
+	mu      sync.Mutex
+	entries map[string]cachedRequestObject
+}{
+	entries: make(map[string]cachedRequestObject),
+}
+
+type cachedRequestObject struct {
+	requestObject      string
+	expiresAtTimestamp int64
+}
+
+// shouldFetchRequestObject returns true if the request_uri points to a request
+// object hosted by the client instead of one created during PAR.
+func shouldFetchRequestObject(ctx *oidc.Context, req goidc.AuthorizationParameters) bool {
+	return ctx.JARIsEnabled && ctx.JARByReferenceIsEnabled && req.RequestURI != "" &&
+		!strings.HasPrefix(req.RequestURI, parRequestURIPrefix)
+}
+
+// requestObjectByReference fetches the request object hosted at the request
+// URI, which must be one of the URIs registered by the client.
+func requestObjectByReference(
+	ctx *oidc.Context,
+	reqURI string,
+	client *goidc.Client,
+) (
+	string,
+	oidc.Error,
+) {
+	if !isRequestURIRegistered(client, reqURI) {
+		return "", oidc.NewError(oidc.ErrorCodeInvalidRequestURI, "the request_uri is not registered for the client")
+	}
+
+	now := time.Now().Unix()
+	if ctx.JARByReferenceCacheLifetimeSecs > 0 {
+		requestObjectCache.mu.Lock()
+		entry, ok := requestObjectCache.entries[reqURI]
+		requestObjectCache.mu.Unlock()
+		if ok && now < entry.expiresAtTimestamp {
+			return entry.requestObject, nil
+		}
+	}
+
+	reqObject, err := fetchRequestObject(reqURI)
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInvalidRequestURI, err.Error())
+	}
+
+	if ctx.JARByReferenceCacheLifetimeSecs > 0 {
+		requestObjectCache.mu.Lock()
+		// Remove the expired entries, so the cache doesn't grow indefinitely.
+		for uri, entry := range requestObjectCache.entries {
+			if now >= entry.expiresAtTimestamp {
+				delete(requestObjectCache.entries, uri)
+			}
+		}
+		requestObjectCache.entries[reqURI] = cachedRequestObject{
+			requestObject:      reqObject,
+			expiresAtTimestamp: now + ctx.JARByReferenceCacheLifetimeSecs,
+		}
+		requestObjectCache.mu.Unlock()
+	}
+
+	return reqObject, nil
+}
+
+// isRequestURIRegistered compares the request URI with the ones registered by
+// the client. The fragment is ignored, since clients may use it to
+// differentiate versions of the request object.
+func isRequestURIRegistered(client *goidc.Client, reqURI string) bool {
+	parsedReqURI, err := url.Parse(reqURI)
+	if err != nil || parsedReqURI.Scheme != "https" {
+		return false
+	}
+	parsedReqURI.Fragment = ""
+
+	for _, registeredURI := range client.RequestURIS {
+		parsedRegisteredURI, err := url.Parse(registeredURI)
+		if err != nil {
+			continue
+		}
+		parsedRegisteredURI.Fragment = ""
+		if parsedRegisteredURI.String() == parsedReqURI.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func fetchRequestObject(reqURI string) (string, error) {
+	resp, err := requestObjectHTTPClient.Get(reqURI)
+	if err != nil {
+		return "", errors.New("could not fetch the request object")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("could not fetch the request object")
+	}
+
+	reqObject, err := io.ReadAll(io.LimitReader(resp.Body, requestObjectMaxSizeBytes+1))
+	if err != nil {
+		return "", errors.New("could not read the request object")
+	}
+
+	if int64(len(reqObject)) > requestObjectMaxSizeBytes {
+		return "", errors.New("the request object is too large")
+	}
+
+	return strings.TrimSpace(string(reqObject)), nil
+}
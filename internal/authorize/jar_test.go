@@ -2,6 +2,8 @@ package authorize
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -68,3 +70,143 @@ func TestExtractJARFromRequestObject_SignedRequestObjectHappyPath(t *testing.T)
 	assert.Equal(t, client.ID, jar.ClientID, "invalid JAR client_id")
 	assert.Equal(t, goidc.ResponseTypeCode, jar.ResponseType, "invalid JAR response_type")
 }
+
+func TestExtractJARFromRequestObject_TypeIsRequired(t *testing.T) {
+	testCases := []struct {
+		typ           string
+		shouldBeValid bool
+	}{
+		{"oauth-authz-req+jwt", true},
+		{"jwt", false},
+		{"", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.typ, func(t *testing.T) {
+			// Given.
+			privateJWK := oidc.PrivateRS256JWK(t, "client_key_id")
+			ctx := oidc.NewTestContext(t)
+			ctx.JARIsEnabled = true
+			ctx.JARTypeIsRequired = true
+			ctx.JARSignatureAlgorithms = []jose.SignatureAlgorithm{jose.SignatureAlgorithm(privateJWK.Algorithm)}
+			ctx.JARLifetimeSecs = 60
+
+			client := &goidc.Client{
+				ID: "random_client_id",
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					PublicJWKS: oidc.RawJWKS(privateJWK.Public()),
+				},
+			}
+
+			opts := (&jose.SignerOptions{}).WithHeader("kid", privateJWK.KeyID)
+			if testCase.typ != "" {
+				opts = opts.WithType(jose.ContentType(testCase.typ))
+			}
+			signer, err := jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+				opts,
+			)
+			require.Nil(t, err)
+
+			now := time.Now().Unix()
+			request, err := jwt.Signed(signer).Claims(map[string]any{
+				goidc.ClaimIssuer:   client.ID,
+				goidc.ClaimAudience: ctx.Host,
+				goidc.ClaimIssuedAt: now,
+				goidc.ClaimExpiry:   now + ctx.JARLifetimeSecs - 1,
+				"client_id":         client.ID,
+				"response_type":     goidc.ResponseTypeCode,
+			}).Serialize()
+			require.Nil(t, err)
+
+			// When.
+			_, oauthErr := JARFromRequestObject(ctx, request, client)
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, oauthErr)
+				return
+			}
+
+			require.NotNil(t, oauthErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidResquestObject, oauthErr.Code())
+		})
+	}
+}
+
+func TestRequestObjectByReference(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JARByReferenceCacheLifetimeSecs = 60
+	reqURI := setUpRequestObjectServer(t, "random_request_object")
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			RequestURIS: []string{reqURI + "#version1"},
+		},
+	}
+
+	// When.
+	reqObject, err := requestObjectByReference(ctx, reqURI+"#version2", client)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, "random_request_object", reqObject)
+
+	requestObjectCache.mu.Lock()
+	defer requestObjectCache.mu.Unlock()
+	assert.Contains(t, requestObjectCache.entries, reqURI+"#version2", "the request object should be cached")
+}
+
+func TestRequestObjectByReference_URINotRegistered(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	reqURI := setUpRequestObjectServer(t, "random_request_object")
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			RequestURIS: []string{"https://client.example.com/request"},
+		},
+	}
+
+	// When.
+	_, err := requestObjectByReference(ctx, reqURI, client)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequestURI, err.Code())
+}
+
+func TestRequestObjectByReference_RequestObjectTooLarge(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	reqURI := setUpRequestObjectServer(t, strings.Repeat("a", int(requestObjectMaxSizeBytes)+1))
+	client := &goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			RequestURIS: []string{reqURI},
+		},
+	}
+
+	// When.
+	_, err := requestObjectByReference(ctx, reqURI, client)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequestURI, err.Code())
+}
+
+// setUpRequestObjectServer hosts the request object in a TLS server and
+// returns the URI where it can be fetched.
+func setUpRequestObjectServer(t *testing.T, reqObject string) string {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/oauth-authz-req+jwt")
+		_, _ = w.Write([]byte(reqObject))
+	}))
+	t.Cleanup(server.Close)
+
+	originalClient := requestObjectHTTPClient
+	requestObjectHTTPClient = server.Client()
+	t.Cleanup(func() { requestObjectHTTPClient = originalClient })
+
+	return server.URL + "/request"
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +16,9 @@ import (
 type authorizationRequest struct {
 	ClientID string `json:"client_id"`
 	goidc.AuthorizationParameters
+	// protectedParams are the custom parameters prefixed with
+	// protectedParamPrefix, either sent directly or inside a request object.
+	protectedParams map[string]any
 }
 
 func newAuthorizationRequest(req *http.Request) authorizationRequest {
@@ -32,8 +36,13 @@ func newAuthorizationRequest(req *http.Request) authorizationRequest {
 			CodeChallenge:       req.URL.Query().Get("code_challenge"),
 			CodeChallengeMethod: goidc.CodeChallengeMethod(req.URL.Query().Get("code_challenge_method")),
 			Prompt:              goidc.PromptType(req.URL.Query().Get("prompt")),
+			IDTokenHint:         req.URL.Query().Get("id_token_hint"),
+			LoginHint:           req.URL.Query().Get("login_hint"),
+			DPoPJWKThumbprint:   req.URL.Query().Get("dpop_jkt"),
 			Display:             goidc.DisplayValue(req.URL.Query().Get("display")),
 			ACRValues:           req.URL.Query().Get("acr_values"),
+			UILocales:           req.URL.Query().Get("ui_locales"),
+			ClaimsLocales:       req.URL.Query().Get("claims_locales"),
 		},
 	}
 
@@ -50,6 +59,10 @@ func newAuthorizationRequest(req *http.Request) authorizationRequest {
 		}
 	}
 
+	if resources := req.URL.Query()["resource"]; len(resources) != 0 {
+		params.Resources = resources
+	}
+
 	authorizationDetails := req.URL.Query().Get("authorization_details")
 	if authorizationDetails != "" {
 		var authorizationDetailsObject []goidc.AuthorizationDetail
@@ -58,6 +71,13 @@ func newAuthorizationRequest(req *http.Request) authorizationRequest {
 		}
 	}
 
+	params.protectedParams = make(map[string]any)
+	for param, values := range req.URL.Query() {
+		if strings.HasPrefix(param, protectedParamPrefix) {
+			params.protectedParams[param] = values[0]
+		}
+	}
+
 	return params
 }
 
@@ -71,6 +91,7 @@ type authorizationResponse struct {
 	State             string
 	Error             oidc.ErrorCode
 	ErrorDescription  string
+	ErrorURI          string
 }
 
 func (rp authorizationResponse) Parameters() map[string]string {
@@ -105,6 +126,9 @@ func (rp authorizationResponse) Parameters() map[string]string {
 	if rp.ErrorDescription != "" {
 		params["error_description"] = rp.ErrorDescription
 	}
+	if rp.ErrorURI != "" {
+		params["error_uri"] = rp.ErrorURI
+	}
 
 	return params
 }
@@ -127,8 +151,13 @@ func newPushedAuthorizationRequest(req *http.Request) pushedAuthorizationRequest
 		CodeChallenge:       req.PostFormValue("code_challenge"),
 		CodeChallengeMethod: goidc.CodeChallengeMethod(req.PostFormValue("code_challenge_method")),
 		Prompt:              goidc.PromptType(req.PostFormValue("prompt")),
+		IDTokenHint:         req.PostFormValue("id_token_hint"),
+		LoginHint:           req.PostFormValue("login_hint"),
+		DPoPJWKThumbprint:   req.PostFormValue("dpop_jkt"),
 		Display:             goidc.DisplayValue(req.PostFormValue("display")),
 		ACRValues:           req.PostFormValue("acr_values"),
+		UILocales:           req.PostFormValue("ui_locales"),
+		ClaimsLocales:       req.PostFormValue("claims_locales"),
 	}
 
 	maxAge, err := strconv.Atoi(req.PostFormValue("max_age"))
@@ -144,6 +173,10 @@ func newPushedAuthorizationRequest(req *http.Request) pushedAuthorizationRequest
 		}
 	}
 
+	if resources := req.PostForm["resource"]; len(resources) != 0 {
+		params.Resources = resources
+	}
+
 	authorizationDetails := req.PostFormValue("authorization_details")
 	if authorizationDetails != "" {
 		var authorizationDetailsObject []goidc.AuthorizationDetail
@@ -175,3 +208,24 @@ func newAuthnSession(authParams goidc.AuthorizationParameters, client *goidc.Cli
 		AdditionalUserInfoClaims: map[string]any{},
 	}
 }
+
+type deviceAuthorizationRequest struct {
+	Scopes string
+	authn.ClientAuthnRequest
+}
+
+func newDeviceAuthorizationRequest(req *http.Request) deviceAuthorizationRequest {
+	return deviceAuthorizationRequest{
+		ClientAuthnRequest: authn.NewClientAuthnRequest(req),
+		Scopes:             req.PostFormValue("scope"),
+	}
+}
+
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
@@ -29,6 +29,8 @@ func TestNewAuthorizationRequest(t *testing.T) {
 	params.Set("prompt", "login")
 	params.Set("display", "page")
 	params.Set("acr_values", "0 1")
+	params.Set("ui_locales", "pt-BR en")
+	params.Set("claims_locales", "ja")
 	params.Set("max_age", "0")
 	params.Set("claims", "{}")
 	params.Set("authorization_details", "[]")
@@ -55,6 +57,8 @@ func TestNewAuthorizationRequest(t *testing.T) {
 	assert.Equal(t, goidc.PromptTypeLogin, authorizationReq.Prompt)
 	assert.Equal(t, goidc.DisplayValuePage, authorizationReq.Display)
 	assert.Equal(t, "0 1", authorizationReq.ACRValues)
+	assert.Equal(t, "pt-BR en", authorizationReq.UILocales)
+	assert.Equal(t, "ja", authorizationReq.ClaimsLocales)
 	require.NotNil(t, authorizationReq.MaxAuthnAgeSecs)
 	assert.Equal(t, 0, *authorizationReq.MaxAuthnAgeSecs)
 	require.NotNil(t, authorizationReq.Claims)
@@ -78,6 +82,8 @@ func TestNewPushedAuthorizationRequest(t *testing.T) {
 	params.Set("prompt", "login")
 	params.Set("display", "page")
 	params.Set("acr_values", "0 1")
+	params.Set("ui_locales", "pt-BR en")
+	params.Set("claims_locales", "ja")
 	params.Set("max_age", "0")
 	params.Set("claims", "{}")
 	params.Set("authorization_details", "[]")
@@ -103,6 +109,8 @@ func TestNewPushedAuthorizationRequest(t *testing.T) {
 	assert.Equal(t, goidc.PromptTypeLogin, pushedAuthReq.Prompt)
 	assert.Equal(t, goidc.DisplayValuePage, pushedAuthReq.Display)
 	assert.Equal(t, "0 1", pushedAuthReq.ACRValues)
+	assert.Equal(t, "pt-BR en", pushedAuthReq.UILocales)
+	assert.Equal(t, "ja", pushedAuthReq.ClaimsLocales)
 	require.NotNil(t, pushedAuthReq.MaxAuthnAgeSecs)
 	assert.Equal(t, 0, *pushedAuthReq.MaxAuthnAgeSecs)
 	require.NotNil(t, pushedAuthReq.Claims)
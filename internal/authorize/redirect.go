@@ -26,6 +26,7 @@ func redirectError(
 	redirectParams := authorizationResponse{
 		Error:            oauthErr.ErrorCode,
 		ErrorDescription: oauthErr.ErrorDescription,
+		ErrorURI:         ctx.ErrorURI(oauthErr.ErrorCode),
 		State:            oauthErr.State,
 	}
 	return redirectResponse(ctx, client, oauthErr.AuthorizationParameters, redirectParams)
@@ -42,7 +43,7 @@ func redirectResponse(
 		redirectParams.Issuer = ctx.Host
 	}
 
-	responseMode := responseMode(params)
+	responseMode := responseMode(params, client)
 	if responseMode.IsJARM() || client.JARMSignatureAlgorithm != "" {
 		responseJWT, err := createJARMResponse(ctx, client, redirectParams)
 		if err != nil {
@@ -71,7 +72,13 @@ func redirectResponse(
 
 // responseMode returns the response mode based on the response type.
 // According to "5. Definitions of Multiple-Valued Response Type Combinations" of https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#Combinations.
-func responseMode(params goidc.AuthorizationParameters) goidc.ResponseMode {
+// If the client requires JARM and no response mode was informed, the JWT
+// version of the default response mode is used.
+func responseMode(params goidc.AuthorizationParameters, client *goidc.Client) goidc.ResponseMode {
+	if params.ResponseMode == "" && client.JARMSignatureAlgorithm != "" {
+		params.ResponseMode = goidc.ResponseModeJWT
+	}
+
 	if params.ResponseMode == "" {
 		if params.ResponseType.IsImplicit() {
 			return goidc.ResponseModeFragment
@@ -102,7 +109,7 @@ func createJARMResponse(
 		return "", err
 	}
 
-	if client.JARMKeyEncryptionAlgorithm != "" {
+	if ctx.JARMEncryptionIsEnabled && client.JARMKeyEncryptionAlgorithm != "" {
 		responseJWT, err = encryptJARMResponse(ctx, responseJWT, client)
 		if err != nil {
 			return "", err
@@ -161,7 +168,14 @@ func encryptJARMResponse(
 		return "", oidc.NewError(oidc.ErrorCodeInvalidRequest, err.Error())
 	}
 
-	encryptedResponseJWT, err := token.EncryptJWT(ctx, responseJWT, jwk, client.JARMContentEncryptionAlgorithm)
+	// If the content encryption algorithm is not informed, A128CBC-HS256 is
+	// used as defined in the JARM specification.
+	contentEncryptionAlgorithm := client.JARMContentEncryptionAlgorithm
+	if contentEncryptionAlgorithm == "" {
+		contentEncryptionAlgorithm = jose.A128CBC_HS256
+	}
+
+	encryptedResponseJWT, err := token.EncryptJWT(ctx, responseJWT, jwk, contentEncryptionAlgorithm)
 	if err != nil {
 		return "", oidc.NewError(oidc.ErrorCodeInvalidRequest, err.Error())
 	}
@@ -201,6 +215,7 @@ var formPostResponseTemplate string = `
 	<html>
 	<body onload="javascript:document.forms[0].submit()">
 		<form id="form" method="post" action="{{ .redirect_uri }}">
+			<input type="hidden" name="iss" value="{{ .iss }}"/>
 			<input type="hidden" name="code" value="{{ .code }}"/>
 			<input type="hidden" name="state" value="{{ .state }}"/>
 			<input type="hidden" name="access_token" value="{{ .access_token }}"/>
@@ -209,6 +224,7 @@ var formPostResponseTemplate string = `
 			<input type="hidden" name="response" value="{{ .response }}"/>
 			<input type="hidden" name="error" value="{{ .error }}"/>
 			<input type="hidden" name="error_description" value="{{ .error_description }}"/>
+			<input type="hidden" name="error_uri" value="{{ .error_uri }}"/>
 		</form>
 	</body>
 
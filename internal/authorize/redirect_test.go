@@ -2,9 +2,17 @@ package authorize
 
 import (
 	"fmt"
+	"html"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"testing"
 
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetURLWithQueryParams(t *testing.T) {
@@ -45,3 +53,293 @@ func TestGetURLWithFragmentParams(t *testing.T) {
 	}
 
 }
+
+func TestResponseMode(t *testing.T) {
+	testCases := []struct {
+		responseType         goidc.ResponseType
+		responseMode         goidc.ResponseMode
+		jarmIsRequired       bool
+		expectedResponseMode goidc.ResponseMode
+	}{
+		{goidc.ResponseTypeCode, "", false, goidc.ResponseModeQuery},
+		{goidc.ResponseTypeCodeAndIDToken, "", false, goidc.ResponseModeFragment},
+		{goidc.ResponseTypeCode, goidc.ResponseModeJWT, false, goidc.ResponseModeQueryJWT},
+		{goidc.ResponseTypeCodeAndIDToken, goidc.ResponseModeJWT, false, goidc.ResponseModeFragmentJWT},
+		{goidc.ResponseTypeCode, "", true, goidc.ResponseModeQueryJWT},
+		{goidc.ResponseTypeCodeAndIDToken, "", true, goidc.ResponseModeFragmentJWT},
+		{goidc.ResponseTypeCode, goidc.ResponseModeFormPostJWT, true, goidc.ResponseModeFormPostJWT},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("case %v", i), func(t *testing.T) {
+			// Given.
+			client := &goidc.Client{}
+			if testCase.jarmIsRequired {
+				client.JARMSignatureAlgorithm = jose.RS256
+			}
+			params := goidc.AuthorizationParameters{
+				ResponseType: testCase.responseType,
+				ResponseMode: testCase.responseMode,
+			}
+
+			// When.
+			mode := responseMode(params, client)
+
+			// Then.
+			assert.Equal(t, testCase.expectedResponseMode, mode)
+		})
+	}
+}
+
+func TestRedirectResponse_JARM(t *testing.T) {
+	testCases := []struct {
+		responseMode goidc.ResponseMode
+		response     func(t *testing.T, ctx *oidc.Context) string
+	}{
+		{
+			goidc.ResponseModeQueryJWT,
+			func(t *testing.T, ctx *oidc.Context) string {
+				redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+				require.Nil(t, err)
+				return redirectURL.Query().Get("response")
+			},
+		},
+		{
+			goidc.ResponseModeFragmentJWT,
+			func(t *testing.T, ctx *oidc.Context) string {
+				redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+				require.Nil(t, err)
+				fragment, err := url.ParseQuery(redirectURL.Fragment)
+				require.Nil(t, err)
+				return fragment.Get("response")
+			},
+		},
+		{
+			goidc.ResponseModeFormPostJWT,
+			func(t *testing.T, ctx *oidc.Context) string {
+				body := ctx.Response().(*httptest.ResponseRecorder).Body.String()
+				matches := regexp.MustCompile(`name="response" value="([^"]+)"`).FindStringSubmatch(body)
+				require.Len(t, matches, 2)
+				return html.UnescapeString(matches[1])
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.responseMode), func(t *testing.T) {
+			// Given.
+			ctx, client := setUpJARM(t)
+
+			// When.
+			err := redirectResponse(ctx, client, goidc.AuthorizationParameters{
+				RedirectURI:  client.RedirectURIS[0],
+				ResponseType: goidc.ResponseTypeCode,
+				ResponseMode: testCase.responseMode,
+			}, authorizationResponse{
+				AuthorizationCode: "random_code",
+				State:             "random_state",
+			})
+
+			// Then.
+			require.Nil(t, err)
+
+			response := testCase.response(t, ctx)
+			require.NotEmpty(t, response)
+			claims := oidc.SafeClaims(t, response, oidc.TestServerPrivateJWK)
+			assert.Equal(t, ctx.Host, claims["iss"])
+			assert.Equal(t, client.ID, claims["aud"])
+			assert.Equal(t, claims["iat"].(float64)+float64(ctx.JARMLifetimeSecs), claims["exp"])
+			assert.Equal(t, "random_code", claims["code"])
+			assert.Equal(t, "random_state", claims["state"])
+		})
+	}
+}
+
+func TestRedirectResponse_EncryptedJARM(t *testing.T) {
+	// Given.
+	ctx, client := setUpJARM(t)
+	ctx.JARMEncryptionIsEnabled = true
+	ctx.JARMKeyEncrytionAlgorithms = []jose.KeyAlgorithm{jose.RSA_OAEP_256}
+	ctx.JARMContentEncryptionAlgorithms = []jose.ContentEncryption{jose.A128CBC_HS256}
+
+	encryptionJWK := oidc.PrivateRS256JWKWithUsage(t, "encryption_key", goidc.KeyUsageEncryption)
+	encryptionJWK.Algorithm = string(jose.RSA_OAEP_256)
+	client.PublicJWKS = oidc.RawJWKS(encryptionJWK.Public())
+	client.JARMKeyEncryptionAlgorithm = jose.RSA_OAEP_256
+
+	// When.
+	oauthErr := redirectResponse(ctx, client, goidc.AuthorizationParameters{
+		RedirectURI:  client.RedirectURIS[0],
+		ResponseType: goidc.ResponseTypeCode,
+		ResponseMode: goidc.ResponseModeQueryJWT,
+	}, authorizationResponse{
+		AuthorizationCode: "random_code",
+	})
+
+	// Then.
+	require.Nil(t, oauthErr)
+
+	redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+	require.Nil(t, err)
+	response := redirectURL.Query().Get("response")
+
+	jwe, err := jose.ParseEncrypted(
+		response,
+		[]jose.KeyAlgorithm{jose.RSA_OAEP_256},
+		[]jose.ContentEncryption{jose.A128CBC_HS256},
+	)
+	require.Nil(t, err)
+	assert.Equal(t, encryptionJWK.KeyID, jwe.Header.KeyID)
+
+	responseJWT, err := jwe.Decrypt(encryptionJWK.Key)
+	require.Nil(t, err)
+
+	claims := oidc.SafeClaims(t, string(responseJWT), oidc.TestServerPrivateJWK)
+	assert.Equal(t, "random_code", claims["code"])
+}
+
+func TestRedirectError_WithErrorURI(t *testing.T) {
+	testCases := []struct {
+		responseMode goidc.ResponseMode
+		errorURI     func(t *testing.T, ctx *oidc.Context) string
+	}{
+		{
+			goidc.ResponseModeQuery,
+			func(t *testing.T, ctx *oidc.Context) string {
+				redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+				require.Nil(t, err)
+				return redirectURL.Query().Get("error_uri")
+			},
+		},
+		{
+			goidc.ResponseModeFormPost,
+			func(t *testing.T, ctx *oidc.Context) string {
+				body := ctx.Response().(*httptest.ResponseRecorder).Body.String()
+				matches := regexp.MustCompile(`name="error_uri" value="([^"]+)"`).FindStringSubmatch(body)
+				require.Len(t, matches, 2)
+				return html.UnescapeString(matches[1])
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.responseMode), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.ErrorURIBase = "https://docs.example.com"
+			ctx.ErrorURIs = map[string]string{
+				string(oidc.ErrorCodeInvalidScope): "/errors#invalid_scope",
+			}
+			client, err := ctx.Client(oidc.TestClientID)
+			require.Nil(t, err)
+
+			params := goidc.AuthorizationParameters{
+				RedirectURI:  client.RedirectURIS[0],
+				ResponseType: goidc.ResponseTypeCode,
+				ResponseMode: testCase.responseMode,
+			}
+
+			// When.
+			oauthErr := redirectError(ctx, newRedirectionError(oidc.ErrorCodeInvalidScope, "invalid scope", params), client)
+
+			// Then.
+			require.Nil(t, oauthErr)
+			assert.Equal(t, "https://docs.example.com/errors#invalid_scope", testCase.errorURI(t, ctx))
+		})
+	}
+}
+
+func TestRedirectResponse_WithIssuer(t *testing.T) {
+	testCases := []struct {
+		responseMode goidc.ResponseMode
+		issuer       func(t *testing.T, ctx *oidc.Context) string
+	}{
+		{
+			goidc.ResponseModeQuery,
+			func(t *testing.T, ctx *oidc.Context) string {
+				redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+				require.Nil(t, err)
+				return redirectURL.Query().Get("iss")
+			},
+		},
+		{
+			goidc.ResponseModeFragment,
+			func(t *testing.T, ctx *oidc.Context) string {
+				redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+				require.Nil(t, err)
+				fragment, err := url.ParseQuery(redirectURL.Fragment)
+				require.Nil(t, err)
+				return fragment.Get("iss")
+			},
+		},
+		{
+			goidc.ResponseModeFormPost,
+			func(t *testing.T, ctx *oidc.Context) string {
+				body := ctx.Response().(*httptest.ResponseRecorder).Body.String()
+				matches := regexp.MustCompile(`name="iss" value="([^"]+)"`).FindStringSubmatch(body)
+				require.Len(t, matches, 2)
+				return html.UnescapeString(matches[1])
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.responseMode), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.IssuerResponseParameterIsEnabled = true
+			client, err := ctx.Client(oidc.TestClientID)
+			require.Nil(t, err)
+
+			// When.
+			oauthErr := redirectResponse(ctx, client, goidc.AuthorizationParameters{
+				RedirectURI:  client.RedirectURIS[0],
+				ResponseType: goidc.ResponseTypeCode,
+				ResponseMode: testCase.responseMode,
+			}, authorizationResponse{
+				AuthorizationCode: "random_code",
+			})
+
+			// Then.
+			require.Nil(t, oauthErr)
+			assert.Equal(t, ctx.Host, testCase.issuer(t, ctx))
+		})
+	}
+}
+
+func TestRedirectError_WithIssuer(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.IssuerResponseParameterIsEnabled = true
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+
+	params := goidc.AuthorizationParameters{
+		RedirectURI:  client.RedirectURIS[0],
+		ResponseType: goidc.ResponseTypeCode,
+	}
+
+	// When.
+	oauthErr := redirectError(ctx, newRedirectionError(oidc.ErrorCodeAccessDenied, "access denied", params), client)
+
+	// Then.
+	require.Nil(t, oauthErr)
+	redirectURL, err := url.Parse(ctx.Response().Header().Get("Location"))
+	require.Nil(t, err)
+	assert.Equal(t, ctx.Host, redirectURL.Query().Get("iss"))
+	assert.Equal(t, string(oidc.ErrorCodeAccessDenied), redirectURL.Query().Get("error"))
+}
+
+func setUpJARM(t *testing.T) (*oidc.Context, *goidc.Client) {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.JARMIsEnabled = true
+	ctx.JARMLifetimeSecs = 60
+	ctx.DefaultJARMSignatureKeyID = oidc.TestServerPrivateJWK.KeyID
+
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+
+	return ctx, client
+}
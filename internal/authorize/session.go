@@ -2,9 +2,11 @@ package authorize
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/strutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -22,6 +24,13 @@ func initAuthnSession(
 	if err != nil {
 		return nil, err
 	}
+	ignoreUnsupportedParams(ctx, session, client)
+	// At this point, the request was validated, so a missing redirect_uri
+	// means the client's default one can be used.
+	if session.RedirectURI == "" {
+		session.RedirectURI, _ = defaultRedirectURI(ctx, client)
+		session.RedirectURIIsDefault = true
+	}
 
 	return session, initAuthnSessionWithPolicy(ctx, client, session)
 }
@@ -35,7 +44,18 @@ func authnSession(
 	oidc.Error,
 ) {
 
-	if shouldInitAuthnSessionWithPAR(ctx, req.AuthorizationParameters) {
+	if shouldFetchRequestObject(ctx, req.AuthorizationParameters) {
+		reqObject, err := requestObjectByReference(ctx, req.RequestURI, client)
+		if err != nil {
+			return nil, err
+		}
+		// From now on, the request is handled as if the request object was
+		// passed by value.
+		req.RequestObject = reqObject
+		req.RequestURI = ""
+	}
+
+	if shouldInitAuthnSessionWithPAR(ctx, req.AuthorizationParameters, client) {
 		return authnSessionWithPAR(ctx, req, client)
 	}
 
@@ -47,9 +67,22 @@ func authnSession(
 	return initValidSimpleAuthnSession(ctx, req, client)
 }
 
-func shouldInitAuthnSessionWithPAR(ctx *oidc.Context, req goidc.AuthorizationParameters) bool {
+func shouldInitAuthnSessionWithPAR(
+	ctx *oidc.Context,
+	req goidc.AuthorizationParameters,
+	client *goidc.Client,
+) bool {
+	// FAPI 2.0 requires PAR.
+	if ctx.ClientProfile(client) == goidc.ProfileFAPI2 {
+		return true
+	}
 	// Note: if PAR is not enabled, we just disconsider the request_uri.
-	return ctx.PARIsRequired || (ctx.PARIsEnabled && req.RequestURI != "")
+	parIsRequired := ctx.PARIsRequired
+	// The client's own requirement takes precedence over the server default.
+	if client.PARIsRequired != nil {
+		parIsRequired = *client.PARIsRequired
+	}
+	return parIsRequired || (ctx.PARIsEnabled && req.RequestURI != "")
 }
 
 func authnSessionWithPAR(
@@ -63,7 +96,7 @@ func authnSessionWithPAR(
 
 	session, err := getSessionCreatedWithPAR(ctx, req)
 	if err != nil {
-		return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid request_uri")
+		return nil, err
 	}
 
 	if err := validateRequestWithPAR(ctx, req, session, client); err != nil {
@@ -75,6 +108,10 @@ func authnSessionWithPAR(
 	}
 
 	session.UpdateParams(req.AuthorizationParameters)
+	session.ProtectedParameters = mergeProtectedParams(session.ProtectedParameters, req.protectedParams)
+	// Keep the request_uri, so the session can be found again if the user
+	// refreshes the authorization page.
+	session.RequestURI = req.RequestURI
 	return session, nil
 }
 
@@ -85,6 +122,7 @@ func getSessionCreatedWithPAR(
 	*goidc.AuthnSession,
 	oidc.Error,
 ) {
+	// When PAR is required, authorization requests that were not pushed are rejected.
 	if req.RequestURI == "" {
 		return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest, "request_uri is required")
 	}
@@ -127,6 +165,7 @@ func authnSessionWithJAR(
 
 	session := newAuthnSession(jar.AuthorizationParameters, client)
 	session.UpdateParams(req.AuthorizationParameters)
+	session.ProtectedParameters = mergeProtectedParams(jar.protectedParams, req.protectedParams)
 	return session, nil
 }
 
@@ -161,7 +200,9 @@ func initValidSimpleAuthnSession(
 	if err := validateRequest(ctx, req, client); err != nil {
 		return nil, err
 	}
-	return newAuthnSession(req.AuthorizationParameters, client), nil
+	session := newAuthnSession(req.AuthorizationParameters, client)
+	session.ProtectedParameters = req.protectedParams
+	return session, nil
 }
 
 func initAuthnSessionWithPolicy(
@@ -169,6 +210,14 @@ func initAuthnSessionWithPolicy(
 	client *goidc.Client,
 	session *goidc.AuthnSession,
 ) oidc.Error {
+	if session.ACRValues == "" {
+		session.ACRValues = client.DefaultACRValues
+	}
+
+	if session.MaxAuthnAgeSecs == nil {
+		session.MaxAuthnAgeSecs = client.DefaultMaxAgeSecs
+	}
+
 	policy, ok := ctx.FindAvailablePolicy(client, session)
 	if !ok {
 		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "no policy available", session.AuthorizationParameters)
@@ -177,14 +226,19 @@ func initAuthnSessionWithPolicy(
 	if session.Nonce != "" {
 		session.SetClaimIDToken(goidc.ClaimNonce, session.Nonce)
 	}
+	session.GrantResources(session.Resources)
+	if ctx.SIDClaimIsEnabled {
+		session.SessionID = uuid.NewString()
+	}
 	session.PolicyID = policy.ID
 	id, err := callbackID()
 	if err != nil {
 		return newRedirectionError(oidc.ErrorCodeInternalError, err.Error(), session.AuthorizationParameters)
 	}
 	session.CallbackID = id
-	// FIXME: To think about:Treating the request_uri as one-time use will cause problems when the user refreshes the page.
-	session.RequestURI = ""
+	// The request_uri is kept during the flow, so refreshing the authorization
+	// page restarts the authentication with the same session. It's only
+	// discarded once the authorization code is issued.
 	session.ExpiresAtTimestamp = time.Now().Unix() + ctx.AuthenticationSessionTimeoutSecs
 	return nil
 }
@@ -207,6 +261,7 @@ func pushedAuthnSession(
 	if oauthErr != nil {
 		return nil, oauthErr
 	}
+	ignoreUnsupportedParams(ctx, session, client)
 
 	reqURI, err := requestURI()
 	if err != nil {
@@ -214,6 +269,7 @@ func pushedAuthnSession(
 	}
 	session.RequestURI = reqURI
 	session.ExpiresAtTimestamp = time.Now().Unix() + ctx.ParLifetimeSecs
+	session.RequestURIExpiresAtTimestamp = session.ExpiresAtTimestamp
 
 	return session, nil
 }
@@ -253,6 +309,7 @@ func pushedAuthnSessionWithJAR(
 	}
 
 	session := newAuthnSession(jar.AuthorizationParameters, client)
+	session.ProtectedParameters = mergeProtectedParams(jar.protectedParams, protectedParams(ctx))
 	return session, nil
 }
 
@@ -271,6 +328,223 @@ func extractJARFromRequest(
 	return JARFromRequestObject(ctx, req.RequestObject, client)
 }
 
+// ignoreUnsupportedParams removes from the session the parameters that the
+// server doesn't support, so they are not taken into account by the policies.
+func ignoreUnsupportedParams(ctx *oidc.Context, session *goidc.AuthnSession, client *goidc.Client) {
+	if ctx.IgnoreUnknownScopes {
+		session.Scopes = client.AllowedScopes(ctx.Scopes, session.Scopes)
+	}
+
+	if !ctx.ClaimsParameterIsEnabled {
+		session.Claims = nil
+	}
+
+	if !ctx.ResourceIndicatorsIsEnabled {
+		session.Resources = nil
+	}
+
+	if !ctx.AuthorizationCodeBindingIsEnabled || !ctx.DPoPIsEnabled {
+		session.DPoPJWKThumbprint = ""
+	}
+}
+
+// enforceClaimsPlacement makes sure a claim requested with the "claims" parameter
+// only in the ID token section is not returned by the userinfo endpoint and vice versa.
+func enforceClaimsPlacement(ctx *oidc.Context, session *goidc.AuthnSession) {
+	if !ctx.ClaimsParameterIsEnabled || session.Claims == nil {
+		return
+	}
+
+	for claim := range session.Claims.IDToken {
+		if _, ok := session.Claims.UserInfoClaim(claim); !ok {
+			delete(session.AdditionalUserInfoClaims, claim)
+		}
+	}
+
+	for claim := range session.Claims.UserInfo {
+		if _, ok := session.Claims.IDTokenClaim(claim); !ok {
+			delete(session.AdditionalIDTokenClaims, claim)
+		}
+	}
+}
+
+// enforceOfflineAccessConsent removes the "offline_access" scope from the
+// scopes granted when the user didn't consent to it as required by the server,
+// so no refresh token is issued.
+func enforceOfflineAccessConsent(ctx *oidc.Context, session *goidc.AuthnSession) {
+	if !strutil.ContainsOfflineAccess(session.GrantedScopes) || isOfflineAccessConsented(ctx, session) {
+		return
+	}
+
+	scopes := slices.DeleteFunc(strutil.SplitWithSpaces(session.GrantedScopes), func(scope string) bool {
+		return scope == goidc.ScopeOfflineAccess.ID
+	})
+	session.GrantedScopes = strings.Join(scopes, " ")
+}
+
+func isOfflineAccessConsented(ctx *oidc.Context, session *goidc.AuthnSession) bool {
+	if ctx.OfflineAccessConsentIsRequired && !session.OfflineAccessIsGranted {
+		return false
+	}
+
+	// The prompt parameter doesn't apply to device authorization requests.
+	if ctx.OfflineAccessPromptConsentIsRequired && session.DeviceCode == "" &&
+		!session.Prompt.Contains(goidc.PromptTypeConsent) {
+		return false
+	}
+
+	return true
+}
+
+// enforceRequestedClaims removes the claims that don't honor the value
+// constraints requested with the "claims" parameter and, depending on the
+// essential claims policy, fails if an essential claim was not provided.
+func enforceRequestedClaims(ctx *oidc.Context, session *goidc.AuthnSession) error {
+	if !ctx.ClaimsParameterIsEnabled || session.Claims == nil {
+		return nil
+	}
+
+	if err := enforceClaimsRequest(ctx, session.Claims.IDToken, session.AdditionalIDTokenClaims); err != nil {
+		return err
+	}
+
+	return enforceClaimsRequest(ctx, session.Claims.UserInfo, session.AdditionalUserInfoClaims)
+}
+
+// authTimeIsStale returns whether the user authenticated longer ago than the
+// max age requested by the client. If the authentication time is unknown, it
+// is considered stale.
+func authTimeIsStale(session *goidc.AuthnSession) bool {
+	if session.MaxAuthnAgeSecs == nil {
+		return false
+	}
+
+	return session.AuthTime == 0 || time.Now().Unix()-session.AuthTime > int64(*session.MaxAuthnAgeSecs)
+}
+
+// setAuthnContextClaims records the authentication context reference and
+// methods achieved during the authentication into the ID token.
+// The authentication time is always returned when the max age is requested.
+func setAuthnContextClaims(session *goidc.AuthnSession) {
+	if session.MaxAuthnAgeSecs != nil && session.AuthTime != 0 {
+		session.SetAuthTimeClaimIDToken(int(session.AuthTime))
+	}
+
+	if session.ACR != "" {
+		session.SetACRClaimIDToken(session.ACR)
+	}
+
+	if len(session.AMRs) != 0 {
+		session.SetAMRClaimIDToken(session.AMRs...)
+	}
+}
+
+// validateEssentialACR makes sure the authentication context reference
+// achieved satisfies the values requested for the "acr" claim when it is
+// essential. Without requested values, the essential claims policy applies.
+func validateEssentialACR(ctx *oidc.Context, session *goidc.AuthnSession) oidc.Error {
+	if !ctx.ClaimsParameterIsEnabled || session.Claims == nil {
+		return nil
+	}
+
+	info, ok := session.Claims.IDTokenClaim(goidc.ClaimAuthenticationContextReference)
+	if !ok || !info.IsEssential || (info.Value == "" && len(info.Values) == 0) {
+		return nil
+	}
+
+	acr, ok := session.AdditionalIDTokenClaims[goidc.ClaimAuthenticationContextReference]
+	if !ok || !acrIsSatisfied(ctx, info, goidc.ACR(fmt.Sprint(acr))) {
+		return newRedirectionError(oidc.ErrorCodeUnmetAuthenticationRequirements,
+			"the essential acr could not be satisfied", session.AuthorizationParameters)
+	}
+
+	return nil
+}
+
+func acrIsSatisfied(ctx *oidc.Context, info goidc.ClaimObjectInfo, achieved goidc.ACR) bool {
+	requested := slices.Clone(info.Values)
+	if info.Value != "" {
+		requested = append(requested, info.Value)
+	}
+
+	if len(requested) == 0 {
+		return achieved != ""
+	}
+
+	for _, acr := range requested {
+		if ctx.MatchACR(goidc.ACR(acr), achieved) {
+			return true
+		}
+	}
+	return false
+}
+
+func claimValueIsAllowed(ctx *oidc.Context, name string, info goidc.ClaimObjectInfo, value any) bool {
+	if name == goidc.ClaimAuthenticationContextReference {
+		return acrIsSatisfied(ctx, info, goidc.ACR(fmt.Sprint(value)))
+	}
+	return info.Allows(value)
+}
+
+func enforceClaimsRequest(
+	ctx *oidc.Context,
+	requestedClaims map[string]goidc.ClaimObjectInfo,
+	claims map[string]any,
+) error {
+	claimNames, _ := claims[goidc.ClaimNames].(map[string]any)
+	for name, info := range requestedClaims {
+		// The subject is always set by the server.
+		if name == goidc.ClaimSubject {
+			continue
+		}
+
+		value, ok := claims[name]
+		if ok && !claimValueIsAllowed(ctx, name, info, value) {
+			delete(claims, name)
+			ok = false
+		}
+
+		// Aggregated and distributed claims are provided by other sources.
+		if _, isExternal := claimNames[name]; isExternal {
+			ok = true
+		}
+
+		if !ok && info.IsEssential && ctx.EssentialClaimsPolicy == goidc.EssentialClaimsPolicyError {
+			return fmt.Errorf("the essential claim %s could not be provided", name)
+		}
+	}
+
+	return nil
+}
+
+// validateClaimSources makes sure every distributed claim source set by the
+// policy informs the endpoint where the claims can be fetched from.
+func validateClaimSources(session *goidc.AuthnSession) error {
+	for _, claims := range []map[string]any{session.AdditionalIDTokenClaims, session.AdditionalUserInfoClaims} {
+		sources, ok := claims[goidc.ClaimSources].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		for sourceID, source := range sources {
+			src, ok := source.(map[string]any)
+			if !ok {
+				return fmt.Errorf("invalid claim source %s", sourceID)
+			}
+
+			if _, ok := src["JWT"]; ok {
+				continue
+			}
+
+			if endpoint, _ := src["endpoint"].(string); endpoint == "" {
+				return fmt.Errorf("the distributed claim source %s must inform an endpoint", sourceID)
+			}
+		}
+	}
+
+	return nil
+}
+
 func protectedParams(ctx *oidc.Context) map[string]any {
 	protectedParams := make(map[string]any)
 	for param, value := range ctx.FormData() {
@@ -282,6 +556,19 @@ func protectedParams(ctx *oidc.Context) map[string]any {
 	return protectedParams
 }
 
+// mergeProtectedParams combines the protected parameters informed inside and
+// outside a request object. The ones informed inside have priority.
+func mergeProtectedParams(insideParams, outsideParams map[string]any) map[string]any {
+	params := make(map[string]any, len(insideParams)+len(outsideParams))
+	for param, value := range outsideParams {
+		params[param] = value
+	}
+	for param, value := range insideParams {
+		params[param] = value
+	}
+	return params
+}
+
 func authorizationCode() (string, error) {
 	return strutil.Random(authorizationCodeLength)
 }
@@ -291,7 +578,7 @@ func requestURI() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("urn:ietf:params:oauth:request_uri:%s", s), nil
+	return parRequestURIPrefix + s, nil
 }
 
 func callbackID() (string, error) {
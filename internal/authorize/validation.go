@@ -1,10 +1,12 @@
 package authorize
 
 import (
+	"fmt"
 	"slices"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/internal/token"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -31,10 +33,15 @@ func validateRequestWithPAR(
 	}
 
 	mergedParams := session.AuthorizationParameters.Merge(req.AuthorizationParameters)
-	if session.IsExpired() {
+	if session.IsExpired() || session.RequestURIIsExpired() {
 		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "the request_uri is expired", mergedParams)
 	}
 
+	// The resources pushed cannot be broadened at the authorization endpoint.
+	if ctx.ResourceIndicatorsIsEnabled && !session.Resources.ContainsAll(req.Resources) {
+		return newRedirectionError(oidc.ErrorCodeInvalidTarget, "the resources cannot differ from the ones pushed", mergedParams)
+	}
+
 	return nil
 }
 
@@ -104,7 +111,11 @@ func validatePushedRequest(
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "request_uri is not allowed during PAR")
 	}
 
-	if ctx.Profile == goidc.ProfileFAPI2 && req.RedirectURI != "" {
+	if err := validatePushedRequestLimits(ctx, req.AuthorizationParameters); err != nil {
+		return err
+	}
+
+	if ctx.ClientProfile(client) == goidc.ProfileFAPI2 && req.RedirectURI != "" {
 		client.AllowRedirectURI(req.RedirectURI)
 	}
 
@@ -113,6 +124,22 @@ func validatePushedRequest(
 
 // -------------------------------------------------- Helper Functions -------------------------------------------------- //
 
+// validatePushedRequestLimits protects the server against oversized pushed
+// authorization requests, since they are stored until the request_uri expires.
+func validatePushedRequestLimits(ctx *oidc.Context, params goidc.AuthorizationParameters) oidc.Error {
+	if ctx.PARMaxScopeLength > 0 && len(params.Scopes) > ctx.PARMaxScopeLength {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest,
+			fmt.Sprintf("scope cannot be longer than %d characters", ctx.PARMaxScopeLength))
+	}
+
+	if ctx.PARMaxAuthorizationDetails > 0 && len(params.AuthorizationDetails) > ctx.PARMaxAuthorizationDetails {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest,
+			fmt.Sprintf("authorization_details cannot have more than %d entries", ctx.PARMaxAuthorizationDetails))
+	}
+
+	return nil
+}
+
 func validateInWithOutParams(
 	ctx *oidc.Context,
 	insideParams goidc.AuthorizationParameters,
@@ -120,7 +147,7 @@ func validateInWithOutParams(
 	client *goidc.Client,
 ) oidc.Error {
 
-	if ctx.Profile == goidc.ProfileFAPI2 && insideParams.RedirectURI != "" {
+	if ctx.ClientProfile(client) == goidc.ProfileFAPI2 && insideParams.RedirectURI != "" {
 		client.AllowRedirectURI(insideParams.RedirectURI)
 	}
 
@@ -131,7 +158,7 @@ func validateInWithOutParams(
 
 	// When the openid scope is not requested, the authorization request becomes a standard OAuth one,
 	// so there's no need to validate these rules below.
-	if ctx.Profile == goidc.ProfileOpenID && strutil.ContainsOpenID(mergedParams.Scopes) {
+	if ctx.ClientProfile(client) == goidc.ProfileOpenID && strutil.ContainsOpenID(mergedParams.Scopes) {
 		if outsideParams.ResponseType == "" {
 			return newRedirectionError(oidc.ErrorCodeInvalidRequest, "invalid response_type", mergedParams)
 		}
@@ -155,7 +182,11 @@ func validateParamsAuthorize(
 ) oidc.Error {
 
 	if params.RedirectURI == "" {
-		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "redirect_uri is required")
+		redirectURI, ok := defaultRedirectURI(ctx, client)
+		if !ok {
+			return oidc.NewError(oidc.ErrorCodeInvalidRequest, "redirect_uri is required")
+		}
+		params.RedirectURI = redirectURI
 	}
 
 	if err := validateParams(ctx, params, client); err != nil {
@@ -191,7 +222,9 @@ func validateParams(
 	client *goidc.Client,
 ) oidc.Error {
 
-	if !client.IsRedirectURIAllowed(params.RedirectURI) {
+	// The redirect_uri can be omitted during PAR and informed later at the
+	// authorization endpoint.
+	if params.RedirectURI != "" && !isRedirectURIAllowed(ctx, client, params.RedirectURI) {
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid redirect_uri")
 	}
 
@@ -219,6 +252,18 @@ func validateParams(
 		return err
 	}
 
+	if params.Claims != nil && !ctx.ClaimsParameterIsEnabled && ctx.UnsupportedClaimsParameterIsRejected {
+		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "the claims parameter is not supported", params)
+	}
+
+	if err := validateResources(ctx, params, client); err != nil {
+		return err
+	}
+
+	if err := validatePrompt(ctx, params); err != nil {
+		return err
+	}
+
 	if params.Display != "" && !slices.Contains(ctx.DisplayValues, params.Display) {
 		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "invalid display value", params)
 	}
@@ -230,16 +275,35 @@ func validateParams(
 	return nil
 }
 
+// defaultRedirectURI returns the redirect URI to be used when the client
+// doesn't inform one. This is only possible when the client has a single
+// redirect URI registered, otherwise the choice would be ambiguous.
+func defaultRedirectURI(ctx *oidc.Context, client *goidc.Client) (string, bool) {
+	if !ctx.RedirectURIIsOptional || len(client.RedirectURIS) != 1 {
+		return "", false
+	}
+	return client.RedirectURIS[0], true
+}
+
 func validateScopes(
 	ctx *oidc.Context,
 	params goidc.AuthorizationParameters,
 	client *goidc.Client,
 ) oidc.Error {
-	if !client.AreScopesAllowed(ctx.Scopes, params.Scopes) {
+	scopes := params.Scopes
+	if ctx.IgnoreUnknownScopes {
+		scopes = client.AllowedScopes(ctx.Scopes, params.Scopes)
+		// The request is still rejected if none of the scopes requested are recognized.
+		if params.Scopes != "" && scopes == "" {
+			return newRedirectionError(oidc.ErrorCodeInvalidScope, "invalid scope", params)
+		}
+	}
+
+	if !client.AreScopesAllowed(ctx.Scopes, scopes) {
 		return newRedirectionError(oidc.ErrorCodeInvalidScope, "invalid scope", params)
 	}
 
-	if params.Scopes != "" && ctx.OpenIDScopeIsRequired && !strutil.ContainsOpenID(params.Scopes) {
+	if scopes != "" && ctx.OpenIDScopeIsRequired && !strutil.ContainsOpenID(scopes) {
 		return newRedirectionError(oidc.ErrorCodeInvalidScope, "scope openid is required", params)
 	}
 
@@ -251,22 +315,43 @@ func validatePKCE(
 	params goidc.AuthorizationParameters,
 	client *goidc.Client,
 ) oidc.Error {
-	if ctx.PkceIsEnabled && client.AuthnMethod == goidc.ClientAuthnNone && params.CodeChallenge == "" {
+	if (ctx.PkceIsEnabled || ctx.PkceIsRequiredForPublicClients) &&
+		client.AuthnMethod == goidc.ClientAuthnNone && params.CodeChallenge == "" {
 		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "pkce is required for public clients", params)
 	}
 
 	if ctx.PkceIsRequired && params.CodeChallenge == "" {
 		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "code_challenge is required", params)
 	}
+
+	if ctx.PkceS256IsRequired && params.CodeChallenge != "" &&
+		params.CodeChallengeMethod != goidc.CodeChallengeMethodSHA256 {
+		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "code_challenge_method must be S256", params)
+	}
+
+	// FAPI 2.0 requires PKCE with the S256 code challenge method.
+	if ctx.ClientProfile(client) == goidc.ProfileFAPI2 {
+		if params.CodeChallenge == "" {
+			return newRedirectionError(oidc.ErrorCodeInvalidRequest, "code_challenge is required", params)
+		}
+
+		if params.CodeChallengeMethod != goidc.CodeChallengeMethodSHA256 {
+			return newRedirectionError(oidc.ErrorCodeInvalidRequest, "code_challenge_method must be S256", params)
+		}
+	}
 	return nil
 }
 
 func validateResponseType(
-	_ *oidc.Context,
+	ctx *oidc.Context,
 	params goidc.AuthorizationParameters,
 	client *goidc.Client,
 ) oidc.Error {
 
+	if params.ResponseType != "" && !slices.Contains(ctx.ResponseTypes, params.ResponseType) {
+		return newRedirectionError(oidc.ErrorCodeUnsupportedResponseType, "response_type not supported", params)
+	}
+
 	if params.ResponseType != "" && !client.IsResponseTypeAllowed(params.ResponseType) {
 		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "invalid response_type", params)
 	}
@@ -287,6 +372,10 @@ func validateResponseMode(
 	params goidc.AuthorizationParameters,
 	client *goidc.Client,
 ) oidc.Error {
+	if params.ResponseMode != "" && !slices.Contains(ctx.ResponseModes, params.ResponseMode) {
+		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "response_mode not supported", params)
+	}
+
 	if !ctx.JARMIsEnabled && params.ResponseMode.IsJARM() {
 		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "invalid response_mode", params)
 	}
@@ -317,6 +406,47 @@ func validateAuthorizationDetails(
 		if !slices.Contains(ctx.AuthorizationDetailTypes, authDetailType) || !client.IsAuthorizationDetailTypeAllowed(authDetailType) {
 			return newRedirectionError(oidc.ErrorCodeInvalidRequest, "invalid authorization detail type", params)
 		}
+
+		validator, ok := ctx.AuthorizationDetailValidators[authDetailType]
+		if !ok {
+			continue
+		}
+
+		if err := validator(ctx, authDetail); err != nil {
+			return newRedirectionError(oidc.ErrorCodeInvalidAuthorizationDetails, err.Error(), params)
+		}
+	}
+
+	return nil
+}
+
+func validateResources(
+	ctx *oidc.Context,
+	params goidc.AuthorizationParameters,
+	client *goidc.Client,
+) oidc.Error {
+	if !ctx.ResourceIndicatorsIsEnabled {
+		return nil
+	}
+
+	if err := token.ValidateResources(ctx, client, params.Resources); err != nil {
+		return newRedirectionError(err.Code(), err.Error(), params)
+	}
+
+	return nil
+}
+
+func validatePrompt(ctx *oidc.Context, params goidc.AuthorizationParameters) oidc.Error {
+	if !params.Prompt.Contains(goidc.PromptTypeCreate) {
+		return nil
+	}
+
+	if !ctx.AccountCreationIsEnabled {
+		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "prompt create is not supported", params)
+	}
+
+	if params.Prompt.Contains(goidc.PromptTypeNone) {
+		return newRedirectionError(oidc.ErrorCodeInvalidRequest, "prompt none cannot be combined with create", params)
 	}
 
 	return nil
@@ -336,3 +466,12 @@ func validateACRValues(
 
 	return nil
 }
+
+// isRedirectURIAllowed compares the redirect URI to the ones registered by the
+// client according to the matching mode configured.
+func isRedirectURIAllowed(ctx *oidc.Context, client *goidc.Client, redirectURI string) bool {
+	if ctx.RedirectURIMatchingMode == goidc.RedirectURIMatchingPrefix {
+		return client.IsRedirectURIPrefixAllowed(redirectURI)
+	}
+	return client.IsRedirectURIAllowed(redirectURI)
+}
@@ -1,6 +1,7 @@
 package authorize
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -167,8 +168,9 @@ func TestValidateAuthorizationRequestWithPAR(t *testing.T) {
 				},
 			},
 			&goidc.AuthnSession{
-				ClientID:           client.ID,
-				ExpiresAtTimestamp: time.Now().Unix() + 10,
+				ClientID:                     client.ID,
+				ExpiresAtTimestamp:           time.Now().Unix() + 10,
+				RequestURIExpiresAtTimestamp: time.Now().Unix() + 10,
 			},
 			func(client *goidc.Client) *goidc.Client {
 				return client
@@ -189,8 +191,9 @@ func TestValidateAuthorizationRequestWithPAR(t *testing.T) {
 				},
 			},
 			&goidc.AuthnSession{
-				ClientID:           client.ID,
-				ExpiresAtTimestamp: time.Now().Unix() + 10,
+				ClientID:                     client.ID,
+				ExpiresAtTimestamp:           time.Now().Unix() + 10,
+				RequestURIExpiresAtTimestamp: time.Now().Unix() + 10,
 				AuthorizationParameters: goidc.AuthorizationParameters{
 					RedirectURI: client.RedirectURIS[0],
 				},
@@ -336,3 +339,266 @@ func TestValidateAuthorizationRequestWithJAR(t *testing.T) {
 		)
 	}
 }
+
+func TestValidateAuthorizationRequest_DisabledResponseType(t *testing.T) {
+	testCases := []struct {
+		responseType  goidc.ResponseType
+		shouldBeValid bool
+	}{
+		{goidc.ResponseTypeCode, true},
+		{goidc.ResponseTypeCodeAndIDToken, true},
+		{goidc.ResponseTypeToken, false},
+		{goidc.ResponseTypeIDTokenAndToken, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.responseType), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.ResponseTypes = []goidc.ResponseType{goidc.ResponseTypeCode, goidc.ResponseTypeCodeAndIDToken}
+			client := oidc.NewTestClient(t)
+			req := authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					ResponseType: testCase.responseType,
+					ResponseMode: goidc.ResponseModeFragment,
+					Scopes:       goidc.ScopeOpenID.ID,
+					Nonce:        "random_nonce",
+				},
+			}
+
+			// When.
+			err := validateRequest(ctx, req, client)
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, err)
+				return
+			}
+
+			var redirectErr redirectionError
+			require.ErrorAs(t, err, &redirectErr)
+			assert.Equal(t, oidc.ErrorCodeUnsupportedResponseType, redirectErr.Code())
+		})
+	}
+}
+
+func TestValidateAuthorizationRequest_DisabledResponseMode(t *testing.T) {
+	testCases := []struct {
+		responseMode  goidc.ResponseMode
+		shouldBeValid bool
+	}{
+		{goidc.ResponseModeFormPost, true},
+		{goidc.ResponseModeQuery, false},
+		{goidc.ResponseModeFragment, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.responseMode), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.ResponseModes = []goidc.ResponseMode{goidc.ResponseModeFormPost}
+			client := oidc.NewTestClient(t)
+			req := authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					ResponseType: goidc.ResponseTypeCode,
+					ResponseMode: testCase.responseMode,
+					Scopes:       goidc.ScopeOpenID.ID,
+				},
+			}
+
+			// When.
+			err := validateRequest(ctx, req, client)
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, err)
+				return
+			}
+
+			var redirectErr redirectionError
+			require.ErrorAs(t, err, &redirectErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, redirectErr.Code())
+		})
+	}
+}
+
+func TestValidateAuthorizationRequest_RedirectURIMatching(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mode          goidc.RedirectURIMatchingMode
+		redirectURI   string
+		shouldBeValid bool
+	}{
+		{"exact match", goidc.RedirectURIMatchingExact, "https://good.com/callback", true},
+		{"prefix bypass under exact matching", goidc.RedirectURIMatchingExact, "https://good.com/callback.evil.com", false},
+		{"prefix bypass under prefix matching", goidc.RedirectURIMatchingPrefix, "https://good.com/callback.evil.com", true},
+		{"default mode", "", "https://good.com/callback.evil.com", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.RedirectURIMatchingMode = testCase.mode
+			client := oidc.NewTestClient(t)
+			client.RedirectURIS = []string{"https://good.com/callback"}
+			req := authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  testCase.redirectURI,
+					ResponseType: goidc.ResponseTypeCode,
+					Scopes:       goidc.ScopeOpenID.ID,
+				},
+			}
+
+			// When.
+			err := validateRequest(ctx, req, client)
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, err)
+				return
+			}
+
+			require.NotNil(t, err)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+		})
+	}
+}
+
+func TestValidateAuthorizationRequest_MissingRedirectURI(t *testing.T) {
+	testCases := []struct {
+		name          string
+		redirectURIs  []string
+		isOptional    bool
+		shouldBeValid bool
+	}{
+		{"single redirect uri", []string{"https://example.client.com"}, true, true},
+		{"multiple redirect uris", []string{"https://example.client.com", "https://another.client.com"}, true, false},
+		{"redirect uri not optional", []string{"https://example.client.com"}, false, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.RedirectURIIsOptional = testCase.isOptional
+			client := oidc.NewTestClient(t)
+			client.RedirectURIS = testCase.redirectURIs
+			req := authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					ResponseType: goidc.ResponseTypeCode,
+					Scopes:       goidc.ScopeOpenID.ID,
+				},
+			}
+
+			// When.
+			err := validateRequest(ctx, req, client)
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, err)
+				return
+			}
+
+			var oauthErr oidc.Error
+			require.ErrorAs(t, err, &oauthErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+			var redirectErr redirectionError
+			assert.False(t, errors.As(err, &redirectErr), "the error should not be redirected")
+		})
+	}
+}
+
+func TestValidateAuthorizationRequest_PKCERequiredForPublicClients(t *testing.T) {
+	testCases := []struct {
+		name          string
+		authnMethod   goidc.ClientAuthnType
+		shouldBeValid bool
+	}{
+		{"public client without code challenge", goidc.ClientAuthnNone, false},
+		{"confidential client without code challenge", goidc.ClientAuthnSecretPost, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.PkceIsRequiredForPublicClients = true
+			client := oidc.NewTestClient(t)
+			client.AuthnMethod = testCase.authnMethod
+			req := authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:  client.RedirectURIS[0],
+					ResponseType: goidc.ResponseTypeCode,
+					Scopes:       client.Scopes,
+				},
+			}
+
+			// When.
+			err := validateRequest(ctx, req, client)
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, err)
+				return
+			}
+
+			var redirectErr redirectionError
+			require.ErrorAs(t, err, &redirectErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, redirectErr.Code())
+		})
+	}
+}
+
+func TestValidateAuthorizationRequest_PKCES256Required(t *testing.T) {
+	testCases := []struct {
+		name          string
+		method        goidc.CodeChallengeMethod
+		shouldBeValid bool
+	}{
+		{"s256", goidc.CodeChallengeMethodSHA256, true},
+		{"plain", goidc.CodeChallengeMethodPlain, false},
+		{"method not informed", "", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.PkceIsEnabled = true
+			ctx.PkceS256IsRequired = true
+			ctx.CodeChallengeMethods = []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256, goidc.CodeChallengeMethodPlain}
+			client := oidc.NewTestClient(t)
+			req := authorizationRequest{
+				ClientID: client.ID,
+				AuthorizationParameters: goidc.AuthorizationParameters{
+					RedirectURI:         client.RedirectURIS[0],
+					ResponseType:        goidc.ResponseTypeCode,
+					Scopes:              client.Scopes,
+					CodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+					CodeChallengeMethod: testCase.method,
+				},
+			}
+
+			// When.
+			err := validateRequest(ctx, req, client)
+
+			// Then.
+			if testCase.shouldBeValid {
+				require.Nil(t, err)
+				return
+			}
+
+			var redirectErr redirectionError
+			require.ErrorAs(t, err, &redirectErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, redirectErr.Code())
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
 func HandlerCreate(config oidc.Configuration) http.HandlerFunc {
@@ -21,7 +22,7 @@ func HandlerCreate(config oidc.Configuration) http.HandlerFunc {
 
 		resp, err := create(ctx, req)
 		if err != nil {
-			ctx.WriteError(err)
+			writeCreationError(ctx, req, err)
 			return
 		}
 
@@ -114,3 +115,19 @@ func HandlerDelete(config oidc.Configuration) http.HandlerFunc {
 	}
 
 }
+
+// writeCreationError informs the client in the WWW-Authenticate header why its
+// initial access token was rejected as described in RFC 6750.
+func writeCreationError(ctx *oidc.Context, req dynamicClientRequest, err oidc.Error) {
+	if err.Code() == oidc.ErrorCodeInvalidToken {
+		params := map[string]string{}
+		// When the request has no token, the challenge must not contain an error.
+		if req.InitialAccessToken != "" {
+			params["error"] = string(err.Code())
+			params["error_description"] = err.Error()
+		}
+		ctx.Response().Header().Set(goidc.HeaderWWWAuthenticate, oidc.BuildWWWAuthenticate(string(goidc.TokenTypeBearer), params))
+	}
+
+	ctx.WriteError(err)
+}
@@ -1,11 +1,14 @@
 package dcr
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/strutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func setDefaults(_ *oidc.Context, dynamicClient *dynamicClientRequest) oidc.Error {
@@ -13,9 +16,7 @@ func setDefaults(_ *oidc.Context, dynamicClient *dynamicClientRequest) oidc.Erro
 		dynamicClient.AuthnMethod = goidc.ClientAuthnSecretBasic
 	}
 
-	if dynamicClient.AuthnMethod == goidc.ClientAuthnSecretPost ||
-		dynamicClient.AuthnMethod == goidc.ClientAuthnSecretBasic ||
-		dynamicClient.AuthnMethod == goidc.ClientAuthnSecretJWT {
+	if hasSecret(dynamicClient.AuthnMethod) {
 		secret, err := clientSecret()
 		if err != nil {
 			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
@@ -50,24 +51,42 @@ func setDefaults(_ *oidc.Context, dynamicClient *dynamicClientRequest) oidc.Erro
 	return nil
 }
 
-func newClient(dynamicClient dynamicClientRequest) *goidc.Client {
-	hashedRegistrationAccessToken, _ := bcrypt.GenerateFromPassword([]byte(dynamicClient.RegistrationAccessToken), bcrypt.DefaultCost)
+func newClient(ctx *oidc.Context, dynamicClient dynamicClientRequest) (*goidc.Client, oidc.Error) {
+	hashedRegistrationAccessToken, err := ctx.SecretHasher.Hash(dynamicClient.RegistrationAccessToken)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
 	client := &goidc.Client{
 		ID:                            dynamicClient.ID,
-		HashedRegistrationAccessToken: string(hashedRegistrationAccessToken),
+		HashedRegistrationAccessToken: hashedRegistrationAccessToken,
 		ClientMetaInfo:                dynamicClient.ClientMetaInfo,
 	}
 
 	if dynamicClient.AuthnMethod == goidc.ClientAuthnSecretPost || dynamicClient.AuthnMethod == goidc.ClientAuthnSecretBasic {
-		clientHashedSecret, _ := bcrypt.GenerateFromPassword([]byte(dynamicClient.Secret), bcrypt.DefaultCost)
-		client.HashedSecret = string(clientHashedSecret)
+		clientHashedSecret, err := ctx.SecretHasher.Hash(dynamicClient.Secret)
+		if err != nil {
+			return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		client.HashedSecret = clientHashedSecret
 	}
 
-	if dynamicClient.AuthnMethod == goidc.ClientAuthnSecretJWT {
+	// The secret is also kept in plain text when it's used to sign ID tokens.
+	if dynamicClient.AuthnMethod == goidc.ClientAuthnSecretJWT || isSymmetricAlgorithm(dynamicClient.IDTokenSignatureAlgorithm) {
 		client.Secret = dynamicClient.Secret
 	}
 
-	return client
+	return client, nil
+}
+
+// hasSecret returns true if the authentication method relies on a client secret.
+func hasSecret(authnMethod goidc.ClientAuthnType) bool {
+	return authnMethod == goidc.ClientAuthnSecretPost ||
+		authnMethod == goidc.ClientAuthnSecretBasic ||
+		authnMethod == goidc.ClientAuthnSecretJWT
+}
+
+func isSymmetricAlgorithm(alg jose.SignatureAlgorithm) bool {
+	return strings.HasPrefix(string(alg), "HS")
 }
 
 func registrationURI(ctx *oidc.Context, clientID string) string {
@@ -91,13 +110,54 @@ func protectedClient(
 	}
 
 	if dynamicClient.RegistrationAccessToken == "" ||
-		!client.IsRegistrationAccessTokenValid(dynamicClient.RegistrationAccessToken) {
+		!client.IsRegistrationAccessTokenValid(ctx.SecretHasher, dynamicClient.RegistrationAccessToken) {
 		return nil, oidc.NewError(oidc.ErrorCodeAccessDenied, "invalid token")
 	}
 
 	return client, nil
 }
 
+// RotateClientSecret issues a new secret to the client and returns it.
+// The previous secret remains valid for overlapSecs, so the client can be
+// updated without downtime.
+func RotateClientSecret(ctx *oidc.Context, clientID string, overlapSecs int64) (string, error) {
+	client, err := ctx.Client(clientID)
+	if err != nil {
+		return "", err
+	}
+
+	if !hasSecret(client.AuthnMethod) && client.Secret == "" {
+		return "", errors.New("the client does not authenticate with a secret")
+	}
+
+	secret, err := clientSecret()
+	if err != nil {
+		return "", err
+	}
+
+	client.PreviousSecret = client.Secret
+	client.PreviousHashedSecret = client.HashedSecret
+	client.PreviousSecretExpiresAtTimestamp = time.Now().Unix() + overlapSecs
+
+	if client.HashedSecret != "" || client.AuthnMethod == goidc.ClientAuthnSecretPost ||
+		client.AuthnMethod == goidc.ClientAuthnSecretBasic {
+		client.HashedSecret, err = ctx.SecretHasher.Hash(secret)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if client.Secret != "" || client.AuthnMethod == goidc.ClientAuthnSecretJWT {
+		client.Secret = secret
+	}
+
+	if err := ctx.SaveClient(client); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
 func clientID() (string, error) {
 	clientID, err := strutil.Random(dynamicClientIDLength)
 	if err != nil {
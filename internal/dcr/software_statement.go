@@ -0,0 +1,77 @@
+package dcr
+
+import (
+	"encoding/json"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/oidc"
+)
+
+// applySoftwareStatement validates the software statement informed during
+// registration, if any, and merges the metadata it asserts over the ones
+// informed in the request as described in RFC 7591.
+// The values asserted in the statement take precedence.
+func applySoftwareStatement(ctx *oidc.Context, dynamicClient *dynamicClientRequest) oidc.Error {
+	statement := dynamicClient.SoftwareStatement
+	if statement == "" {
+		return nil
+	}
+
+	rawClaims, err := validSoftwareStatement(ctx, statement)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(rawClaims, &dynamicClient.ClientMetaInfo); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInvalidSoftwareStatement, "invalid software statement metadata")
+	}
+	// Keep the statement as it was informed, so it cannot be replaced by a
+	// claim inside it.
+	dynamicClient.SoftwareStatement = statement
+	return nil
+}
+
+// validSoftwareStatement verifies the statement with the keys of its issuer,
+// which must be trusted, and returns its raw claims.
+func validSoftwareStatement(ctx *oidc.Context, statement string) (json.RawMessage, oidc.Error) {
+	parsedStatement, err := jwt.ParseSigned(statement, ctx.SoftwareStatementSignatureAlgorithms)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidSoftwareStatement, "invalid software statement")
+	}
+
+	var unsafeClaims jwt.Claims
+	if err := parsedStatement.UnsafeClaimsWithoutVerification(&unsafeClaims); err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidSoftwareStatement, "invalid software statement")
+	}
+
+	jwksURI, ok := ctx.SoftwareStatementTrustedIssuers[unsafeClaims.Issuer]
+	if !ok {
+		return nil, oidc.NewError(oidc.ErrorCodeUnapprovedSoftwareStatement, "the software statement issuer is not trusted")
+	}
+
+	keys, err := ctx.IssuerPublicKeys(jwksURI, parsedStatement.Headers[0].KeyID)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, "could not load the software statement issuer jwks")
+	}
+
+	var claims jwt.Claims
+	var rawClaims json.RawMessage
+	verified := false
+	for _, key := range keys {
+		if err := parsedStatement.Claims(key.Key, &claims, &rawClaims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidSoftwareStatement, "invalid software statement signature")
+	}
+
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer: unsafeClaims.Issuer,
+	}, ctx.ClockSkewTolerance()); err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidSoftwareStatement, "invalid software statement")
+	}
+
+	return rawClaims, nil
+}
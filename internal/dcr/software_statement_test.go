@@ -0,0 +1,146 @@
+package dcr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateClient_WithSoftwareStatement(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpSoftwareStatement(t)
+	client := oidc.NewTestClient(t)
+	client.Name = "request_name"
+	client.SoftwareStatement = newSoftwareStatement(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://trusted.issuer.com",
+		goidc.ClaimIssuedAt: time.Now().Unix(),
+		"client_name":       "statement_name",
+		"software_id":       "software_id",
+		"software_version":  "1.0.0",
+	})
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	resp, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, "statement_name", resp.Name)
+	assert.Equal(t, "software_id", resp.SoftwareID)
+	assert.Equal(t, "1.0.0", resp.SoftwareVersion)
+	assert.Equal(t, client.SoftwareStatement, resp.SoftwareStatement)
+
+	savedClient, clientErr := ctx.Client(resp.ID)
+	require.Nil(t, clientErr)
+	assert.Equal(t, "statement_name", savedClient.Name)
+	assert.Equal(t, "software_id", savedClient.SoftwareID)
+	assert.Equal(t, "1.0.0", savedClient.SoftwareVersion)
+}
+
+func TestCreateClient_SoftwareStatementFromUntrustedIssuer(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpSoftwareStatement(t)
+	client := oidc.NewTestClient(t)
+	client.SoftwareStatement = newSoftwareStatement(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://untrusted.issuer.com",
+		goidc.ClaimIssuedAt: time.Now().Unix(),
+		"software_id":       "software_id",
+	})
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	_, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeUnapprovedSoftwareStatement, err.Code())
+}
+
+func TestCreateClient_SoftwareStatementWithInvalidSignature(t *testing.T) {
+	// Given.
+	ctx, _ := setUpSoftwareStatement(t)
+	otherJWK := oidc.PrivateRS256JWK(t, "issuer_key_id")
+	client := oidc.NewTestClient(t)
+	client.SoftwareStatement = newSoftwareStatement(t, otherJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://trusted.issuer.com",
+		goidc.ClaimIssuedAt: time.Now().Unix(),
+	})
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	_, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidSoftwareStatement, err.Code())
+}
+
+func TestCreateClient_ExpiredSoftwareStatement(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpSoftwareStatement(t)
+	client := oidc.NewTestClient(t)
+	client.SoftwareStatement = newSoftwareStatement(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://trusted.issuer.com",
+		goidc.ClaimIssuedAt: time.Now().Add(-2 * time.Hour).Unix(),
+		goidc.ClaimExpiry:   time.Now().Add(-1 * time.Hour).Unix(),
+	})
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	_, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidSoftwareStatement, err.Code())
+}
+
+func setUpSoftwareStatement(t *testing.T) (*oidc.Context, jose.JSONWebKey) {
+	t.Helper()
+
+	issuerJWK := oidc.PrivateRS256JWK(t, "issuer_key_id")
+	publicIssuerJWK := issuerJWK.Public()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(oidc.RawJWKS(publicIssuerJWK))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := oidc.NewTestContext(t)
+	ctx.SoftwareStatementSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+	ctx.SoftwareStatementTrustedIssuers = map[string]string{
+		"https://trusted.issuer.com": server.URL,
+	}
+
+	return ctx, issuerJWK
+}
+
+func newSoftwareStatement(t *testing.T, jwk jose.JSONWebKey, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(jwk.Algorithm), Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", jwk.KeyID),
+	)
+	require.Nil(t, err)
+
+	statement, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.Nil(t, err)
+
+	return statement
+}
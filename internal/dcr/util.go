@@ -1,6 +1,8 @@
 package dcr
 
 import (
+	"crypto/subtle"
+
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -12,6 +14,14 @@ func create(
 	dynamicClientResponse,
 	oidc.Error,
 ) {
+	if err := validateInitialAccessToken(ctx, dynamicClient); err != nil {
+		return dynamicClientResponse{}, err
+	}
+
+	if err := applySoftwareStatement(ctx, &dynamicClient); err != nil {
+		return dynamicClientResponse{}, err
+	}
+
 	if err := setCreationDefaults(ctx, &dynamicClient); err != nil {
 		return dynamicClientResponse{}, err
 	}
@@ -25,11 +35,23 @@ func create(
 		return dynamicClientResponse{}, err
 	}
 
-	newClient := newClient(dynamicClient)
+	newClient, err := newClient(ctx, dynamicClient)
+	if err != nil {
+		return dynamicClientResponse{}, err
+	}
+
 	if err := ctx.SaveClient(newClient); err != nil {
 		return dynamicClientResponse{}, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
 	}
 
+	ctx.NotifyEvent(goidc.Event{
+		Type:     goidc.EventTypeClientRegistered,
+		ClientID: newClient.ID,
+		Details: map[string]any{
+			"client_name": newClient.Name,
+		},
+	})
+
 	return dynamicClientResponse{
 		ID:                      dynamicClient.ID,
 		RegistrationURI:         registrationURI(ctx, dynamicClient.ID),
@@ -39,6 +61,36 @@ func create(
 	}, nil
 }
 
+// validateInitialAccessToken enforces the registration policy defined by the DCR mode.
+func validateInitialAccessToken(
+	ctx *oidc.Context,
+	dynamicClient dynamicClientRequest,
+) oidc.Error {
+	switch ctx.DCRMode {
+	case goidc.DCRModeClosed:
+		return oidc.NewError(oidc.ErrorCodeAccessDenied, "client registration is not allowed")
+	case goidc.DCRModeProtected:
+		if dynamicClient.InitialAccessToken == "" || !isInitialAccessTokenValid(ctx, dynamicClient.InitialAccessToken) {
+			return oidc.NewError(oidc.ErrorCodeInvalidToken, "invalid initial access token")
+		}
+	}
+
+	return nil
+}
+
+func isInitialAccessTokenValid(ctx *oidc.Context, token string) bool {
+	if ctx.DCRInitialAccessTokenValidator != nil {
+		return ctx.DCRInitialAccessTokenValidator(ctx, token)
+	}
+
+	for _, t := range ctx.DCRInitialAccessTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
 func setCreationDefaults(
 	ctx *oidc.Context,
 	dynamicClient *dynamicClientRequest,
@@ -70,6 +122,10 @@ func update(
 		return dynamicClientResponse{}, err
 	}
 
+	if err := applySoftwareStatement(ctx, &dynamicClient); err != nil {
+		return dynamicClientResponse{}, err
+	}
+
 	if err := setUpdateDefaults(ctx, client, &dynamicClient); err != nil {
 		return dynamicClientResponse{}, err
 	}
@@ -82,7 +138,11 @@ func update(
 		return dynamicClientResponse{}, err
 	}
 
-	updatedClient := newClient(dynamicClient)
+	updatedClient, err := newClient(ctx, dynamicClient)
+	if err != nil {
+		return dynamicClientResponse{}, err
+	}
+
 	if err := ctx.SaveClient(updatedClient); err != nil {
 		return dynamicClientResponse{}, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
 	}
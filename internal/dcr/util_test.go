@@ -1,8 +1,16 @@
 package dcr
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/go-jose/go-jose/v4"
+
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
@@ -30,6 +38,213 @@ func TestCreateClient(t *testing.T) {
 	require.Nil(t, err)
 }
 
+func TestCreateClient_Profile(t *testing.T) {
+	testCases := []struct {
+		name             string
+		profile          goidc.Profile
+		profileIsEnabled bool
+		shouldBeValid    bool
+	}{
+		{"server profile", goidc.ProfileOpenID, false, true},
+		{"stricter profile not allowed", goidc.ProfileFAPI2, false, false},
+		{"stricter profile allowed", goidc.ProfileFAPI2, true, true},
+		{"invalid profile", "random_profile", true, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			client := oidc.NewTestClient(t)
+			client.Profile = testCase.profile
+			ctx := oidc.NewTestContext(t)
+			ctx.DCRProfileIsEnabled = testCase.profileIsEnabled
+			dynamicClientReq := dynamicClientRequest{
+				ClientMetaInfo: client.ClientMetaInfo,
+			}
+
+			// When.
+			_, err := create(ctx, dynamicClientReq)
+
+			// Then.
+			if testCase.shouldBeValid {
+				assert.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Equal(t, oidc.ErrorCodeInvalidClientMetadata, err.Code())
+		})
+	}
+}
+
+func TestCreateClient_InvalidBackChannelLogoutURI(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	client.BackChannelLogoutURI = "https://example.client.com/logout#fragment"
+	ctx := oidc.NewTestContext(t)
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	_, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidClientMetadata, err.Code())
+}
+
+func TestCreateClient_InvalidRequestURI(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	client.RequestURIS = []string{"http://example.client.com/request"}
+	ctx := oidc.NewTestContext(t)
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	_, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidClientMetadata, err.Code())
+}
+
+func TestCreateClient_OpenMode(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	ctx := oidc.NewTestContext(t)
+	ctx.DCRMode = goidc.DCRModeOpen
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	resp, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.Nil(t, err)
+	assert.NotEmpty(t, resp.ID)
+}
+
+func TestCreateClient_ProtectedMode(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	ctx := oidc.NewTestContext(t)
+	ctx.DCRMode = goidc.DCRModeProtected
+	ctx.DCRInitialAccessTokens = []string{"initial_access_token"}
+
+	testCases := []struct {
+		initialAccessToken string
+		isValid            bool
+	}{
+		{"initial_access_token", true},
+		{"invalid_initial_access_token", false},
+		{"", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.initialAccessToken, func(t *testing.T) {
+			dynamicClientReq := dynamicClientRequest{
+				InitialAccessToken: testCase.initialAccessToken,
+				ClientMetaInfo:     client.ClientMetaInfo,
+			}
+
+			// When.
+			_, err := create(ctx, dynamicClientReq)
+
+			// Then.
+			if testCase.isValid {
+				assert.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+				assert.Equal(t, oidc.ErrorCodeInvalidToken, err.Code())
+				assert.Equal(t, http.StatusUnauthorized, err.Code().StatusCode())
+			}
+		})
+	}
+}
+
+func TestCreateClient_ProtectedModeWithValidator(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	ctx := oidc.NewTestContext(t)
+	ctx.DCRMode = goidc.DCRModeProtected
+	ctx.DCRInitialAccessTokenValidator = func(ctx goidc.Context, token string) bool {
+		return token == "initial_access_token"
+	}
+
+	// When.
+	_, validTokenErr := create(ctx, dynamicClientRequest{
+		InitialAccessToken: "initial_access_token",
+		ClientMetaInfo:     client.ClientMetaInfo,
+	})
+	_, invalidTokenErr := create(ctx, dynamicClientRequest{
+		InitialAccessToken: "invalid_initial_access_token",
+		ClientMetaInfo:     client.ClientMetaInfo,
+	})
+
+	// Then.
+	assert.Nil(t, validTokenErr)
+	require.NotNil(t, invalidTokenErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidToken, invalidTokenErr.Code())
+}
+
+func TestHandlerCreate_ProtectedMode(t *testing.T) {
+	testCases := []struct {
+		name               string
+		authorization      string
+		wwwAuthenticate    string
+		expectedStatusCode int
+	}{
+		{"missing token", "", "Bearer", http.StatusUnauthorized},
+		{"invalid token", "Bearer invalid_initial_access_token",
+			`Bearer error="invalid_token", error_description="invalid initial access token"`, http.StatusUnauthorized},
+		{"valid token", "Bearer initial_access_token", "", http.StatusCreated},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.DCRMode = goidc.DCRModeProtected
+			ctx.DCRInitialAccessTokens = []string{"initial_access_token"}
+
+			body, err := json.Marshal(oidc.NewTestClient(t).ClientMetaInfo)
+			require.Nil(t, err)
+			req := httptest.NewRequest(http.MethodPost, goidc.EndpointDynamicClient, bytes.NewReader(body))
+			if testCase.authorization != "" {
+				req.Header.Set("Authorization", testCase.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			// When.
+			HandlerCreate(ctx.Configuration)(w, req)
+
+			// Then.
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.wwwAuthenticate, w.Header().Get(goidc.HeaderWWWAuthenticate))
+		})
+	}
+}
+
+func TestCreateClient_ClosedMode(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	ctx := oidc.NewTestContext(t)
+	ctx.DCRMode = goidc.DCRModeClosed
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	_, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeAccessDenied, err.Code())
+}
+
 func TestUpdateClient(t *testing.T) {
 	// Given.
 	client := oidc.NewTestClient(t)
@@ -106,6 +321,26 @@ func TestGetClient_InvalidToken(t *testing.T) {
 	assert.Contains(t, err.Error(), "invalid token")
 }
 
+func TestGetClient_CustomSecretHasher(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.SecretHasher = sha256Hasher{}
+	createResp, oauthErr := create(ctx, dynamicClientRequest{
+		ClientMetaInfo: oidc.NewTestClient(t).ClientMetaInfo,
+	})
+	require.Nil(t, oauthErr)
+
+	// When.
+	resp, oauthErr := client(ctx, dynamicClientRequest{
+		ID:                      createResp.ID,
+		RegistrationAccessToken: createResp.RegistrationAccessToken,
+	})
+
+	// Then.
+	require.Nil(t, oauthErr)
+	assert.Equal(t, createResp.ID, resp.ID)
+}
+
 func TestDeleteClient(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
@@ -139,3 +374,268 @@ func TestDeleteClient_InvalidToken(t *testing.T) {
 	require.NotNil(t, err)
 	assert.Contains(t, err.Error(), "invalid token")
 }
+
+func TestCreateClient_MetadataLimits(t *testing.T) {
+	testCases := []struct {
+		name        string
+		setLimit    func(ctx *oidc.Context, limit int)
+		count       func(client *goidc.Client) int
+		description string
+	}{
+		{
+			"redirect_uris",
+			func(ctx *oidc.Context, limit int) { ctx.DCRMaxRedirectURIs = limit },
+			func(client *goidc.Client) int { return len(client.RedirectURIS) },
+			"too many redirect_uris",
+		},
+		{
+			"grant_types",
+			func(ctx *oidc.Context, limit int) { ctx.DCRMaxGrantTypes = limit },
+			func(client *goidc.Client) int { return len(client.GrantTypes) },
+			"too many grant_types",
+		},
+		{
+			"response_types",
+			func(ctx *oidc.Context, limit int) { ctx.DCRMaxResponseTypes = limit },
+			func(client *goidc.Client) int { return len(client.ResponseTypes) },
+			"too many response_types",
+		},
+		{
+			"authorization_data_types",
+			func(ctx *oidc.Context, limit int) { ctx.DCRMaxAuthorizationDetailTypes = limit },
+			func(client *goidc.Client) int { return len(client.AuthorizationDetailTypes) },
+			"too many authorization_data_types",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			client := oidc.NewTestClient(t)
+			client.RedirectURIS = append(client.RedirectURIS, "https://example.client.com/callback2")
+			client.AuthorizationDetailTypes = []string{"type1", "type2"}
+			ctx := oidc.NewTestContext(t)
+
+			// When the number of values is exactly the limit.
+			testCase.setLimit(ctx, testCase.count(client))
+			_, err := create(ctx, dynamicClientRequest{
+				ClientMetaInfo: client.ClientMetaInfo,
+			})
+
+			// Then.
+			require.Nil(t, err)
+
+			// When the number of values is above the limit.
+			testCase.setLimit(ctx, testCase.count(client)-1)
+			_, err = create(ctx, dynamicClientRequest{
+				ClientMetaInfo: client.ClientMetaInfo,
+			})
+
+			// Then.
+			require.NotNil(t, err)
+			assert.Equal(t, oidc.ErrorCodeInvalidClientMetadata, err.Code())
+			assert.Equal(t, testCase.description, err.Error())
+		})
+	}
+}
+
+func TestCreateClient_IDTokenEncryptionWithoutEncryptionKey(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	client.IDTokenKeyEncryptionAlgorithm = jose.RSA_OAEP
+	signingJWK := oidc.PrivatePS256JWK(t, "signing_key")
+	client.PublicJWKS = oidc.RawJWKS(signingJWK.Public())
+	ctx := oidc.NewTestContext(t)
+	ctx.UserInfoEncryptionIsEnabled = true
+	ctx.UserInfoKeyEncryptionAlgorithms = []jose.KeyAlgorithm{jose.RSA_OAEP}
+	ctx.UserInfoContentEncryptionAlgorithms = []jose.ContentEncryption{jose.A128CBC_HS256}
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	_, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestCreateClient_IDTokenEncryption(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	client.IDTokenKeyEncryptionAlgorithm = jose.RSA_OAEP
+	encJWK := oidc.PrivateRS256JWKWithUsage(t, "encryption_key", goidc.KeyUsageEncryption)
+	encJWK.Algorithm = string(jose.RSA_OAEP)
+	client.PublicJWKS = oidc.RawJWKS(encJWK.Public())
+	ctx := oidc.NewTestContext(t)
+	ctx.UserInfoEncryptionIsEnabled = true
+	ctx.UserInfoKeyEncryptionAlgorithms = []jose.KeyAlgorithm{jose.RSA_OAEP}
+	ctx.UserInfoContentEncryptionAlgorithms = []jose.ContentEncryption{jose.A128CBC_HS256}
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	resp, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.Nil(t, err)
+	assert.NotEmpty(t, resp.ID)
+}
+
+func TestCreateClient_SymmetricIDTokenSignature(t *testing.T) {
+	// Given.
+	client := oidc.NewTestClient(t)
+	client.AuthnMethod = goidc.ClientAuthnSecretBasic
+	client.IDTokenSignatureAlgorithm = jose.HS256
+	ctx := oidc.NewTestContext(t)
+	ctx.IDTokenSymmetricSignatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+	ctx.ClientAuthnMethods = append(ctx.ClientAuthnMethods, goidc.ClientAuthnSecretBasic)
+	dynamicClientReq := dynamicClientRequest{
+		ClientMetaInfo: client.ClientMetaInfo,
+	}
+
+	// When.
+	resp, err := create(ctx, dynamicClientReq)
+
+	// Then.
+	require.Nil(t, err)
+	newClient, clientErr := ctx.Client(resp.ID)
+	require.Nil(t, clientErr)
+	assert.Equal(t, resp.Secret, newClient.Secret, "the secret must be kept to sign ID tokens")
+}
+
+func TestValidateIDTokenSignatureAlgorithm_Symmetric(t *testing.T) {
+	testCases := []struct {
+		authnMethod goidc.ClientAuthnType
+		isValid     bool
+	}{
+		{goidc.ClientAuthnSecretBasic, true},
+		{goidc.ClientAuthnSecretJWT, true},
+		{goidc.ClientAuthnNone, false},
+		{goidc.ClientAuthnPrivateKeyJWT, false},
+		{goidc.ClientAuthnTLS, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.authnMethod), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.IDTokenSymmetricSignatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+			dynamicClient := dynamicClientRequest{
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					AuthnMethod:               testCase.authnMethod,
+					IDTokenSignatureAlgorithm: jose.HS256,
+				},
+			}
+
+			// When.
+			err := validateIDTokenSignatureAlgorithm(ctx, dynamicClient)
+
+			// Then.
+			if testCase.isValid {
+				assert.Nil(t, err)
+				return
+			}
+
+			require.NotNil(t, err)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+		})
+	}
+}
+
+func TestValidatePARRequirement(t *testing.T) {
+	required, optional := true, false
+	testCases := []struct {
+		name             string
+		serverPAREnabled bool
+		serverRequires   bool
+		clientRequires   *bool
+		isValid          bool
+	}{
+		{"client requires par when optional", true, false, &required, true},
+		{"client requires par when not supported", false, false, &required, false},
+		{"client relaxes par when required", true, true, &optional, false},
+		{"client relaxes par when optional", true, false, &optional, true},
+		{"client follows the server default", true, true, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.PARIsEnabled = testCase.serverPAREnabled
+			ctx.PARIsRequired = testCase.serverRequires
+			dynamicClient := dynamicClientRequest{
+				ClientMetaInfo: goidc.ClientMetaInfo{
+					PARIsRequired: testCase.clientRequires,
+				},
+			}
+
+			// When.
+			err := validatePARRequirement(ctx, dynamicClient)
+
+			// Then.
+			if testCase.isValid {
+				assert.Nil(t, err)
+				return
+			}
+
+			require.NotNil(t, err)
+			assert.Equal(t, oidc.ErrorCodeInvalidClientMetadata, err.Code())
+		})
+	}
+}
+
+func TestRotateClientSecret(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.AuthnMethod = goidc.ClientAuthnSecretPost
+	require.Nil(t, ctx.SaveClient(client))
+	previousHashedSecret := client.HashedSecret
+
+	// When.
+	secret, rotationErr := RotateClientSecret(ctx, oidc.TestClientID, 60)
+
+	// Then.
+	require.Nil(t, rotationErr)
+	assert.NotEmpty(t, secret)
+
+	rotatedClient, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	assert.True(t, ctx.SecretHasher.Compare(rotatedClient.HashedSecret, secret))
+	assert.True(t, ctx.SecretHasher.Compare(rotatedClient.PreviousHashedSecret, oidc.TestClientSecret))
+	assert.Equal(t, previousHashedSecret, rotatedClient.PreviousHashedSecret)
+	assert.True(t, rotatedClient.PreviousSecretIsActive())
+}
+
+func TestRotateClientSecret_ClientWithoutSecret(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.AuthnMethod = goidc.ClientAuthnNone
+	require.Nil(t, ctx.SaveClient(client))
+
+	// When.
+	_, err = RotateClientSecret(ctx, oidc.TestClientID, 60)
+
+	// Then.
+	assert.NotNil(t, err)
+}
+
+// sha256Hasher is a secret hasher that neither bcrypt nor argon2id can verify.
+type sha256Hasher struct{}
+
+func (sha256Hasher) Hash(secret string) (string, error) {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func (h sha256Hasher) Compare(hashedSecret, secret string) bool {
+	hash, _ := h.Hash(secret)
+	return hash == hashedSecret
+}
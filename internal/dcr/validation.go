@@ -3,6 +3,7 @@ package dcr
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"slices"
 
 	"github.com/go-jose/go-jose/v4"
@@ -42,6 +43,12 @@ func validateDynamicClientRequest(
 		validatePublicJWKS,
 		validatePublicJWKSURI,
 		validateAuthorizationDetailTypes,
+		validatePostLogoutRedirectURIS,
+		validateBackChannelLogoutURI,
+		validateRequestURIS,
+		validatePARRequirement,
+		validateMetadataLimits,
+		validateProfile,
 	)
 }
 
@@ -127,6 +134,26 @@ func validateAuthnMethod(
 	return nil
 }
 
+func validateProfile(
+	ctx *oidc.Context,
+	dynamicClient dynamicClientRequest,
+) oidc.Error {
+	if dynamicClient.Profile == "" {
+		return nil
+	}
+
+	if dynamicClient.Profile != goidc.ProfileOpenID && dynamicClient.Profile != goidc.ProfileFAPI2 {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "invalid profile")
+	}
+
+	// The profile changes how the client's requests are validated, so clients
+	// can only choose it if the server allows them to.
+	if !ctx.DCRProfileIsEnabled && dynamicClient.Profile != ctx.Profile {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "the profile cannot be registered")
+	}
+	return nil
+}
+
 func validateOpenIDScopeIfRequired(
 	ctx *oidc.Context,
 	dynamicClient dynamicClientRequest,
@@ -164,9 +191,15 @@ func validateIDTokenSignatureAlgorithm(
 		return nil
 	}
 
-	if !slices.Contains(ctx.UserInfoSignatureAlgorithms(), dynamicClient.IDTokenSignatureAlgorithm) {
+	if !slices.Contains(ctx.IDTokenSignatureAlgorithms(), dynamicClient.IDTokenSignatureAlgorithm) {
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "id_token_signed_response_alg not supported")
 	}
+
+	// Symmetric algorithms use the client secret as the key, so only clients
+	// that authenticate with a secret can use them.
+	if isSymmetricAlgorithm(dynamicClient.IDTokenSignatureAlgorithm) && !hasSecret(dynamicClient.AuthnMethod) {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "symmetric id_token_signed_response_alg requires a client secret")
+	}
 	return nil
 }
 
@@ -221,6 +254,9 @@ func validateClientSignatureAlgorithmForPrivateKeyJWT(
 	}
 
 	if dynamicClient.AuthnSignatureAlgorithm == "" {
+		if ctx.ClientAuthnSignatureAlgorithmIsRequired {
+			return oidc.NewError(oidc.ErrorCodeInvalidRequest, "token_endpoint_auth_signing_alg is required")
+		}
 		return nil
 	}
 
@@ -239,6 +275,9 @@ func validateClientSignatureAlgorithmForClientSecretJWT(
 	}
 
 	if dynamicClient.AuthnSignatureAlgorithm == "" {
+		if ctx.ClientAuthnSignatureAlgorithmIsRequired {
+			return oidc.NewError(oidc.ErrorCodeInvalidRequest, "token_endpoint_auth_signing_alg is required")
+		}
 		return nil
 	}
 
@@ -300,8 +339,16 @@ func validateTLSSubjectInfoWhenTLSAuthn(
 		numberOfIdentifiers++
 	}
 
+	if dynamicClient.TLSSubjectAlternativeNameURI != "" {
+		numberOfIdentifiers++
+	}
+
+	if dynamicClient.TLSSubjectAlternativeNameEmail != "" {
+		numberOfIdentifiers++
+	}
+
 	if numberOfIdentifiers != 1 {
-		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "only one of: tls_client_auth_subject_dn, tls_client_auth_san_dns, tls_client_auth_san_ip must be informed")
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "only one of: tls_client_auth_subject_dn, tls_client_auth_san_dns, tls_client_auth_san_ip, tls_client_auth_san_uri, tls_client_auth_san_email must be informed")
 	}
 
 	return nil
@@ -331,6 +378,24 @@ func validateIDTokenEncryptionAlgorithms(
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "id_token_encrypted_response_enc not supported")
 	}
 
+	if dynamicClient.IDTokenKeyEncryptionAlgorithm == "" {
+		return nil
+	}
+
+	if dynamicClient.PublicJWKS == nil && dynamicClient.PublicJWKSURI == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "jwks or jwks_uri is required for ID token encryption")
+	}
+
+	// Keys informed by reference are only fetched when the ID token is issued.
+	if dynamicClient.PublicJWKS == nil {
+		return nil
+	}
+
+	client := goidc.Client{ClientMetaInfo: dynamicClient.ClientMetaInfo}
+	if _, err := client.IDTokenEncryptionJWK(); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "the jwks must contain an encryption key for id_token_encrypted_response_alg")
+	}
+
 	return nil
 }
 
@@ -444,6 +509,72 @@ func validatePublicJWKSURI(
 	return nil
 }
 
+func validatePostLogoutRedirectURIS(
+	_ *oidc.Context,
+	dynamicClient dynamicClientRequest,
+) oidc.Error {
+	for _, uri := range dynamicClient.PostLogoutRedirectURIS {
+		if !strutil.IsAbsoluteURIWithoutFragment(uri) {
+			return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata,
+				"post_logout_redirect_uris must be absolute uris without a fragment")
+		}
+	}
+
+	return nil
+}
+
+func validateBackChannelLogoutURI(
+	_ *oidc.Context,
+	dynamicClient dynamicClientRequest,
+) oidc.Error {
+	if dynamicClient.BackChannelLogoutURI == "" {
+		return nil
+	}
+
+	if !strutil.IsAbsoluteURIWithoutFragment(dynamicClient.BackChannelLogoutURI) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata,
+			"backchannel_logout_uri must be an absolute uri without a fragment")
+	}
+
+	return nil
+}
+
+func validateRequestURIS(
+	_ *oidc.Context,
+	dynamicClient dynamicClientRequest,
+) oidc.Error {
+	for _, uri := range dynamicClient.RequestURIS {
+		parsedURI, err := url.Parse(uri)
+		if err != nil || parsedURI.Scheme != "https" || parsedURI.Host == "" {
+			return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "request_uris must be https uris")
+		}
+	}
+
+	return nil
+}
+
+// validatePARRequirement makes sure clients can only require pushed
+// authorization requests when they are available and cannot opt out of them
+// when the server requires them.
+func validatePARRequirement(
+	ctx *oidc.Context,
+	dynamicClient dynamicClientRequest,
+) oidc.Error {
+	if dynamicClient.PARIsRequired == nil {
+		return nil
+	}
+
+	if *dynamicClient.PARIsRequired && !ctx.PARIsEnabled {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "pushed authorization requests are not supported")
+	}
+
+	if !*dynamicClient.PARIsRequired && ctx.PARIsRequired {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "pushed authorization requests are required by the server")
+	}
+
+	return nil
+}
+
 func validateAuthorizationDetailTypes(
 	ctx *oidc.Context,
 	dynamicClient dynamicClientRequest,
@@ -491,3 +622,32 @@ func validateScopes(
 
 	return nil
 }
+
+// validateMetadataLimits bounds the number of values a client can register
+// for list metadata, so registrations cannot be abused.
+func validateMetadataLimits(
+	ctx *oidc.Context,
+	dynamicClient dynamicClientRequest,
+) oidc.Error {
+	if exceedsLimit(len(dynamicClient.RedirectURIS), ctx.DCRMaxRedirectURIs) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "too many redirect_uris")
+	}
+
+	if exceedsLimit(len(dynamicClient.GrantTypes), ctx.DCRMaxGrantTypes) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "too many grant_types")
+	}
+
+	if exceedsLimit(len(dynamicClient.ResponseTypes), ctx.DCRMaxResponseTypes) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "too many response_types")
+	}
+
+	if exceedsLimit(len(dynamicClient.AuthorizationDetailTypes), ctx.DCRMaxAuthorizationDetailTypes) {
+		return oidc.NewError(oidc.ErrorCodeInvalidClientMetadata, "too many authorization_data_types")
+	}
+
+	return nil
+}
+
+func exceedsLimit(n int, limit int) bool {
+	return limit > 0 && n > limit
+}
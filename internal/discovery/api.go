@@ -1,6 +1,7 @@
 package discovery
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
@@ -8,9 +9,18 @@ import (
 
 func HandlerWellKnown(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
+		ctx := oidc.NewRequestContext(config, r, w)
 
 		openidConfig := wellKnown(ctx)
+		if ctx.SignedMetadataIsEnabled {
+			signedMetadata, err := signMetadata(ctx, openidConfig)
+			if err != nil {
+				ctx.WriteError(err)
+				return
+			}
+			openidConfig.SignedMetadata = signedMetadata
+		}
+
 		if err := ctx.Write(openidConfig, http.StatusOK); err != nil {
 			ctx.WriteError(err)
 		}
@@ -18,10 +28,54 @@ func HandlerWellKnown(config *oidc.Configuration) http.HandlerFunc {
 
 }
 
+func HandlerFederation(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		entityConfig, err := entityConfiguration(ctx)
+		if err != nil {
+			ctx.WriteError(err)
+			return
+		}
+
+		if err := ctx.WriteEntityStatement(entityConfig, http.StatusOK); err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
+
+func HandlerProtectedResource(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+		if err := ctx.Write(protectedResource(ctx), http.StatusOK); err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
+
 func HandlerJWKS(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
-		if err := ctx.Write(ctx.PublicKeys(), http.StatusOK); err != nil {
+		ctx := oidc.NewRequestContext(config, r, w)
+		jwks := ctx.PublicKeys()
+
+		if ctx.JWKSCacheMaxAgeSecs != 0 {
+			etag, err := jwksETag(jwks)
+			if err != nil {
+				ctx.WriteError(err)
+				return
+			}
+
+			// Override the headers that prevent caching.
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", ctx.JWKSCacheMaxAgeSecs))
+			w.Header().Del("Pragma")
+			w.Header().Set("ETag", etag)
+			if isETagMatch(r.Header.Get("If-None-Match"), etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		if err := ctx.Write(jwks, http.StatusOK); err != nil {
 			ctx.WriteError(err)
 		}
 	}
@@ -30,6 +30,7 @@ type openIDConfiguration struct {
 	ClientAuthnMethods                             []goidc.ClientAuthnType       `json:"token_endpoint_auth_methods_supported"`
 	JARIsRequired                                  bool                          `json:"require_signed_request_object,omitempty"`
 	JARIsEnabled                                   bool                          `json:"request_parameter_supported"`
+	JARByReferenceIsEnabled                        bool                          `json:"request_uri_parameter_supported"`
 	JARAlgorithms                                  []jose.SignatureAlgorithm     `json:"request_object_signing_alg_values_supported,omitempty"`
 	JARKeyEncrytionAlgorithms                      []jose.KeyAlgorithm           `json:"request_object_encryption_alg_values_supported,omitempty"`
 	JARContentEncryptionAlgorithms                 []jose.ContentEncryption      `json:"request_object_encryption_enc_values_supported,omitempty"`
@@ -45,16 +46,43 @@ type openIDConfiguration struct {
 	IntrospectionEndpoint                          string                        `json:"introspection_endpoint,omitempty"`
 	IntrospectionEndpointClientAuthnMethods        []goidc.ClientAuthnType       `json:"introspection_endpoint_auth_methods_supported,omitempty"`
 	IntrospectionEndpointClientSignatureAlgorithms []jose.SignatureAlgorithm     `json:"introspection_endpoint_auth_signing_alg_values_supported,omitempty"`
+	IntrospectionSignatureAlgorithms               []jose.SignatureAlgorithm     `json:"introspection_signing_alg_values_supported,omitempty"`
+	RevocationEndpoint                             string                        `json:"revocation_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint                    string                        `json:"device_authorization_endpoint,omitempty"`
+	RevocationEndpointClientAuthnMethods           []goidc.ClientAuthnType       `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	RevocationEndpointClientSignatureAlgorithms    []jose.SignatureAlgorithm     `json:"revocation_endpoint_auth_signing_alg_values_supported,omitempty"`
 	MTLSConfiguration                              *openIDMTLSConfiguration      `json:"mtls_endpoint_aliases,omitempty"`
 	TLSBoundTokensIsEnabled                        bool                          `json:"tls_client_certificate_bound_access_tokens,omitempty"`
 	AuthenticationContextReferences                []goidc.ACR                   `json:"acr_values_supported,omitempty"`
+	PromptValuesSupported                          []goidc.PromptType            `json:"prompt_values_supported,omitempty"`
 	DisplayValuesSupported                         []goidc.DisplayValue          `json:"display_values_supported,omitempty"`
+	UILocalesSupported                             []string                      `json:"ui_locales_supported,omitempty"`
+	ClaimsLocalesSupported                         []string                      `json:"claims_locales_supported,omitempty"`
+	EndSessionEndpoint                             string                        `json:"end_session_endpoint,omitempty"`
+	BackChannelLogoutIsSupported                   bool                          `json:"backchannel_logout_supported,omitempty"`
+	BackChannelLogoutSessionIsSupported            bool                          `json:"backchannel_logout_session_supported,omitempty"`
+	SignedMetadata                                 string                        `json:"signed_metadata,omitempty"`
 }
 
 type openIDMTLSConfiguration struct {
-	TokenEndpoint              string `json:"token_endpoint"`
-	ParEndpoint                string `json:"pushed_authorization_request_endpoint,omitempty"`
-	UserinfoEndpoint           string `json:"userinfo_endpoint"`
-	ClientRegistrationEndpoint string `json:"registration_endpoint,omitempty"`
-	IntrospectionEndpoint      string `json:"introspection_endpoint,omitempty"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	ParEndpoint                 string `json:"pushed_authorization_request_endpoint,omitempty"`
+	UserinfoEndpoint            string `json:"userinfo_endpoint"`
+	ClientRegistrationEndpoint  string `json:"registration_endpoint,omitempty"`
+	IntrospectionEndpoint       string `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint          string `json:"revocation_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
+}
+
+// protectedResourceMetadata is the metadata of a protected resource as
+// defined in RFC 9728.
+type protectedResourceMetadata struct {
+	Resource                  string                    `json:"resource"`
+	AuthorizationServers      []string                  `json:"authorization_servers"`
+	Scopes                    []string                  `json:"scopes_supported,omitempty"`
+	BearerMethods             []string                  `json:"bearer_methods_supported"`
+	SignatureAlgorithms       []jose.SignatureAlgorithm `json:"resource_signing_alg_values_supported,omitempty"`
+	TLSBoundTokensIsEnabled   bool                      `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	DPoPSignatureAlgorithms   []jose.SignatureAlgorithm `json:"dpop_signing_alg_values_supported,omitempty"`
+	DPoPBoundTokensIsRequired bool                      `json:"dpop_bound_access_tokens_required,omitempty"`
 }
@@ -1,6 +1,14 @@
 package discovery
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -22,7 +30,7 @@ func wellKnown(ctx *oidc.Context) openIDConfiguration {
 		UserClaimsSupported:                  ctx.UserClaims,
 		ClaimTypesSupported:                  ctx.ClaimTypes,
 		SubjectIdentifierTypes:               ctx.SubjectIdentifierTypes,
-		IDTokenSignatureAlgorithms:           ctx.UserInfoSignatureAlgorithms(),
+		IDTokenSignatureAlgorithms:           ctx.IDTokenSignatureAlgorithms(),
 		UserInfoSignatureAlgorithms:          ctx.UserInfoSignatureAlgorithms(),
 		ClientAuthnMethods:                   ctx.ClientAuthnMethods,
 		Scopes:                               scopes,
@@ -33,6 +41,9 @@ func wellKnown(ctx *oidc.Context) openIDConfiguration {
 		AuthorizationDetailTypesSupported:    ctx.AuthorizationDetailTypes,
 		AuthenticationContextReferences:      ctx.AuthenticationContextReferences,
 		DisplayValuesSupported:               ctx.DisplayValues,
+		UILocalesSupported:                   ctx.UILocales,
+		ClaimsLocalesSupported:               ctx.ClaimsLocales,
+		PromptValuesSupported:                ctx.PromptValues,
 	}
 
 	if ctx.PARIsEnabled {
@@ -47,6 +58,7 @@ func wellKnown(ctx *oidc.Context) openIDConfiguration {
 	if ctx.JARIsEnabled {
 		config.JARIsEnabled = ctx.JARIsEnabled
 		config.JARIsRequired = ctx.JARIsRequired
+		config.JARByReferenceIsEnabled = ctx.JARByReferenceIsEnabled
 		config.JARAlgorithms = ctx.JARSignatureAlgorithms
 		if ctx.JAREncryptionIsEnabled {
 			config.JARKeyEncrytionAlgorithms = ctx.JARKeyEncryptionAlgorithms()
@@ -70,6 +82,28 @@ func wellKnown(ctx *oidc.Context) openIDConfiguration {
 		config.IntrospectionEndpoint = ctx.BaseURL() + string(goidc.EndpointTokenIntrospection)
 		config.IntrospectionEndpointClientAuthnMethods = ctx.IntrospectionClientAuthnMethods
 		config.IntrospectionEndpointClientSignatureAlgorithms = ctx.IntrospectionClientSignatureAlgorithms()
+		if ctx.IntrospectionJWTResponseIsEnabled {
+			config.IntrospectionSignatureAlgorithms = ctx.IntrospectionSignatureAlgorithms()
+		}
+	}
+
+	if ctx.TokenRevocationIsEnabled {
+		config.RevocationEndpoint = ctx.BaseURL() + string(goidc.EndpointTokenRevocation)
+		config.RevocationEndpointClientAuthnMethods = ctx.ClientAuthnMethods
+		config.RevocationEndpointClientSignatureAlgorithms = ctx.ClientSignatureAlgorithms()
+	}
+
+	if ctx.DeviceGrantIsEnabled {
+		config.DeviceAuthorizationEndpoint = ctx.BaseURL() + string(goidc.EndpointDeviceAuthorization)
+	}
+
+	if ctx.EndSessionIsEnabled {
+		config.EndSessionEndpoint = ctx.BaseURL() + string(goidc.EndpointEndSession)
+	}
+
+	if ctx.BackChannelLogoutIsEnabled {
+		config.BackChannelLogoutIsSupported = true
+		config.BackChannelLogoutSessionIsSupported = ctx.SIDClaimIsEnabled
 	}
 
 	if ctx.MTLSIsEnabled {
@@ -91,6 +125,14 @@ func wellKnown(ctx *oidc.Context) openIDConfiguration {
 		if ctx.IntrospectionIsEnabled {
 			config.IntrospectionEndpoint = ctx.MTLSBaseURL() + string(goidc.EndpointTokenIntrospection)
 		}
+
+		if ctx.TokenRevocationIsEnabled {
+			config.MTLSConfiguration.RevocationEndpoint = ctx.MTLSBaseURL() + string(goidc.EndpointTokenRevocation)
+		}
+
+		if ctx.DeviceGrantIsEnabled {
+			config.MTLSConfiguration.DeviceAuthorizationEndpoint = ctx.MTLSBaseURL() + string(goidc.EndpointDeviceAuthorization)
+		}
 	}
 
 	if ctx.UserInfoEncryptionIsEnabled {
@@ -102,3 +144,131 @@ func wellKnown(ctx *oidc.Context) openIDConfiguration {
 
 	return config
 }
+
+// signMetadata returns the metadata claims as a JWT signed by the server as
+// defined in RFC 8414.
+// protectedResource builds the metadata of the resource server protected by
+// the tokens issued by the server based on its configuration.
+func protectedResource(ctx *oidc.Context) protectedResourceMetadata {
+	metadata := protectedResourceMetadata{
+		Resource:             ctx.ProtectedResource,
+		AuthorizationServers: []string{ctx.Host},
+		// RFC 6750 recommends clients to always send tokens in the header.
+		BearerMethods:       []string{"header"},
+		SignatureAlgorithms: ctx.ProtectedResourceSignatureAlgorithms,
+	}
+
+	for _, scope := range ctx.Scopes {
+		metadata.Scopes = append(metadata.Scopes, scope.ID)
+	}
+
+	if ctx.TLSBoundTokensIsEnabled {
+		metadata.TLSBoundTokensIsEnabled = true
+	}
+
+	if ctx.DPoPIsEnabled {
+		metadata.DPoPSignatureAlgorithms = ctx.DPoPSignatureAlgorithms
+		metadata.DPoPBoundTokensIsRequired = ctx.DPoPIsRequired
+	}
+
+	return metadata
+}
+
+func signMetadata(ctx *oidc.Context, openidConfig openIDConfiguration) (string, error) {
+	privateJWK, ok := ctx.PrivateKey(ctx.SignedMetadataSignatureKeyID)
+	if !ok {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, "the metadata signature key was not found")
+	}
+
+	rawConfig, err := json.Marshal(openidConfig)
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(rawConfig, &claims); err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+	// RFC 8414. "...The signed metadata MUST be digitally signed or MACed using
+	// JSON Web Signature (JWS) and MUST contain an "iss" (issuer) claim..."
+	claims[goidc.ClaimIssuer] = ctx.Host
+	claims[goidc.ClaimIssuedAt] = time.Now().Unix()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", privateJWK.KeyID),
+	)
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	signedMetadata, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	return signedMetadata, nil
+}
+
+// entityConfiguration returns the entity statement issued by the server about
+// itself describing it as an OpenID provider in the federation.
+func entityConfiguration(ctx *oidc.Context) (string, error) {
+	openidConfig, err := json.Marshal(wellKnown(ctx))
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	now := time.Now().Unix()
+	statement := oidc.EntityStatement{
+		Issuer:         ctx.Host,
+		Subject:        ctx.Host,
+		IssuedAt:       now,
+		ExpiresAt:      now + ctx.FederationEntityStatementLifetimeSecs,
+		JWKS:           ctx.PublicKeys(),
+		AuthorityHints: ctx.FederationAuthorityHints,
+		Metadata: map[string]json.RawMessage{
+			"openid_provider": openidConfig,
+		},
+	}
+
+	privateJWK := ctx.TokenSignatureKey(goidc.TokenOptions{})
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+		(&jose.SignerOptions{}).WithType("entity-statement+jwt").WithHeader("kid", privateJWK.KeyID),
+	)
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	entityConfig, err := jwt.Signed(signer).Claims(statement).Serialize()
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	return entityConfig, nil
+}
+
+// jwksETag derives an entity tag from the content of the JWKS, so it changes
+// whenever a key is added, removed or rotated.
+func jwksETag(jwks jose.JSONWebKeySet) (string, error) {
+	jwksBytes, err := json.Marshal(jwks)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(jwksBytes)
+	return `"` + base64.RawURLEncoding.EncodeToString(hash[:]) + `"`, nil
+}
+
+// isETagMatch returns whether the entity tag is among the ones informed in
+// the If-None-Match header. Weak tags are compared as strong ones as
+// described in RFC 9110.
+func isETagMatch(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,17 @@
 package discovery
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetOpenIDConfiguration(t *testing.T) {
@@ -37,6 +42,9 @@ func TestGetOpenIDConfiguration(t *testing.T) {
 			AuthorizationDetailTypes:               []string{"detail_type"},
 			AuthenticationContextReferences:        []goidc.ACR{"0"},
 			DisplayValues:                          []goidc.DisplayValue{goidc.DisplayValuePage},
+			UILocales:                              []string{"en", "pt-BR"},
+			ClaimsLocales:                          []string{"en", "ja"},
+			PromptValues:                           []goidc.PromptType{goidc.PromptTypeLogin, goidc.PromptTypeCreate},
 		},
 	}
 
@@ -70,6 +78,9 @@ func TestGetOpenIDConfiguration(t *testing.T) {
 	assert.Equal(t, []string{"detail_type"}, openidConfig.AuthorizationDetailTypesSupported)
 	assert.Equal(t, []goidc.ACR{"0"}, openidConfig.AuthenticationContextReferences)
 	assert.Equal(t, []goidc.DisplayValue{goidc.DisplayValuePage}, openidConfig.DisplayValuesSupported)
+	assert.Equal(t, []string{"en", "pt-BR"}, openidConfig.UILocalesSupported)
+	assert.Equal(t, []string{"en", "ja"}, openidConfig.ClaimsLocalesSupported)
+	assert.Equal(t, []goidc.PromptType{goidc.PromptTypeLogin, goidc.PromptTypeCreate}, openidConfig.PromptValuesSupported)
 }
 
 func TestGetOpenIDConfiguration_WithPAR(t *testing.T) {
@@ -90,6 +101,55 @@ func TestGetOpenIDConfiguration_WithPAR(t *testing.T) {
 	assert.True(t, openidConfig.PARIsRequired)
 }
 
+func TestGetOpenIDConfiguration_WithDeviceGrant(t *testing.T) {
+	// Given.
+	ctx := &oidc.Context{
+		Configuration: oidc.Configuration{
+			Host:                 "https://example.com",
+			DeviceGrantIsEnabled: true,
+		},
+	}
+
+	// When.
+	openidConfig := wellKnown(ctx)
+
+	// Then.
+	assert.Equal(t, ctx.Host+string(goidc.EndpointDeviceAuthorization), openidConfig.DeviceAuthorizationEndpoint)
+}
+
+func TestGetOpenIDConfiguration_WithRPInitiatedLogout(t *testing.T) {
+	// Given.
+	ctx := &oidc.Context{
+		Configuration: oidc.Configuration{
+			Host:                "https://example.com",
+			EndSessionIsEnabled: true,
+		},
+	}
+
+	// When.
+	openidConfig := wellKnown(ctx)
+
+	// Then.
+	assert.Equal(t, ctx.Host+string(goidc.EndpointEndSession), openidConfig.EndSessionEndpoint)
+}
+
+func TestGetOpenIDConfiguration_WithBackChannelLogout(t *testing.T) {
+	// Given.
+	ctx := &oidc.Context{
+		Configuration: oidc.Configuration{
+			BackChannelLogoutIsEnabled: true,
+			SIDClaimIsEnabled:          true,
+		},
+	}
+
+	// When.
+	openidConfig := wellKnown(ctx)
+
+	// Then.
+	assert.True(t, openidConfig.BackChannelLogoutIsSupported)
+	assert.True(t, openidConfig.BackChannelLogoutSessionIsSupported)
+}
+
 func TestGetOpenIDConfiguration_WithJAR(t *testing.T) {
 	// Given.
 	ctx := &oidc.Context{
@@ -143,3 +203,177 @@ func TestGetOpenIDConfiguration_WithDPoP(t *testing.T) {
 	// Then.
 	assert.Equal(t, ctx.DPoPSignatureAlgorithms, openidConfig.DPoPSignatureAlgorithms)
 }
+
+func TestHandlerFederation(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.FederationIsEnabled = true
+	ctx.FederationEntityStatementLifetimeSecs = 60
+	ctx.FederationAuthorityHints = []string{"https://anchor.com"}
+
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointFederation, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerFederation(&ctx.Configuration)(w, req)
+
+	// Then.
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/entity-statement+jwt", w.Header().Get("Content-Type"))
+
+	entityConfig := w.Body.String()
+	parsedEntityConfig, err := jwt.ParseSigned(entityConfig, []jose.SignatureAlgorithm{jose.RS256})
+	require.Nil(t, err)
+	assert.Equal(t, "entity-statement+jwt", parsedEntityConfig.Headers[0].ExtraHeaders["typ"])
+
+	claims := oidc.SafeClaims(t, entityConfig, oidc.TestServerPrivateJWK)
+	assert.Equal(t, ctx.Host, claims[goidc.ClaimIssuer])
+	assert.Equal(t, ctx.Host, claims[goidc.ClaimSubject])
+	assert.Equal(t, []any{"https://anchor.com"}, claims["authority_hints"])
+	assert.Contains(t, claims, "jwks")
+
+	metadata := claims["metadata"].(map[string]any)
+	openidProvider := metadata["openid_provider"].(map[string]any)
+	assert.Equal(t, ctx.Host, openidProvider["issuer"])
+
+	// The entity configuration must be verifiable as a trust chain anchored at the server.
+	_, err = oidc.ValidateTrustChain(
+		[]string{entityConfig, entityConfig},
+		ctx.Host,
+		[]goidc.TrustAnchor{{EntityID: ctx.Host, JWKS: ctx.PublicKeys()}},
+	)
+	assert.Nil(t, err)
+}
+
+func TestHandlerProtectedResource(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ProtectedResourceMetadataIsEnabled = true
+	ctx.ProtectedResource = "https://resource.example.com"
+	ctx.ProtectedResourceSignatureAlgorithms = []jose.SignatureAlgorithm{jose.PS256}
+	ctx.DPoPIsEnabled = true
+	ctx.DPoPSignatureAlgorithms = []jose.SignatureAlgorithm{jose.ES256}
+
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointProtectedResource, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerProtectedResource(&ctx.Configuration)(w, req)
+
+	// Then.
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var metadata map[string]any
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &metadata))
+	assert.Equal(t, map[string]any{
+		"resource":                              "https://resource.example.com",
+		"authorization_servers":                 []any{ctx.Host},
+		"scopes_supported":                      []any{goidc.ScopeOpenID.ID, oidc.TestScope1.ID, oidc.TestScope2.ID},
+		"bearer_methods_supported":              []any{"header"},
+		"resource_signing_alg_values_supported": []any{"PS256"},
+		"dpop_signing_alg_values_supported":     []any{"ES256"},
+	}, metadata)
+}
+
+func TestHandlerWellKnown_WithSignedMetadata(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.SignedMetadataIsEnabled = true
+	ctx.SignedMetadataSignatureKeyID = oidc.TestServerPrivateJWK.KeyID
+
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointWellKnown, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerWellKnown(&ctx.Configuration)(w, req)
+
+	// Then.
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var openidConfig openIDConfiguration
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &openidConfig))
+	require.NotEmpty(t, openidConfig.SignedMetadata)
+
+	claims := oidc.SafeClaims(t, openidConfig.SignedMetadata, oidc.TestServerPrivateJWK)
+	assert.Equal(t, openidConfig.Issuer, claims[goidc.ClaimIssuer])
+	assert.Equal(t, openidConfig.Issuer, claims["issuer"])
+	assert.Equal(t, openidConfig.AuthorizationEndpoint, claims["authorization_endpoint"])
+	assert.Equal(t, openidConfig.TokenEndpoint, claims["token_endpoint"])
+	assert.Equal(t, openidConfig.JWKSEndpoint, claims["jwks_uri"])
+	assert.NotContains(t, claims, "signed_metadata")
+}
+
+func TestHandlerJWKS_WithCacheMaxAge(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JWKSCacheMaxAgeSecs = 3600
+
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointJSONWebKeySet, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerJWKS(&ctx.Configuration)(w, req)
+
+	// Then.
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=3600", w.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	var jwks jose.JSONWebKeySet
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	assert.Len(t, jwks.Keys, len(ctx.PublicKeys().Keys))
+}
+
+func TestHandlerJWKS_MatchingETag(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JWKSCacheMaxAgeSecs = 3600
+
+	w := httptest.NewRecorder()
+	HandlerJWKS(&ctx.Configuration)(w, httptest.NewRequest(http.MethodGet, goidc.EndpointJSONWebKeySet, nil))
+	etag := w.Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointJSONWebKeySet, nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	w = httptest.NewRecorder()
+
+	// When.
+	HandlerJWKS(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Empty(t, w.Body.Bytes())
+}
+
+func TestHandlerJWKS_ETagDoesNotMatch(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.JWKSCacheMaxAgeSecs = 3600
+
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointJSONWebKeySet, nil)
+	req.Header.Set("If-None-Match", `"outdated"`)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerJWKS(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Body.Bytes())
+}
+
+func TestHandlerJWKS_CacheIsDisabled(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointJSONWebKeySet, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerJWKS(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
@@ -0,0 +1,2 @@
+// Package health implements the liveness and readiness probes of the server.
+package health
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// pingTimeout limits how long each store can take to answer a ping.
+const pingTimeout = 2 * time.Second
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type response struct {
+	Status string `json:"status"`
+}
+
+// HandlerLiveness reports the server is running.
+func HandlerLiveness(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+		if err := ctx.Write(response{Status: statusOK}, http.StatusOK); err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
+
+// HandlerReadiness reports whether the server can handle requests, that is,
+// whether all the stores implementing [goidc.Pinger] are reachable.
+func HandlerReadiness(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		status, resp := http.StatusOK, response{Status: statusOK}
+		if err := ping(ctx); err != nil {
+			ctx.Logger().WarnContext(r.Context(), "a store is not reachable", "error", err)
+			status, resp = http.StatusServiceUnavailable, response{Status: statusUnavailable}
+		}
+
+		if err := ctx.Write(resp, status); err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
+
+func ping(ctx *oidc.Context) error {
+	for _, pinger := range pingers(ctx) {
+		pingCtx, cancel := context.WithTimeout(ctx.Request().Context(), pingTimeout)
+		err := pinger.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pingers returns the stores configured that can be pinged.
+func pingers(ctx *oidc.Context) []goidc.Pinger {
+	stores := []any{
+		ctx.ClientManager,
+		ctx.GrantSessionManager,
+		ctx.AuthnSessionManager,
+		ctx.DeviceSessionManager,
+		ctx.LogoutSessionManager,
+		ctx.DPoPNonceStore,
+		ctx.IntrospectionCache,
+	}
+
+	var pingers []goidc.Pinger
+	for _, store := range stores {
+		if pinger, ok := store.(goidc.Pinger); ok {
+			pingers = append(pingers, pinger)
+		}
+	}
+	return append(pingers, ctx.ReadinessCheckers...)
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/storage/redis"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerLiveness(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointHealth, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerLiveness(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestHandlerReadiness(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ReadinessCheckers = []goidc.Pinger{pingerFunc(func(context.Context) error { return nil })}
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointReady, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerReadiness(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestHandlerReadiness_CheckerFails(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ReadinessCheckers = []goidc.Pinger{pingerFunc(func(context.Context) error {
+		return errors.New("connection refused")
+	})}
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointReady, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerReadiness(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"unavailable"}`, w.Body.String())
+}
+
+func TestHandlerReadiness_StoreFails(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ClientManager = pingableClientManager{
+		ClientManager: ctx.ClientManager,
+		err:           errors.New("connection refused"),
+	}
+	req := httptest.NewRequest(http.MethodGet, goidc.EndpointReady, nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerReadiness(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandlerReadiness_RedisStore(t *testing.T) {
+	// Given.
+	mr := miniredis.RunT(t)
+	ctx := oidc.NewTestContext(t)
+	ctx.GrantSessionManager = redis.NewGrantSessionManager(goredis.NewClient(&goredis.Options{Addr: mr.Addr()}))
+
+	// When.
+	w := httptest.NewRecorder()
+	HandlerReadiness(&ctx.Configuration)(w, httptest.NewRequest(http.MethodGet, goidc.EndpointReady, nil))
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Given.
+	mr.Close()
+
+	// When.
+	w = httptest.NewRecorder()
+	HandlerReadiness(&ctx.Configuration)(w, httptest.NewRequest(http.MethodGet, goidc.EndpointReady, nil))
+
+	// Then.
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "the server is not ready when redis is down")
+}
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+type pingableClientManager struct {
+	goidc.ClientManager
+	err error
+}
+
+func (m pingableClientManager) Ping(context.Context) error {
+	return m.err
+}
@@ -0,0 +1,125 @@
+package jwksutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Cache fetches the JWKS published by issuers and keeps them for a while, so
+// the issuers are not contacted on every request.
+type Cache struct {
+	httpClient *http.Client
+	ttl        time.Duration
+	// refetchInterval is the minimum time between two fetches of the same
+	// JWKS when a key ID is not found in it.
+	refetchInterval time.Duration
+	mu              sync.Mutex
+	entries         map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	jwks      jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// NewCache creates a cache that keeps each JWKS during ttl.
+// A JWKS missing a key ID is fetched again before ttl expires, but at most
+// once every refetchInterval.
+func NewCache(ttl, refetchInterval time.Duration) *Cache {
+	return &Cache{
+		httpClient:      &http.Client{Timeout: fetchTimeout},
+		ttl:             ttl,
+		refetchInterval: refetchInterval,
+		entries:         make(map[string]cacheEntry),
+	}
+}
+
+// Fetch returns the JWKS published at uri. The JWKS is only fetched if it's
+// not cached yet or if the cached one has expired.
+func (c *Cache) Fetch(ctx context.Context, uri string) (jose.JSONWebKeySet, error) {
+	entry, ok := c.entry(uri)
+	if ok && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.jwks, nil
+	}
+
+	return c.refresh(ctx, uri)
+}
+
+// Keys returns the keys published at uri identified by keyID or all of them if
+// keyID is empty.
+// If no key is identified by keyID, the issuer may have rotated its keys, so
+// the JWKS is fetched again unless it was fetched less than the refetch
+// interval ago.
+func (c *Cache) Keys(ctx context.Context, uri, keyID string) ([]jose.JSONWebKey, error) {
+	jwks, err := c.Fetch(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyID == "" {
+		return jwks.Keys, nil
+	}
+
+	if keys := jwks.Key(keyID); len(keys) != 0 {
+		return keys, nil
+	}
+
+	if entry, _ := c.entry(uri); time.Since(entry.fetchedAt) < c.refetchInterval {
+		return nil, nil
+	}
+
+	jwks, err = c.refresh(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return jwks.Key(keyID), nil
+}
+
+func (c *Cache) entry(uri string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[uri]
+	return entry, ok
+}
+
+func (c *Cache) refresh(ctx context.Context, uri string) (jose.JSONWebKeySet, error) {
+	jwks, err := c.fetch(ctx, uri)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	c.mu.Lock()
+	c.entries[uri] = cacheEntry{jwks: jwks, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return jwks, nil
+}
+
+func (c *Cache) fetch(ctx context.Context, uri string) (jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return jose.JSONWebKeySet{}, errors.New("could not fetch the jwks")
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	return jwks, nil
+}
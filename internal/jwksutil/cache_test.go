@@ -0,0 +1,125 @@
+package jwksutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/jwksutil"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheFetch(t *testing.T) {
+	// Given.
+	var requests atomic.Int32
+	server := jwksServer(t, &requests)
+	cache := jwksutil.NewCache(time.Minute, time.Minute)
+
+	// When.
+	jwks, err := cache.Fetch(context.Background(), server.URL)
+	require.Nil(t, err)
+	_, err = cache.Fetch(context.Background(), server.URL)
+	require.Nil(t, err)
+
+	// Then.
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "random_key_id", jwks.Keys[0].KeyID)
+	assert.Equal(t, int32(1), requests.Load(), "the jwks should be fetched only once while cached")
+}
+
+func TestCacheFetch_ExpiredEntry(t *testing.T) {
+	// Given.
+	var requests atomic.Int32
+	server := jwksServer(t, &requests)
+	cache := jwksutil.NewCache(0, 0)
+
+	// When.
+	_, err := cache.Fetch(context.Background(), server.URL)
+	require.Nil(t, err)
+	_, err = cache.Fetch(context.Background(), server.URL)
+	require.Nil(t, err)
+
+	// Then.
+	assert.Equal(t, int32(2), requests.Load(), "expired jwks should be fetched again")
+}
+
+func TestCacheFetch_CanceledContext(t *testing.T) {
+	// Given.
+	var requests atomic.Int32
+	server := jwksServer(t, &requests)
+	cache := jwksutil.NewCache(time.Minute, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// When.
+	_, err := cache.Fetch(ctx, server.URL)
+
+	// Then.
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(0), requests.Load())
+}
+
+func TestCacheKeys_UnknownKeyID(t *testing.T) {
+	// Given.
+	var requests atomic.Int32
+	var keyID atomic.Value
+	keyID.Store("old_key_id")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		privateJWK := oidc.PrivatePS256JWK(t, keyID.Load().(string))
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{privateJWK.Public()}})
+	}))
+	defer server.Close()
+	cache := jwksutil.NewCache(time.Minute, 0)
+
+	_, err := cache.Keys(context.Background(), server.URL, "old_key_id")
+	require.Nil(t, err)
+	// The issuer rotates its keys.
+	keyID.Store("new_key_id")
+
+	// When.
+	keys, err := cache.Keys(context.Background(), server.URL, "new_key_id")
+
+	// Then.
+	require.Nil(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "new_key_id", keys[0].KeyID)
+	assert.Equal(t, int32(2), requests.Load(), "the jwks should be fetched again for an unknown key id")
+}
+
+func TestCacheKeys_UnknownKeyIDFetchedRecently(t *testing.T) {
+	// Given.
+	var requests atomic.Int32
+	server := jwksServer(t, &requests)
+	cache := jwksutil.NewCache(time.Minute, time.Minute)
+
+	// When.
+	_, err := cache.Keys(context.Background(), server.URL, "random_key_id")
+	require.Nil(t, err)
+	keys, err := cache.Keys(context.Background(), server.URL, "unknown_key_id")
+
+	// Then.
+	require.Nil(t, err)
+	assert.Empty(t, keys)
+	assert.Equal(t, int32(1), requests.Load(), "the jwks should not be fetched again before the refetch interval")
+}
+
+func jwksServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	privateJWK := oidc.PrivatePS256JWK(t, "random_key_id")
+	jwk := privateJWK.Public()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,8 @@
+package jwksutil
+
+import "time"
+
+const (
+	// fetchTimeout limits how long fetching the keys of an issuer can take.
+	fetchTimeout = 5 * time.Second
+)
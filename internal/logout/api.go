@@ -0,0 +1,31 @@
+package logout
+
+import (
+	"net/http"
+
+	"github.com/luikyv/go-oidc/internal/oidc"
+)
+
+func HandlerEndSession(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		req := newEndSessionRequest(ctx.Request())
+		if err := endSession(ctx, req); err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
+
+// HandlerCallback resumes a logout that was interrupted to interact with the
+// user.
+func HandlerCallback(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		callbackID := ctx.Request().PathValue("callback")
+		if err := continueLogout(ctx, callbackID); err != nil {
+			ctx.WriteError(err)
+		}
+	}
+}
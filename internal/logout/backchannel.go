@@ -0,0 +1,190 @@
+package logout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+var httpClient = &http.Client{Timeout: notificationTimeout}
+
+// NotifyClients sends a logout token to the back-channel logout URI of every
+// client the user has a grant session with.
+// If sid is informed, only the clients that took part in that session of the
+// user are notified.
+// Clients are notified concurrently and a failure to notify one of them
+// doesn't prevent the others from being notified. The failures are returned
+// together once all the clients were notified.
+func NotifyClients(ctx *oidc.Context, subject string, sid string) error {
+	clientSessionIDs, err := clientsToNotify(ctx, subject, sid)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(clientSessionIDs))
+	i := 0
+	for clientID, sessionIDs := range clientSessionIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := notifyClient(ctx, clientID, subject, sid, sessionIDs); err != nil {
+				errs[i] = fmt.Errorf("could not notify the client %s: %w", clientID, err)
+			}
+		}(i)
+		i++
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// clientsToNotify returns the IDs of the clients with grant sessions for the
+// user mapped to the session IDs in which their grant sessions were created.
+func clientsToNotify(ctx *oidc.Context, subject string, sid string) (map[string][]string, error) {
+	grantSessions, err := ctx.GrantSessionsBySubject(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSessionIDs := map[string][]string{}
+	for _, grantSession := range grantSessions {
+		if sid != "" && grantSession.SessionID != sid {
+			continue
+		}
+
+		sessionIDs := clientSessionIDs[grantSession.ClientID]
+		if grantSession.SessionID != "" && !slices.Contains(sessionIDs, grantSession.SessionID) {
+			sessionIDs = append(sessionIDs, grantSession.SessionID)
+		}
+		clientSessionIDs[grantSession.ClientID] = sessionIDs
+	}
+
+	return clientSessionIDs, nil
+}
+
+// notifyClient sends logout tokens to the back-channel logout URI of the client.
+// If the client requires the "sid" claim and sid is not informed, one logout
+// token is sent for each session in which the client's grant sessions were
+// created.
+func notifyClient(
+	ctx *oidc.Context,
+	clientID string,
+	subject string,
+	sid string,
+	sessionIDs []string,
+) error {
+	client, err := ctx.Client(clientID)
+	if err != nil {
+		return err
+	}
+
+	if client.BackChannelLogoutURI == "" {
+		return nil
+	}
+
+	if !client.BackChannelLogoutSessionIsRequired || sid != "" {
+		return sendLogoutToken(ctx, client, subject, sid)
+	}
+
+	if len(sessionIDs) == 0 {
+		return errors.New("the client requires the session ID in logout tokens, but it is not known")
+	}
+
+	var errs []error
+	for _, sessionID := range sessionIDs {
+		errs = append(errs, sendLogoutToken(ctx, client, subject, sessionID))
+	}
+	return errors.Join(errs...)
+}
+
+func sendLogoutToken(ctx *oidc.Context, client *goidc.Client, subject string, sid string) error {
+	logoutToken, err := makeLogoutToken(ctx, client, subject, sid)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"logout_token": {logoutToken}}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		client.BackChannelLogoutURI,
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// makeLogoutToken creates a logout token signed with the same key used to
+// sign ID tokens for the client.
+func makeLogoutToken(
+	ctx *oidc.Context,
+	client *goidc.Client,
+	subject string,
+	sid string,
+) (
+	string,
+	error,
+) {
+	privateJWK, oauthErr := ctx.IDTokenSigningKey(client)
+	if oauthErr != nil {
+		return "", oauthErr
+	}
+
+	timestampNow := time.Now().Unix()
+	claims := map[string]any{
+		goidc.ClaimTokenID:  uuid.NewString(),
+		goidc.ClaimIssuer:   ctx.Host,
+		goidc.ClaimSubject:  subject,
+		goidc.ClaimAudience: client.ID,
+		goidc.ClaimIssuedAt: timestampNow,
+		goidc.ClaimExpiry:   timestampNow + logoutTokenLifetimeSecs,
+		goidc.ClaimEvents: map[string]any{
+			goidc.EventBackChannelLogout: map[string]any{},
+		},
+	}
+
+	if sid != "" {
+		claims[goidc.ClaimSessionID] = sid
+	}
+
+	signerOpts := (&jose.SignerOptions{}).WithType("logout+jwt")
+	if privateJWK.KeyID != "" {
+		signerOpts = signerOpts.WithHeader("kid", privateJWK.KeyID)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+		signerOpts,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return jwt.Signed(signer).Claims(claims).Serialize()
+}
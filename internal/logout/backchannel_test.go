@@ -0,0 +1,220 @@
+package logout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyClients(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	logoutTokens := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logoutTokens <- r.PostFormValue("logout_token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	setUpLogoutClient(t, ctx, oidc.TestClientID, server.URL)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	err := NotifyClients(ctx, "random_subject", "random_sid")
+
+	// Then.
+	require.Nil(t, err)
+	require.Len(t, logoutTokens, 1)
+
+	claims := oidc.SafeClaims(t, <-logoutTokens, oidc.TestServerPrivateJWK)
+	assert.Equal(t, ctx.Host, claims["iss"])
+	assert.Equal(t, "random_subject", claims["sub"])
+	assert.Equal(t, oidc.TestClientID, claims["aud"])
+	assert.Equal(t, "random_sid", claims["sid"])
+	assert.NotEmpty(t, claims["jti"])
+	assert.Equal(t, map[string]any{goidc.EventBackChannelLogout: map[string]any{}}, claims["events"])
+	assert.Nil(t, claims["nonce"], "logout tokens must not contain a nonce")
+}
+
+func TestNotifyClients_SymmetricSignature(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.IDTokenSymmetricSignatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+	logoutTokens := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logoutTokens <- r.PostFormValue("logout_token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	setUpLogoutClient(t, ctx, oidc.TestClientID, server.URL)
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.Secret = "random_secret_with_at_least_32_bytes"
+	client.IDTokenSignatureAlgorithm = jose.HS256
+	require.Nil(t, ctx.SaveClient(client))
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	err = NotifyClients(ctx, "random_subject", "random_sid")
+
+	// Then.
+	require.Nil(t, err)
+	require.Len(t, logoutTokens, 1)
+
+	parsedToken, err := jwt.ParseSigned(<-logoutTokens, []jose.SignatureAlgorithm{jose.HS256})
+	require.Nil(t, err)
+	assert.Empty(t, parsedToken.Headers[0].KeyID, "symmetric logout tokens must not have a key ID")
+
+	var claims map[string]any
+	require.Nil(t, parsedToken.Claims([]byte(client.Secret), &claims), "the logout token must verify with the client secret")
+	assert.Equal(t, "random_subject", claims["sub"])
+}
+
+func TestNotifyClients_OnlyClientsOfTheSessionAreNotified(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	var mu sync.Mutex
+	var notifiedPaths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		notifiedPaths = append(notifiedPaths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	setUpLogoutClient(t, ctx, "client_one", server.URL+"/one")
+	setUpLogoutClient(t, ctx, "client_two", server.URL+"/two")
+	saveGrantSession(t, ctx, "client_one", "random_sid")
+	saveGrantSession(t, ctx, "client_two", "another_sid")
+
+	// When.
+	err := NotifyClients(ctx, "random_subject", "random_sid")
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, []string{"/one"}, notifiedPaths)
+}
+
+func TestNotifyClients_FailingClientDoesNotBlockTheOthers(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	var mu sync.Mutex
+	var notifiedPaths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		notifiedPaths = append(notifiedPaths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	setUpLogoutClient(t, ctx, "failing_client", server.URL+"/failing")
+	setUpLogoutClient(t, ctx, "healthy_client", server.URL+"/healthy")
+	saveGrantSession(t, ctx, "failing_client", "random_sid")
+	saveGrantSession(t, ctx, "healthy_client", "random_sid")
+
+	// When.
+	err := NotifyClients(ctx, "random_subject", "")
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failing_client")
+	assert.NotContains(t, err.Error(), "healthy_client")
+	assert.ElementsMatch(t, []string{"/failing", "/healthy"}, notifiedPaths)
+}
+
+func TestNotifyClients_ClientWithoutLogoutURIIsSkipped(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	err := NotifyClients(ctx, "random_subject", "random_sid")
+
+	// Then.
+	assert.Nil(t, err)
+}
+
+func TestNotifyClients_SessionRequired(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	logoutTokens := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logoutTokens <- r.PostFormValue("logout_token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	setUpLogoutClient(t, ctx, oidc.TestClientID, server.URL)
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.BackChannelLogoutSessionIsRequired = true
+	require.Nil(t, ctx.SaveClient(client))
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	err = NotifyClients(ctx, "random_subject", "")
+
+	// Then.
+	require.Nil(t, err)
+	require.Len(t, logoutTokens, 1)
+
+	claims := oidc.SafeClaims(t, <-logoutTokens, oidc.TestServerPrivateJWK)
+	assert.Equal(t, "random_sid", claims["sid"], "the session of the grant session should be informed")
+}
+
+func TestNotifyClients_SessionRequiredButUnknown(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	notified := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notified = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	setUpLogoutClient(t, ctx, oidc.TestClientID, server.URL)
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.BackChannelLogoutSessionIsRequired = true
+	require.Nil(t, ctx.SaveClient(client))
+	saveGrantSession(t, ctx, oidc.TestClientID, "")
+
+	// When.
+	err = NotifyClients(ctx, "random_subject", "")
+
+	// Then.
+	assert.NotNil(t, err)
+	assert.False(t, notified, "a logout token without sid must not be sent")
+}
+
+func setUpLogoutClient(t *testing.T, ctx *oidc.Context, clientID string, logoutURI string) {
+	t.Helper()
+
+	client := oidc.NewTestClient(t)
+	client.ID = clientID
+	client.BackChannelLogoutURI = logoutURI
+	require.Nil(t, ctx.SaveClient(client))
+}
+
+func saveGrantSession(t *testing.T, ctx *oidc.Context, clientID string, sid string) {
+	t.Helper()
+
+	require.Nil(t, ctx.SaveGrantSession(&goidc.GrantSession{
+		ID:        clientID + "_grant_session_id",
+		ClientID:  clientID,
+		Subject:   "random_subject",
+		SessionID: sid,
+	}))
+}
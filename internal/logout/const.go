@@ -0,0 +1,12 @@
+package logout
+
+import "time"
+
+const (
+	logoutTokenLifetimeSecs int64 = 120
+	callbackIDLength        int   = 20
+	// notificationTimeout limits how long the server waits for a client to
+	// acknowledge a logout token, so unresponsive clients don't hold the
+	// others.
+	notificationTimeout = 5 * time.Second
+)
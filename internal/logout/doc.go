@@ -0,0 +1,3 @@
+// Package logout implements the logic to inform clients that the session of
+// a user ended.
+package logout
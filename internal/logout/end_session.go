@@ -0,0 +1,215 @@
+package logout
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// endSession logs the user out as described in OpenID Connect RP-Initiated
+// Logout 1.0.
+// The grant sessions of the user are deleted, so the tokens issued to the
+// clients can no longer be refreshed nor introspected, and the user is then
+// redirected to the post logout redirect URI, if informed.
+func endSession(ctx *oidc.Context, req endSessionRequest) oidc.Error {
+	session, err := newLogoutSession(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return logout(ctx, session)
+}
+
+// continueLogout resumes a logout that was interrupted to interact with the
+// user.
+func continueLogout(ctx *oidc.Context, callbackID string) oidc.Error {
+	session, err := ctx.LogoutSessionByCallbackID(callbackID)
+	if err != nil {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid logout session")
+	}
+
+	if session.IsExpired() {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "session timeout")
+	}
+
+	return logout(ctx, session)
+}
+
+func logout(ctx *oidc.Context, session *goidc.LogoutSession) oidc.Error {
+	if ctx.LogoutFunc != nil {
+		switch ctx.LogoutFunc(ctx, session) {
+		case goidc.StatusInProgress:
+			if err := ctx.SaveLogoutSession(session); err != nil {
+				return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+			}
+			return nil
+		case goidc.StatusFailure:
+			if err := ctx.DeleteLogoutSession(session.ID); err != nil {
+				return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+			}
+			return oidc.NewError(oidc.ErrorCodeAccessDenied, "the logout was denied")
+		}
+	}
+
+	if err := ctx.DeleteLogoutSession(session.ID); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	if err := endUserSessions(ctx, session); err != nil {
+		return err
+	}
+
+	if session.PostLogoutRedirectURI == "" {
+		if err := ctx.RenderHTML(loggedOutTemplate, nil); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		return nil
+	}
+
+	ctx.Redirect(postLogoutRedirectURL(session))
+	return nil
+}
+
+func newLogoutSession(
+	ctx *oidc.Context,
+	req endSessionRequest,
+) (
+	*goidc.LogoutSession,
+	oidc.Error,
+) {
+	callbackID, err := strutil.Random(callbackIDLength)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	session := &goidc.LogoutSession{
+		ID:                    uuid.NewString(),
+		CallbackID:            callbackID,
+		ExpiresAtTimestamp:    time.Now().Unix() + ctx.AuthenticationSessionTimeoutSecs,
+		ClientID:              req.ClientID,
+		PostLogoutRedirectURI: req.PostLogoutRedirectURI,
+		State:                 req.State,
+	}
+
+	if req.IDTokenHint != "" {
+		claims, err := idTokenHintClaims(ctx, req.IDTokenHint)
+		if err != nil {
+			return nil, err
+		}
+
+		if session.ClientID == "" && len(claims.Audience) == 1 {
+			session.ClientID = claims.Audience[0]
+		}
+
+		if session.ClientID != "" && !claims.Audience.Contains(session.ClientID) {
+			return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest,
+				"the id_token_hint was not issued to the client")
+		}
+
+		session.Subject = claims.Subject
+		session.SessionID = claims.SessionID
+	}
+
+	if session.PostLogoutRedirectURI == "" {
+		return session, nil
+	}
+
+	// The client must be known to validate the post logout redirect URI.
+	if session.ClientID == "" {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest,
+			"client_id or id_token_hint is required when post_logout_redirect_uri is informed")
+	}
+
+	client, err := ctx.Client(session.ClientID)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidClient, "invalid client")
+	}
+
+	if !client.IsPostLogoutRedirectURIAllowed(session.PostLogoutRedirectURI) {
+		return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid post_logout_redirect_uri")
+	}
+
+	return session, nil
+}
+
+type idTokenClaims struct {
+	jwt.Claims
+	SessionID string `json:"sid,omitempty"`
+}
+
+// idTokenHintClaims validates the id_token_hint and returns its claims.
+// The hint must be an ID token previously issued by the server, but it may
+// be expired.
+func idTokenHintClaims(ctx *oidc.Context, idTokenHint string) (idTokenClaims, oidc.Error) {
+	var claims idTokenClaims
+	if err := ctx.ParseIDTokenHint(idTokenHint, &claims); err != nil {
+		return idTokenClaims{}, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid id_token_hint")
+	}
+
+	if claims.Issuer != ctx.Host || claims.Subject == "" {
+		return idTokenClaims{}, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid id_token_hint")
+	}
+
+	return claims, nil
+}
+
+// endUserSessions deletes the grant sessions of the user. If the session ID
+// is known, only the grant sessions created during that session are deleted.
+// When back-channel logout is enabled, the clients are notified before the
+// grant sessions are deleted, since they are used to find which clients must
+// be notified.
+// Nothing is done if the grant session manager cannot find the grant sessions
+// of a user.
+func endUserSessions(ctx *oidc.Context, session *goidc.LogoutSession) oidc.Error {
+	if session.Subject == "" || !ctx.GrantSessionsCanBeFoundBySubject() {
+		return nil
+	}
+
+	if ctx.BackChannelLogoutIsEnabled {
+		// A client that fails to process the logout token must not prevent the
+		// user from logging out.
+		_ = NotifyClients(ctx, session.Subject, session.SessionID)
+	}
+
+	grantSessions, err := ctx.GrantSessionsBySubject(session.Subject)
+	if err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	for _, grantSession := range grantSessions {
+		if session.SessionID != "" && grantSession.SessionID != session.SessionID {
+			continue
+		}
+		if err := ctx.DeleteGrantSession(grantSession.ID); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func postLogoutRedirectURL(session *goidc.LogoutSession) string {
+	if session.State == "" {
+		return session.PostLogoutRedirectURI
+	}
+
+	redirectURL, _ := url.Parse(session.PostLogoutRedirectURI)
+	query := redirectURL.Query()
+	query.Set("state", session.State)
+	redirectURL.RawQuery = query.Encode()
+	return redirectURL.String()
+}
+
+var loggedOutTemplate string = `
+	<!-- This HTML document is displayed when there is no post logout redirect URI to send the user to. -->
+	<html>
+	<body>
+		<p>You have been logged out.</p>
+	</body>
+	</html>
+`
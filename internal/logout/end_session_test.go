@@ -0,0 +1,364 @@
+package logout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndSession(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+	saveGrantSession(t, ctx, "another_client_id", "another_sid")
+
+	// When.
+	err := endSession(ctx, endSessionRequest{
+		IDTokenHint:           idTokenHint(t, ctx, "random_sid"),
+		PostLogoutRedirectURI: "https://example.com/logged_out",
+		State:                 "random_state",
+	})
+
+	// Then.
+	require.Nil(t, err)
+
+	resp := ctx.Response().(*httptest.ResponseRecorder)
+	assert.Equal(t, http.StatusSeeOther, resp.Code)
+	redirectURL, _ := url.Parse(resp.Header().Get("Location"))
+	assert.Equal(t, "https://example.com/logged_out", redirectURL.Scheme+"://"+redirectURL.Host+redirectURL.Path)
+	assert.Equal(t, "random_state", redirectURL.Query().Get("state"))
+
+	grantSessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, grantSessions, 1, "only the grant sessions of the session informed should be deleted")
+	assert.Equal(t, "another_sid", grantSessions[0].SessionID)
+}
+
+func TestEndSession_WithoutPostLogoutRedirectURI(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	err := endSession(ctx, endSessionRequest{
+		IDTokenHint: idTokenHint(t, ctx, ""),
+	})
+
+	// Then.
+	require.Nil(t, err)
+	resp := ctx.Response().(*httptest.ResponseRecorder)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/html", resp.Header().Get("Content-Type"))
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+func TestEndSession_PostLogoutRedirectURINotRegistered(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	err := endSession(ctx, endSessionRequest{
+		IDTokenHint:           idTokenHint(t, ctx, "random_sid"),
+		PostLogoutRedirectURI: "https://attacker.com",
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1, "the user must not be logged out")
+}
+
+func TestEndSession_PostLogoutRedirectURIWithoutClient(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+
+	// When.
+	err := endSession(ctx, endSessionRequest{
+		PostLogoutRedirectURI: "https://example.com/logged_out",
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestEndSession_IDTokenHintIssuedToAnotherClient(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+
+	// When.
+	err := endSession(ctx, endSessionRequest{
+		IDTokenHint: idTokenHint(t, ctx, "random_sid"),
+		ClientID:    "another_client_id",
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestEndSession_InvalidIDTokenHint(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	privateJWK := oidc.PrivateRS256JWK(t, oidc.TestKeyID)
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: privateJWK.Key},
+		(&jose.SignerOptions{}).WithHeader("kid", privateJWK.KeyID),
+	)
+	require.Nil(t, err)
+	forgedIDToken, err := jwt.Signed(signer).Claims(map[string]any{
+		goidc.ClaimIssuer:   ctx.Host,
+		goidc.ClaimSubject:  "random_subject",
+		goidc.ClaimAudience: oidc.TestClientID,
+	}).Serialize()
+	require.Nil(t, err)
+
+	// When.
+	oauthErr := endSession(ctx, endSessionRequest{
+		IDTokenHint: forgedIDToken,
+	})
+
+	// Then.
+	require.NotNil(t, oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+}
+
+func TestEndSession_SymmetricIDTokenHint(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	ctx.IDTokenSymmetricSignatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.Secret = "random_secret_with_at_least_32_bytes"
+	client.IDTokenSignatureAlgorithm = jose.HS256
+	require.Nil(t, ctx.SaveClient(client))
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	oauthErr := endSession(ctx, endSessionRequest{
+		IDTokenHint:           symmetricIDTokenHint(t, ctx, client.Secret),
+		PostLogoutRedirectURI: "https://example.com/logged_out",
+	})
+
+	// Then.
+	require.Nil(t, oauthErr)
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+func TestEndSession_SymmetricIDTokenHintWithWrongSecret(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	ctx.IDTokenSymmetricSignatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.Secret = "random_secret_with_at_least_32_bytes"
+	client.IDTokenSignatureAlgorithm = jose.HS256
+	require.Nil(t, ctx.SaveClient(client))
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+
+	// When.
+	oauthErr := endSession(ctx, endSessionRequest{
+		IDTokenHint: symmetricIDTokenHint(t, ctx, "another_secret_with_at_least_32_bytes"),
+	})
+
+	// Then.
+	require.NotNil(t, oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1, "the user must not be logged out")
+}
+
+func TestEndSession_LogoutFuncInProgress(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+	var logoutSession *goidc.LogoutSession
+	ctx.LogoutFunc = func(ctx goidc.Context, session *goidc.LogoutSession) goidc.AuthnStatus {
+		logoutSession = session
+		_ = ctx.RenderHTML("<html><body>Do you want to log out?</body></html>", nil)
+		return goidc.StatusInProgress
+	}
+
+	// When.
+	err := endSession(ctx, endSessionRequest{
+		IDTokenHint:           idTokenHint(t, ctx, "random_sid"),
+		PostLogoutRedirectURI: "https://example.com/logged_out",
+	})
+
+	// Then.
+	require.Nil(t, err)
+	require.NotNil(t, logoutSession)
+	assert.Equal(t, oidc.TestClientID, logoutSession.ClientID)
+	assert.Equal(t, "random_subject", logoutSession.Subject)
+	assert.Equal(t, "random_sid", logoutSession.SessionID)
+
+	resp := ctx.Response().(*httptest.ResponseRecorder)
+	assert.Contains(t, resp.Body.String(), "Do you want to log out?")
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1, "the user should not be logged out yet")
+
+	logoutSessions := oidc.LogoutSessions(t, ctx)
+	require.Len(t, logoutSessions, 1, "the logout session should be saved so the logout can be resumed")
+	assert.NotEmpty(t, logoutSessions[0].CallbackID)
+}
+
+func TestContinueLogout(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+	ctx.LogoutFunc = func(ctx goidc.Context, session *goidc.LogoutSession) goidc.AuthnStatus {
+		if ctx.Request().FormValue("confirm") != "yes" {
+			return goidc.StatusInProgress
+		}
+		return goidc.StatusSuccess
+	}
+
+	err := endSession(ctx, endSessionRequest{
+		IDTokenHint:           idTokenHint(t, ctx, "random_sid"),
+		PostLogoutRedirectURI: "https://example.com/logged_out",
+		State:                 "random_state",
+	})
+	require.Nil(t, err)
+	logoutSessions := oidc.LogoutSessions(t, ctx)
+	require.Len(t, logoutSessions, 1)
+
+	ctx.Req = httptest.NewRequest(http.MethodPost, "/end_session/callback", strings.NewReader("confirm=yes"))
+	ctx.Req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx.Resp = httptest.NewRecorder()
+
+	// When.
+	err = continueLogout(ctx, logoutSessions[0].CallbackID)
+
+	// Then.
+	require.Nil(t, err)
+
+	resp := ctx.Response().(*httptest.ResponseRecorder)
+	assert.Equal(t, http.StatusSeeOther, resp.Code)
+	redirectURL, _ := url.Parse(resp.Header().Get("Location"))
+	assert.Equal(t, "random_state", redirectURL.Query().Get("state"))
+
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+	assert.Empty(t, oidc.LogoutSessions(t, ctx), "the logout session should be deleted once the logout finishes")
+}
+
+func TestContinueLogout_InvalidCallbackID(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+
+	// When.
+	err := continueLogout(ctx, "invalid_callback_id")
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func TestContinueLogout_ExpiredSession(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+	require.Nil(t, ctx.SaveLogoutSession(&goidc.LogoutSession{
+		ID:                 "random_session_id",
+		CallbackID:         "random_callback_id",
+		ExpiresAtTimestamp: time.Now().Unix() - 10,
+		Subject:            "random_subject",
+	}))
+
+	// When.
+	err := continueLogout(ctx, "random_callback_id")
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1, "the user must not be logged out")
+}
+
+func TestEndSession_LogoutFuncFailure(t *testing.T) {
+	// Given.
+	ctx := setUpEndSession(t)
+	saveGrantSession(t, ctx, oidc.TestClientID, "random_sid")
+	ctx.LogoutFunc = func(ctx goidc.Context, session *goidc.LogoutSession) goidc.AuthnStatus {
+		return goidc.StatusFailure
+	}
+
+	// When.
+	err := endSession(ctx, endSessionRequest{
+		IDTokenHint: idTokenHint(t, ctx, "random_sid"),
+	})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeAccessDenied, err.Code())
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1)
+}
+
+func setUpEndSession(t *testing.T) *oidc.Context {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.EndSessionIsEnabled = true
+
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.PostLogoutRedirectURIS = []string{"https://example.com/logged_out"}
+	require.Nil(t, ctx.SaveClient(client))
+
+	return ctx
+}
+
+// idTokenHint creates an expired ID token for the test client, since expired
+// ID tokens are also valid hints.
+func idTokenHint(t *testing.T, ctx *oidc.Context, sid string) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: oidc.TestServerPrivateJWK.Key},
+		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", oidc.TestServerPrivateJWK.KeyID),
+	)
+	require.Nil(t, err)
+
+	claims := map[string]any{
+		goidc.ClaimIssuer:   ctx.Host,
+		goidc.ClaimSubject:  "random_subject",
+		goidc.ClaimAudience: oidc.TestClientID,
+		goidc.ClaimIssuedAt: time.Now().Unix() - 120,
+		goidc.ClaimExpiry:   time.Now().Unix() - 60,
+	}
+	if sid != "" {
+		claims[goidc.ClaimSessionID] = sid
+	}
+
+	idToken, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.Nil(t, err)
+	return idToken
+}
+
+// symmetricIDTokenHint creates an ID token for the test client signed with
+// HS256 using the secret informed.
+func symmetricIDTokenHint(t *testing.T, ctx *oidc.Context, secret string) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.HS256, Key: []byte(secret)},
+		(&jose.SignerOptions{}).WithType("jwt"),
+	)
+	require.Nil(t, err)
+
+	idToken, err := jwt.Signed(signer).Claims(map[string]any{
+		goidc.ClaimIssuer:   ctx.Host,
+		goidc.ClaimSubject:  "random_subject",
+		goidc.ClaimAudience: oidc.TestClientID,
+		goidc.ClaimIssuedAt: time.Now().Unix(),
+		goidc.ClaimExpiry:   time.Now().Unix() + 60,
+	}).Serialize()
+	require.Nil(t, err)
+	return idToken
+}
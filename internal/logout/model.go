@@ -0,0 +1,21 @@
+package logout
+
+import "net/http"
+
+type endSessionRequest struct {
+	IDTokenHint           string
+	ClientID              string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+// newEndSessionRequest reads the parameters from either the query or the
+// body, since the end session endpoint accepts both GET and POST.
+func newEndSessionRequest(req *http.Request) endSessionRequest {
+	return endSessionRequest{
+		IDTokenHint:           req.FormValue("id_token_hint"),
+		ClientID:              req.FormValue("client_id"),
+		PostLogoutRedirectURI: req.FormValue("post_logout_redirect_uri"),
+		State:                 req.FormValue("state"),
+	}
+}
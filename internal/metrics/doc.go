@@ -0,0 +1,2 @@
+// Package metrics implements collectors for the metrics recorded by the server.
+package metrics
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	requestsTotalMetric   = "goidc_http_requests_total"
+	requestDurationMetric = "goidc_http_request_duration_seconds"
+	grantsTotalMetric     = "goidc_grants_total"
+)
+
+// durationBuckets are the upper bounds in seconds of the request duration
+// histogram. They are the same as the Prometheus client defaults.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	endpoint   string
+	statusCode int
+}
+
+type durationHistogram struct {
+	// bucketCounts holds the number of observations in each bucket, the
+	// cumulative counts are calculated when the metrics are exported.
+	bucketCounts []int64
+	count        int64
+	sumSecs      float64
+}
+
+type grantKey struct {
+	grantType goidc.GrantType
+	errorCode string
+}
+
+// PrometheusCollector keeps the metrics in memory and exposes them in the
+// Prometheus text format when served as an HTTP handler.
+type PrometheusCollector struct {
+	requests  map[requestKey]int64
+	durations map[string]*durationHistogram
+	grants    map[grantKey]int64
+	mu        sync.Mutex
+}
+
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		requests:  make(map[requestKey]int64),
+		durations: make(map[string]*durationHistogram),
+		grants:    make(map[grantKey]int64),
+	}
+}
+
+func (c *PrometheusCollector) ObserveRequest(endpoint string, statusCode int, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests[requestKey{endpoint: endpoint, statusCode: statusCode}]++
+
+	histogram, ok := c.durations[endpoint]
+	if !ok {
+		histogram = &durationHistogram{bucketCounts: make([]int64, len(durationBuckets))}
+		c.durations[endpoint] = histogram
+	}
+	secs := duration.Seconds()
+	if i, _ := slices.BinarySearch(durationBuckets, secs); i < len(durationBuckets) {
+		histogram.bucketCounts[i]++
+	}
+	histogram.count++
+	histogram.sumSecs += secs
+}
+
+func (c *PrometheusCollector) ObserveGrant(grantType goidc.GrantType, errorCode string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.grants[grantKey{grantType: grantType, errorCode: errorCode}]++
+}
+
+// GrantCount returns how many token requests for the grant type ended with
+// the error code informed. An empty error code refers to successful requests.
+func (c *PrometheusCollector) GrantCount(grantType goidc.GrantType, errorCode string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.grants[grantKey{grantType: grantType, errorCode: errorCode}]
+}
+
+func (c *PrometheusCollector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	c.write(w)
+}
+
+func (c *PrometheusCollector) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s Total number of requests by endpoint and status code.\n", requestsTotalMetric)
+	fmt.Fprintf(w, "# TYPE %s counter\n", requestsTotalMetric)
+	requestKeys := mapKeys(c.requests, func(k1, k2 requestKey) int {
+		return strings.Compare(k1.endpoint+strconv.Itoa(k1.statusCode), k2.endpoint+strconv.Itoa(k2.statusCode))
+	})
+	for _, key := range requestKeys {
+		fmt.Fprintf(w, "%s{endpoint=%q,code=\"%d\"} %d\n", requestsTotalMetric, key.endpoint, key.statusCode, c.requests[key])
+	}
+
+	fmt.Fprintf(w, "# HELP %s Duration of the requests by endpoint.\n", requestDurationMetric)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", requestDurationMetric)
+	for _, endpoint := range mapKeys(c.durations, strings.Compare) {
+		histogram := c.durations[endpoint]
+		var cumulativeCount int64
+		for i, upperBound := range durationBuckets {
+			cumulativeCount += histogram.bucketCounts[i]
+			fmt.Fprintf(w, "%s_bucket{endpoint=%q,le=\"%s\"} %d\n", requestDurationMetric, endpoint,
+				strconv.FormatFloat(upperBound, 'f', -1, 64), cumulativeCount)
+		}
+		fmt.Fprintf(w, "%s_bucket{endpoint=%q,le=\"+Inf\"} %d\n", requestDurationMetric, endpoint, histogram.count)
+		fmt.Fprintf(w, "%s_sum{endpoint=%q} %s\n", requestDurationMetric, endpoint,
+			strconv.FormatFloat(histogram.sumSecs, 'f', -1, 64))
+		fmt.Fprintf(w, "%s_count{endpoint=%q} %d\n", requestDurationMetric, endpoint, histogram.count)
+	}
+
+	fmt.Fprintf(w, "# HELP %s Total number of token requests by grant type and outcome.\n", grantsTotalMetric)
+	fmt.Fprintf(w, "# TYPE %s counter\n", grantsTotalMetric)
+	grantKeys := mapKeys(c.grants, func(k1, k2 grantKey) int {
+		return strings.Compare(string(k1.grantType)+k1.errorCode, string(k2.grantType)+k2.errorCode)
+	})
+	for _, key := range grantKeys {
+		outcome := "success"
+		if key.errorCode != "" {
+			outcome = "failure"
+		}
+		fmt.Fprintf(w, "%s{grant_type=%q,outcome=%q,error=%q} %d\n", grantsTotalMetric, key.grantType, outcome,
+			key.errorCode, c.grants[key])
+	}
+}
+
+// mapKeys returns the keys of m sorted with cmp, so the metrics are always
+// exported in the same order.
+func mapKeys[K comparable, V any](m map[K]V, cmp func(K, K) int) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, cmp)
+	return keys
+}
@@ -0,0 +1,37 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/metrics"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	// Given.
+	collector := metrics.NewPrometheusCollector()
+	collector.ObserveRequest(goidc.EndpointToken, http.StatusOK, 20*time.Millisecond)
+	collector.ObserveRequest(goidc.EndpointToken, http.StatusBadRequest, 2*time.Second)
+	collector.ObserveGrant(goidc.GrantClientCredentials, "")
+	collector.ObserveGrant(goidc.GrantClientCredentials, "invalid_client")
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	collector.ServeHTTP(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `goidc_http_requests_total{endpoint="/token",code="200"} 1`)
+	assert.Contains(t, body, `goidc_http_requests_total{endpoint="/token",code="400"} 1`)
+	assert.Contains(t, body, `goidc_http_request_duration_seconds_bucket{endpoint="/token",le="0.025"} 1`)
+	assert.Contains(t, body, `goidc_http_request_duration_seconds_bucket{endpoint="/token",le="2.5"} 2`)
+	assert.Contains(t, body, `goidc_http_request_duration_seconds_count{endpoint="/token"} 2`)
+	assert.Contains(t, body, `goidc_grants_total{grant_type="client_credentials",outcome="success",error=""} 1`)
+	assert.Contains(t, body, `goidc_grants_total{grant_type="client_credentials",outcome="failure",error="invalid_client"} 1`)
+}
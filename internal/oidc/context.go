@@ -1,11 +1,14 @@
 package oidc
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"net/textproto"
 	"net/url"
@@ -14,6 +17,9 @@ import (
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/jwksutil"
+	"github.com/luikyv/go-oidc/internal/strutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -21,6 +27,9 @@ type Context struct {
 	Req  *http.Request
 	Resp http.ResponseWriter
 	Configuration
+	// logAttrs are the fields added to the logs once they become known
+	// while handling the request, e.g. the client ID.
+	logAttrs []any
 }
 
 func NewContext(
@@ -35,6 +44,28 @@ func NewContext(
 	}
 }
 
+type configurationKey struct{}
+
+// WithConfiguration returns a copy of req that carries config, so the request
+// is handled with a snapshot of the configuration taken when it arrived
+// instead of the one that can be updated concurrently.
+func WithConfiguration(req *http.Request, config Configuration) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), configurationKey{}, config))
+}
+
+// NewRequestContext creates a context with the configuration carried by req,
+// if any, or with config otherwise.
+func NewRequestContext(
+	config *Configuration,
+	req *http.Request,
+	resp http.ResponseWriter,
+) *Context {
+	if snapshot, ok := req.Context().Value(configurationKey{}).(Configuration); ok {
+		return NewContext(snapshot, req, resp)
+	}
+	return NewContext(*config, req, resp)
+}
+
 func (ctx *Context) ClientSignatureAlgorithms() []jose.SignatureAlgorithm {
 	return append(ctx.PrivateKeyJWTSignatureAlgorithms, ctx.ClientSecretJWTSignatureAlgorithms...)
 }
@@ -127,7 +158,125 @@ func (ctx *Context) Audiences() []string {
 	return audiences
 }
 
+// MatchACR returns whether the authentication context reference achieved
+// satisfies the one requested. If ACRMatchFunc is not defined, the values must
+// be equal.
+func (ctx *Context) MatchACR(requested, achieved goidc.ACR) bool {
+	if ctx.ACRMatchFunc == nil {
+		return requested == achieved
+	}
+	return ctx.ACRMatchFunc(requested, achieved)
+}
+
+// ClientProfile returns the profile that applies to the client.
+// A client can only be subject to a stricter profile than the server's.
+func (ctx *Context) ClientProfile(client *goidc.Client) goidc.Profile {
+	if client.Profile == goidc.ProfileFAPI2 {
+		return goidc.ProfileFAPI2
+	}
+	return ctx.Profile
+}
+
+// AssertionAudiences returns the audiences accepted in client assertions
+// according to the assertion audience mode.
+func (ctx *Context) AssertionAudiences() []string {
+	var audiences []string
+	switch ctx.AssertionAudienceMode {
+	case goidc.AssertionAudienceModeIssuer:
+		audiences = []string{ctx.Host}
+		if ctx.MTLSIsEnabled {
+			audiences = append(audiences, ctx.MTLSHost)
+		}
+	case goidc.AssertionAudienceModeEndpoint:
+		// The token endpoint is also accepted, since clients commonly address
+		// assertions to it regardless of the endpoint requested.
+		audiences = []string{ctx.Host + ctx.Request().RequestURI}
+		if tokenEndpoint := ctx.BaseURL() + goidc.EndpointToken; !slices.Contains(audiences, tokenEndpoint) {
+			audiences = append(audiences, tokenEndpoint)
+		}
+		if ctx.MTLSIsEnabled {
+			audiences = append(audiences, ctx.MTLSHost+ctx.Request().RequestURI)
+			if tokenEndpoint := ctx.MTLSBaseURL() + goidc.EndpointToken; !slices.Contains(audiences, tokenEndpoint) {
+				audiences = append(audiences, tokenEndpoint)
+			}
+		}
+	default:
+		audiences = ctx.Audiences()
+	}
+	return audiences
+}
+
+// IssuerPublicKeys returns the keys published by a trusted issuer at jwksURI
+// that can verify a JWT with the key ID informed. If keyID is empty, all the
+// keys are returned.
+func (ctx *Context) IssuerPublicKeys(jwksURI, keyID string) ([]jose.JSONWebKey, error) {
+	return ctx.IssuerJWKSCache.Keys(ctx, jwksURI, keyID)
+}
+
+// ClockSkewTolerance returns the leeway to be used when validating time claims.
+func (ctx *Context) ClockSkewTolerance() time.Duration {
+	return time.Duration(ctx.ClockSkewToleranceSecs) * time.Second
+}
+
+// Logger returns the logger of the server decorated with the fields of the
+// current request, i.e. the endpoint, the correlation ID and the fields added
+// with AddLogAttrs.
+func (ctx *Context) Logger() *slog.Logger {
+	logger := ctx.Configuration.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var attrs []any
+	if ctx.Req != nil {
+		attrs = append(attrs, "endpoint", ctx.Req.URL.Path)
+		if correlationID := ctx.CorrelationID(); correlationID != "" {
+			attrs = append(attrs, "correlation_id", correlationID)
+		}
+	}
+	attrs = append(attrs, ctx.logAttrs...)
+	if len(attrs) == 0 {
+		return logger
+	}
+	return logger.With(attrs...)
+}
+
+// AddLogAttrs adds fields, as key value pairs, to all the logs written for the
+// current request from now on.
+func (ctx *Context) AddLogAttrs(args ...any) {
+	ctx.logAttrs = append(ctx.logAttrs, args...)
+}
+
+// CorrelationID returns the ID used to correlate the logs of a request.
+func (ctx *Context) CorrelationID() string {
+	if ctx.CorrelationIDHeader == "" || ctx.Req == nil {
+		return ""
+	}
+	return ctx.Req.Header.Get(ctx.CorrelationIDHeader)
+}
+
+// NotifyEvent informs the event hooks about the event.
+// A hook that panics is recovered, so it doesn't break the request.
+func (ctx *Context) NotifyEvent(event goidc.Event) {
+	for _, hook := range ctx.EventHooks {
+		ctx.notifyHook(hook, event)
+	}
+}
+
+func (ctx *Context) notifyHook(hook goidc.EventHook, event goidc.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			ctx.Logger().Error("event hook panicked", "event_type", event.Type, "panic", r)
+		}
+	}()
+	hook.OnEvent(ctx.Request().Context(), event)
+}
+
 func (ctx *Context) Policy(policyID string) goidc.AuthnPolicy {
+	if ctx.AccountCreationIsEnabled && ctx.AccountCreationPolicy.ID == policyID {
+		return ctx.AccountCreationPolicy
+	}
+
 	for _, policy := range ctx.Policies {
 		if policy.ID == policyID {
 			return policy
@@ -140,6 +289,14 @@ func (ctx *Context) FindAvailablePolicy(client *goidc.Client, session *goidc.Aut
 	policy goidc.AuthnPolicy,
 	ok bool,
 ) {
+	if ctx.AccountCreationIsEnabled && session.Prompt.Contains(goidc.PromptTypeCreate) {
+		policy = ctx.AccountCreationPolicy
+		if policy.SetUp == nil || policy.SetUp(ctx, client, session) {
+			session.AccountCreationIsRequested = true
+			return policy, true
+		}
+	}
+
 	for _, policy = range ctx.Policies {
 		if ok = policy.SetUp(ctx, client, session); ok {
 			return policy, true
@@ -161,7 +318,14 @@ func (ctx *Context) Client(clientID string) (*goidc.Client, error) {
 			return staticClient, nil
 		}
 	}
-	return ctx.ClientManager.Get(ctx.Request().Context(), clientID)
+
+	client, err := ctx.ClientManager.Get(ctx.Request().Context(), clientID)
+	// Clients that are not registered can still be trusted if they are part of
+	// the federation.
+	if err != nil && ctx.FederationIsEnabled && isEntityID(clientID) {
+		return ctx.federationClient(clientID)
+	}
+	return client, err
 }
 
 func (ctx *Context) DeleteClient(id string) error {
@@ -170,7 +334,12 @@ func (ctx *Context) DeleteClient(id string) error {
 
 func (ctx *Context) SaveGrantSession(session *goidc.GrantSession) error {
 	// TODO: Flag to avoid saving jwt tokens.
-	return ctx.GrantSessionManager.Save(ctx.Request().Context(), session)
+	if err := ctx.GrantSessionManager.Save(ctx.Request().Context(), session); err != nil {
+		return err
+	}
+
+	ctx.invalidateIntrospectionCache(session.ID)
+	return nil
 }
 
 func (ctx *Context) GrantSessionByTokenID(tokenID string) (*goidc.GrantSession, error) {
@@ -181,8 +350,57 @@ func (ctx *Context) GrantSessionByRefreshToken(refreshToken string) (*goidc.Gran
 	return ctx.GrantSessionManager.GetByRefreshToken(ctx.Request().Context(), refreshToken)
 }
 
+func (ctx *Context) GrantSessionByPreviousRefreshToken(refreshToken string) (*goidc.GrantSession, error) {
+	return ctx.GrantSessionManager.GetByPreviousRefreshToken(ctx.Request().Context(), refreshToken)
+}
+
+// GrantSessionsCanBeFoundBySubject informs whether the grant session manager
+// implements [goidc.GrantSessionSubjectFinder].
+func (ctx *Context) GrantSessionsCanBeFoundBySubject() bool {
+	_, ok := ctx.GrantSessionManager.(goidc.GrantSessionSubjectFinder)
+	return ok
+}
+
+func (ctx *Context) GrantSessionsBySubject(subject string) ([]*goidc.GrantSession, error) {
+	finder, ok := ctx.GrantSessionManager.(goidc.GrantSessionSubjectFinder)
+	if !ok {
+		return nil, errors.New("the grant session manager cannot find grant sessions by subject")
+	}
+	return finder.GetBySubject(ctx.Request().Context(), subject)
+}
+
 func (ctx *Context) DeleteGrantSession(id string) error {
-	return ctx.GrantSessionManager.Delete(ctx.Request().Context(), id)
+	if err := ctx.GrantSessionManager.Delete(ctx.Request().Context(), id); err != nil {
+		return err
+	}
+
+	ctx.invalidateIntrospectionCache(id)
+	return nil
+}
+
+// CachedIntrospection returns the introspection result cached for the token, if
+// the introspection cache is enabled.
+func (ctx *Context) CachedIntrospection(tokenID string) (goidc.TokenInfo, bool) {
+	if ctx.IntrospectionCache == nil {
+		return goidc.TokenInfo{}, false
+	}
+	return ctx.IntrospectionCache.Get(ctx.Request().Context(), tokenID)
+}
+
+func (ctx *Context) CacheIntrospection(tokenID, grantSessionID string, info goidc.TokenInfo) {
+	if ctx.IntrospectionCache == nil {
+		return
+	}
+	ctx.IntrospectionCache.Set(ctx.Request().Context(), tokenID, grantSessionID, info)
+}
+
+// invalidateIntrospectionCache removes the cached results of the tokens issued
+// for the grant session, so changes such as revocations take effect immediately.
+func (ctx *Context) invalidateIntrospectionCache(grantSessionID string) {
+	if ctx.IntrospectionCache == nil {
+		return
+	}
+	ctx.IntrospectionCache.InvalidateGrantSession(ctx.Request().Context(), grantSessionID)
 }
 
 func (ctx *Context) SaveAuthnSession(session *goidc.AuthnSession) error {
@@ -205,6 +423,42 @@ func (ctx *Context) DeleteAuthnSession(id string) error {
 	return ctx.AuthnSessionManager.Delete(ctx.Request().Context(), id)
 }
 
+func (ctx *Context) SaveDeviceSession(session *goidc.DeviceSession) error {
+	return ctx.DeviceSessionManager.Save(ctx.Request().Context(), session)
+}
+
+func (ctx *Context) DeviceSessionByDeviceCode(deviceCode string) (*goidc.DeviceSession, error) {
+	return ctx.DeviceSessionManager.GetByDeviceCode(ctx.Request().Context(), deviceCode)
+}
+
+func (ctx *Context) DeviceSessionByUserCode(userCode string) (*goidc.DeviceSession, error) {
+	return ctx.DeviceSessionManager.GetByUserCode(ctx.Request().Context(), userCode)
+}
+
+func (ctx *Context) DeleteDeviceSession(id string) error {
+	return ctx.DeviceSessionManager.Delete(ctx.Request().Context(), id)
+}
+
+func (ctx *Context) SaveLogoutSession(session *goidc.LogoutSession) error {
+	return ctx.LogoutSessionManager.Save(ctx.Request().Context(), session)
+}
+
+func (ctx *Context) LogoutSessionByCallbackID(callbackID string) (*goidc.LogoutSession, error) {
+	return ctx.LogoutSessionManager.GetByCallbackID(ctx.Request().Context(), callbackID)
+}
+
+func (ctx *Context) DeleteLogoutSession(id string) error {
+	return ctx.LogoutSessionManager.Delete(ctx.Request().Context(), id)
+}
+
+func (ctx *Context) IssueDPoPNonce(clientID string) (string, error) {
+	return ctx.DPoPNonceStore.Issue(ctx.Request().Context(), clientID)
+}
+
+func (ctx *Context) ConsumeDPoPNonce(clientID, nonce string) (bool, error) {
+	return ctx.DPoPNonceStore.Consume(ctx.Request().Context(), clientID, nonce)
+}
+
 //---------------------------------------- HTTP Utils ----------------------------------------//
 
 func (ctx *Context) BaseURL() string {
@@ -308,6 +562,20 @@ func (ctx *Context) Write(obj any, status int) error {
 }
 
 func (ctx *Context) WriteJWT(token string, status int) error {
+	return ctx.writeJWT(token, "application/jwt", status)
+}
+
+// WriteTokenIntrospectionJWT writes an introspection response signed as a JWT as defined in RFC 9701.
+func (ctx *Context) WriteTokenIntrospectionJWT(token string, status int) error {
+	return ctx.writeJWT(token, "application/token-introspection+jwt", status)
+}
+
+// WriteEntityStatement writes a signed OpenID federation entity statement.
+func (ctx *Context) WriteEntityStatement(statement string, status int) error {
+	return ctx.writeJWT(statement, "application/entity-statement+jwt", status)
+}
+
+func (ctx *Context) writeJWT(token string, contentType string, status int) error {
 	// Check if the request was terminated before writing anything.
 	select {
 	case <-ctx.Request().Context().Done():
@@ -315,7 +583,7 @@ func (ctx *Context) WriteJWT(token string, status int) error {
 	default:
 	}
 
-	ctx.Resp.Header().Set("Content-Type", "application/jwt")
+	ctx.Resp.Header().Set("Content-Type", contentType)
 	ctx.Resp.WriteHeader(status)
 
 	if _, err := ctx.Resp.Write([]byte(token)); err != nil {
@@ -329,6 +597,7 @@ func (ctx *Context) WriteError(err error) {
 
 	var oauthErr Error
 	if !errors.As(err, &oauthErr) {
+		ctx.Logger().Error("could not handle the request", "error", err)
 		if err := ctx.Write(map[string]any{
 			"error":             ErrorCodeInternalError,
 			"error_description": err.Error(),
@@ -339,14 +608,37 @@ func (ctx *Context) WriteError(err error) {
 	}
 
 	errorCode := oauthErr.Code()
-	if err := ctx.Write(map[string]any{
+	// Errors caused by the client are expected, so only server errors are
+	// logged as errors.
+	level := slog.LevelInfo
+	if errorCode.StatusCode() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	ctx.Logger().Log(ctx.Request().Context(), level, "the request failed",
+		"error_code", errorCode, "error_description", oauthErr.Error())
+
+	resp := map[string]any{
 		"error":             errorCode,
 		"error_description": oauthErr.Error(),
-	}, errorCode.StatusCode()); err != nil {
+	}
+	if errorURI := ctx.ErrorURI(errorCode); errorURI != "" {
+		resp["error_uri"] = errorURI
+	}
+	if err := ctx.Write(resp, errorCode.StatusCode()); err != nil {
 		ctx.Response().WriteHeader(http.StatusInternalServerError)
 	}
 }
 
+// ErrorURI returns the URI of the page documenting the error code, if one was
+// configured, to be informed as the "error_uri" parameter defined in RFC 6749.
+func (ctx *Context) ErrorURI(code ErrorCode) string {
+	path, ok := ctx.ErrorURIs[string(code)]
+	if !ok {
+		return ""
+	}
+	return ctx.ErrorURIBase + path
+}
+
 func (ctx *Context) Redirect(redirectURL string) {
 	http.Redirect(ctx.Resp, ctx.Req, redirectURL, http.StatusSeeOther)
 }
@@ -368,6 +660,23 @@ func (ctx *Context) RenderHTML(
 	return tmpl.Execute(ctx.Resp, params)
 }
 
+// ScopeCapturedClaims returns the claims extracted from the scopes informed,
+// space separated, by the Capture function of the scopes they match.
+func (ctx *Context) ScopeCapturedClaims(scopes string) map[string]any {
+	claims := map[string]any{}
+	for _, requestedScope := range strutil.SplitWithSpaces(scopes) {
+		for _, scope := range ctx.Scopes {
+			if scope.Capture == nil || !scope.Matches(requestedScope) {
+				continue
+			}
+			for k, v := range scope.Capture(requestedScope) {
+				claims[k] = v
+			}
+		}
+	}
+	return claims
+}
+
 //---------------------------------------- Key Management ----------------------------------------//
 
 func (ctx *Context) SignatureAlgorithms() []jose.SignatureAlgorithm {
@@ -383,7 +692,7 @@ func (ctx *Context) SignatureAlgorithms() []jose.SignatureAlgorithm {
 func (ctx *Context) PublicKeys() jose.JSONWebKeySet {
 	publicKeys := []jose.JSONWebKey{}
 	for _, privateKey := range ctx.PrivateJWKS.Keys {
-		publicKeys = append(publicKeys, privateKey.Public())
+		publicKeys = append(publicKeys, publicJWK(privateKey))
 	}
 
 	return jose.JSONWebKeySet{Keys: publicKeys}
@@ -395,7 +704,7 @@ func (ctx *Context) PublicKey(keyID string) (jose.JSONWebKey, bool) {
 		return jose.JSONWebKey{}, false
 	}
 
-	return key.Public(), true
+	return publicJWK(key), true
 }
 
 func (ctx *Context) PrivateKey(keyID string) (jose.JSONWebKey, bool) {
@@ -406,6 +715,16 @@ func (ctx *Context) PrivateKey(keyID string) (jose.JSONWebKey, bool) {
 	return keys[0], true
 }
 
+// publicJWK returns the public part of a key in the server JWKS.
+// Keys backed by a goidc.Signer hold no private material, so their public
+// key is provided by the signer itself.
+func publicJWK(key jose.JSONWebKey) jose.JSONWebKey {
+	if signer, ok := key.Key.(goidc.Signer); ok {
+		return *signer.Public()
+	}
+	return key.Public()
+}
+
 func (ctx *Context) TokenSignatureKey(tokenOptions goidc.TokenOptions) jose.JSONWebKey {
 	keyID := tokenOptions.JWTSignatureKeyID
 	if keyID == "" {
@@ -430,6 +749,88 @@ func (ctx *Context) IDTokenSignatureKey(client *goidc.Client) jose.JSONWebKey {
 	return ctx.privateKeyBasedOnAlgorithmOrDefault(client.IDTokenSignatureAlgorithm, ctx.DefaultUserInfoSignatureKeyID, ctx.UserInfoSignatureKeyIDs)
 }
 
+// IDTokenSigningKey returns the key used to sign the ID tokens of the client.
+// When the client requested a symmetric algorithm, the key is its secret and
+// it has no key ID.
+func (ctx *Context) IDTokenSigningKey(client *goidc.Client) (jose.JSONWebKey, Error) {
+	alg := client.IDTokenSignatureAlgorithm
+	if !strings.HasPrefix(string(alg), "HS") {
+		return ctx.IDTokenSignatureKey(client), nil
+	}
+
+	if !slices.Contains(ctx.IDTokenSymmetricSignatureAlgorithms, alg) {
+		return jose.JSONWebKey{}, NewError(ErrorCodeInvalidRequest,
+			fmt.Sprintf("the ID token signature algorithm %s is not supported", alg))
+	}
+
+	if client.Secret == "" || !slices.Contains([]goidc.ClientAuthnType{
+		goidc.ClientAuthnSecretBasic,
+		goidc.ClientAuthnSecretPost,
+		goidc.ClientAuthnSecretJWT,
+	}, client.AuthnMethod) {
+		return jose.JSONWebKey{}, NewError(ErrorCodeInvalidRequest,
+			"symmetric ID token signature algorithms require a client authenticated with a secret")
+	}
+
+	return jose.JSONWebKey{Key: []byte(client.Secret), Algorithm: string(alg)}, nil
+}
+
+// ParseIDTokenHint verifies an ID token previously issued by the server and
+// decodes its claims into dest. The token may be expired.
+// ID tokens signed with a symmetric algorithm are verified with the secret of
+// the client they were issued to.
+func (ctx *Context) ParseIDTokenHint(idTokenHint string, dest any) error {
+	parsedIDToken, err := jwt.ParseSigned(idTokenHint, ctx.IDTokenSignatureAlgorithms())
+	if err != nil {
+		return err
+	}
+
+	if len(parsedIDToken.Headers) != 1 {
+		return errors.New("the id token must have exactly one signature")
+	}
+	header := parsedIDToken.Headers[0]
+
+	if !strings.HasPrefix(header.Algorithm, "HS") {
+		if header.KeyID == "" {
+			return errors.New("the id token has no key id")
+		}
+
+		publicKey, ok := ctx.PublicKey(header.KeyID)
+		if !ok || publicKey.Use != string(goidc.KeyUsageSignature) {
+			return errors.New("the id token was not signed by the server")
+		}
+
+		return parsedIDToken.Claims(publicKey.Key, dest)
+	}
+
+	// The claims are decoded without verification only to find the client
+	// whose secret signed the token.
+	var claims jwt.Claims
+	if err := parsedIDToken.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return err
+	}
+
+	if len(claims.Audience) != 1 {
+		return errors.New("the id token must be issued to a single client")
+	}
+
+	client, err := ctx.Client(claims.Audience[0])
+	if err != nil {
+		return err
+	}
+
+	signingKey, oauthErr := ctx.IDTokenSigningKey(client)
+	if oauthErr != nil {
+		return oauthErr
+	}
+
+	if signingKey.Algorithm != header.Algorithm {
+		return errors.New("the id token was not signed with the algorithm of the client")
+	}
+
+	return parsedIDToken.Claims(signingKey.Key, dest)
+}
+
 func (ctx *Context) JARMSignatureKey(client *goidc.Client) jose.JSONWebKey {
 	return ctx.privateKeyBasedOnAlgorithmOrDefault(client.JARMSignatureAlgorithm, ctx.DefaultJARMSignatureKeyID, ctx.JARMSignatureKeyIDs)
 }
@@ -438,6 +839,16 @@ func (ctx *Context) UserInfoSignatureAlgorithms() []jose.SignatureAlgorithm {
 	return ctx.signatureAlgorithms(ctx.UserInfoSignatureKeyIDs)
 }
 
+// IDTokenSignatureAlgorithms returns the algorithms available to sign ID tokens,
+// including the symmetric ones, which use the client secret as the key.
+func (ctx *Context) IDTokenSignatureAlgorithms() []jose.SignatureAlgorithm {
+	return slices.Concat(ctx.UserInfoSignatureAlgorithms(), ctx.IDTokenSymmetricSignatureAlgorithms)
+}
+
+func (ctx *Context) IntrospectionSignatureAlgorithms() []jose.SignatureAlgorithm {
+	return ctx.signatureAlgorithms([]string{ctx.IntrospectionJWTResponseSignatureKeyID})
+}
+
 func (ctx *Context) JARMSignatureAlgorithms() []jose.SignatureAlgorithm {
 	return ctx.signatureAlgorithms(ctx.JARMSignatureKeyIDs)
 }
@@ -465,7 +876,8 @@ func (ctx *Context) signatureAlgorithms(keyIDs []string) []jose.SignatureAlgorit
 }
 
 // privateKeyBasedOnAlgorithmOrDefault tries to find a key that matches signatureAlgorithm
-// from the subset of keys defined by keyIDs.
+// from the subset of keys defined by keyIDs. The default key is preferred when
+// it matches the algorithm, so the active key is used whenever possible.
 // If no key is found, return the key associated to defaultKeyID.
 func (ctx *Context) privateKeyBasedOnAlgorithmOrDefault(
 	signatureAlgorithm jose.SignatureAlgorithm,
@@ -473,8 +885,16 @@ func (ctx *Context) privateKeyBasedOnAlgorithmOrDefault(
 	keyIDs []string,
 ) jose.JSONWebKey {
 	if signatureAlgorithm != "" {
+		if keys := ctx.PrivateJWKS.Key(defaultKeyID); len(keys) != 0 &&
+			keys[0].Algorithm == string(signatureAlgorithm) {
+			return keys[0]
+		}
+
 		for _, keyID := range keyIDs {
-			return ctx.privateKey(keyID)
+			key := ctx.privateKey(keyID)
+			if key.Algorithm == string(signatureAlgorithm) {
+				return key
+			}
 		}
 	}
 
@@ -488,6 +908,83 @@ func (ctx *Context) privateKey(keyID string) jose.JSONWebKey {
 	return keys[0]
 }
 
+// RotateSignatureKey makes key the default key to sign new tokens for the
+// purpose informed. The key is added to the JWKS, unless a key with the same
+// ID is already published, in which case the published key is used.
+// The key previously used for the purpose remains in the JWKS, so the tokens
+// it signed can still be verified until it is removed with RetireKey.
+// The ID of the key that was replaced is returned.
+func (c *Configuration) RotateSignatureKey(purpose goidc.SignatureKeyPurpose, key jose.JSONWebKey) (string, error) {
+	publishedKeys := c.PrivateJWKS.Key(key.KeyID)
+	if len(publishedKeys) != 0 {
+		key = publishedKeys[0]
+	}
+
+	if !key.Valid() || key.IsPublic() || key.Use != string(goidc.KeyUsageSignature) {
+		return "", errors.New("the key must be a valid private key meant for signing")
+	}
+
+	if !slices.Contains([]goidc.SignatureKeyPurpose{
+		goidc.SignatureKeyPurposeToken,
+		goidc.SignatureKeyPurposeUserInfo,
+		goidc.SignatureKeyPurposeJARM,
+	}, purpose) {
+		return "", fmt.Errorf("invalid signature key purpose %s", purpose)
+	}
+
+	if len(publishedKeys) == 0 {
+		// A new slice is created, so contexts built from the previous configuration are not affected.
+		c.PrivateJWKS = jose.JSONWebKeySet{Keys: append(slices.Clone(c.PrivateJWKS.Keys), key)}
+	}
+
+	var oldKeyID string
+	switch purpose {
+	case goidc.SignatureKeyPurposeToken:
+		oldKeyID = c.DefaultTokenSignatureKeyID
+		c.DefaultTokenSignatureKeyID = key.KeyID
+	case goidc.SignatureKeyPurposeUserInfo:
+		oldKeyID = c.DefaultUserInfoSignatureKeyID
+		c.DefaultUserInfoSignatureKeyID = key.KeyID
+		if !slices.Contains(c.UserInfoSignatureKeyIDs, key.KeyID) {
+			c.UserInfoSignatureKeyIDs = append(slices.Clone(c.UserInfoSignatureKeyIDs), key.KeyID)
+		}
+	case goidc.SignatureKeyPurposeJARM:
+		oldKeyID = c.DefaultJARMSignatureKeyID
+		c.DefaultJARMSignatureKeyID = key.KeyID
+		if !slices.Contains(c.JARMSignatureKeyIDs, key.KeyID) {
+			c.JARMSignatureKeyIDs = append(slices.Clone(c.JARMSignatureKeyIDs), key.KeyID)
+		}
+	}
+
+	return oldKeyID, nil
+}
+
+// RetireKey removes a key from the JWKS and from the lists of signing keys,
+// so it's no longer published nor used to sign or verify tokens.
+// Keys that are still used by default for any purpose are not removed.
+func (c *Configuration) RetireKey(keyID string) {
+	if slices.Contains([]string{
+		c.DefaultTokenSignatureKeyID,
+		c.DefaultUserInfoSignatureKeyID,
+		c.DefaultJARMSignatureKeyID,
+		c.IntrospectionJWTResponseSignatureKeyID,
+		c.SignedMetadataSignatureKeyID,
+	}, keyID) {
+		return
+	}
+
+	isRetired := func(id string) bool {
+		return id == keyID
+	}
+	c.PrivateJWKS = jose.JSONWebKeySet{
+		Keys: slices.DeleteFunc(slices.Clone(c.PrivateJWKS.Keys), func(key jose.JSONWebKey) bool {
+			return isRetired(key.KeyID)
+		}),
+	}
+	c.UserInfoSignatureKeyIDs = slices.DeleteFunc(slices.Clone(c.UserInfoSignatureKeyIDs), isRetired)
+	c.JARMSignatureKeyIDs = slices.DeleteFunc(slices.Clone(c.JARMSignatureKeyIDs), isRetired)
+}
+
 //----------------------------------------Context ----------------------------------------//
 
 func (ctx *Context) Deadline() (deadline time.Time, ok bool) {
@@ -509,14 +1006,23 @@ func (ctx *Context) Value(key any) any {
 type Configuration struct {
 	Profile goidc.Profile
 	// Host is the domain where the server runs. This value will be used the auth server issuer.
-	Host                string
-	PathPrefix          string
-	MTLSIsEnabled       bool
-	MTLSHost            string
-	Scopes              []goidc.Scope
+	Host          string
+	PathPrefix    string
+	MTLSIsEnabled bool
+	MTLSHost      string
+	Scopes        []goidc.Scope
+	// If IgnoreUnknownScopes is true, the scopes requested that are not recognized are
+	// discarded and the remaining ones are granted. Otherwise, the request is rejected.
+	IgnoreUnknownScopes bool
 	ClientManager       goidc.ClientManager
 	GrantSessionManager goidc.GrantSessionManager
 	AuthnSessionManager goidc.AuthnSessionManager
+	// DeviceSessionManager stores the device authorization requests when the
+	// device grant is enabled.
+	DeviceSessionManager goidc.DeviceSessionManager
+	// LogoutSessionManager stores the logout requests interrupted to interact
+	// with the user when RP-Initiated Logout is enabled.
+	LogoutSessionManager goidc.LogoutSessionManager
 	// PrivateJWKS contains the server JWKS with private and public information.
 	// When exposing it, the private information is removed.
 	PrivateJWKS jose.JSONWebKeySet
@@ -528,6 +1034,118 @@ type Configuration struct {
 	ClientAuthnMethods              []goidc.ClientAuthnType
 	IntrospectionIsEnabled          bool
 	IntrospectionClientAuthnMethods []goidc.ClientAuthnType
+	// If TokenRevocationIsEnabled is true, clients can revoke their tokens at the
+	// revocation endpoint as described in RFC 7009.
+	TokenRevocationIsEnabled bool
+	// If DeviceGrantIsEnabled is true, clients can obtain tokens with the device
+	// authorization grant as described in RFC 8628.
+	DeviceGrantIsEnabled bool
+	// DeviceCodeLifetimeSecs defines for how long device codes and user codes are valid.
+	DeviceCodeLifetimeSecs int64
+	// DevicePollingIntervalSecs is the minimum amount of time clients must wait
+	// between token requests while the user has not approved the device yet.
+	DevicePollingIntervalSecs int64
+	// If JWTBearerGrantIsEnabled is true, clients can exchange assertions signed
+	// by trusted issuers for access tokens as described in RFC 7523.
+	JWTBearerGrantIsEnabled bool
+	// JWTBearerTrustedIssuers maps the issuers trusted to sign assertions to their JWKS URIs.
+	JWTBearerTrustedIssuers map[string]string
+	// JWTBearerMappingFunc maps the subject of assertions to local subjects.
+	// If nil, the subject of the assertion is used as is.
+	JWTBearerMappingFunc goidc.JWTBearerMappingFunc
+	// JWTBearerSignatureAlgorithms are the algorithms accepted for signing assertions.
+	JWTBearerSignatureAlgorithms []jose.SignatureAlgorithm
+	// JWTBearerAssertionLifetimeSecs is the maximum lifetime accepted for assertions.
+	JWTBearerAssertionLifetimeSecs int64
+	// TokenRateLimiter throttles the requests to the token endpoint per client.
+	// If nil, the requests are not throttled.
+	TokenRateLimiter goidc.RateLimiter
+	// MetricsCollector records metrics about the requests handled.
+	// If nil, no metrics are recorded.
+	MetricsCollector goidc.MetricsCollector
+	// If PkceIsRequiredForPublicClients is true, clients that don't authenticate
+	// must use PKCE even when it's not required for all clients.
+	PkceIsRequiredForPublicClients bool
+	// If PkceS256IsRequired is true, only the S256 code challenge method is
+	// accepted. Requests that don't inform the method, which would default
+	// to plain, are rejected as well.
+	PkceS256IsRequired bool
+	// ClockSkewToleranceSecs is the leeway allowed when validating the time
+	// claims of JWTs issued by other parties, e.g. request objects, DPoP proofs
+	// and client assertions, so small clock differences are tolerated.
+	ClockSkewToleranceSecs int64
+	// If ProtectedResourceMetadataIsEnabled is true, the metadata of
+	// ProtectedResource is published as described in RFC 9728.
+	ProtectedResourceMetadataIsEnabled bool
+	// ProtectedResource is the identifier of the resource server protected by
+	// the tokens issued by the server.
+	ProtectedResource string
+	// ProtectedResourceSignatureAlgorithms are the algorithms the protected
+	// resource uses to sign its responses.
+	ProtectedResourceSignatureAlgorithms []jose.SignatureAlgorithm
+	// SecretHasher hashes the client secrets and registration access tokens
+	// before they are stored.
+	SecretHasher goidc.SecretHasher
+	// If JARTypeIsRequired is true, request objects must have the header "typ"
+	// set to "oauth-authz-req+jwt" as recommended by RFC 9101.
+	JARTypeIsRequired bool
+	// IntrospectionCache, if defined, caches the introspection results of
+	// access tokens.
+	IntrospectionCache goidc.IntrospectionCache
+	// ErrorURIBase and ErrorURIs define the "error_uri" parameter returned with
+	// errors. ErrorURIs maps error codes to paths relative to ErrorURIBase.
+	// Only the error codes mapped have an error URI.
+	ErrorURIBase string
+	ErrorURIs    map[string]string
+	// ResourceServerAuthzFunc defines which clients can introspect tokens
+	// issued to other clients. If not informed, clients can only introspect
+	// their own tokens.
+	ResourceServerAuthzFunc goidc.ResourceServerAuthzFunc
+	// RedirectURIMatchingMode defines how redirect URIs are compared to the
+	// ones registered by clients. If not informed, exact matching is used.
+	RedirectURIMatchingMode goidc.RedirectURIMatchingMode
+	// JWKSCacheMaxAgeSecs defines for how long clients can cache the JWKS.
+	// If zero, the JWKS is not cacheable like the other endpoints.
+	JWKSCacheMaxAgeSecs int64
+	// SoftwareStatementTrustedIssuers maps the issuers trusted to sign
+	// software statements to their JWKS URIs.
+	SoftwareStatementTrustedIssuers      map[string]string
+	SoftwareStatementSignatureAlgorithms []jose.SignatureAlgorithm
+	// IssuerJWKSCache keeps the keys of the trusted assertion and software
+	// statement issuers.
+	IssuerJWKSCache *jwksutil.Cache
+	// ScopePolicyFunc restricts the scopes issued per grant type.
+	ScopePolicyFunc goidc.ScopePolicyFunc
+	// If OfflineAccessConsentIsRequired is true, the "offline_access" scope is
+	// only granted if the policy confirms it with [goidc.AuthnSession.GrantOfflineAccess].
+	OfflineAccessConsentIsRequired bool
+	// If OfflineAccessPromptConsentIsRequired is true, the "offline_access"
+	// scope is only granted to authorization requests with "prompt=consent".
+	OfflineAccessPromptConsentIsRequired bool
+	// If HealthEndpointsAreEnabled is true, the liveness and readiness probes
+	// are exposed. ReadinessCheckers are pinged by the readiness probe in
+	// addition to the stores that implement [goidc.Pinger].
+	HealthEndpointsAreEnabled bool
+	ReadinessCheckers         []goidc.Pinger
+	// The PARMax... fields limit the size of pushed authorization requests.
+	// A value of zero means there is no limit.
+	PARMaxRequestBodyBytes     int64
+	PARMaxAuthorizationDetails int
+	PARMaxScopeLength          int
+	// CorrelationIDHeader is the header that carries the ID used to correlate
+	// the logs of a request. The ID is generated if the request doesn't have
+	// one and is echoed in the response.
+	CorrelationIDHeader string
+	// If IntrospectionJWTResponseIsEnabled is true, clients can request introspection responses
+	// signed as JWTs by sending "Accept: application/token-introspection+jwt" as defined in RFC 9701.
+	IntrospectionJWTResponseIsEnabled bool
+	// IntrospectionJWTResponseSignatureKeyID is the ID of the key used to sign introspection responses.
+	IntrospectionJWTResponseSignatureKeyID string
+	// If SignedMetadataIsEnabled is true, the discovery document also contains
+	// its claims as a signed JWT in the "signed_metadata" field as defined in RFC 8414.
+	SignedMetadataIsEnabled bool
+	// SignedMetadataSignatureKeyID is the ID of the key used to sign the metadata.
+	SignedMetadataSignatureKeyID string
 	// PrivateKeyJWTSignatureAlgorithms contains algorithms accepted for signing client assertions during private_key_jwt.
 	PrivateKeyJWTSignatureAlgorithms []jose.SignatureAlgorithm
 	// PrivateKeyJWTAssertionLifetimeSecs is used to validate that the assertion will expire in the near future during private_key_jwt.
@@ -536,7 +1154,19 @@ type Configuration struct {
 	ClientSecretJWTSignatureAlgorithms []jose.SignatureAlgorithm
 	// It is used to validate that the assertion will expire in the near future during client_secret_jwt.
 	ClientSecretJWTAssertionLifetimeSecs int64
-	OpenIDScopeIsRequired                bool
+	// If ClientAuthnSignatureAlgorithmIsRequired is true, clients authenticating with private_key_jwt or
+	// client_secret_jwt must register the algorithm used to sign their assertions ("token_endpoint_auth_signing_alg").
+	ClientAuthnSignatureAlgorithmIsRequired bool
+	// AssertionAudienceMode restricts the audiences accepted in client assertions.
+	// If empty, both the issuer and the endpoint URL are accepted.
+	AssertionAudienceMode goidc.AssertionAudienceMode
+	OpenIDScopeIsRequired bool
+	// If RedirectURIIsOptional is true, clients with exactly one registered redirect URI
+	// can omit the "redirect_uri" parameter during the authorization request.
+	RedirectURIIsOptional bool
+	// IDTokenSymmetricSignatureAlgorithms are the HMAC algorithms clients can
+	// request to have their ID tokens signed with their secrets.
+	IDTokenSymmetricSignatureAlgorithms []jose.SignatureAlgorithm
 	// DefaultUserInfoSignatureKeyID defines the default key used to sign ID tokens and the user info endpoint response.
 	// The key can be overridden depending on the client properties "id_token_signed_response_alg" and "userinfo_signed_response_alg".
 	DefaultUserInfoSignatureKeyID string
@@ -547,57 +1177,170 @@ type Configuration struct {
 	UserInfoKeyEncryptionAlgorithms     []jose.KeyAlgorithm
 	UserInfoContentEncryptionAlgorithms []jose.ContentEncryption
 	// IDTokenExpiresInSecs defines the expiry time of ID tokens.
-	IDTokenExpiresInSecs      int64
+	IDTokenExpiresInSecs int64
+	// If SIDClaimIsEnabled is true, ID tokens contain the "sid" claim identifying the session of the user.
+	SIDClaimIsEnabled bool
+	// If BackChannelLogoutIsEnabled is true, the server can notify clients
+	// when the session of a user ends as described in OpenID Connect
+	// Back-Channel Logout 1.0.
+	BackChannelLogoutIsEnabled bool
+	// If EndSessionIsEnabled is true, clients can log users out at the end
+	// session endpoint as described in OpenID Connect RP-Initiated Logout 1.0.
+	EndSessionIsEnabled bool
+	// LogoutFunc, if set, is executed before the sessions of the user are ended
+	// at the end session endpoint.
+	LogoutFunc goidc.LogoutFunc
+	// If SilentAuthnIsEnabled is true, authorization requests with "prompt=none" and a valid
+	// "id_token_hint" are answered without user interaction as long as UserSessionFunc
+	// informs the hinted user still has an active session.
+	SilentAuthnIsEnabled bool
+	UserSessionFunc      goidc.UserSessionFunc
+	// If AuthorizationCodeBindingIsEnabled is true, authorization codes are bound to the DPoP key
+	// informed with "dpop_jkt" or presented during PAR, and to the client certificate presented
+	// during PAR. The code can only be redeemed with the same key or certificate.
+	AuthorizationCodeBindingIsEnabled bool
+	// If FederationIsEnabled is true, the server publishes its entity configuration and
+	// trusts clients whose trust chain, fetched with FederationTrustChainFunc, resolves
+	// to one of FederationTrustAnchors.
+	FederationIsEnabled                   bool
+	FederationTrustAnchors                []goidc.TrustAnchor
+	FederationTrustChainFunc              goidc.TrustChainFunc
+	FederationAuthorityHints              []string
+	FederationEntityStatementLifetimeSecs int64
+	// If AccessTokenTypeIsRequired is true, JWT access tokens presented to the server must
+	// have the header "typ" set to "at+jwt", so other JWTs signed by the server, e.g. ID tokens,
+	// cannot be used as access tokens.
+	AccessTokenTypeIsRequired bool
+	// TokenResponseCustomizeFunc, if defined, adds custom fields to the token response.
+	TokenResponseCustomizeFunc goidc.TokenResponseCustomizeFunc
+	// If IDTokenIsSuppressed is true, ID tokens are never issued, even if the openid scope is granted.
+	// This is intended for deployments acting as pure OAuth authorization servers.
+	IDTokenIsSuppressed       bool
 	ShouldRotateRefreshTokens bool
-	RefreshTokenLifetimeSecs  int64
+	// If RefreshTokenReuseDetectionIsEnabled is true, presenting a refresh token
+	// that was already rotated revokes the grant session it belongs to, since
+	// it might have been stolen.
+	RefreshTokenReuseDetectionIsEnabled bool
+	RefreshTokenLifetimeSecs            int64
 	// UserClaims defines the user claims that can be returned in the userinfo endpoint or in the ID token.
 	// This will be transmitted in the /.well-known/openid-configuration endpoint.
 	UserClaims []string
+	// ScopeClaims maps scopes to the user claims they grant access to.
+	// When ClaimResolverFunc is set, the userinfo endpoint returns the claims
+	// associated to the scopes granted with the values it resolves.
+	ScopeClaims       map[string][]string
+	ClaimResolverFunc goidc.ClaimResolverFunc
 	// ClaimTypes are claim types supported by the server.
 	ClaimTypes []goidc.ClaimType
 	// IssuerResponseParameterIsEnabled indicates if the "iss" parameter will be returned when redirecting the user back to the client application.
 	IssuerResponseParameterIsEnabled bool
 	// ClaimsParameterIsEnabled informs the clients whether the server accepts the "claims" parameter.
 	// This will be transmitted in the /.well-known/openid-configuration endpoint.
-	ClaimsParameterIsEnabled               bool
+	ClaimsParameterIsEnabled bool
+	// If UnsupportedClaimsParameterIsRejected is true, requests containing the "claims" parameter are
+	// rejected when the parameter is not enabled. Otherwise, the parameter is just ignored.
+	UnsupportedClaimsParameterIsRejected bool
+	// EssentialClaimsPolicy defines whether the authorization fails when an
+	// essential claim requested with the "claims" parameter is not provided.
+	EssentialClaimsPolicy                  goidc.EssentialClaimsPolicy
 	AuthorizationDetailsParameterIsEnabled bool
 	AuthorizationDetailTypes               []string
-	JARMIsEnabled                          bool
-	DefaultJARMSignatureKeyID              string
-	JARMSignatureKeyIDs                    []string
-	JARMLifetimeSecs                       int64
-	JARMEncryptionIsEnabled                bool
-	JARMKeyEncrytionAlgorithms             []jose.KeyAlgorithm
-	JARMContentEncryptionAlgorithms        []jose.ContentEncryption
-	JARIsEnabled                           bool
-	JARIsRequired                          bool
-	JARSignatureAlgorithms                 []jose.SignatureAlgorithm
-	JARLifetimeSecs                        int64
-	JAREncryptionIsEnabled                 bool
-	JARKeyEncryptionIDs                    []string
-	JARContentEncryptionAlgorithms         []jose.ContentEncryption
+	// AuthorizationDetailValidators maps authorization detail types to the
+	// functions that validate their fields.
+	AuthorizationDetailValidators map[string]goidc.AuthorizationDetailValidationFunc
+	// ResourceIndicatorsIsEnabled allows clients to inform the resources they want to access with the
+	// "resource" parameter as defined in RFC 8707.
+	ResourceIndicatorsIsEnabled bool
+	// Resources are the resources clients are allowed to request.
+	Resources []string
+	// ResourceValidationFunc, if defined, further restricts the resources each client can request.
+	ResourceValidationFunc          goidc.ResourceValidationFunc
+	JARMIsEnabled                   bool
+	DefaultJARMSignatureKeyID       string
+	JARMSignatureKeyIDs             []string
+	JARMLifetimeSecs                int64
+	JARMEncryptionIsEnabled         bool
+	JARMKeyEncrytionAlgorithms      []jose.KeyAlgorithm
+	JARMContentEncryptionAlgorithms []jose.ContentEncryption
+	JARIsEnabled                    bool
+	JARIsRequired                   bool
+	JARSignatureAlgorithms          []jose.SignatureAlgorithm
+	JARLifetimeSecs                 int64
+	JAREncryptionIsEnabled          bool
+	JARKeyEncryptionIDs             []string
+	JARContentEncryptionAlgorithms  []jose.ContentEncryption
+	// If JARByReferenceIsEnabled is true, clients can pass request objects by
+	// reference using the "request_uri" parameter with one of their
+	// registered request URIs.
+	JARByReferenceIsEnabled bool
+	// JARByReferenceCacheLifetimeSecs defines for how long request objects
+	// fetched by reference are cached. Zero disables the cache.
+	JARByReferenceCacheLifetimeSecs int64
 	// PARIsEnabled allows client to push authorization requests.
 	PARIsEnabled bool
 	// If PARIsRequired is true, authorization requests can only be made if they were pushed.
-	PARIsRequired                    bool
-	ParLifetimeSecs                  int64
-	DPoPIsEnabled                    bool
-	DPoPIsRequired                   bool
-	DPoPLifetimeSecs                 int
-	DPoPSignatureAlgorithms          []jose.SignatureAlgorithm
-	PkceIsEnabled                    bool
-	PkceIsRequired                   bool
-	CodeChallengeMethods             []goidc.CodeChallengeMethod
-	SubjectIdentifierTypes           []goidc.SubjectIdentifierType
-	Policies                         []goidc.AuthnPolicy
-	TokenOptions                     goidc.TokenOptionsFunc
-	DCRIsEnabled                     bool
-	ShouldRotateRegistrationTokens   bool
-	DCRPlugin                        goidc.DCRPluginFunc
+	PARIsRequired           bool
+	ParLifetimeSecs         int64
+	DPoPIsEnabled           bool
+	DPoPIsRequired          bool
+	DPoPLifetimeSecs        int
+	DPoPSignatureAlgorithms []jose.SignatureAlgorithm
+	// If DPoPNonceIsRequired is true, DPoP proofs sent to the token endpoint must
+	// contain a nonce previously issued by DPoPNonceStore.
+	DPoPNonceIsRequired    bool
+	DPoPNonceStore         goidc.DPoPNonceStore
+	PkceIsEnabled          bool
+	PkceIsRequired         bool
+	CodeChallengeMethods   []goidc.CodeChallengeMethod
+	SubjectIdentifierTypes []goidc.SubjectIdentifierType
+	Policies               []goidc.AuthnPolicy
+	TokenOptions           goidc.TokenOptionsFuncV2
+	// If AccountCreationIsEnabled is true, requests with "prompt=create" are
+	// handled by the AccountCreationPolicy instead of the regular policies.
+	AccountCreationIsEnabled bool
+	AccountCreationPolicy    goidc.AuthnPolicy
+	// PromptValues are the values of the "prompt" parameter supported by the server.
+	PromptValues []goidc.PromptType
+	// EventHooks are notified of the lifecycle events of the server.
+	EventHooks []goidc.EventHook
+	// Logger is the logger used by the server. If not informed, the default
+	// logger is used.
+	Logger *slog.Logger
+	// If OpaqueTokenHashingIsEnabled is true, only the hash of opaque access tokens is stored as the token ID.
+	// The token value is then hashed again when it's presented so the grant session can be found.
+	OpaqueTokenHashingIsEnabled    bool
+	DCRIsEnabled                   bool
+	ShouldRotateRegistrationTokens bool
+	DCRPlugin                      goidc.DCRPluginFunc
+	DCRMode                        goidc.DCRMode
+	// If DCRProfileIsEnabled is true, clients can choose their profile during
+	// DCR. Otherwise, they can only register the profile of the server.
+	DCRProfileIsEnabled bool
+	// DCRInitialAccessTokens are the tokens accepted to register clients when DCR is protected.
+	DCRInitialAccessTokens []string
+	// DCRInitialAccessTokenValidator is an alternative to DCRInitialAccessTokens to validate initial access tokens.
+	DCRInitialAccessTokenValidator goidc.InitialAccessTokenValidatorFunc
+	// The DCRMax... fields limit how many values a client can register for each metadata.
+	// A value of zero means there is no limit.
+	DCRMaxRedirectURIs               int
+	DCRMaxGrantTypes                 int
+	DCRMaxResponseTypes              int
+	DCRMaxAuthorizationDetailTypes   int
 	AuthenticationSessionTimeoutSecs int64
 	TLSBoundTokensIsEnabled          bool
 	AuthenticationContextReferences  []goidc.ACR
-	DisplayValues                    []goidc.DisplayValue
+	// ACRMatchFunc, if defined, replaces the exact comparison between the
+	// requested and the achieved authentication context references.
+	ACRMatchFunc  goidc.ACRMatchFunc
+	DisplayValues []goidc.DisplayValue
+	// UILocales and ClaimsLocales are the languages supported for the user
+	// interface and for the claim values respectively.
+	UILocales     []string
+	ClaimsLocales []string
+	// If PublicClientRestrictionIsEnabled is true, public clients, i.e. the ones
+	// that authenticate with "none", cannot use the client_credentials grant,
+	// since they cannot keep credentials confidential.
+	PublicClientRestrictionIsEnabled bool
 	// If SenderConstrainedTokenIsRequired is true, at least one mechanism of sender contraining
 	// tokens is required, either DPoP or client TLS.
 	SenderConstrainedTokenIsRequired bool
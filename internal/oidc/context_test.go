@@ -1,6 +1,10 @@
 package oidc_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -126,6 +130,78 @@ func TestExecuteDCRPlugin_HappyPath(t *testing.T) {
 	assert.Equal(t, goidc.ClientAuthnNone, clientInfo.AuthnMethod)
 }
 
+func TestLogger(t *testing.T) {
+	// Given.
+	var logs bytes.Buffer
+	ctx := oidc.NewTestContext(t)
+	ctx.Configuration.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	ctx.CorrelationIDHeader = goidc.HeaderCorrelationID
+	ctx.Req = httptest.NewRequest(http.MethodPost, "/token", nil)
+	ctx.Req.Header.Set(goidc.HeaderCorrelationID, "random_id")
+
+	// When.
+	ctx.AddLogAttrs("client_id", "random_client_id")
+	ctx.Logger().Info("random message")
+
+	// Then.
+	assert.Contains(t, logs.String(), "endpoint=/token")
+	assert.Contains(t, logs.String(), "correlation_id=random_id")
+	assert.Contains(t, logs.String(), "client_id=random_client_id")
+}
+
+func TestWriteError_LogsErrorCode(t *testing.T) {
+	// Given.
+	var logs bytes.Buffer
+	ctx := oidc.NewTestContext(t)
+	ctx.Configuration.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	// When.
+	ctx.WriteError(oidc.NewError(oidc.ErrorCodeInvalidRequest, "random error"))
+	ctx.WriteError(oidc.NewError(oidc.ErrorCodeInternalError, "random error"))
+
+	// Then.
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "level=INFO")
+	assert.Contains(t, lines[0], "error_code=invalid_request")
+	assert.Contains(t, lines[1], "level=ERROR")
+	assert.Contains(t, lines[1], "error_code=internal_error")
+}
+
+func TestNotifyEvent(t *testing.T) {
+	// Given.
+	var logs bytes.Buffer
+	ctx := oidc.NewTestContext(t)
+	ctx.Configuration.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	var events []goidc.Event
+	ctx.EventHooks = []goidc.EventHook{
+		eventHookFunc(func(_ context.Context, _ goidc.Event) {
+			panic("random panic")
+		}),
+		eventHookFunc(func(_ context.Context, event goidc.Event) {
+			events = append(events, event)
+		}),
+	}
+	event := goidc.Event{
+		Type:     goidc.EventTypeTokenIssued,
+		ClientID: "random_client_id",
+	}
+
+	// When.
+	assert.NotPanics(t, func() { ctx.NotifyEvent(event) })
+
+	// Then.
+	assert.Equal(t, []goidc.Event{event}, events, "a hook panicking should not prevent the others from being notified")
+	assert.Contains(t, logs.String(), "random panic")
+}
+
+type eventHookFunc func(ctx context.Context, event goidc.Event)
+
+func (f eventHookFunc) OnEvent(ctx context.Context, event goidc.Event) {
+	f(ctx, event)
+}
+
 func TestGetAudiences_HappyPath(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
@@ -156,6 +232,87 @@ func TestGetAudiences_MTLSIsEnabled(t *testing.T) {
 	assert.Contains(t, audiences, ctx.MTLSHost+"/auth/token")
 }
 
+func TestAssertionAudiences(t *testing.T) {
+	testCases := []struct {
+		mode     goidc.AssertionAudienceMode
+		expected []string
+	}{
+		{"", []string{"https://example.com", "https://example.com/auth/token"}},
+		{goidc.AssertionAudienceModeIssuer, []string{"https://example.com"}},
+		{goidc.AssertionAudienceModeEndpoint, []string{"https://example.com/auth/token", "https://example.com/token"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.mode), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.Host = "https://example.com"
+			ctx.Req = httptest.NewRequest(http.MethodPost, "/auth/token", nil)
+			ctx.AssertionAudienceMode = testCase.mode
+
+			// When.
+			audiences := ctx.AssertionAudiences()
+
+			// Then.
+			assert.ElementsMatch(t, testCase.expected, audiences)
+		})
+	}
+}
+
+func TestAssertionAudiences_EndpointModeAtTokenEndpoint(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.Host = "https://example.com"
+	ctx.MTLSIsEnabled = true
+	ctx.MTLSHost = "https://matls-example.com"
+	ctx.Req = httptest.NewRequest(http.MethodPost, goidc.EndpointToken, nil)
+	ctx.AssertionAudienceMode = goidc.AssertionAudienceModeEndpoint
+
+	// When.
+	audiences := ctx.AssertionAudiences()
+
+	// Then.
+	assert.ElementsMatch(t, []string{"https://example.com/token", "https://matls-example.com/token"}, audiences)
+}
+
+func TestWriteError_WithErrorURI(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ErrorURIBase = "https://docs.example.com"
+	ctx.ErrorURIs = map[string]string{
+		string(oidc.ErrorCodeInvalidScope): "/errors#invalid_scope",
+	}
+
+	// When.
+	ctx.WriteError(oidc.NewError(oidc.ErrorCodeInvalidScope, "invalid scope"))
+
+	// Then.
+	resp := ctx.Response().(*httptest.ResponseRecorder)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var body map[string]any
+	require.Nil(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "invalid_scope", body["error"])
+	assert.Equal(t, "https://docs.example.com/errors#invalid_scope", body["error_uri"])
+}
+
+func TestWriteError_ErrorCodeWithoutErrorURI(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ErrorURIBase = "https://docs.example.com"
+	ctx.ErrorURIs = map[string]string{
+		string(oidc.ErrorCodeInvalidScope): "/errors#invalid_scope",
+	}
+
+	// When.
+	ctx.WriteError(oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid request"))
+
+	// Then.
+	var body map[string]any
+	require.Nil(t, json.Unmarshal(ctx.Response().(*httptest.ResponseRecorder).Body.Bytes(), &body))
+	assert.NotContains(t, body, "error_uri")
+}
+
 func TestGetPolicyByID_HappyPath(t *testing.T) {
 	// Given.
 	policyID := "random_policy_id"
@@ -470,6 +627,136 @@ func TestUserInfoSignatureKey_ClientWithDefaultAlgorithm(t *testing.T) {
 	assert.Equal(t, signingKeyID, jwk.KeyID)
 }
 
+func TestUserInfoSignatureKey_MultipleKeysWithSameAlgorithm(t *testing.T) {
+	// Given.
+	oldKey := oidc.PrivatePS256JWK(t, "old_key")
+	activeKey := oidc.PrivatePS256JWK(t, "active_key")
+
+	ctx := oidc.Context{}
+	ctx.PrivateJWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey, activeKey}}
+	ctx.DefaultUserInfoSignatureKeyID = activeKey.KeyID
+	ctx.UserInfoSignatureKeyIDs = []string{oldKey.KeyID, activeKey.KeyID}
+
+	client := &goidc.Client{}
+	client.UserInfoSignatureAlgorithm = jose.PS256
+
+	// When.
+	jwk := ctx.UserInfoSignatureKey(client)
+
+	// Then.
+	assert.Equal(t, activeKey.KeyID, jwk.KeyID, "the active key should be preferred")
+}
+
+func TestRotateSignatureKey(t *testing.T) {
+	// Given.
+	oldKey := oidc.PrivatePS256JWK(t, "old_key")
+	newKey := oidc.PrivatePS256JWK(t, "new_key")
+
+	config := oidc.Configuration{}
+	config.PrivateJWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey}}
+	config.DefaultUserInfoSignatureKeyID = oldKey.KeyID
+	config.UserInfoSignatureKeyIDs = []string{oldKey.KeyID}
+
+	// When.
+	oldKeyID, err := config.RotateSignatureKey(goidc.SignatureKeyPurposeUserInfo, newKey)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, oldKey.KeyID, oldKeyID)
+	assert.Equal(t, newKey.KeyID, config.DefaultUserInfoSignatureKeyID)
+	assert.Equal(t, []string{oldKey.KeyID, newKey.KeyID}, config.UserInfoSignatureKeyIDs)
+	assert.Len(t, config.PrivateJWKS.Keys, 2, "the previous key should still be published")
+}
+
+func TestRotateSignatureKey_PublishedKey(t *testing.T) {
+	// Given.
+	oldKey := oidc.PrivatePS256JWK(t, "old_key")
+	newKey := oidc.PrivatePS256JWK(t, "new_key")
+
+	config := oidc.Configuration{}
+	config.PrivateJWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey, newKey}}
+	config.DefaultTokenSignatureKeyID = oldKey.KeyID
+
+	// When.
+	oldKeyID, err := config.RotateSignatureKey(goidc.SignatureKeyPurposeToken, jose.JSONWebKey{KeyID: newKey.KeyID})
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, oldKey.KeyID, oldKeyID)
+	assert.Equal(t, newKey.KeyID, config.DefaultTokenSignatureKeyID)
+	assert.Len(t, config.PrivateJWKS.Keys, 2, "the published key should not be added again")
+}
+
+func TestRotateSignatureKey_InvalidKey(t *testing.T) {
+	// Given.
+	config := oidc.Configuration{}
+	config.PrivateJWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oidc.PrivatePS256JWK(t, "key_id")}}
+	config.DefaultTokenSignatureKeyID = "key_id"
+	newKey := oidc.PrivatePS256JWK(t, "new_key")
+
+	// When.
+	_, err := config.RotateSignatureKey(goidc.SignatureKeyPurposeToken, newKey.Public())
+
+	// Then.
+	assert.NotNil(t, err)
+	assert.Equal(t, "key_id", config.DefaultTokenSignatureKeyID)
+	assert.Len(t, config.PrivateJWKS.Keys, 1)
+}
+
+func TestRotateSignatureKey_InvalidPurpose(t *testing.T) {
+	// Given.
+	config := oidc.Configuration{}
+	config.PrivateJWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oidc.PrivatePS256JWK(t, "key_id")}}
+
+	// When.
+	_, err := config.RotateSignatureKey("invalid_purpose", oidc.PrivatePS256JWK(t, "new_key"))
+
+	// Then.
+	assert.NotNil(t, err)
+	assert.Len(t, config.PrivateJWKS.Keys, 1, "the key should not be published")
+}
+
+func TestRetireKey(t *testing.T) {
+	// Given.
+	oldKey := oidc.PrivatePS256JWK(t, "old_key")
+	activeKey := oidc.PrivatePS256JWK(t, "active_key")
+
+	config := oidc.Configuration{}
+	config.PrivateJWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey, activeKey}}
+	config.DefaultUserInfoSignatureKeyID = activeKey.KeyID
+	config.UserInfoSignatureKeyIDs = []string{oldKey.KeyID, activeKey.KeyID}
+	config.DefaultJARMSignatureKeyID = activeKey.KeyID
+	config.JARMSignatureKeyIDs = []string{oldKey.KeyID, activeKey.KeyID}
+
+	// When.
+	config.RetireKey(oldKey.KeyID)
+
+	// Then.
+	assert.Equal(t, []jose.JSONWebKey{activeKey}, config.PrivateJWKS.Keys)
+	assert.Equal(t, []string{activeKey.KeyID}, config.UserInfoSignatureKeyIDs)
+	assert.Equal(t, []string{activeKey.KeyID}, config.JARMSignatureKeyIDs)
+
+	ctx := oidc.Context{Configuration: config}
+	client := &goidc.Client{}
+	client.UserInfoSignatureAlgorithm = jose.PS256
+	assert.NotPanics(t, func() { ctx.UserInfoSignatureKey(client) })
+}
+
+func TestRetireKey_DefaultKey(t *testing.T) {
+	// Given.
+	key := oidc.PrivatePS256JWK(t, "key_id")
+
+	config := oidc.Configuration{}
+	config.PrivateJWKS = jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key}}
+	config.DefaultTokenSignatureKeyID = key.KeyID
+
+	// When.
+	config.RetireKey(key.KeyID)
+
+	// Then.
+	assert.Len(t, config.PrivateJWKS.Keys, 1, "keys used by default should not be retired")
+}
+
 func TestIDTokenSignatureKey_HappyPath(t *testing.T) {
 	// Given.
 	signingKeyID := "signing_key"
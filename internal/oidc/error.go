@@ -1,6 +1,11 @@
 package oidc
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
 
 type ErrorCode string
 
@@ -13,17 +18,39 @@ const (
 	ErrorCodeInvalidScope                ErrorCode = "invalid_scope"
 	ErrorCodeInvalidAuthorizationDetails ErrorCode = "invalid_authorization_details"
 	ErrorCodeUnsupportedGrantType        ErrorCode = "unsupported_grant_type"
+	ErrorCodeUnsupportedResponseType     ErrorCode = "unsupported_response_type"
 	ErrorCodeInvalidResquestObject       ErrorCode = "invalid_request_object"
+	ErrorCodeInvalidRequestURI           ErrorCode = "invalid_request_uri"
 	ErrorCodeInvalidToken                ErrorCode = "invalid_token"
+	ErrorCodeInvalidTarget               ErrorCode = "invalid_target"
+	ErrorCodeInvalidClientMetadata       ErrorCode = "invalid_client_metadata"
+	ErrorCodeLoginRequired               ErrorCode = "login_required"
+	ErrorCodeConsentRequired             ErrorCode = "consent_required"
+	ErrorCodeInteractionRequired         ErrorCode = "interaction_required"
+	ErrorCodeAuthorizationPending        ErrorCode = "authorization_pending"
+	ErrorCodeSlowDown                    ErrorCode = "slow_down"
+	ErrorCodeExpiredToken                ErrorCode = "expired_token"
+	ErrorCodeUseDPoPNonce                ErrorCode = "use_dpop_nonce"
+	ErrorCodeTooManyRequests             ErrorCode = "too_many_requests"
+	ErrorCodeInsufficientScope           ErrorCode = "insufficient_scope"
+	// ErrorCodeUnmetAuthenticationRequirements is defined by OpenID Connect
+	// Core Unmet Authentication Requirements 1.0.
+	ErrorCodeUnmetAuthenticationRequirements ErrorCode = "unmet_authentication_requirements"
+	// ErrorCodeInvalidSoftwareStatement and ErrorCodeUnapprovedSoftwareStatement
+	// are defined by RFC 7591.
+	ErrorCodeInvalidSoftwareStatement    ErrorCode = "invalid_software_statement"
+	ErrorCodeUnapprovedSoftwareStatement ErrorCode = "unapproved_software_statement"
 	ErrorCodeInternalError               ErrorCode = "internal_error"
 )
 
 func (ec ErrorCode) StatusCode() int {
 	switch ec {
-	case ErrorCodeAccessDenied:
+	case ErrorCodeAccessDenied, ErrorCodeInsufficientScope:
 		return http.StatusForbidden
 	case ErrorCodeInvalidClient, ErrorCodeInvalidToken, ErrorCodeUnauthorizedClient:
 		return http.StatusUnauthorized
+	case ErrorCodeTooManyRequests:
+		return http.StatusTooManyRequests
 	case ErrorCodeInternalError:
 		return http.StatusInternalServerError
 	default:
@@ -55,3 +82,28 @@ func NewError(code ErrorCode, description string) Error {
 		ErrorDescription: description,
 	}
 }
+
+// BuildWWWAuthenticate returns the value of the WWW-Authenticate header for
+// the authentication scheme informed, e.g. "Bearer" or "DPoP", as described in
+// RFC 6750 and RFC 9449.
+// The parameters are written in alphabetical order and the ones with empty
+// values are skipped.
+func BuildWWWAuthenticate(scheme string, params map[string]string) string {
+	var names []string
+	for name, value := range params {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	attributes := make([]string, 0, len(names))
+	for _, name := range names {
+		attributes = append(attributes, fmt.Sprintf("%s=%q", name, params[name]))
+	}
+
+	if len(attributes) == 0 {
+		return scheme
+	}
+	return scheme + " " + strings.Join(attributes, ", ")
+}
@@ -0,0 +1,53 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildWWWAuthenticate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		scheme   string
+		params   map[string]string
+		expected string
+	}{
+		{
+			"bearer without error",
+			"Bearer",
+			nil,
+			"Bearer",
+		},
+		{
+			"bearer with insufficient scope",
+			"Bearer",
+			map[string]string{
+				"error":             "insufficient_scope",
+				"error_description": "the token was not granted the scope openid",
+				"scope":             "openid",
+			},
+			`Bearer error="insufficient_scope", error_description="the token was not granted the scope openid", scope="openid"`,
+		},
+		{
+			"dpop with algorithms",
+			"DPoP",
+			map[string]string{
+				"algs":              "ES256 PS256",
+				"error":             "invalid_token",
+				"error_description": "",
+			},
+			`DPoP algs="ES256 PS256", error="invalid_token"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			header := BuildWWWAuthenticate(testCase.scheme, testCase.params)
+
+			// Then.
+			assert.Equal(t, testCase.expected, header)
+		})
+	}
+}
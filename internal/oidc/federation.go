@@ -0,0 +1,188 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+const (
+	entityStatementType     = "entity-statement+jwt"
+	relyingPartyEntityType  = "openid_relying_party"
+	entityStatementMaxDepth = 10
+)
+
+var entityStatementSignatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// EntityStatement is a signed statement issued by an entity of an OpenID federation
+// about itself, the entity configuration, or about one of its subordinates.
+type EntityStatement struct {
+	Issuer         string                     `json:"iss"`
+	Subject        string                     `json:"sub"`
+	IssuedAt       int64                      `json:"iat"`
+	ExpiresAt      int64                      `json:"exp"`
+	JWKS           jose.JSONWebKeySet         `json:"jwks"`
+	Metadata       map[string]json.RawMessage `json:"metadata,omitempty"`
+	AuthorityHints []string                   `json:"authority_hints,omitempty"`
+}
+
+type parsedEntityStatement struct {
+	jws *jose.JSONWebSignature
+	EntityStatement
+}
+
+// ValidateTrustChain verifies that the trust chain of the entity identified by
+// entityID resolves to one of the trust anchors.
+// The first statement of the chain must be the entity configuration of the entity
+// and each statement must be signed with a key present in the statement that
+// follows it. The last statement must be signed by a trust anchor.
+// The entity configuration is returned if the chain is valid.
+func ValidateTrustChain(
+	chain []string,
+	entityID string,
+	trustAnchors []goidc.TrustAnchor,
+) (
+	EntityStatement,
+	error,
+) {
+	if len(chain) < 2 {
+		return EntityStatement{}, errors.New("the trust chain must contain at least the entity configuration and a statement issued by a trust anchor")
+	}
+
+	if len(chain) > entityStatementMaxDepth {
+		return EntityStatement{}, errors.New("the trust chain is too long")
+	}
+
+	statements := make([]parsedEntityStatement, len(chain))
+	for i, rawStatement := range chain {
+		statement, err := parseEntityStatement(rawStatement)
+		if err != nil {
+			return EntityStatement{}, fmt.Errorf("invalid entity statement at position %d: %w", i, err)
+		}
+		statements[i] = statement
+	}
+
+	entityConfig := statements[0]
+	if entityConfig.Issuer != entityID || entityConfig.Subject != entityID {
+		return EntityStatement{}, errors.New("the entity configuration must be issued by the entity about itself")
+	}
+
+	// The entity configuration is self-signed.
+	if err := verifyEntityStatement(entityConfig, entityConfig.JWKS); err != nil {
+		return EntityStatement{}, err
+	}
+
+	for i := 1; i < len(statements); i++ {
+		if statements[i].Subject != statements[i-1].Issuer {
+			return EntityStatement{}, fmt.Errorf("the entity statement at position %d is not about %s", i, statements[i-1].Issuer)
+		}
+
+		if err := verifyEntityStatement(statements[i-1], statements[i].JWKS); err != nil {
+			return EntityStatement{}, err
+		}
+	}
+
+	last := statements[len(statements)-1]
+	for _, trustAnchor := range trustAnchors {
+		if trustAnchor.EntityID == last.Issuer {
+			if err := verifyEntityStatement(last, trustAnchor.JWKS); err != nil {
+				return EntityStatement{}, err
+			}
+			return entityConfig.EntityStatement, nil
+		}
+	}
+
+	return EntityStatement{}, errors.New("the trust chain doesn't resolve to a trust anchor")
+}
+
+func parseEntityStatement(statement string) (parsedEntityStatement, error) {
+	jws, err := jose.ParseSigned(statement, entityStatementSignatureAlgorithms)
+	if err != nil {
+		return parsedEntityStatement{}, err
+	}
+
+	if len(jws.Signatures) != 1 {
+		return parsedEntityStatement{}, errors.New("the entity statement must have exactly one signature")
+	}
+
+	if jws.Signatures[0].Header.ExtraHeaders["typ"] != entityStatementType {
+		return parsedEntityStatement{}, errors.New("invalid typ header. it should be " + entityStatementType)
+	}
+
+	// The claims are read before the signature is verified so the keys of the
+	// next statement in the chain can be identified.
+	var claims EntityStatement
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		return parsedEntityStatement{}, err
+	}
+
+	now := time.Now().Unix()
+	if claims.ExpiresAt == 0 || now > claims.ExpiresAt {
+		return parsedEntityStatement{}, errors.New("the entity statement is expired")
+	}
+
+	if claims.IssuedAt > now {
+		return parsedEntityStatement{}, errors.New("the entity statement was issued in the future")
+	}
+
+	return parsedEntityStatement{jws: jws, EntityStatement: claims}, nil
+}
+
+func verifyEntityStatement(statement parsedEntityStatement, jwks jose.JSONWebKeySet) error {
+	keyID := statement.jws.Signatures[0].Header.KeyID
+	keys := jwks.Key(keyID)
+	if keyID == "" || len(keys) == 0 {
+		return fmt.Errorf("the entity statement issued by %s was signed with an unknown key", statement.Issuer)
+	}
+
+	if _, err := statement.jws.Verify(keys[0].Public().Key); err != nil {
+		return fmt.Errorf("invalid signature for the entity statement issued by %s: %w", statement.Issuer, err)
+	}
+
+	return nil
+}
+
+// federationClient builds a client from the relying party metadata of the entity
+// configuration of a federation entity whose trust chain is valid.
+func (ctx *Context) federationClient(entityID string) (*goidc.Client, error) {
+	chain, err := ctx.FederationTrustChainFunc(ctx, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the trust chain: %w", err)
+	}
+
+	entityConfig, err := ValidateTrustChain(chain, entityID, ctx.FederationTrustAnchors)
+	if err != nil {
+		return nil, err
+	}
+
+	rawMetadata, ok := entityConfig.Metadata[relyingPartyEntityType]
+	if !ok {
+		return nil, errors.New("the entity is not a relying party")
+	}
+
+	var metadata goidc.ClientMetaInfo
+	if err := json.Unmarshal(rawMetadata, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid relying party metadata: %w", err)
+	}
+
+	return &goidc.Client{
+		ID:             entityID,
+		ClientMetaInfo: metadata,
+	}, nil
+}
+
+// isEntityID returns whether the identifier is a federation entity identifier,
+// i.e. an https URL.
+func isEntityID(id string) bool {
+	return strings.HasPrefix(id, "https://")
+}
@@ -0,0 +1,173 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTrustChain(t *testing.T) {
+	leafJWK := PrivateRS256JWK(t, "leaf_key")
+	intermediateJWK := PrivateRS256JWK(t, "intermediate_key")
+	anchorJWK := PrivateRS256JWK(t, "anchor_key")
+	attackerJWK := PrivateRS256JWK(t, "leaf_key")
+
+	leafID := "https://client.com"
+	intermediateID := "https://intermediate.com"
+	anchorID := "https://anchor.com"
+	trustAnchors := []goidc.TrustAnchor{
+		{EntityID: anchorID, JWKS: publicJWKS(anchorJWK)},
+	}
+
+	leafConfig := signedEntityStatement(t, leafJWK, leafID, leafID, publicJWKS(leafJWK))
+	intermediateStatement := signedEntityStatement(t, intermediateJWK, intermediateID, leafID, publicJWKS(leafJWK))
+	anchorStatement := signedEntityStatement(t, anchorJWK, anchorID, intermediateID, publicJWKS(intermediateJWK))
+
+	testCases := []struct {
+		name          string
+		chain         []string
+		shouldBeValid bool
+	}{
+		{
+			"valid chain",
+			[]string{leafConfig, intermediateStatement, anchorStatement},
+			true,
+		},
+		{
+			"chain not resolving to a trust anchor",
+			[]string{leafConfig, intermediateStatement},
+			false,
+		},
+		{
+			"entity configuration signed with an unknown key",
+			[]string{
+				signedEntityStatement(t, attackerJWK, leafID, leafID, publicJWKS(attackerJWK)),
+				intermediateStatement,
+				anchorStatement,
+			},
+			false,
+		},
+		{
+			"statement about another entity",
+			[]string{
+				leafConfig,
+				signedEntityStatement(t, intermediateJWK, intermediateID, "https://another.com", publicJWKS(leafJWK)),
+				anchorStatement,
+			},
+			false,
+		},
+		{
+			"statement signed by an impersonated trust anchor",
+			[]string{
+				leafConfig,
+				intermediateStatement,
+				signedEntityStatement(t, attackerJWK, anchorID, intermediateID, publicJWKS(intermediateJWK)),
+			},
+			false,
+		},
+		{
+			"missing statements",
+			[]string{leafConfig},
+			false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			entityConfig, err := ValidateTrustChain(testCase.chain, leafID, trustAnchors)
+
+			// Then.
+			if !testCase.shouldBeValid {
+				assert.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, leafID, entityConfig.Subject)
+		})
+	}
+}
+
+func TestClient_Federation(t *testing.T) {
+	// Given.
+	leafJWK := PrivateRS256JWK(t, "leaf_key")
+	anchorJWK := PrivateRS256JWK(t, "anchor_key")
+	leafID := "https://client.com"
+	anchorID := "https://anchor.com"
+
+	ctx := NewTestContext(t)
+	ctx.FederationIsEnabled = true
+	ctx.FederationTrustAnchors = []goidc.TrustAnchor{
+		{EntityID: anchorID, JWKS: publicJWKS(anchorJWK)},
+	}
+	ctx.FederationTrustChainFunc = func(_ goidc.Context, entityID string) ([]string, error) {
+		if entityID != leafID {
+			return nil, errors.New("unknown entity")
+		}
+		return []string{
+			signedEntityStatement(t, leafJWK, leafID, leafID, publicJWKS(leafJWK)),
+			signedEntityStatement(t, anchorJWK, anchorID, leafID, publicJWKS(leafJWK)),
+		}, nil
+	}
+
+	// When.
+	client, err := ctx.Client(leafID)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, leafID, client.ID)
+	assert.Equal(t, []string{"https://client.com/callback"}, client.RedirectURIS)
+
+	// When.
+	_, err = ctx.Client("https://another.com")
+
+	// Then.
+	assert.NotNil(t, err)
+}
+
+func signedEntityStatement(
+	t *testing.T,
+	jwk jose.JSONWebKey,
+	issuer string,
+	subject string,
+	jwks jose.JSONWebKeySet,
+) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(jwk.Algorithm), Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType(entityStatementType).WithHeader("kid", jwk.KeyID),
+	)
+	require.Nil(t, err)
+
+	now := time.Now().Unix()
+	statement := EntityStatement{
+		Issuer:    issuer,
+		Subject:   subject,
+		IssuedAt:  now,
+		ExpiresAt: now + 60,
+		JWKS:      jwks,
+	}
+	if issuer == subject {
+		statement.Metadata = map[string]json.RawMessage{
+			relyingPartyEntityType: json.RawMessage(`{"redirect_uris":["https://client.com/callback"]}`),
+		}
+	}
+
+	jws, err := jwt.Signed(signer).Claims(statement).Serialize()
+	require.Nil(t, err)
+
+	return jws
+}
+
+func publicJWKS(jwk jose.JSONWebKey) jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}}
+}
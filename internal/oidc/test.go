@@ -9,9 +9,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/jwksutil"
 	"github.com/luikyv/go-oidc/internal/storage/inmemory"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/require"
@@ -65,14 +67,16 @@ func NewTestClient(_ *testing.T) *goidc.Client {
 
 func NewTestContext(t *testing.T) *Context {
 	config := Configuration{
-		Profile:             goidc.ProfileOpenID,
-		Host:                TestHost,
-		ClientManager:       inmemory.NewClientManager(),
-		GrantSessionManager: inmemory.NewGrantSessionManager(),
-		AuthnSessionManager: inmemory.NewAuthnSessionManager(),
-		Scopes:              []goidc.Scope{goidc.ScopeOpenID, TestScope1, TestScope2},
-		PrivateJWKS:         jose.JSONWebKeySet{Keys: []jose.JSONWebKey{TestServerPrivateJWK}},
-		ClientAuthnMethods:  []goidc.ClientAuthnType{goidc.ClientAuthnNone, goidc.ClientAuthnSecretPost},
+		Profile:              goidc.ProfileOpenID,
+		Host:                 TestHost,
+		ClientManager:        inmemory.NewClientManager(),
+		GrantSessionManager:  inmemory.NewGrantSessionManager(),
+		AuthnSessionManager:  inmemory.NewAuthnSessionManager(),
+		DeviceSessionManager: inmemory.NewDeviceSessionManager(),
+		LogoutSessionManager: inmemory.NewLogoutSessionManager(),
+		Scopes:               []goidc.Scope{goidc.ScopeOpenID, TestScope1, TestScope2},
+		PrivateJWKS:          jose.JSONWebKeySet{Keys: []jose.JSONWebKey{TestServerPrivateJWK}},
+		ClientAuthnMethods:   []goidc.ClientAuthnType{goidc.ClientAuthnNone, goidc.ClientAuthnSecretPost},
 		GrantTypes: []goidc.GrantType{
 			goidc.GrantAuthorizationCode,
 			goidc.GrantClientCredentials,
@@ -89,16 +93,27 @@ func NewTestContext(t *testing.T) *Context {
 			goidc.ResponseTypeIDTokenAndToken,
 			goidc.ResponseTypeCodeAndIDTokenAndToken,
 		},
+		ResponseModes: []goidc.ResponseMode{
+			goidc.ResponseModeQuery,
+			goidc.ResponseModeFragment,
+			goidc.ResponseModeFormPost,
+			goidc.ResponseModeJWT,
+			goidc.ResponseModeQueryJWT,
+			goidc.ResponseModeFragmentJWT,
+			goidc.ResponseModeFormPostJWT,
+		},
 		DefaultTokenSignatureKeyID:    TestServerPrivateJWK.KeyID,
 		DefaultUserInfoSignatureKeyID: TestServerPrivateJWK.KeyID,
 		UserInfoSignatureKeyIDs:       []string{TestServerPrivateJWK.KeyID},
-		TokenOptions: func(client *goidc.Client, scopes string) (goidc.TokenOptions, error) {
+		TokenOptions: func(client *goidc.Client, grantType goidc.GrantType, scopes string, resources goidc.Resources) (goidc.TokenOptions, error) {
 			return goidc.TokenOptions{
 				TokenLifetimeSecs: 60,
 				TokenFormat:       goidc.TokenFormatJWT,
 			}, nil
 		},
 		AuthenticationSessionTimeoutSecs: 60,
+		SecretHasher:                     goidc.BCryptHasher{},
+		IssuerJWKSCache:                  jwksutil.NewCache(time.Minute, 0),
 	}
 	ctx := Context{
 		Configuration: config,
@@ -131,6 +146,26 @@ func GrantSessions(_ *testing.T, ctx *Context) []*goidc.GrantSession {
 	return tokens
 }
 
+func DeviceSessions(_ *testing.T, ctx *Context) []*goidc.DeviceSession {
+	manager, _ := ctx.DeviceSessionManager.(*inmemory.DeviceSessionManager)
+	sessions := make([]*goidc.DeviceSession, 0, len(manager.Sessions))
+	for _, s := range manager.Sessions {
+		sessions = append(sessions, s)
+	}
+
+	return sessions
+}
+
+func LogoutSessions(_ *testing.T, ctx *Context) []*goidc.LogoutSession {
+	manager, _ := ctx.LogoutSessionManager.(*inmemory.LogoutSessionManager)
+	sessions := make([]*goidc.LogoutSession, 0, len(manager.Sessions))
+	for _, s := range manager.Sessions {
+		sessions = append(sessions, s)
+	}
+
+	return sessions
+}
+
 func Clients(_ *testing.T, ctx *Context) []*goidc.Client {
 	manager, _ := ctx.ClientManager.(*inmemory.ClientManager)
 	clients := make([]*goidc.Client, 0, len(manager.Clients))
@@ -3,12 +3,16 @@ package inmemory
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
 type AuthnSessionManager struct {
 	Sessions map[string]*goidc.AuthnSession
+	mu       sync.RWMutex
+	sweeper  *sweeper
 }
 
 func NewAuthnSessionManager() *AuthnSessionManager {
@@ -17,10 +21,28 @@ func NewAuthnSessionManager() *AuthnSessionManager {
 	}
 }
 
+// NewAuthnSessionManagerWithSweeper creates a manager that removes the expired
+// sessions every interval. Close must be called to stop the sweeper.
+func NewAuthnSessionManagerWithSweeper(interval time.Duration) *AuthnSessionManager {
+	manager := NewAuthnSessionManager()
+	manager.sweeper = startSweeper(interval, manager.removeExpired)
+	return manager
+}
+
+// Close stops the sweeper, if there is one.
+func (manager *AuthnSessionManager) Close() {
+	if manager.sweeper != nil {
+		manager.sweeper.stop()
+	}
+}
+
 func (manager *AuthnSessionManager) Save(
 	_ context.Context,
 	session *goidc.AuthnSession,
 ) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
 	manager.Sessions[session.ID] = session
 	return nil
 }
@@ -77,16 +99,33 @@ func (manager *AuthnSessionManager) GetByRequestURI(
 }
 
 func (manager *AuthnSessionManager) Delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
 	delete(manager.Sessions, id)
 	return nil
 }
 
+func (manager *AuthnSessionManager) removeExpired() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for id, session := range manager.Sessions {
+		if session.IsExpired() {
+			delete(manager.Sessions, id)
+		}
+	}
+}
+
 func (manager *AuthnSessionManager) getFirstSession(
 	condition func(*goidc.AuthnSession) bool,
 ) (
 	*goidc.AuthnSession,
 	bool,
 ) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
 	sessions := make([]*goidc.AuthnSession, 0, len(manager.Sessions))
 	for _, s := range manager.Sessions {
 		sessions = append(sessions, s)
@@ -3,6 +3,7 @@ package inmemory_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/luikyv/go-oidc/internal/storage/inmemory"
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -115,3 +116,30 @@ func TestDeleteAuthnSession_SessionDoesNotExist(t *testing.T) {
 	// Then.
 	require.Nil(t, err)
 }
+
+func TestAuthnSessionManagerWithSweeper(t *testing.T) {
+	// Given.
+	manager := inmemory.NewAuthnSessionManagerWithSweeper(10 * time.Millisecond)
+	t.Cleanup(manager.Close)
+
+	now := time.Now().Unix()
+	require.Nil(t, manager.Save(context.Background(), &goidc.AuthnSession{
+		ID:                 "expired_session_id",
+		CallbackID:         "expired_callback_id",
+		ExpiresAtTimestamp: now - 10,
+	}))
+	require.Nil(t, manager.Save(context.Background(), &goidc.AuthnSession{
+		ID:                 "valid_session_id",
+		CallbackID:         "valid_callback_id",
+		ExpiresAtTimestamp: now + 60,
+	}))
+
+	// Then.
+	assert.Eventually(t, func() bool {
+		_, err := manager.GetByCallbackID(context.Background(), "expired_callback_id")
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "the expired session should be removed")
+
+	_, err := manager.GetByCallbackID(context.Background(), "valid_callback_id")
+	assert.Nil(t, err, "the valid session should be kept")
+}
@@ -0,0 +1,90 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+type DeviceSessionManager struct {
+	Sessions map[string]*goidc.DeviceSession
+	mu       sync.RWMutex
+}
+
+func NewDeviceSessionManager() *DeviceSessionManager {
+	return &DeviceSessionManager{
+		Sessions: make(map[string]*goidc.DeviceSession),
+	}
+}
+
+func (manager *DeviceSessionManager) Save(
+	_ context.Context,
+	session *goidc.DeviceSession,
+) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.Sessions[session.ID] = session
+	return nil
+}
+
+func (manager *DeviceSessionManager) GetByDeviceCode(
+	_ context.Context,
+	deviceCode string,
+) (
+	*goidc.DeviceSession,
+	error,
+) {
+	session, exists := manager.getFirstSession(func(s *goidc.DeviceSession) bool {
+		return s.DeviceCode == deviceCode
+	})
+	if !exists {
+		return nil, errors.New("entity not found")
+	}
+
+	return session, nil
+}
+
+func (manager *DeviceSessionManager) GetByUserCode(
+	_ context.Context,
+	userCode string,
+) (
+	*goidc.DeviceSession,
+	error,
+) {
+	session, exists := manager.getFirstSession(func(s *goidc.DeviceSession) bool {
+		return s.UserCode == userCode
+	})
+	if !exists {
+		return nil, errors.New("entity not found")
+	}
+
+	return session, nil
+}
+
+func (manager *DeviceSessionManager) Delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	delete(manager.Sessions, id)
+	return nil
+}
+
+func (manager *DeviceSessionManager) getFirstSession(
+	condition func(*goidc.DeviceSession) bool,
+) (
+	*goidc.DeviceSession,
+	bool,
+) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	sessions := make([]*goidc.DeviceSession, 0, len(manager.Sessions))
+	for _, s := range manager.Sessions {
+		sessions = append(sessions, s)
+	}
+
+	return findFirst(sessions, condition)
+}
@@ -0,0 +1,96 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateOrUpdateDeviceSession_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewDeviceSessionManager()
+	session := &goidc.DeviceSession{
+		ID: "random_session_id",
+	}
+
+	// When.
+	err := manager.Save(context.Background(), session)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, manager.Sessions, 1, "there should be exactly one session")
+
+	// When.
+	err = manager.Save(context.Background(), session)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, manager.Sessions, 1, "there should be exactly one session")
+}
+
+func TestGetDeviceSessionByDeviceCode_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewDeviceSessionManager()
+	sessionID := "random_session_id"
+	deviceCode := "random_device_code"
+	manager.Sessions[sessionID] = &goidc.DeviceSession{
+		ID:         sessionID,
+		DeviceCode: deviceCode,
+	}
+
+	// When.
+	session, err := manager.GetByDeviceCode(context.Background(), deviceCode)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, sessionID, session.ID, "invalid session ID")
+}
+
+func TestGetDeviceSessionByUserCode_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewDeviceSessionManager()
+	sessionID := "random_session_id"
+	userCode := "BCDFGHJK"
+	manager.Sessions[sessionID] = &goidc.DeviceSession{
+		ID:       sessionID,
+		UserCode: userCode,
+	}
+
+	// When.
+	session, err := manager.GetByUserCode(context.Background(), userCode)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, sessionID, session.ID, "invalid session ID")
+}
+
+func TestGetDeviceSessionByUserCode_SessionDoesNotExist(t *testing.T) {
+	// Given.
+	manager := inmemory.NewDeviceSessionManager()
+
+	// When.
+	_, err := manager.GetByUserCode(context.Background(), "BCDFGHJK")
+
+	// Then.
+	assert.NotNil(t, err)
+}
+
+func TestDeleteDeviceSession_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewDeviceSessionManager()
+	sessionID := "random_session_id"
+	manager.Sessions[sessionID] = &goidc.DeviceSession{
+		ID: sessionID,
+	}
+
+	// When.
+	err := manager.Delete(context.Background(), sessionID)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, manager.Sessions, 0, "the session should be deleted")
+}
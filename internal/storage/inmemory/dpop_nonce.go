@@ -0,0 +1,68 @@
+package inmemory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/strutil"
+)
+
+const dpopNonceLength = 32
+
+type dpopNonce struct {
+	clientID           string
+	expiresAtTimestamp int64
+}
+
+// DPoPNonceStore keeps the DPoP nonces in memory. Each nonce can be consumed
+// only once and is valid for lifetimeSecs.
+type DPoPNonceStore struct {
+	Nonces       map[string]dpopNonce
+	lifetimeSecs int64
+	mu           sync.Mutex
+}
+
+func NewDPoPNonceStore(lifetimeSecs int64) *DPoPNonceStore {
+	return &DPoPNonceStore{
+		Nonces:       make(map[string]dpopNonce),
+		lifetimeSecs: lifetimeSecs,
+	}
+}
+
+func (store *DPoPNonceStore) Issue(_ context.Context, clientID string) (string, error) {
+	nonce, err := strutil.Random(dpopNonceLength)
+	if err != nil {
+		return "", err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// Remove the expired nonces so the store doesn't grow indefinitely.
+	now := time.Now().Unix()
+	for n, info := range store.Nonces {
+		if now > info.expiresAtTimestamp {
+			delete(store.Nonces, n)
+		}
+	}
+
+	store.Nonces[nonce] = dpopNonce{
+		clientID:           clientID,
+		expiresAtTimestamp: now + store.lifetimeSecs,
+	}
+	return nonce, nil
+}
+
+func (store *DPoPNonceStore) Consume(_ context.Context, clientID, nonce string) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	info, exists := store.Nonces[nonce]
+	if !exists {
+		return false, nil
+	}
+
+	delete(store.Nonces, nonce)
+	return info.clientID == clientID && time.Now().Unix() <= info.expiresAtTimestamp, nil
+}
@@ -0,0 +1,60 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDPoPNonceStore(t *testing.T) {
+	// Given.
+	store := inmemory.NewDPoPNonceStore(60)
+	nonce, err := store.Issue(context.Background(), "random_client_id")
+	require.Nil(t, err)
+
+	// When.
+	ok, err := store.Consume(context.Background(), "random_client_id", nonce)
+
+	// Then.
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	// When.
+	ok, err = store.Consume(context.Background(), "random_client_id", nonce)
+
+	// Then.
+	require.Nil(t, err)
+	assert.False(t, ok, "the nonce cannot be replayed")
+}
+
+func TestDPoPNonceStore_IssuedToAnotherClient(t *testing.T) {
+	// Given.
+	store := inmemory.NewDPoPNonceStore(60)
+	nonce, err := store.Issue(context.Background(), "random_client_id")
+	require.Nil(t, err)
+
+	// When.
+	ok, err := store.Consume(context.Background(), "another_client_id", nonce)
+
+	// Then.
+	require.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestDPoPNonceStore_ExpiredNonce(t *testing.T) {
+	// Given.
+	store := inmemory.NewDPoPNonceStore(-1)
+	nonce, err := store.Issue(context.Background(), "random_client_id")
+	require.Nil(t, err)
+
+	// When.
+	ok, err := store.Consume(context.Background(), "random_client_id", nonce)
+
+	// Then.
+	require.Nil(t, err)
+	assert.False(t, ok)
+	assert.Empty(t, store.Nonces)
+}
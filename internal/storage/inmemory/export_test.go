@@ -0,0 +1,8 @@
+package inmemory
+
+// BucketCount exposes how many buckets the rate limiter keeps to the tests.
+func BucketCount(limiter *RateLimiter) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return len(limiter.buckets)
+}
@@ -11,3 +11,15 @@ func findFirst[T interface{}](slice []T, condition func(T) bool) (element T, ok
 
 	return element, false
 }
+
+// Return all the elements in a slice for which the condition is true.
+func findAll[T interface{}](slice []T, condition func(T) bool) []T {
+	var elements []T
+	for _, element := range slice {
+		if condition(element) {
+			elements = append(elements, element)
+		}
+	}
+
+	return elements
+}
@@ -3,12 +3,17 @@ package inmemory
 import (
 	"context"
 	"errors"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
 type GrantSessionManager struct {
 	Sessions map[string]*goidc.GrantSession
+	mu       sync.RWMutex
+	sweeper  *sweeper
 }
 
 func NewGrantSessionManager() *GrantSessionManager {
@@ -17,7 +22,25 @@ func NewGrantSessionManager() *GrantSessionManager {
 	}
 }
 
+// NewGrantSessionManagerWithSweeper creates a manager that removes the expired
+// grant sessions every interval. Close must be called to stop the sweeper.
+func NewGrantSessionManagerWithSweeper(interval time.Duration) *GrantSessionManager {
+	manager := NewGrantSessionManager()
+	manager.sweeper = startSweeper(interval, manager.removeExpired)
+	return manager
+}
+
+// Close stops the sweeper, if there is one.
+func (manager *GrantSessionManager) Close() {
+	if manager.sweeper != nil {
+		manager.sweeper.stop()
+	}
+}
+
 func (manager *GrantSessionManager) Save(_ context.Context, grantSession *goidc.GrantSession) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
 	manager.Sessions[grantSession.ID] = grantSession
 	return nil
 }
@@ -44,16 +67,60 @@ func (manager *GrantSessionManager) GetByRefreshToken(_ context.Context, refresh
 	return grantSession, nil
 }
 
+func (manager *GrantSessionManager) GetByPreviousRefreshToken(_ context.Context, refreshToken string) (*goidc.GrantSession, error) {
+	grantSession, exists := manager.getFirstToken(func(t *goidc.GrantSession) bool {
+		return slices.Contains(t.PreviousRefreshTokens, refreshToken)
+	})
+	if !exists {
+		return nil, errors.New("entity not found")
+	}
+
+	return grantSession, nil
+}
+
+func (manager *GrantSessionManager) GetBySubject(_ context.Context, subject string) ([]*goidc.GrantSession, error) {
+	return manager.getAll(func(t *goidc.GrantSession) bool {
+		return t.Subject == subject
+	}), nil
+}
+
 func (manager *GrantSessionManager) Delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
 	delete(manager.Sessions, id)
 	return nil
 }
 
+// removeExpired deletes the grant sessions that can no longer be used, that is,
+// neither the refresh token nor the last access token issued are valid.
+func (manager *GrantSessionManager) removeExpired() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for id, session := range manager.Sessions {
+		if session.IsExpired() && session.HasLastTokenExpired() {
+			delete(manager.Sessions, id)
+		}
+	}
+}
+
 func (manager *GrantSessionManager) getFirstToken(condition func(*goidc.GrantSession) bool) (*goidc.GrantSession, bool) {
+	return findFirst(manager.sessions(), condition)
+}
+
+func (manager *GrantSessionManager) getAll(condition func(*goidc.GrantSession) bool) []*goidc.GrantSession {
+	return findAll(manager.sessions(), condition)
+}
+
+func (manager *GrantSessionManager) sessions() []*goidc.GrantSession {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
 	grantSessions := make([]*goidc.GrantSession, 0, len(manager.Sessions))
 	for _, t := range manager.Sessions {
 		grantSessions = append(grantSessions, t)
 	}
 
-	return findFirst(grantSessions, condition)
+	return grantSessions
 }
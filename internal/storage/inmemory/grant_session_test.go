@@ -3,6 +3,7 @@ package inmemory_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/luikyv/go-oidc/internal/storage/inmemory"
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -95,3 +96,80 @@ func TestDeleteAuthnGrantSession_SessionDoesNotExist(t *testing.T) {
 	// Then.
 	require.Nil(t, err)
 }
+
+func TestGrantSessionManagerWithSweeper(t *testing.T) {
+	// Given.
+	manager := inmemory.NewGrantSessionManagerWithSweeper(10 * time.Millisecond)
+	t.Cleanup(manager.Close)
+
+	now := time.Now().Unix()
+	require.Nil(t, manager.Save(context.Background(), &goidc.GrantSession{
+		ID:                         "expired_session_id",
+		TokenID:                    "expired_token_id",
+		LastTokenIssuedAtTimestamp: now - 120,
+		ExpiresAtTimestamp:         now - 60,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}))
+	require.Nil(t, manager.Save(context.Background(), &goidc.GrantSession{
+		ID:                         "refreshable_session_id",
+		TokenID:                    "refreshable_token_id",
+		LastTokenIssuedAtTimestamp: now - 120,
+		ExpiresAtTimestamp:         now + 60,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}))
+
+	// Then.
+	assert.Eventually(t, func() bool {
+		_, err := manager.GetByTokenID(context.Background(), "expired_token_id")
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "the expired grant session should be removed")
+
+	_, err := manager.GetByTokenID(context.Background(), "refreshable_token_id")
+	assert.Nil(t, err, "the grant session whose refresh token is still valid should be kept")
+}
+
+func TestGetGrantSessionsBySubject_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewGrantSessionManager()
+	manager.Sessions["random_session_id_1"] = &goidc.GrantSession{
+		ID:      "random_session_id_1",
+		Subject: "random_subject",
+	}
+	manager.Sessions["random_session_id_2"] = &goidc.GrantSession{
+		ID:      "random_session_id_2",
+		Subject: "random_subject",
+	}
+	manager.Sessions["random_session_id_3"] = &goidc.GrantSession{
+		ID:      "random_session_id_3",
+		Subject: "another_subject",
+	}
+
+	// When.
+	sessions, err := manager.GetBySubject(context.Background(), "random_subject")
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, sessions, 2)
+}
+
+func TestGetGrantSessionByPreviousRefreshToken_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewGrantSessionManager()
+	sessionID := "random_session_id"
+	manager.Sessions[sessionID] = &goidc.GrantSession{
+		ID:                    sessionID,
+		RefreshToken:          "random_refresh_token",
+		PreviousRefreshTokens: []string{"random_previous_refresh_token"},
+	}
+
+	// When.
+	session, err := manager.GetByPreviousRefreshToken(context.Background(), "random_previous_refresh_token")
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, sessionID, session.ID, "invalid session ID")
+}
@@ -0,0 +1,131 @@
+package inmemory
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// inactiveTokenTTL limits for how long an inactive introspection result is
+// cached, so a token that becomes valid, e.g. after a grant session is saved
+// in another instance, is not reported as inactive for too long.
+const inactiveTokenTTL = 5 * time.Second
+
+type introspectionEntry struct {
+	tokenID        string
+	grantSessionID string
+	info           goidc.TokenInfo
+	expiresAt      time.Time
+}
+
+// IntrospectionCache is a least recently used cache of introspection results.
+// Entries are evicted when the cache is full, when they are older than the
+// TTL or when the token they refer to expires.
+type IntrospectionCache struct {
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	// order keeps the most recently used entries at the front.
+	order *list.List
+	// tokenIDs indexes the cached token IDs by grant session ID.
+	tokenIDs map[string]map[string]struct{}
+	mu       sync.Mutex
+}
+
+func NewIntrospectionCache(size int, ttl time.Duration) *IntrospectionCache {
+	return &IntrospectionCache{
+		size:     size,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		tokenIDs: make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *IntrospectionCache) Get(_ context.Context, tokenID string) (goidc.TokenInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[tokenID]
+	if !ok {
+		return goidc.TokenInfo{}, false
+	}
+
+	entry := element.Value.(*introspectionEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return goidc.TokenInfo{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.info, true
+}
+
+func (c *IntrospectionCache) Set(_ context.Context, tokenID, grantSessionID string, info goidc.TokenInfo) {
+	if c.size <= 0 {
+		return
+	}
+
+	ttl := c.ttl
+	if !info.IsActive {
+		ttl = min(ttl, inactiveTokenTTL)
+	}
+	expiresAt := time.Now().Add(ttl)
+	if info.IsActive {
+		if tokenExpiresAt := time.Unix(info.ExpiresAtTimestamp, 0); tokenExpiresAt.Before(expiresAt) {
+			expiresAt = tokenExpiresAt
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[tokenID]; ok {
+		c.remove(element)
+	}
+
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[tokenID] = c.order.PushFront(&introspectionEntry{
+		tokenID:        tokenID,
+		grantSessionID: grantSessionID,
+		info:           info,
+		expiresAt:      expiresAt,
+	})
+	if grantSessionID != "" {
+		if c.tokenIDs[grantSessionID] == nil {
+			c.tokenIDs[grantSessionID] = make(map[string]struct{})
+		}
+		c.tokenIDs[grantSessionID][tokenID] = struct{}{}
+	}
+}
+
+func (c *IntrospectionCache) InvalidateGrantSession(_ context.Context, grantSessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tokenID := range c.tokenIDs[grantSessionID] {
+		if element, ok := c.entries[tokenID]; ok {
+			c.remove(element)
+		}
+	}
+	delete(c.tokenIDs, grantSessionID)
+}
+
+// remove deletes the entry from the cache and its indexes.
+// It must be called while holding the lock.
+func (c *IntrospectionCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*introspectionEntry)
+	delete(c.entries, entry.tokenID)
+	if tokenIDs, ok := c.tokenIDs[entry.grantSessionID]; ok {
+		delete(tokenIDs, entry.tokenID)
+		if len(tokenIDs) == 0 {
+			delete(c.tokenIDs, entry.grantSessionID)
+		}
+	}
+}
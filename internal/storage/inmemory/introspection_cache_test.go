@@ -0,0 +1,98 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntrospectionCache(t *testing.T) {
+	// Given.
+	cache := inmemory.NewIntrospectionCache(10, time.Minute)
+	info := goidc.TokenInfo{
+		IsActive:           true,
+		ClientID:           "random_client_id",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+
+	// When.
+	cache.Set(context.Background(), "random_token_id", "random_grant_session_id", info)
+
+	// Then.
+	cachedInfo, ok := cache.Get(context.Background(), "random_token_id")
+	assert.True(t, ok)
+	assert.Equal(t, info, cachedInfo)
+}
+
+func TestIntrospectionCache_InvalidateGrantSession(t *testing.T) {
+	// Given.
+	cache := inmemory.NewIntrospectionCache(10, time.Minute)
+	info := goidc.TokenInfo{IsActive: true, ExpiresAtTimestamp: time.Now().Unix() + 60}
+	cache.Set(context.Background(), "random_token_id", "random_grant_session_id", info)
+	cache.Set(context.Background(), "another_token_id", "random_grant_session_id", info)
+	cache.Set(context.Background(), "other_session_token_id", "another_grant_session_id", info)
+
+	// When.
+	cache.InvalidateGrantSession(context.Background(), "random_grant_session_id")
+
+	// Then.
+	_, ok := cache.Get(context.Background(), "random_token_id")
+	assert.False(t, ok)
+	_, ok = cache.Get(context.Background(), "another_token_id")
+	assert.False(t, ok)
+	_, ok = cache.Get(context.Background(), "other_session_token_id")
+	assert.True(t, ok, "tokens of other grant sessions should remain cached")
+}
+
+func TestIntrospectionCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	// Given.
+	cache := inmemory.NewIntrospectionCache(2, time.Minute)
+	info := goidc.TokenInfo{IsActive: true, ExpiresAtTimestamp: time.Now().Unix() + 60}
+	cache.Set(context.Background(), "token_id_1", "grant_session_id_1", info)
+	cache.Set(context.Background(), "token_id_2", "grant_session_id_2", info)
+	cache.Get(context.Background(), "token_id_1")
+
+	// When.
+	cache.Set(context.Background(), "token_id_3", "grant_session_id_3", info)
+
+	// Then.
+	_, ok := cache.Get(context.Background(), "token_id_1")
+	assert.True(t, ok)
+	_, ok = cache.Get(context.Background(), "token_id_2")
+	assert.False(t, ok, "the least recently used entry should be evicted")
+	_, ok = cache.Get(context.Background(), "token_id_3")
+	assert.True(t, ok)
+}
+
+func TestIntrospectionCache_ExpiredToken(t *testing.T) {
+	// Given.
+	cache := inmemory.NewIntrospectionCache(10, time.Minute)
+	info := goidc.TokenInfo{IsActive: true, ExpiresAtTimestamp: time.Now().Unix() - 1}
+
+	// When.
+	cache.Set(context.Background(), "random_token_id", "random_grant_session_id", info)
+
+	// Then.
+	_, ok := cache.Get(context.Background(), "random_token_id")
+	assert.False(t, ok, "the result should not outlive the token")
+}
+
+func TestIntrospectionCache_EntryExpiresAfterTTL(t *testing.T) {
+	// Given.
+	cache := inmemory.NewIntrospectionCache(10, 50*time.Millisecond)
+
+	// When.
+	cache.Set(context.Background(), "random_token_id", "", goidc.TokenInfo{IsActive: false})
+
+	// Then.
+	_, ok := cache.Get(context.Background(), "random_token_id")
+	assert.True(t, ok)
+
+	time.Sleep(60 * time.Millisecond)
+	_, ok = cache.Get(context.Background(), "random_token_id")
+	assert.False(t, ok)
+}
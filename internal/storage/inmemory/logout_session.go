@@ -0,0 +1,64 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+type LogoutSessionManager struct {
+	Sessions map[string]*goidc.LogoutSession
+	mu       sync.RWMutex
+}
+
+func NewLogoutSessionManager() *LogoutSessionManager {
+	return &LogoutSessionManager{
+		Sessions: make(map[string]*goidc.LogoutSession),
+	}
+}
+
+func (manager *LogoutSessionManager) Save(
+	_ context.Context,
+	session *goidc.LogoutSession,
+) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.Sessions[session.ID] = session
+	return nil
+}
+
+func (manager *LogoutSessionManager) GetByCallbackID(
+	_ context.Context,
+	callbackID string,
+) (
+	*goidc.LogoutSession,
+	error,
+) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	sessions := make([]*goidc.LogoutSession, 0, len(manager.Sessions))
+	for _, s := range manager.Sessions {
+		sessions = append(sessions, s)
+	}
+
+	session, exists := findFirst(sessions, func(s *goidc.LogoutSession) bool {
+		return s.CallbackID == callbackID
+	})
+	if !exists {
+		return nil, errors.New("entity not found")
+	}
+
+	return session, nil
+}
+
+func (manager *LogoutSessionManager) Delete(_ context.Context, id string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	delete(manager.Sessions, id)
+	return nil
+}
@@ -0,0 +1,78 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateOrUpdateLogoutSession_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewLogoutSessionManager()
+	session := &goidc.LogoutSession{
+		ID: "random_session_id",
+	}
+
+	// When.
+	err := manager.Save(context.Background(), session)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, manager.Sessions, 1, "there should be exactly one session")
+
+	// When.
+	err = manager.Save(context.Background(), session)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, manager.Sessions, 1, "there should be exactly one session")
+}
+
+func TestGetLogoutSessionByCallbackID_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewLogoutSessionManager()
+	sessionID := "random_session_id"
+	callbackID := "random_callback_id"
+	manager.Sessions[sessionID] = &goidc.LogoutSession{
+		ID:         sessionID,
+		CallbackID: callbackID,
+	}
+
+	// When.
+	session, err := manager.GetByCallbackID(context.Background(), callbackID)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, sessionID, session.ID, "invalid session ID")
+}
+
+func TestGetLogoutSessionByCallbackID_SessionNotFound(t *testing.T) {
+	// Given.
+	manager := inmemory.NewLogoutSessionManager()
+
+	// When.
+	_, err := manager.GetByCallbackID(context.Background(), "random_callback_id")
+
+	// Then.
+	assert.NotNil(t, err)
+}
+
+func TestDeleteLogoutSession_HappyPath(t *testing.T) {
+	// Given.
+	manager := inmemory.NewLogoutSessionManager()
+	sessionID := "random_session_id"
+	manager.Sessions[sessionID] = &goidc.LogoutSession{
+		ID: sessionID,
+	}
+
+	// When.
+	err := manager.Delete(context.Background(), sessionID)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Empty(t, manager.Sessions)
+}
@@ -0,0 +1,77 @@
+package inmemory
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type tokenBucket struct {
+	tokens         float64
+	lastRefilledAt time.Time
+}
+
+// RateLimiter is a token bucket rate limiter that keeps one bucket per key in
+// memory. Each bucket holds up to burst tokens and is refilled at
+// requestsPerSec tokens per second.
+type RateLimiter struct {
+	buckets        map[string]*tokenBucket
+	requestsPerSec float64
+	burst          int
+	// idleTimeout is how long an unused bucket takes to be completely
+	// refilled. After that, it is the same as a new bucket and can be evicted.
+	idleTimeout   time.Duration
+	lastEvictedAt time.Time
+	mu            sync.Mutex
+}
+
+func NewRateLimiter(requestsPerSec float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		buckets:        make(map[string]*tokenBucket),
+		requestsPerSec: requestsPerSec,
+		burst:          burst,
+		idleTimeout:    time.Duration(float64(burst) / requestsPerSec * float64(time.Second)),
+		lastEvictedAt:  time.Now(),
+	}
+}
+
+func (limiter *RateLimiter) Allow(_ context.Context, key string) (bool, time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(limiter.lastEvictedAt) >= limiter.idleTimeout {
+		limiter.evictIdleBuckets(now)
+	}
+
+	bucket, exists := limiter.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(limiter.burst), lastRefilledAt: now}
+		limiter.buckets[key] = bucket
+	}
+
+	elapsedSecs := now.Sub(bucket.lastRefilledAt).Seconds()
+	bucket.tokens = math.Min(float64(limiter.burst), bucket.tokens+elapsedSecs*limiter.requestsPerSec)
+	bucket.lastRefilledAt = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	retryAfterSecs := (1 - bucket.tokens) / limiter.requestsPerSec
+	return false, time.Duration(retryAfterSecs * float64(time.Second))
+}
+
+// evictIdleBuckets removes the buckets that were not used for longer than the
+// idle timeout, so the memory used doesn't grow with every key ever seen.
+// It must be called while holding the lock.
+func (limiter *RateLimiter) evictIdleBuckets(now time.Time) {
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.lastRefilledAt) >= limiter.idleTimeout {
+			delete(limiter.buckets, key)
+		}
+	}
+	limiter.lastEvictedAt = now
+}
@@ -0,0 +1,60 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	// Given.
+	limiter := inmemory.NewRateLimiter(10, 2)
+
+	// When.
+	firstOK, _ := limiter.Allow(context.Background(), "random_client_id")
+	secondOK, _ := limiter.Allow(context.Background(), "random_client_id")
+	thirdOK, retryAfter := limiter.Allow(context.Background(), "random_client_id")
+
+	// Then.
+	assert.True(t, firstOK)
+	assert.True(t, secondOK)
+	assert.False(t, thirdOK, "the burst was exceeded")
+	assert.Greater(t, retryAfter, time.Duration(0))
+	assert.LessOrEqual(t, retryAfter, 100*time.Millisecond)
+
+	// When.
+	time.Sleep(retryAfter)
+	ok, _ := limiter.Allow(context.Background(), "random_client_id")
+
+	// Then.
+	assert.True(t, ok, "a token should be available after waiting")
+}
+
+func TestRateLimiter_KeysAreIndependent(t *testing.T) {
+	// Given.
+	limiter := inmemory.NewRateLimiter(1, 1)
+	limiter.Allow(context.Background(), "random_client_id")
+
+	// When.
+	ok, _ := limiter.Allow(context.Background(), "another_client_id")
+
+	// Then.
+	assert.True(t, ok)
+}
+
+func TestRateLimiter_IdleBucketsAreEvicted(t *testing.T) {
+	// Given.
+	limiter := inmemory.NewRateLimiter(100, 1)
+	limiter.Allow(context.Background(), "random_client_id")
+	// Wait until the bucket is completely refilled.
+	time.Sleep(20 * time.Millisecond)
+
+	// When.
+	limiter.Allow(context.Background(), "another_client_id")
+
+	// Then.
+	assert.Equal(t, 1, inmemory.BucketCount(limiter), "the idle bucket should be evicted")
+}
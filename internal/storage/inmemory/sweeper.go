@@ -0,0 +1,39 @@
+package inmemory
+
+import (
+	"sync"
+	"time"
+)
+
+// sweeper executes a function periodically in the background until it is stopped.
+type sweeper struct {
+	done chan struct{}
+	once sync.Once
+}
+
+func startSweeper(interval time.Duration, sweep func()) *sweeper {
+	s := &sweeper{
+		done: make(chan struct{}),
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sweep()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *sweeper) stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
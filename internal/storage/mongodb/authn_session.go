@@ -19,6 +19,12 @@ func NewAuthnSessionManager(database *mongo.Database) AuthnSessionManager {
 	}
 }
 
+// Ping checks whether MongoDB is reachable, so the manager can be used by the
+// readiness probe.
+func (manager AuthnSessionManager) Ping(ctx context.Context) error {
+	return manager.Collection.Database().Client().Ping(ctx, nil)
+}
+
 func (manager AuthnSessionManager) Save(
 	ctx context.Context,
 	session *goidc.AuthnSession,
@@ -19,6 +19,12 @@ func NewClientManager(database *mongo.Database) ClientManager {
 	}
 }
 
+// Ping checks whether MongoDB is reachable, so the manager can be used by the
+// readiness probe.
+func (manager ClientManager) Ping(ctx context.Context) error {
+	return manager.Collection.Database().Client().Ping(ctx, nil)
+}
+
 func (manager ClientManager) Save(
 	ctx context.Context,
 	client *goidc.Client,
@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"time"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,19 +14,75 @@ type GrantSessionManager struct {
 	Collection *mongo.Collection
 }
 
+// grantSessionDocument is how grant sessions are stored.
+// The expiry is duplicated as a date, since TTL indexes only work with dates.
+type grantSessionDocument struct {
+	goidc.GrantSession `bson:",inline"`
+	ExpiresAt          time.Time `bson:"expires_at_date"`
+}
+
 func NewGrantSessionManager(database *mongo.Database) GrantSessionManager {
+	return NewGrantSessionManagerWithCollection(database.Collection("grant_sessions"))
+}
+
+// NewGrantSessionManagerWithCollection creates a manager that stores grant
+// sessions in the collection informed.
+// CreateIndexes should be called beforehand.
+func NewGrantSessionManagerWithCollection(collection *mongo.Collection) GrantSessionManager {
 	return GrantSessionManager{
-		Collection: database.Collection("grant_sessions"),
+		Collection: collection,
 	}
 }
 
+// Ping checks whether MongoDB is reachable, so the manager can be used by the
+// readiness probe.
+func (manager GrantSessionManager) Ping(ctx context.Context) error {
+	return manager.Collection.Database().Client().Ping(ctx, nil)
+}
+
+// CreateIndexes creates the indexes used to look up grant sessions by token
+// ID and by refresh token, and a TTL index so MongoDB evicts the grant
+// sessions once they expire.
+func (manager GrantSessionManager) CreateIndexes(ctx context.Context) error {
+	_, err := manager.Collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "token_id", Value: 1}},
+			// Grant sessions whose access token was revoked have no token ID,
+			// so they must be left out of the unique index.
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(
+				bson.D{{Key: "token_id", Value: bson.D{{Key: "$gt", Value: ""}}}},
+			),
+		},
+		{
+			Keys:    bson.D{{Key: "refresh_token", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "previous_refresh_tokens", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys: bson.D{{Key: "sub", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at_date", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
 func (manager GrantSessionManager) Save(
 	ctx context.Context,
 	grantSession *goidc.GrantSession,
 ) error {
 	shouldReplace := true
 	filter := bson.D{{Key: "_id", Value: grantSession.ID}}
-	if _, err := manager.Collection.ReplaceOne(ctx, filter, grantSession, &options.ReplaceOptions{Upsert: &shouldReplace}); err != nil {
+	document := grantSessionDocument{
+		GrantSession: *grantSession,
+		ExpiresAt:    time.Unix(grantSession.ExpiresAtTimestamp, 0),
+	}
+	if _, err := manager.Collection.ReplaceOne(ctx, filter, document, &options.ReplaceOptions{Upsert: &shouldReplace}); err != nil {
 		return err
 	}
 
@@ -52,6 +109,40 @@ func (manager GrantSessionManager) GetByRefreshToken(
 	return manager.getWithFilter(ctx, bson.D{{Key: "refresh_token", Value: refreshToken}})
 }
 
+func (manager GrantSessionManager) GetByPreviousRefreshToken(
+	ctx context.Context,
+	refreshToken string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	return manager.getWithFilter(ctx, bson.D{{Key: "previous_refresh_tokens", Value: refreshToken}})
+}
+
+func (manager GrantSessionManager) GetBySubject(
+	ctx context.Context,
+	subject string,
+) (
+	[]*goidc.GrantSession,
+	error,
+) {
+	cursor, err := manager.Collection.Find(ctx, bson.D{{Key: "sub", Value: subject}})
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []grantSessionDocument
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+
+	grantSessions := make([]*goidc.GrantSession, len(documents))
+	for i := range documents {
+		grantSessions[i] = &documents[i].GrantSession
+	}
+	return grantSessions, nil
+}
+
 func (manager GrantSessionManager) Delete(
 	ctx context.Context,
 	id string,
@@ -77,10 +168,10 @@ func (manager GrantSessionManager) getWithFilter(
 		return nil, result.Err()
 	}
 
-	var grantSession goidc.GrantSession
-	if err := result.Decode(&grantSession); err != nil {
+	var document grantSessionDocument
+	if err := result.Decode(&document); err != nil {
 		return nil, err
 	}
 
-	return &grantSession, nil
+	return &document.GrantSession, nil
 }
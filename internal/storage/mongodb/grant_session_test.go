@@ -0,0 +1,224 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestGrantSessionDocument(t *testing.T) {
+	// Given.
+	now := time.Now().Unix()
+	document := grantSessionDocument{
+		GrantSession: goidc.GrantSession{
+			ID:                 "random_id",
+			TokenID:            "random_token_id",
+			RefreshToken:       "random_refresh_token",
+			ExpiresAtTimestamp: now + 60,
+			TokenOptions: goidc.TokenOptions{
+				TokenLifetimeSecs: 60,
+			},
+		},
+		ExpiresAt: time.Unix(now+60, 0),
+	}
+
+	// When.
+	raw, err := bson.Marshal(document)
+	require.Nil(t, err)
+
+	// Then.
+	var fields bson.M
+	require.Nil(t, bson.Unmarshal(raw, &fields))
+	assert.Equal(t, "random_id", fields["_id"])
+	assert.Equal(t, "random_token_id", fields["token_id"], "the token ID must match the index")
+	assert.Equal(t, "random_refresh_token", fields["refresh_token"], "the refresh token must match the index")
+	assert.Equal(t, int64(60), fields["token_lifetime_secs"], "the token options must be inlined")
+	assert.IsType(t, primitive.DateTime(0), fields["expires_at_date"], "the TTL index requires a date")
+
+	var decoded grantSessionDocument
+	require.Nil(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, document.GrantSession, decoded.GrantSession)
+}
+
+func TestGrantSessionDocument_WithoutTokenID(t *testing.T) {
+	// Given.
+	document := grantSessionDocument{
+		GrantSession: goidc.GrantSession{
+			ID: "random_id",
+		},
+	}
+
+	// When.
+	raw, err := bson.Marshal(document)
+	require.Nil(t, err)
+
+	// Then.
+	var fields bson.M
+	require.Nil(t, bson.Unmarshal(raw, &fields))
+	assert.NotContains(t, fields, "token_id", "sessions without token ID must be left out of the unique index")
+}
+
+func TestCreateIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("token_id_index_is_partial", func(mt *mtest.T) {
+		// Given.
+		manager := NewGrantSessionManagerWithCollection(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		// When.
+		err := manager.CreateIndexes(context.Background())
+
+		// Then.
+		require.Nil(mt, err)
+
+		indexes := mt.GetStartedEvent().Command.Lookup("indexes").Array()
+		values, err := indexes.Values()
+		require.Nil(mt, err)
+
+		indexesByKey := map[string]bson.Raw{}
+		for _, value := range values {
+			index := value.Document()
+			key, err := index.Lookup("key").Document().Elements()
+			require.Nil(mt, err)
+			indexesByKey[key[0].Key()] = index
+		}
+
+		tokenIDIndex := indexesByKey["token_id"]
+		require.NotNil(mt, tokenIDIndex)
+		assert.True(mt, tokenIDIndex.Lookup("unique").Boolean())
+		partialFilter := tokenIDIndex.Lookup("partialFilterExpression").Document()
+		assert.Equal(mt, "", partialFilter.Lookup("token_id", "$gt").StringValue())
+
+		ttlIndex := indexesByKey["expires_at_date"]
+		require.NotNil(mt, ttlIndex)
+		assert.Equal(mt, int32(0), ttlIndex.Lookup("expireAfterSeconds").Int32())
+	})
+}
+
+func TestPing(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("server_is_reachable", func(mt *mtest.T) {
+		// Given.
+		manager := NewGrantSessionManagerWithCollection(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		// When.
+		err := manager.Ping(context.Background())
+
+		// Then.
+		assert.Nil(mt, err)
+		assert.Equal(mt, "ping", mt.GetStartedEvent().CommandName)
+	})
+
+	mt.Run("server_fails", func(mt *mtest.T) {
+		// Given.
+		manager := NewGrantSessionManagerWithCollection(mt.Coll)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "not ready"}))
+
+		// When.
+		err := manager.Ping(context.Background())
+
+		// Then.
+		assert.NotNil(mt, err)
+	})
+}
+
+func TestGetByTokenID(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("session_is_found_by_token_id", func(mt *mtest.T) {
+		// Given.
+		manager := NewGrantSessionManagerWithCollection(mt.Coll)
+		rawSession, err := bson.Marshal(grantSessionDocument{
+			GrantSession: goidc.GrantSession{
+				ID:      "random_id",
+				TokenID: "random_token_id",
+			},
+		})
+		require.Nil(mt, err)
+		var sessionDocument bson.D
+		require.Nil(mt, bson.Unmarshal(rawSession, &sessionDocument))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.Coll.Database().Name()+"."+mt.Coll.Name(), mtest.FirstBatch, sessionDocument))
+
+		// When.
+		grantSession, err := manager.GetByTokenID(context.Background(), "random_token_id")
+
+		// Then.
+		require.Nil(mt, err)
+		assert.Equal(mt, "random_id", grantSession.ID)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(mt, "random_token_id", filter.Lookup("token_id").StringValue())
+	})
+}
+
+// TestGrantSessionManager_Container runs against a real MongoDB, e.g. one
+// started with "docker run -p 27017:27017 mongo", whose URI is informed in
+// GOIDC_TEST_MONGODB_URI.
+func TestGrantSessionManager_Container(t *testing.T) {
+	uri := os.Getenv("GOIDC_TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("GOIDC_TEST_MONGODB_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(ctx) })
+
+	database := client.Database("goidc_test_" + strconv.FormatInt(time.Now().UnixNano(), 10))
+	t.Cleanup(func() { _ = database.Drop(ctx) })
+
+	manager := NewGrantSessionManager(database)
+	require.Nil(t, manager.CreateIndexes(ctx))
+
+	t.Run("sessions_are_found_by_token_id", func(t *testing.T) {
+		session := &goidc.GrantSession{
+			ID:                 "token_id_session",
+			TokenID:            "random_token_id",
+			ExpiresAtTimestamp: time.Now().Unix() + 600,
+		}
+		require.Nil(t, manager.Save(ctx, session))
+
+		savedSession, err := manager.GetByTokenID(ctx, "random_token_id")
+		require.Nil(t, err)
+		assert.Equal(t, session.ID, savedSession.ID)
+	})
+
+	t.Run("many_sessions_can_have_their_token_revoked", func(t *testing.T) {
+		for _, id := range []string{"revoked_session_1", "revoked_session_2"} {
+			require.Nil(t, manager.Save(ctx, &goidc.GrantSession{
+				ID:                 id,
+				ExpiresAtTimestamp: time.Now().Unix() + 600,
+			}))
+		}
+	})
+
+	t.Run("expired_sessions_are_evicted", func(t *testing.T) {
+		require.Nil(t, manager.Save(ctx, &goidc.GrantSession{
+			ID:                 "expired_session",
+			TokenID:            "expired_token_id",
+			ExpiresAtTimestamp: time.Now().Unix() - 1,
+		}))
+
+		// The TTL monitor of MongoDB runs every 60 seconds.
+		assert.Eventually(t, func() bool {
+			_, err := manager.GetByTokenID(ctx, "expired_token_id")
+			return errors.Is(err, mongo.ErrNoDocuments)
+		}, 2*time.Minute, time.Second)
+	})
+}
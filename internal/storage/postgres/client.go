@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+type ClientManager struct {
+	DB *sql.DB
+}
+
+func NewClientManager(db *sql.DB) ClientManager {
+	return ClientManager{
+		DB: db,
+	}
+}
+
+// Ping checks whether the database is reachable, so the manager can be used
+// by the readiness probe.
+func (manager ClientManager) Ping(ctx context.Context) error {
+	return manager.DB.PingContext(ctx)
+}
+
+// Save creates or replaces the client.
+// The whole client is stored, including the JWKS cached by
+// goidc.Client.FetchPublicJWKS, so the keys don't need to be fetched again.
+func (manager ClientManager) Save(
+	ctx context.Context,
+	client *goidc.Client,
+) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+
+	_, err = manager.DB.ExecContext(
+		ctx,
+		`INSERT INTO clients (client_id, data) VALUES ($1, $2)
+		ON CONFLICT (client_id) DO UPDATE SET data = EXCLUDED.data`,
+		client.ID,
+		data,
+	)
+	return err
+}
+
+func (manager ClientManager) Get(ctx context.Context, id string) (*goidc.Client, error) {
+	var data []byte
+	err := manager.DB.QueryRowContext(
+		ctx,
+		`SELECT data FROM clients WHERE client_id = $1`,
+		id,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("entity not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var client goidc.Client
+	if err := json.Unmarshal(data, &client); err != nil {
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+func (manager ClientManager) Delete(ctx context.Context, id string) error {
+	_, err := manager.DB.ExecContext(ctx, `DELETE FROM clients WHERE client_id = $1`, id)
+	return err
+}
@@ -0,0 +1,128 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/luikyv/go-oidc/internal/storage/postgres"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndGetClient(t *testing.T) {
+	// Given.
+	manager, mock := setUpClientManager(t)
+	client := &goidc.Client{
+		ID:           "random_client_id",
+		HashedSecret: "random_hashed_secret",
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			RedirectURIS:  []string{"https://example.com/callback"},
+			PublicJWKSURI: "https://example.com/jwks",
+			// The JWKS cached after fetching it from the jwks_uri.
+			PublicJWKS: json.RawMessage(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`),
+		},
+	}
+	data, err := json.Marshal(client)
+	require.Nil(t, err)
+
+	mock.ExpectExec("INSERT INTO clients").
+		WithArgs(client.ID, data).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT data FROM clients").
+		WithArgs(client.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow(data))
+
+	// When.
+	err = manager.Save(context.Background(), client)
+
+	// Then.
+	require.Nil(t, err)
+
+	// When.
+	storedClient, err := manager.Get(context.Background(), client.ID)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, client, storedClient)
+	jwks, err := storedClient.FetchPublicJWKS()
+	require.Nil(t, err)
+	assert.Len(t, jwks.Keys, 1, "the cached jwks should be used")
+	require.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetClient_NotFound(t *testing.T) {
+	// Given.
+	manager, mock := setUpClientManager(t)
+	mock.ExpectQuery("SELECT data FROM clients").
+		WithArgs("random_client_id").
+		WillReturnRows(sqlmock.NewRows([]string{"data"}))
+
+	// When.
+	_, err := manager.Get(context.Background(), "random_client_id")
+
+	// Then.
+	require.NotNil(t, err)
+	require.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteClient(t *testing.T) {
+	// Given.
+	manager, mock := setUpClientManager(t)
+	mock.ExpectExec("DELETE FROM clients").
+		WithArgs("random_client_id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// When.
+	err := manager.Delete(context.Background(), "random_client_id")
+
+	// Then.
+	require.Nil(t, err)
+	require.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrate(t *testing.T) {
+	// Given.
+	db, mock, err := sqlmock.New()
+	require.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS clients").
+		WillReturnResult(driver.ResultNoRows)
+
+	// When.
+	err = postgres.Migrate(context.Background(), db)
+
+	// Then.
+	require.Nil(t, err)
+	require.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPing(t *testing.T) {
+	// Given.
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	manager := postgres.NewClientManager(db)
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	// Then.
+	assert.Nil(t, manager.Ping(context.Background()))
+	assert.NotNil(t, manager.Ping(context.Background()))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func setUpClientManager(t *testing.T) (postgres.ClientManager, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return postgres.NewClientManager(db), mock
+}
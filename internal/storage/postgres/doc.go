@@ -0,0 +1,10 @@
+// Package postgres implements the client manager backed by PostgreSQL.
+//
+// The manager works with any database/sql driver for PostgreSQL, e.g. pgx or
+// lib/pq, so the driver is chosen when opening the database.
+// Clients are stored as JSONB with the client ID as the primary key:
+//
+//	clients(client_id TEXT PRIMARY KEY, data JSONB NOT NULL)
+//
+// Migrate creates the tables required by the managers.
+package postgres
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate runs the migrations in lexical order. The migrations are idempotent,
+// so it's safe to run them every time the server starts.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		migration, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if _, err := db.ExecContext(ctx, string(migration)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
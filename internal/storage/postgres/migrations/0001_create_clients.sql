@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS clients (
+    client_id TEXT PRIMARY KEY,
+    data JSONB NOT NULL
+);
@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const authnSessionEntity = "authn_session"
+
+type AuthnSessionManager struct {
+	Client *goredis.Client
+}
+
+func NewAuthnSessionManager(client *goredis.Client) AuthnSessionManager {
+	return AuthnSessionManager{
+		Client: client,
+	}
+}
+
+// Ping checks whether Redis is reachable, so the manager can be used by the
+// readiness probe.
+func (manager AuthnSessionManager) Ping(ctx context.Context) error {
+	return manager.Client.Ping(ctx).Err()
+}
+
+func (manager AuthnSessionManager) Save(
+	ctx context.Context,
+	session *goidc.AuthnSession,
+) error {
+	var oldIndexKeys []string
+	oldSession, err := manager.get(ctx, session.ID)
+	if err == nil {
+		oldIndexKeys = authnSessionIndexKeys(oldSession)
+	} else if !errors.Is(err, goredis.Nil) {
+		return err
+	}
+
+	return save(
+		ctx,
+		manager.Client,
+		session.ID,
+		key(authnSessionEntity, "id", session.ID),
+		session,
+		authnSessionIndexKeys(session),
+		oldIndexKeys,
+		nil,
+		nil,
+		session.ExpiresAtTimestamp,
+	)
+}
+
+func (manager AuthnSessionManager) GetByCallbackID(
+	ctx context.Context,
+	callbackID string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return manager.getByIndex(ctx, key(authnSessionEntity, "callback_id", callbackID))
+}
+
+func (manager AuthnSessionManager) GetByAuthorizationCode(
+	ctx context.Context,
+	authorizationCode string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return manager.getByIndex(ctx, key(authnSessionEntity, "authorization_code", authorizationCode))
+}
+
+func (manager AuthnSessionManager) GetByRequestURI(
+	ctx context.Context,
+	requestURI string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return manager.getByIndex(ctx, key(authnSessionEntity, "request_uri", requestURI))
+}
+
+func (manager AuthnSessionManager) Delete(
+	ctx context.Context,
+	id string,
+) error {
+	session, err := manager.get(ctx, id)
+	if errors.Is(err, goredis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := append(authnSessionIndexKeys(session), key(authnSessionEntity, "id", id))
+	return remove(ctx, manager.Client, keys...)
+}
+
+func (manager AuthnSessionManager) getByIndex(
+	ctx context.Context,
+	indexKey string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	sessionID, err := id(ctx, manager.Client, indexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return manager.get(ctx, sessionID)
+}
+
+func (manager AuthnSessionManager) get(
+	ctx context.Context,
+	id string,
+) (
+	*goidc.AuthnSession,
+	error,
+) {
+	return get[goidc.AuthnSession](ctx, manager.Client, key(authnSessionEntity, "id", id))
+}
+
+func authnSessionIndexKeys(session *goidc.AuthnSession) []string {
+	var keys []string
+	if session.CallbackID != "" {
+		keys = append(keys, key(authnSessionEntity, "callback_id", session.CallbackID))
+	}
+	if session.AuthorizationCode != "" {
+		keys = append(keys, key(authnSessionEntity, "authorization_code", session.AuthorizationCode))
+	}
+	if session.RequestURI != "" {
+		keys = append(keys, key(authnSessionEntity, "request_uri", session.RequestURI))
+	}
+	return keys
+}
@@ -0,0 +1,133 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/luikyv/go-oidc/internal/storage/redis"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAuthnSession_HappyPath(t *testing.T) {
+	// Given.
+	manager, _ := setUpAuthnSessionManager(t)
+	session := &goidc.AuthnSession{
+		ID:                 "random_session_id",
+		CallbackID:         "random_callback_id",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI: "random_request_uri",
+			Scopes:     goidc.ScopeOpenID.ID,
+		},
+	}
+
+	// When.
+	err := manager.Save(context.Background(), session)
+
+	// Then.
+	require.Nil(t, err)
+
+	sessionByCallbackID, err := manager.GetByCallbackID(context.Background(), session.CallbackID)
+	require.Nil(t, err)
+	assert.Equal(t, session.ID, sessionByCallbackID.ID)
+	assert.Equal(t, session.Scopes, sessionByCallbackID.Scopes)
+
+	sessionByRequestURI, err := manager.GetByRequestURI(context.Background(), session.RequestURI)
+	require.Nil(t, err)
+	assert.Equal(t, session.ID, sessionByRequestURI.ID)
+}
+
+func TestSaveAuthnSession_StaleIndexesAreRemoved(t *testing.T) {
+	// Given.
+	manager, _ := setUpAuthnSessionManager(t)
+	session := &goidc.AuthnSession{
+		ID:                 "random_session_id",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			RequestURI: "random_request_uri",
+		},
+	}
+	require.Nil(t, manager.Save(context.Background(), session))
+
+	// When.
+	session.RequestURI = ""
+	session.AuthorizationCode = "random_authorization_code"
+	err := manager.Save(context.Background(), session)
+
+	// Then.
+	require.Nil(t, err)
+
+	_, err = manager.GetByRequestURI(context.Background(), "random_request_uri")
+	assert.NotNil(t, err, "the request_uri should no longer point to the session")
+
+	sessionByCode, err := manager.GetByAuthorizationCode(context.Background(), session.AuthorizationCode)
+	require.Nil(t, err)
+	assert.Equal(t, session.ID, sessionByCode.ID)
+}
+
+func TestSaveAuthnSession_SessionExpires(t *testing.T) {
+	// Given.
+	manager, mr := setUpAuthnSessionManager(t)
+	session := &goidc.AuthnSession{
+		ID:                 "random_session_id",
+		CallbackID:         "random_callback_id",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+	require.Nil(t, manager.Save(context.Background(), session))
+
+	// When.
+	mr.FastForward(2 * time.Minute)
+
+	// Then.
+	_, err := manager.GetByCallbackID(context.Background(), session.CallbackID)
+	assert.NotNil(t, err, "the session should be evicted")
+	assert.Empty(t, mr.Keys(), "all the keys of the session should be evicted")
+}
+
+func TestDeleteAuthnSession_AuthorizationCodeCannotBeReplayed(t *testing.T) {
+	// Given.
+	manager, mr := setUpAuthnSessionManager(t)
+	session := &goidc.AuthnSession{
+		ID:                 "random_session_id",
+		CallbackID:         "random_callback_id",
+		AuthorizationCode:  "random_authorization_code",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+	require.Nil(t, manager.Save(context.Background(), session))
+
+	// When.
+	err := manager.Delete(context.Background(), session.ID)
+
+	// Then.
+	require.Nil(t, err)
+
+	_, err = manager.GetByAuthorizationCode(context.Background(), session.AuthorizationCode)
+	assert.ErrorIs(t, err, goredis.Nil, "the authorization code should not be usable again")
+	assert.Empty(t, mr.Keys(), "the index keys should be deleted along with the session")
+}
+
+func TestDeleteAuthnSession_SessionDoesNotExist(t *testing.T) {
+	// Given.
+	manager, _ := setUpAuthnSessionManager(t)
+
+	// When.
+	err := manager.Delete(context.Background(), "random_session_id")
+
+	// Then.
+	require.Nil(t, err)
+}
+
+func setUpAuthnSessionManager(t *testing.T) (redis.AuthnSessionManager, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return redis.NewAuthnSessionManager(client), mr
+}
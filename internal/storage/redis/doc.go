@@ -0,0 +1,18 @@
+// Package redis implements the session managers backed by Redis.
+//
+// Each session is stored as JSON under the key "goidc:<entity>:id:<id>" and
+// secondary index keys point to the session ID, so lookups don't need to scan:
+//
+//	goidc:grant_session:id:<id>                         -> grant session JSON
+//	goidc:grant_session:token_id:<token_id>             -> <id>
+//	goidc:grant_session:refresh_token:<refresh_token>   -> <id>
+//	goidc:grant_session:previous_refresh_token:<token>  -> <id>
+//	goidc:grant_session:sub:<sub>                       -> set of <id>
+//	goidc:authn_session:id:<id>                         -> authentication session JSON
+//	goidc:authn_session:callback_id:<callback_id>       -> <id>
+//	goidc:authn_session:authorization_code:<code>       -> <id>
+//	goidc:authn_session:request_uri:<request_uri>       -> <id>
+//
+// All the keys of a session expire along with it, so expired sessions are
+// evicted by Redis itself. Set keys live as long as their longest lived member.
+package redis
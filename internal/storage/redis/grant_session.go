@@ -0,0 +1,186 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const grantSessionEntity = "grant_session"
+
+type GrantSessionManager struct {
+	Client *goredis.Client
+}
+
+func NewGrantSessionManager(client *goredis.Client) GrantSessionManager {
+	return GrantSessionManager{
+		Client: client,
+	}
+}
+
+// Ping checks whether Redis is reachable, so the manager can be used by the
+// readiness probe.
+func (manager GrantSessionManager) Ping(ctx context.Context) error {
+	return manager.Client.Ping(ctx).Err()
+}
+
+func (manager GrantSessionManager) Save(
+	ctx context.Context,
+	grantSession *goidc.GrantSession,
+) error {
+	var oldIndexKeys, oldSetKeys []string
+	oldGrantSession, err := manager.get(ctx, grantSession.ID)
+	if err == nil {
+		oldIndexKeys = grantSessionIndexKeys(oldGrantSession)
+		oldSetKeys = grantSessionSetKeys(oldGrantSession)
+	} else if !errors.Is(err, goredis.Nil) {
+		return err
+	}
+
+	return save(
+		ctx,
+		manager.Client,
+		grantSession.ID,
+		key(grantSessionEntity, "id", grantSession.ID),
+		grantSession,
+		grantSessionIndexKeys(grantSession),
+		oldIndexKeys,
+		grantSessionSetKeys(grantSession),
+		oldSetKeys,
+		grantSession.ExpiresAtTimestamp,
+	)
+}
+
+func (manager GrantSessionManager) GetByTokenID(
+	ctx context.Context,
+	tokenID string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	return manager.getByIndex(ctx, key(grantSessionEntity, "token_id", tokenID))
+}
+
+func (manager GrantSessionManager) GetByRefreshToken(
+	ctx context.Context,
+	refreshToken string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	return manager.getByIndex(ctx, key(grantSessionEntity, "refresh_token", refreshToken))
+}
+
+func (manager GrantSessionManager) GetByPreviousRefreshToken(
+	ctx context.Context,
+	refreshToken string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	return manager.getByIndex(ctx, key(grantSessionEntity, "previous_refresh_token", refreshToken))
+}
+
+// GetBySubject returns the grant sessions of the user.
+// The IDs of sessions that were already evicted are cleaned up from the
+// subject index.
+func (manager GrantSessionManager) GetBySubject(
+	ctx context.Context,
+	subject string,
+) (
+	[]*goidc.GrantSession,
+	error,
+) {
+	setKey := key(grantSessionEntity, "sub", subject)
+	ids, err := members(ctx, manager.Client, setKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var grantSessions []*goidc.GrantSession
+	for _, id := range ids {
+		grantSession, err := manager.get(ctx, id)
+		if errors.Is(err, goredis.Nil) {
+			if err := removeMember(ctx, manager.Client, setKey, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		grantSessions = append(grantSessions, grantSession)
+	}
+
+	return grantSessions, nil
+}
+
+func (manager GrantSessionManager) Delete(
+	ctx context.Context,
+	id string,
+) error {
+	grantSession, err := manager.get(ctx, id)
+	if errors.Is(err, goredis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, setKey := range grantSessionSetKeys(grantSession) {
+		if err := removeMember(ctx, manager.Client, setKey, id); err != nil {
+			return err
+		}
+	}
+
+	keys := append(grantSessionIndexKeys(grantSession), key(grantSessionEntity, "id", id))
+	return remove(ctx, manager.Client, keys...)
+}
+
+func (manager GrantSessionManager) getByIndex(
+	ctx context.Context,
+	indexKey string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	grantSessionID, err := id(ctx, manager.Client, indexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return manager.get(ctx, grantSessionID)
+}
+
+func (manager GrantSessionManager) get(
+	ctx context.Context,
+	id string,
+) (
+	*goidc.GrantSession,
+	error,
+) {
+	return get[goidc.GrantSession](ctx, manager.Client, key(grantSessionEntity, "id", id))
+}
+
+func grantSessionIndexKeys(grantSession *goidc.GrantSession) []string {
+	var keys []string
+	if grantSession.TokenID != "" {
+		keys = append(keys, key(grantSessionEntity, "token_id", grantSession.TokenID))
+	}
+	if grantSession.RefreshToken != "" {
+		keys = append(keys, key(grantSessionEntity, "refresh_token", grantSession.RefreshToken))
+	}
+	for _, refreshToken := range grantSession.PreviousRefreshTokens {
+		keys = append(keys, key(grantSessionEntity, "previous_refresh_token", refreshToken))
+	}
+	return keys
+}
+
+func grantSessionSetKeys(grantSession *goidc.GrantSession) []string {
+	if grantSession.Subject == "" {
+		return nil
+	}
+	return []string{key(grantSessionEntity, "sub", grantSession.Subject)}
+}
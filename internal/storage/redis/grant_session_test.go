@@ -0,0 +1,191 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/luikyv/go-oidc/internal/storage/redis"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveGrantSession_HappyPath(t *testing.T) {
+	// Given.
+	manager, mr := setUpGrantSessionManager(t)
+	grantSession := &goidc.GrantSession{
+		ID:                 "random_grant_session_id",
+		TokenID:            "random_token_id",
+		RefreshToken:       "random_refresh_token",
+		ClientID:           "random_client_id",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+
+	// When.
+	err := manager.Save(context.Background(), grantSession)
+
+	// Then.
+	require.Nil(t, err)
+
+	grantSessionByTokenID, err := manager.GetByTokenID(context.Background(), grantSession.TokenID)
+	require.Nil(t, err)
+	assert.Equal(t, grantSession.ID, grantSessionByTokenID.ID)
+	assert.Equal(t, grantSession.ClientID, grantSessionByTokenID.ClientID)
+
+	grantSessionByRefreshToken, err := manager.GetByRefreshToken(context.Background(), grantSession.RefreshToken)
+	require.Nil(t, err)
+	assert.Equal(t, grantSession.ID, grantSessionByRefreshToken.ID)
+
+	assert.InDelta(t, 60, mr.TTL("goidc:grant_session:id:"+grantSession.ID).Seconds(), 2)
+}
+
+func TestSaveGrantSession_RotatedTokensAreNoLongerFound(t *testing.T) {
+	// Given.
+	manager, _ := setUpGrantSessionManager(t)
+	grantSession := &goidc.GrantSession{
+		ID:                 "random_grant_session_id",
+		TokenID:            "random_token_id",
+		RefreshToken:       "random_refresh_token",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+	require.Nil(t, manager.Save(context.Background(), grantSession))
+
+	// When.
+	grantSession.TokenID = "new_token_id"
+	grantSession.RefreshToken = "new_refresh_token"
+	err := manager.Save(context.Background(), grantSession)
+
+	// Then.
+	require.Nil(t, err)
+
+	_, err = manager.GetByTokenID(context.Background(), "random_token_id")
+	assert.ErrorIs(t, err, goredis.Nil)
+	_, err = manager.GetByRefreshToken(context.Background(), "random_refresh_token")
+	assert.ErrorIs(t, err, goredis.Nil)
+
+	_, err = manager.GetByRefreshToken(context.Background(), "new_refresh_token")
+	assert.Nil(t, err)
+}
+
+func TestGetGrantSessionByPreviousRefreshToken_AfterManyRotations(t *testing.T) {
+	// Given.
+	manager, _ := setUpGrantSessionManager(t)
+	grantSession := &goidc.GrantSession{
+		ID:                 "random_grant_session_id",
+		RefreshToken:       "random_refresh_token",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+	require.Nil(t, manager.Save(context.Background(), grantSession))
+
+	for _, refreshToken := range []string{"second_refresh_token", "third_refresh_token"} {
+		grantSession.PreviousRefreshTokens = append(grantSession.PreviousRefreshTokens, grantSession.RefreshToken)
+		grantSession.RefreshToken = refreshToken
+		require.Nil(t, manager.Save(context.Background(), grantSession))
+	}
+
+	// When.
+	session, err := manager.GetByPreviousRefreshToken(context.Background(), "random_refresh_token")
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, grantSession.ID, session.ID, "every consumed refresh token should be found")
+}
+
+func TestSaveGrantSession_SessionExpires(t *testing.T) {
+	// Given.
+	manager, mr := setUpGrantSessionManager(t)
+	grantSession := &goidc.GrantSession{
+		ID:                 "random_grant_session_id",
+		TokenID:            "random_token_id",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+	require.Nil(t, manager.Save(context.Background(), grantSession))
+
+	// When.
+	mr.FastForward(2 * time.Minute)
+
+	// Then.
+	_, err := manager.GetByTokenID(context.Background(), grantSession.TokenID)
+	assert.NotNil(t, err, "the grant session should be evicted")
+	assert.Empty(t, mr.Keys())
+}
+
+func TestGetGrantSessionsBySubject_HappyPath(t *testing.T) {
+	// Given.
+	manager, mr := setUpGrantSessionManager(t)
+	for _, grantSession := range []*goidc.GrantSession{
+		{ID: "random_grant_session_id_1", Subject: "random_subject", ExpiresAtTimestamp: time.Now().Unix() + 60},
+		{ID: "random_grant_session_id_2", Subject: "random_subject", ExpiresAtTimestamp: time.Now().Unix() + 120},
+		{ID: "random_grant_session_id_3", Subject: "another_subject", ExpiresAtTimestamp: time.Now().Unix() + 60},
+	} {
+		require.Nil(t, manager.Save(context.Background(), grantSession))
+	}
+
+	// When.
+	grantSessions, err := manager.GetBySubject(context.Background(), "random_subject")
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, grantSessions, 2)
+	assert.InDelta(t, 120, mr.TTL("goidc:grant_session:sub:random_subject").Seconds(), 2,
+		"the subject index should live as long as its longest lived session")
+
+	// When.
+	mr.FastForward(90 * time.Second)
+	grantSessions, err = manager.GetBySubject(context.Background(), "random_subject")
+
+	// Then.
+	require.Nil(t, err)
+	require.Len(t, grantSessions, 1, "expired sessions should not be returned")
+	assert.Equal(t, "random_grant_session_id_2", grantSessions[0].ID)
+	members, _ := mr.SMembers("goidc:grant_session:sub:random_subject")
+	assert.Equal(t, []string{"random_grant_session_id_2"}, members)
+}
+
+func TestDeleteGrantSession_HappyPath(t *testing.T) {
+	// Given.
+	manager, mr := setUpGrantSessionManager(t)
+	grantSession := &goidc.GrantSession{
+		ID:                 "random_grant_session_id",
+		TokenID:            "random_token_id",
+		RefreshToken:       "random_refresh_token",
+		Subject:            "random_subject",
+		ExpiresAtTimestamp: time.Now().Unix() + 60,
+	}
+	require.Nil(t, manager.Save(context.Background(), grantSession))
+
+	// When.
+	err := manager.Delete(context.Background(), grantSession.ID)
+
+	// Then.
+	require.Nil(t, err)
+	_, err = manager.GetByRefreshToken(context.Background(), grantSession.RefreshToken)
+	assert.ErrorIs(t, err, goredis.Nil)
+	assert.Empty(t, mr.Keys())
+}
+
+func TestGrantSessionManager_ContextIsHonored(t *testing.T) {
+	// Given.
+	manager, _ := setUpGrantSessionManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// When.
+	_, err := manager.GetByTokenID(ctx, "random_token_id")
+
+	// Then.
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func setUpGrantSessionManager(t *testing.T) (redis.GrantSessionManager, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return redis.NewGrantSessionManager(client), mr
+}
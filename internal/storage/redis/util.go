@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "goidc:"
+	// minTTL is the expiry of sessions that are saved when they are already
+	// expired, so the server can still inform why they are no longer valid.
+	minTTL = time.Second
+)
+
+func key(entity string, field string, value string) string {
+	return keyPrefix + entity + ":" + field + ":" + value
+}
+
+// ttl returns for how long a session must be kept based on its expiry.
+func ttl(expiresAtTimestamp int64) time.Duration {
+	ttl := time.Until(time.Unix(expiresAtTimestamp, 0))
+	if ttl < minTTL {
+		return minTTL
+	}
+	return ttl
+}
+
+// save stores the entity and its index keys atomically with the same TTL.
+// The index keys of the previous version of the entity that no longer apply
+// are removed, so they cannot be used to find it anymore.
+//
+// Set keys index many entities at once, e.g. all the grant sessions of a user.
+// The ID of the entity is added to them and their TTL is only ever extended,
+// so they outlive all of their members.
+func save(
+	ctx context.Context,
+	client *goredis.Client,
+	id string,
+	entityKey string,
+	entity any,
+	indexKeys []string,
+	oldIndexKeys []string,
+	setKeys []string,
+	oldSetKeys []string,
+	expiresAtTimestamp int64,
+) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	staleIndexKeys := difference(oldIndexKeys, indexKeys)
+	staleSetKeys := difference(oldSetKeys, setKeys)
+	expiry := ttl(expiresAtTimestamp)
+	_, err = client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		if len(staleIndexKeys) != 0 {
+			pipe.Del(ctx, staleIndexKeys...)
+		}
+		for _, setKey := range staleSetKeys {
+			pipe.SRem(ctx, setKey, id)
+		}
+		pipe.Set(ctx, entityKey, data, expiry)
+		for _, indexKey := range indexKeys {
+			pipe.Set(ctx, indexKey, id, expiry)
+		}
+		for _, setKey := range setKeys {
+			pipe.SAdd(ctx, setKey, id)
+			pipe.ExpireNX(ctx, setKey, expiry)
+			pipe.ExpireGT(ctx, setKey, expiry)
+		}
+		return nil
+	})
+	return err
+}
+
+func get[T any](ctx context.Context, client *goredis.Client, entityKey string) (*T, error) {
+	data, err := client.Get(ctx, entityKey).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var entity T
+	if err := json.Unmarshal(data, &entity); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+// id resolves the ID of the entity an index key points to.
+func id(ctx context.Context, client *goredis.Client, indexKey string) (string, error) {
+	return client.Get(ctx, indexKey).Result()
+}
+
+// members returns the IDs of the entities indexed by a set key.
+func members(ctx context.Context, client *goredis.Client, setKey string) ([]string, error) {
+	return client.SMembers(ctx, setKey).Result()
+}
+
+// removeMember removes the ID of an entity from a set key.
+func removeMember(ctx context.Context, client *goredis.Client, setKey string, id string) error {
+	return client.SRem(ctx, setKey, id).Err()
+}
+
+// difference returns the keys in oldKeys which are not in keys.
+func difference(oldKeys []string, keys []string) []string {
+	var diff []string
+	for _, oldKey := range oldKeys {
+		if !slices.Contains(keys, oldKey) {
+			diff = append(diff, oldKey)
+		}
+	}
+	return diff
+}
+
+func remove(ctx context.Context, client *goredis.Client, keys ...string) error {
+	return client.Del(ctx, keys...).Err()
+}
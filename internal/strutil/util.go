@@ -3,6 +3,7 @@ package strutil
 import (
 	"crypto/rand"
 	"math/big"
+	"net/url"
 	"slices"
 	"strings"
 
@@ -26,7 +27,19 @@ func SplitWithSpaces(s string) []string {
 	return slice
 }
 
+// IsAbsoluteURIWithoutFragment informs whether uri is an absolute URI with no
+// fragment component.
+func IsAbsoluteURIWithoutFragment(uri string) bool {
+	parsedURI, err := url.Parse(uri)
+	return err == nil && parsedURI.IsAbs() && parsedURI.Fragment == ""
+}
+
 func Random(length int) (string, error) {
+	return RandomWithCharset(length, charset)
+}
+
+// RandomWithCharset generates a random string using only the characters of charset.
+func RandomWithCharset(length int, charset string) (string, error) {
 	charsetLen := int64(len(charset))
 	ret := make([]byte, length)
 	for i := 0; i < length; i++ {
@@ -8,9 +8,20 @@ import (
 
 func Handler(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		req, oauthErr := newTokenRequest(ctx.Request())
+		if oauthErr != nil {
+			ctx.WriteError(oauthErr)
+			return
+		}
+
+		ctx.AddLogAttrs("grant_type", req.GrantType)
+		if err := limitTokenRequestRate(ctx, req); err != nil {
+			ctx.WriteError(err)
+			return
+		}
 
-		req := newTokenRequest(ctx.Request())
 		tokenResp, err := HandleTokenCreation(ctx, req)
 		if err != nil {
 			ctx.WriteError(err)
@@ -26,17 +37,44 @@ func Handler(config *oidc.Configuration) http.HandlerFunc {
 
 func HandlerIntrospect(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
+		ctx := oidc.NewRequestContext(config, r, w)
 
 		req := newTokenIntrospectionRequest(ctx.Request())
-		tokenInfo, err := introspect(ctx, req)
+		client, tokenInfo, err := introspect(ctx, req)
 		if err != nil {
 			ctx.WriteError(err)
 			return
 		}
 
+		if shouldReturnIntrospectionResponseJWT(ctx) {
+			jwt, err := introspectionResponseJWT(ctx, client, tokenInfo)
+			if err != nil {
+				ctx.WriteError(err)
+				return
+			}
+
+			if err := ctx.WriteTokenIntrospectionJWT(jwt, http.StatusOK); err != nil {
+				ctx.WriteError(err)
+			}
+			return
+		}
+
 		if err := ctx.Write(tokenInfo, http.StatusOK); err != nil {
 			ctx.WriteError(err)
 		}
 	}
 }
+
+func HandlerRevoke(config *oidc.Configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := oidc.NewRequestContext(config, r, w)
+
+		req := newTokenRevocationRequest(ctx.Request())
+		if err := revoke(ctx, req); err != nil {
+			ctx.WriteError(err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
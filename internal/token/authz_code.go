@@ -18,8 +18,8 @@ func handleAuthorizationCodeGrantTokenCreation(
 	oidc.Error,
 ) {
 
-	if req.AuthorizationCode == "" {
-		return tokenResponse{}, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid authorization code")
+	if err := preValidateAuthorizationCodeGrantRequest(req); err != nil {
+		return tokenResponse{}, err
 	}
 
 	client, session, oauthErr := getAuthenticatedClientAndSession(ctx, req)
@@ -53,7 +53,7 @@ func handleAuthorizationCodeGrantTokenCreation(
 		RefreshToken: grantSession.RefreshToken,
 	}
 
-	if strutil.ContainsOpenID(session.GrantedScopes) {
+	if shouldIssueIDToken(ctx, grantOptions.GrantedScopes) {
 		tokenResp.IDToken, err = MakeIDToken(ctx, client, newIDTokenOptions(grantOptions))
 		if err != nil {
 			return tokenResponse{}, err
@@ -70,6 +70,7 @@ func handleAuthorizationCodeGrantTokenCreation(
 		tokenResp.AuthorizationDetails = grantOptions.GrantedAuthorizationDetails
 	}
 
+	customizeTokenResponse(ctx, client, grantSession, &tokenResp)
 	return tokenResp, nil
 }
 
@@ -97,9 +98,30 @@ func generateAuthorizationCodeGrantSession(
 		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
 	}
 
+	ctx.NotifyEvent(goidc.Event{
+		Type:     goidc.EventTypeAuthorizationGranted,
+		ClientID: grantSession.ClientID,
+		Subject:  grantSession.Subject,
+		Details: map[string]any{
+			"grant_type": grantSession.GrantType,
+			"scope":      grantSession.GrantedScopes,
+		},
+	})
 	return grantSession, nil
 }
 
+func preValidateAuthorizationCodeGrantRequest(req tokenRequest) oidc.Error {
+	if req.AuthorizationCode == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid authorization code")
+	}
+
+	if req.RefreshToken != "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "refresh_token is not allowed for the authorization_code grant type")
+	}
+
+	return nil
+}
+
 func validateAuthorizationCodeGrantRequest(
 	ctx *oidc.Context,
 	req tokenRequest,
@@ -119,15 +141,15 @@ func validateAuthorizationCodeGrantRequest(
 		return oidc.NewError(oidc.ErrorCodeInvalidGrant, "the authorization code is expired")
 	}
 
-	if session.RedirectURI != req.RedirectURI {
-		return oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid redirect_uri")
+	if err := validateRedirectURI(req, session); err != nil {
+		return err
 	}
 
 	if err := validatePkce(ctx, req, client, session); err != nil {
 		return err
 	}
 
-	if err := validateTokenBindingIsRequired(ctx); err != nil {
+	if err := validateTokenBindingIsRequired(ctx, client); err != nil {
 		return err
 	}
 
@@ -135,13 +157,65 @@ func validateAuthorizationCodeGrantRequest(
 		return err
 	}
 
+	if err := validateAuthorizationCodeBinding(ctx, session); err != nil {
+		return err
+	}
+
+	return validateActiveResources(ctx, req, session.GrantedResources)
+}
+
+// validateRedirectURI makes sure the redirect_uri is informed and is identical
+// to the one used at the authorization endpoint. It can only be omitted if it
+// was also omitted during the authorization request.
+func validateRedirectURI(req tokenRequest, session *goidc.AuthnSession) oidc.Error {
+	if req.RedirectURI == "" && session.RedirectURIIsDefault {
+		return nil
+	}
+
+	if req.RedirectURI == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "redirect_uri is required")
+	}
+
+	if req.RedirectURI != session.RedirectURI {
+		return oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid redirect_uri")
+	}
+
+	return nil
+}
+
+// validateAuthorizationCodeBinding makes sure the authorization code is redeemed
+// with the DPoP key and the client certificate it was bound to.
+func validateAuthorizationCodeBinding(
+	ctx *oidc.Context,
+	session *goidc.AuthnSession,
+) oidc.Error {
+	if session.DPoPJWKThumbprint != "" {
+		dpopJWT, ok := ctx.DPoPJWT()
+		if !ok {
+			return oidc.NewError(oidc.ErrorCodeInvalidGrant, "the authorization code is bound to a dpop key")
+		}
+
+		if err := ValidateDPoPJWT(ctx, dpopJWT, DPoPJWTValidationOptions{
+			JWKThumbprint: session.DPoPJWKThumbprint,
+		}); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInvalidGrant, "the authorization code is bound to another dpop key")
+		}
+	}
+
+	if session.ClientCertificateThumbprint != "" {
+		clientCert, ok := ctx.ClientCertificate()
+		if !ok || ClientCertificateThumbprint(clientCert) != session.ClientCertificateThumbprint {
+			return oidc.NewError(oidc.ErrorCodeInvalidGrant, "the authorization code is bound to another client certificate")
+		}
+	}
+
 	return nil
 }
 
 func validatePkce(
 	ctx *oidc.Context,
 	req tokenRequest,
-	_ *goidc.Client,
+	client *goidc.Client,
 	session *goidc.AuthnSession,
 ) oidc.Error {
 	// RFC 7636. "...with a minimum length of 43 characters and a maximum length of 128 characters."
@@ -154,11 +228,11 @@ func validatePkce(
 	if codeChallengeMethod == "" {
 		codeChallengeMethod = goidc.CodeChallengeMethodPlain
 	}
-	if ctx.Profile == goidc.ProfileFAPI2 {
+	if ctx.ClientProfile(client) == goidc.ProfileFAPI2 {
 		codeChallengeMethod = goidc.CodeChallengeMethodSHA256
 	}
 	// In the case PKCE is enabled, if the session was created with a code challenge, the token request must contain the right code verifier.
-	if ctx.PkceIsEnabled && session.CodeChallenge != "" &&
+	if (ctx.PkceIsEnabled || ctx.PkceIsRequiredForPublicClients) && session.CodeChallenge != "" &&
 		(req.CodeVerifier == "" || !isPKCEValid(req.CodeVerifier, session.CodeChallenge, codeChallengeMethod)) {
 		return oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid pkce")
 	}
@@ -227,7 +301,12 @@ func newAuthorizationCodeGrantOptions(
 	oidc.Error,
 ) {
 
-	tokenOptions, err := ctx.TokenOptions(client, req.Scopes)
+	scopes, oauthErr := grantedScopes(ctx, goidc.GrantAuthorizationCode, client, session.GrantedScopes)
+	if oauthErr != nil {
+		return GrantOptions{}, oauthErr
+	}
+
+	tokenOptions, err := ctx.TokenOptions(client, goidc.GrantAuthorizationCode, req.Scopes, tokenResources(req, session.GrantedResources))
 	if err != nil {
 		return GrantOptions{}, oidc.NewError(oidc.ErrorCodeAccessDenied, err.Error())
 	}
@@ -235,9 +314,10 @@ func newAuthorizationCodeGrantOptions(
 
 	grantOptions := GrantOptions{
 		GrantType:                goidc.GrantAuthorizationCode,
-		GrantedScopes:            session.GrantedScopes,
+		GrantedScopes:            scopes,
 		Subject:                  session.Subject,
 		ClientID:                 session.ClientID,
+		SessionID:                session.SessionID,
 		TokenOptions:             tokenOptions,
 		AdditionalIDTokenClaims:  session.AdditionalIDTokenClaims,
 		AdditionalUserInfoClaims: session.AdditionalUserInfoClaims,
@@ -245,6 +325,10 @@ func newAuthorizationCodeGrantOptions(
 	if ctx.AuthorizationDetailsParameterIsEnabled {
 		grantOptions.GrantedAuthorizationDetails = session.GrantedAuthorizationDetails
 	}
+	if ctx.ResourceIndicatorsIsEnabled {
+		grantOptions.GrantedResources = session.GrantedResources
+		grantOptions.ActiveResources = req.Resources
+	}
 
 	return grantOptions, nil
 }
@@ -13,6 +13,10 @@ func handleClientCredentialsGrantTokenCreation(
 	tokenResponse,
 	oidc.Error,
 ) {
+	if oauthErr := preValidateClientCredentialsGrantRequest(req); oauthErr != nil {
+		return tokenResponse{}, oauthErr
+	}
+
 	client, oauthErr := authn.Client(ctx, req.ClientAuthnRequest)
 	if oauthErr != nil {
 		return tokenResponse{}, oauthErr
@@ -32,9 +36,9 @@ func handleClientCredentialsGrantTokenCreation(
 		return tokenResponse{}, err
 	}
 
-	_, oauthErr = generateClientCredentialsGrantSession(ctx, client, token, grantOptions)
+	grantSession, oauthErr := generateClientCredentialsGrantSession(ctx, client, token, grantOptions)
 	if oauthErr != nil {
-		return tokenResponse{}, nil
+		return tokenResponse{}, oauthErr
 	}
 
 	tokenResp := tokenResponse{
@@ -47,6 +51,7 @@ func handleClientCredentialsGrantTokenCreation(
 		tokenResp.Scopes = grantOptions.GrantedScopes
 	}
 
+	customizeTokenResponse(ctx, client, grantSession, &tokenResp)
 	return tokenResp, nil
 }
 
@@ -68,6 +73,14 @@ func generateClientCredentialsGrantSession(
 	return grantSession, nil
 }
 
+func preValidateClientCredentialsGrantRequest(req tokenRequest) oidc.Error {
+	if req.AuthorizationCode != "" || req.RedirectURI != "" || req.RefreshToken != "" || req.CodeVerifier != "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid parameter for client credentials grant")
+	}
+
+	return nil
+}
+
 func validateClientCredentialsGrantRequest(
 	ctx *oidc.Context,
 	req tokenRequest,
@@ -78,15 +91,36 @@ func validateClientCredentialsGrantRequest(
 		return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "invalid grant type")
 	}
 
-	if !client.AreScopesAllowed(ctx.Scopes, req.Scopes) {
+	// Public clients cannot keep credentials confidential, so they may not be
+	// allowed to obtain tokens on their own behalf.
+	if ctx.PublicClientRestrictionIsEnabled && client.AuthnMethod == goidc.ClientAuthnNone {
+		return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "public clients cannot use the client_credentials grant type")
+	}
+
+	scopes := req.Scopes
+	if ctx.IgnoreUnknownScopes {
+		scopes = client.AllowedScopes(ctx.Scopes, req.Scopes)
+		// The request is still rejected if none of the scopes requested are recognized.
+		if req.Scopes != "" && scopes == "" {
+			return oidc.NewError(oidc.ErrorCodeInvalidScope, "invalid scope")
+		}
+	}
+
+	if !client.AreScopesAllowed(ctx.Scopes, scopes) {
 		return oidc.NewError(oidc.ErrorCodeInvalidScope, "invalid scope")
 	}
 
+	if ctx.ResourceIndicatorsIsEnabled {
+		if err := ValidateResources(ctx, client, req.Resources); err != nil {
+			return err
+		}
+	}
+
 	if err := validateTokenBindingRequestWithDPoP(ctx, req, client); err != nil {
 		return err
 	}
 
-	if err := validateTokenBindingIsRequired(ctx); err != nil {
+	if err := validateTokenBindingIsRequired(ctx, client); err != nil {
 		return err
 	}
 
@@ -101,20 +135,33 @@ func newClientCredentialsGrantOptions(
 	GrantOptions,
 	oidc.Error,
 ) {
-	tokenOptions, err := ctx.TokenOptions(client, req.Scopes)
+	scopes := req.Scopes
+	if ctx.IgnoreUnknownScopes {
+		scopes = client.AllowedScopes(ctx.Scopes, req.Scopes)
+	}
+
+	tokenOptions, err := ctx.TokenOptions(client, goidc.GrantClientCredentials, scopes, req.Resources)
 	if err != nil {
 		return GrantOptions{}, oidc.NewError(oidc.ErrorCodeAccessDenied, err.Error())
 	}
 
-	scopes := req.Scopes
 	if scopes == "" {
 		scopes = client.Scopes
 	}
-	return GrantOptions{
+	scopes, oauthErr := grantedScopes(ctx, goidc.GrantClientCredentials, client, scopes)
+	if oauthErr != nil {
+		return GrantOptions{}, oauthErr
+	}
+
+	grantOptions := GrantOptions{
 		GrantType:     goidc.GrantClientCredentials,
 		GrantedScopes: scopes,
 		Subject:       client.ID,
 		ClientID:      client.ID,
 		TokenOptions:  tokenOptions,
-	}, nil
+	}
+	if ctx.ResourceIndicatorsIsEnabled {
+		grantOptions.GrantedResources = req.Resources
+	}
+	return grantOptions, nil
 }
@@ -1,6 +1,12 @@
 package token
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/go-jose/go-jose/v4"
@@ -37,3 +43,500 @@ func TestHandleGrantCreation_ClientCredentialsHappyPath(t *testing.T) {
 	sessions := oidc.GrantSessions(t, ctx)
 	assert.Len(t, sessions, 1, "there should be one session")
 }
+
+func TestHandleGrantCreation_ClientCredentialsWithSubsetOfScopes(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	requestedScopes := fmt.Sprintf("%s %s", oidc.TestScope1.ID, goidc.ScopeOpenID.ID)
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    requestedScopes,
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Empty(t, tokenResp.Scopes, "the scopes should only be returned when they differ from the ones requested")
+
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, requestedScopes, claims[goidc.ClaimScope], "only the scopes requested should be granted")
+
+	sessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, requestedScopes, sessions[0].GrantedScopes)
+	assert.Equal(t, requestedScopes, sessions[0].ActiveScopes)
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithDynamicScope(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	paymentScope := goidc.NewDynamicScope("payment", func(requestedScope string) bool {
+		return strings.HasPrefix(requestedScope, "payment:")
+	})
+	paymentScope.Capture = func(requestedScope string) map[string]any {
+		amount, _ := strings.CutPrefix(requestedScope, "payment:")
+		return map[string]any{"payment_amount": amount}
+	}
+	ctx.Scopes = append(ctx.Scopes, paymentScope)
+
+	client, _ := ctx.Client(oidc.TestClientID)
+	client.Scopes = fmt.Sprintf("%s %s", client.Scopes, paymentScope.ID)
+	require.Nil(t, ctx.SaveClient(client))
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    "payment:30",
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, "payment:30", claims[goidc.ClaimScope], "the exact scope requested should be granted")
+	assert.Equal(t, "30", claims["payment_amount"])
+
+	sessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "payment:30", sessions[0].GrantedScopes)
+}
+
+func TestHandleGrantCreation_ClientCredentialsNotifiesEvents(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	hook := &eventRecorder{}
+	ctx.EventHooks = append(ctx.EventHooks, hook)
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	require.Len(t, hook.events, 2)
+	assert.Equal(t, goidc.EventTypeClientAuthenticated, hook.events[0].Type)
+	assert.Equal(t, goidc.EventTypeTokenIssued, hook.events[1].Type)
+	assert.Equal(t, oidc.TestClientID, hook.events[1].ClientID)
+	assert.Equal(t, goidc.GrantClientCredentials, hook.events[1].Details["grant_type"])
+}
+
+type eventRecorder struct {
+	events []goidc.Event
+}
+
+func (r *eventRecorder) OnEvent(_ context.Context, event goidc.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithoutAuthentication(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID: oidc.TestClientID,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.NotNil(t, err)
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidClient, oauthErr.Code())
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithPublicClient(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.PublicClientRestrictionIsEnabled = true
+	client := oidc.NewTestClient(t)
+	client.AuthnMethod = goidc.ClientAuthnNone
+	require.Nil(t, ctx.SaveClient(client))
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID: oidc.TestClientID,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.NotNil(t, err)
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeUnauthorizedClient, oauthErr.Code())
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithPublicClientRestrictionDisabled(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client := oidc.NewTestClient(t)
+	client.AuthnMethod = goidc.ClientAuthnNone
+	require.Nil(t, ctx.SaveClient(client))
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID: oidc.TestClientID,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1)
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithUnknownScopes(t *testing.T) {
+	testCases := []struct {
+		ignoreUnknownScopes bool
+		shouldSucceed       bool
+	}{
+		{false, false},
+		{true, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprintf("ignore unknown scopes %t", testCase.ignoreUnknownScopes), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.IgnoreUnknownScopes = testCase.ignoreUnknownScopes
+
+			req := tokenRequest{
+				ClientAuthnRequest: authn.ClientAuthnRequest{
+					ClientID:     oidc.TestClientID,
+					ClientSecret: oidc.TestClientSecret,
+				},
+				GrantType: goidc.GrantClientCredentials,
+				Scopes:    oidc.TestScope1.ID + " unknown_scope",
+			}
+
+			// When.
+			tokenResp, err := HandleTokenCreation(ctx, req)
+
+			// Then.
+			if !testCase.shouldSucceed {
+				require.NotNil(t, err)
+				var oauthErr oidc.Error
+				require.ErrorAs(t, err, &oauthErr)
+				assert.Equal(t, oidc.ErrorCodeInvalidScope, oauthErr.Code())
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, oidc.TestScope1.ID, tokenResp.Scopes, "the scopes granted should be informed")
+
+			claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+			assert.Equal(t, oidc.TestScope1.ID, claims[goidc.ClaimScope])
+		})
+	}
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithCustomResponseFields(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.TokenResponseCustomizeFunc = func(
+		_ goidc.Context,
+		client *goidc.Client,
+		grantSession *goidc.GrantSession,
+	) map[string]any {
+		return map[string]any{
+			"tenant":       "random_tenant",
+			"client":       client.ID,
+			"scope_count":  len(grantSession.GrantedScopes),
+			"access_token": "overridden_access_token",
+		}
+	}
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+
+	respBytes, err := json.Marshal(tokenResp)
+	require.Nil(t, err)
+
+	var resp map[string]any
+	require.Nil(t, json.Unmarshal(respBytes, &resp))
+	assert.Equal(t, "random_tenant", resp["tenant"])
+	assert.Equal(t, oidc.TestClientID, resp["client"])
+	assert.Equal(t, tokenResp.AccessToken, resp["access_token"], "standard fields cannot be overridden")
+	assert.Equal(t, string(goidc.TokenTypeBearer), resp["token_type"])
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithResources(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ResourceIndicatorsIsEnabled = true
+	ctx.Resources = []string{"https://resource1.com", "https://resource2.com"}
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+		Resources: goidc.Resources{"https://resource1.com"},
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, "https://resource1.com", claims["aud"])
+
+	sessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, goidc.Resources{"https://resource1.com"}, sessions[0].GrantedResources)
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithTokenOptionsPerResource(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ResourceIndicatorsIsEnabled = true
+	ctx.Resources = []string{"https://resource1.com", "https://resource2.com"}
+	defaultTokenOptions := ctx.TokenOptions
+	ctx.TokenOptions = func(
+		client *goidc.Client,
+		grantType goidc.GrantType,
+		scopes string,
+		resources goidc.Resources,
+	) (
+		goidc.TokenOptions,
+		error,
+	) {
+		opts, err := defaultTokenOptions(client, grantType, scopes, resources)
+		if err != nil {
+			return goidc.TokenOptions{}, err
+		}
+		if grantType == goidc.GrantClientCredentials && slices.Contains(resources, "https://resource2.com") {
+			return opts.WithOpaqueFormat(50).WithLifetime(30), nil
+		}
+		return opts, nil
+	}
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+		Resources: goidc.Resources{"https://resource2.com"},
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, tokenResp.AccessToken, 50, "the access token should be opaque")
+	assert.Equal(t, int64(30), tokenResp.ExpiresIn)
+
+	// When.
+	req.Resources = goidc.Resources{"https://resource1.com"}
+	tokenResp, err = HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, "https://resource1.com", claims["aud"])
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithInvalidResources(t *testing.T) {
+	testCases := []struct {
+		name     string
+		resource string
+	}{
+		{"unregistered resource", "https://unknown.com"},
+		{"relative uri", "resource1"},
+		{"uri with fragment", "https://resource1.com#fragment"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.ResourceIndicatorsIsEnabled = true
+			ctx.Resources = []string{"https://resource1.com", "resource1", "https://resource1.com#fragment"}
+
+			req := tokenRequest{
+				ClientAuthnRequest: authn.ClientAuthnRequest{
+					ClientID:     oidc.TestClientID,
+					ClientSecret: oidc.TestClientSecret,
+				},
+				GrantType: goidc.GrantClientCredentials,
+				Scopes:    oidc.TestScope1.ID,
+				Resources: goidc.Resources{testCase.resource},
+			}
+
+			// When.
+			_, err := HandleTokenCreation(ctx, req)
+
+			// Then.
+			var oauthErr oidc.Error
+			require.ErrorAs(t, err, &oauthErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidTarget, oauthErr.Code())
+			assert.Empty(t, oidc.GrantSessions(t, ctx))
+		})
+	}
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithResourceValidationFunc(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ResourceIndicatorsIsEnabled = true
+	ctx.Resources = []string{"https://resource1.com"}
+	ctx.ResourceValidationFunc = func(_ goidc.Context, client *goidc.Client, _ goidc.Resources) error {
+		return fmt.Errorf("the client %s cannot access the resources", client.ID)
+	}
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+		Resources: goidc.Resources{"https://resource1.com"},
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidTarget, oauthErr.Code())
+}
+
+func TestHandleGrantCreation_ClientCredentialsWithScopePolicy(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.Scopes = append(ctx.Scopes, goidc.ScopeOfflineAccess)
+	ctx.ScopePolicyFunc = offlineAccessOnlyWithAuthorizationCode
+
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.Scopes += " " + goidc.ScopeOfflineAccess.ID
+	require.Nil(t, ctx.SaveClient(client))
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID + " " + goidc.ScopeOfflineAccess.ID,
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, oidc.TestScope1.ID, tokenResp.Scopes, "the scopes granted should be informed")
+
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, oidc.TestScope1.ID, claims[goidc.ClaimScope])
+
+	sessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, oidc.TestScope1.ID, sessions[0].GrantedScopes)
+}
+
+func TestHandleGrantCreation_ClientCredentialsRejectedByScopePolicy(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.ScopePolicyFunc = func(_ goidc.Context, _ goidc.GrantType, _ *goidc.Client, _ string) (string, error) {
+		return "", errors.New("scope not allowed")
+	}
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantClientCredentials,
+		Scopes:    oidc.TestScope1.ID,
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidScope, oauthErr.Code())
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+// offlineAccessOnlyWithAuthorizationCode is a scope policy that only issues
+// offline_access with the authorization code grant.
+func offlineAccessOnlyWithAuthorizationCode(
+	_ goidc.Context,
+	grantType goidc.GrantType,
+	_ *goidc.Client,
+	scopes string,
+) (
+	string,
+	error,
+) {
+	if grantType == goidc.GrantAuthorizationCode {
+		return scopes, nil
+	}
+
+	granted := slices.DeleteFunc(strings.Fields(scopes), func(scope string) bool {
+		return scope == goidc.ScopeOfflineAccess.ID
+	})
+	return strings.Join(granted, " "), nil
+}
@@ -5,4 +5,14 @@ const (
 	// This happens since a refresh token is identified by its length during introspection.
 	RefreshTokenLength              int = 99
 	defaultRefreshTokenLifetimeSecs int = 6000
+	// RFC9068. "...This specification registers the "application/at+jwt" media type,
+	// which can be used to indicate that the content is a JWT access token."
+	accessTokenJWTType = "at+jwt"
+	dpopJWTType        = "dpop+jwt"
+	// RFC 9701. The media type used to request and to return introspection
+	// responses as signed JWTs.
+	introspectionResponseJWTType = "token-introspection+jwt"
+	// deviceSlowDownIncrementSecs is how much the polling interval of a device
+	// increases every time it polls the token endpoint too fast.
+	deviceSlowDownIncrementSecs int64 = 5
 )
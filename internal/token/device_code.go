@@ -0,0 +1,231 @@
+package token
+
+import (
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+func handleDeviceCodeGrantTokenCreation(
+	ctx *oidc.Context,
+	req tokenRequest,
+) (
+	tokenResponse,
+	oidc.Error,
+) {
+	if !ctx.DeviceGrantIsEnabled {
+		return tokenResponse{}, oidc.NewError(oidc.ErrorCodeUnsupportedGrantType, "unsupported grant type")
+	}
+
+	if err := preValidateDeviceCodeGrantRequest(req); err != nil {
+		return tokenResponse{}, err
+	}
+
+	client, err := authn.Client(ctx, req.ClientAuthnRequest)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	session, sessionErr := ctx.DeviceSessionByDeviceCode(req.DeviceCode)
+	if sessionErr != nil {
+		return tokenResponse{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid device code")
+	}
+
+	if err := validateDeviceCodeGrantRequest(ctx, req, client, session); err != nil {
+		return tokenResponse{}, err
+	}
+
+	if err := validateDeviceSessionStatus(ctx, session); err != nil {
+		return tokenResponse{}, err
+	}
+
+	grantOptions, err := newDeviceCodeGrantOptions(ctx, req, client, session)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	token, err := Make(ctx, client, grantOptions)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	grantSession, err := generateDeviceCodeGrantSession(ctx, token, grantOptions)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	tokenResp := tokenResponse{
+		AccessToken:  token.Value,
+		ExpiresIn:    grantOptions.TokenLifetimeSecs,
+		TokenType:    token.Type,
+		RefreshToken: grantSession.RefreshToken,
+	}
+
+	if shouldIssueIDToken(ctx, grantOptions.GrantedScopes) {
+		tokenResp.IDToken, err = MakeIDToken(ctx, client, newIDTokenOptions(grantOptions))
+		if err != nil {
+			return tokenResponse{}, err
+		}
+	}
+
+	if session.Scopes != grantOptions.GrantedScopes {
+		tokenResp.Scopes = grantOptions.GrantedScopes
+	}
+
+	customizeTokenResponse(ctx, client, grantSession, &tokenResp)
+	return tokenResp, nil
+}
+
+func preValidateDeviceCodeGrantRequest(req tokenRequest) oidc.Error {
+	if req.DeviceCode == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "device_code is required")
+	}
+
+	if req.AuthorizationCode != "" || req.RefreshToken != "" || req.CodeVerifier != "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid parameter for device code grant")
+	}
+
+	return nil
+}
+
+func validateDeviceCodeGrantRequest(
+	ctx *oidc.Context,
+	req tokenRequest,
+	client *goidc.Client,
+	session *goidc.DeviceSession,
+) oidc.Error {
+	if !client.IsGrantTypeAllowed(goidc.GrantDeviceCode) {
+		return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "invalid grant type")
+	}
+
+	if session.ClientID != client.ID {
+		return oidc.NewError(oidc.ErrorCodeInvalidGrant, "the device code was not issued to the client")
+	}
+
+	if err := validateTokenBindingIsRequired(ctx, client); err != nil {
+		return err
+	}
+
+	if err := validateTokenBindingRequestWithDPoP(ctx, req, client); err != nil {
+		return err
+	}
+
+	return validateActiveResources(ctx, req, session.GrantedResources)
+}
+
+// validateDeviceSessionStatus makes sure the user approved the device.
+// The device session is deleted once it reaches a final state, so the device
+// code can be exchanged for tokens only once.
+func validateDeviceSessionStatus(
+	ctx *oidc.Context,
+	session *goidc.DeviceSession,
+) oidc.Error {
+	if session.IsExpired() {
+		if err := ctx.DeleteDeviceSession(session.ID); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		return oidc.NewError(oidc.ErrorCodeExpiredToken, "the device code is expired")
+	}
+
+	switch session.Status {
+	case goidc.DeviceSessionStatusAuthorized:
+		if err := ctx.DeleteDeviceSession(session.ID); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		return nil
+	case goidc.DeviceSessionStatusDenied:
+		if err := ctx.DeleteDeviceSession(session.ID); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		return oidc.NewError(oidc.ErrorCodeAccessDenied, "the user denied the authorization request")
+	}
+
+	now := time.Now().Unix()
+	isPollingTooFast := now-session.LastPolledAtTimestamp < session.PollingIntervalSecs
+	session.LastPolledAtTimestamp = now
+	// RFC 8628. "...the interval MUST be increased by 5 seconds for this and
+	// all subsequent requests."
+	if isPollingTooFast {
+		session.PollingIntervalSecs += deviceSlowDownIncrementSecs
+	}
+
+	if err := ctx.SaveDeviceSession(session); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	if isPollingTooFast {
+		return oidc.NewError(oidc.ErrorCodeSlowDown, "the device is polling too fast")
+	}
+
+	return oidc.NewError(oidc.ErrorCodeAuthorizationPending, "the user has not approved the device yet")
+}
+
+func newDeviceCodeGrantOptions(
+	ctx *oidc.Context,
+	req tokenRequest,
+	client *goidc.Client,
+	session *goidc.DeviceSession,
+) (
+	GrantOptions,
+	oidc.Error,
+) {
+	scopes, oauthErr := grantedScopes(ctx, goidc.GrantDeviceCode, client, session.GrantedScopes)
+	if oauthErr != nil {
+		return GrantOptions{}, oauthErr
+	}
+
+	tokenOptions, err := ctx.TokenOptions(client, goidc.GrantDeviceCode, scopes, tokenResources(req, session.GrantedResources))
+	if err != nil {
+		return GrantOptions{}, oidc.NewError(oidc.ErrorCodeAccessDenied, err.Error())
+	}
+	tokenOptions.AddTokenClaims(session.AdditionalTokenClaims)
+
+	grantOptions := GrantOptions{
+		GrantType:                goidc.GrantDeviceCode,
+		GrantedScopes:            scopes,
+		Subject:                  session.Subject,
+		ClientID:                 session.ClientID,
+		SessionID:                session.SessionID,
+		TokenOptions:             tokenOptions,
+		AdditionalIDTokenClaims:  session.AdditionalIDTokenClaims,
+		AdditionalUserInfoClaims: session.AdditionalUserInfoClaims,
+	}
+	if ctx.AuthorizationDetailsParameterIsEnabled {
+		grantOptions.GrantedAuthorizationDetails = session.GrantedAuthorizationDetails
+	}
+	if ctx.ResourceIndicatorsIsEnabled {
+		grantOptions.GrantedResources = session.GrantedResources
+		grantOptions.ActiveResources = req.Resources
+	}
+
+	return grantOptions, nil
+}
+
+func generateDeviceCodeGrantSession(
+	ctx *oidc.Context,
+	token Token,
+	grantOptions GrantOptions,
+) (
+	*goidc.GrantSession,
+	oidc.Error,
+) {
+
+	grantSession := NewGrantSession(grantOptions, token)
+	if strutil.ContainsOfflineAccess(grantSession.GrantedScopes) {
+		token, err := refreshToken()
+		if err != nil {
+			return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		grantSession.RefreshToken = token
+		grantSession.ExpiresAtTimestamp = time.Now().Unix() + ctx.RefreshTokenLifetimeSecs
+	}
+
+	if err := ctx.SaveGrantSession(grantSession); err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	return grantSession, nil
+}
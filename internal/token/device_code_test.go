@@ -0,0 +1,169 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGrantCreation_DeviceCodeGrantHappyPath(t *testing.T) {
+	// Given.
+	ctx, session := setUpDeviceCodeGrant(t, goidc.DeviceSessionStatusAuthorized)
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, newDeviceCodeTokenRequest(session.DeviceCode))
+
+	// Then.
+	require.Nil(t, err)
+
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, oidc.TestClientID, claims["client_id"], "the token was assigned to a different client")
+	assert.Equal(t, session.Subject, claims["sub"], "the token subject should be the user")
+	assert.NotEmpty(t, tokenResp.IDToken)
+
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1, "there should be one session")
+	assert.Empty(t, oidc.DeviceSessions(t, ctx), "the device code should be used only once")
+}
+
+func TestHandleGrantCreation_DeviceCodeGrantAuthorizationPending(t *testing.T) {
+	// Given.
+	ctx, session := setUpDeviceCodeGrant(t, goidc.DeviceSessionStatusPending)
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newDeviceCodeTokenRequest(session.DeviceCode))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeAuthorizationPending, oauthErr.Code())
+	assert.NotZero(t, session.LastPolledAtTimestamp)
+	assert.Equal(t, int64(5), session.PollingIntervalSecs)
+}
+
+func TestHandleGrantCreation_DeviceCodeGrantSlowDown(t *testing.T) {
+	// Given.
+	ctx, session := setUpDeviceCodeGrant(t, goidc.DeviceSessionStatusPending)
+	session.LastPolledAtTimestamp = time.Now().Unix()
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newDeviceCodeTokenRequest(session.DeviceCode))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeSlowDown, oauthErr.Code())
+	assert.Equal(t, int64(10), session.PollingIntervalSecs, "the interval should be increased by 5 seconds")
+}
+
+func TestHandleGrantCreation_DeviceCodeGrantAccessDenied(t *testing.T) {
+	// Given.
+	ctx, session := setUpDeviceCodeGrant(t, goidc.DeviceSessionStatusDenied)
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newDeviceCodeTokenRequest(session.DeviceCode))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeAccessDenied, oauthErr.Code())
+	assert.Empty(t, oidc.DeviceSessions(t, ctx))
+}
+
+func TestHandleGrantCreation_DeviceCodeGrantExpiredToken(t *testing.T) {
+	// Given.
+	ctx, session := setUpDeviceCodeGrant(t, goidc.DeviceSessionStatusAuthorized)
+	session.ExpiresAtTimestamp = time.Now().Unix() - 1
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newDeviceCodeTokenRequest(session.DeviceCode))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeExpiredToken, oauthErr.Code())
+	assert.Empty(t, oidc.DeviceSessions(t, ctx))
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+func TestHandleGrantCreation_DeviceCodeIssuedToAnotherClient(t *testing.T) {
+	// Given.
+	ctx, session := setUpDeviceCodeGrant(t, goidc.DeviceSessionStatusAuthorized)
+	session.ClientID = "another_client_id"
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newDeviceCodeTokenRequest(session.DeviceCode))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidGrant, oauthErr.Code())
+	assert.Len(t, oidc.DeviceSessions(t, ctx), 1, "the device code should not be consumed")
+}
+
+func TestHandleGrantCreation_DeviceCodeGrantNotEnabled(t *testing.T) {
+	// Given.
+	ctx, session := setUpDeviceCodeGrant(t, goidc.DeviceSessionStatusAuthorized)
+	ctx.DeviceGrantIsEnabled = false
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newDeviceCodeTokenRequest(session.DeviceCode))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeUnsupportedGrantType, oauthErr.Code())
+}
+
+func setUpDeviceCodeGrant(
+	t *testing.T,
+	status goidc.DeviceSessionStatus,
+) (
+	*oidc.Context,
+	*goidc.DeviceSession,
+) {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.DeviceGrantIsEnabled = true
+	ctx.IDTokenExpiresInSecs = 60
+
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.GrantTypes = append(client.GrantTypes, goidc.GrantDeviceCode)
+	require.Nil(t, ctx.SaveClient(client))
+
+	now := time.Now().Unix()
+	session := &goidc.DeviceSession{
+		ID:                  "random_device_session_id",
+		DeviceCode:          "random_device_code",
+		UserCode:            "BCDFGHJK",
+		ClientID:            oidc.TestClientID,
+		Scopes:              goidc.ScopeOpenID.ID,
+		Status:              status,
+		PollingIntervalSecs: 5,
+		CreatedAtTimestamp:  now,
+		ExpiresAtTimestamp:  now + 600,
+		Subject:             "user_id",
+		GrantedScopes:       goidc.ScopeOpenID.ID,
+	}
+	require.Nil(t, ctx.SaveDeviceSession(session))
+
+	return ctx, session
+}
+
+func newDeviceCodeTokenRequest(deviceCode string) tokenRequest {
+	return tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType:  goidc.GrantDeviceCode,
+		DeviceCode: deviceCode,
+	}
+}
@@ -1,6 +1,11 @@
 package token
 
 import (
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/authn"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
@@ -10,19 +15,38 @@ func introspect(
 	ctx *oidc.Context,
 	req tokenIntrospectionRequest,
 ) (
+	*goidc.Client,
 	goidc.TokenInfo,
 	oidc.Error,
 ) {
 	client, err := authn.Client(ctx, req.ClientAuthnRequest)
 	if err != nil {
-		return goidc.TokenInfo{}, err
+		return nil, goidc.TokenInfo{}, err
 	}
 
 	if err := validateTokenIntrospectionRequest(ctx, req, client); err != nil {
-		return goidc.TokenInfo{}, err
+		return nil, goidc.TokenInfo{}, err
+	}
+
+	info := TokenIntrospectionInfo(ctx, req.Token)
+	if !canIntrospect(ctx, client, info) {
+		// RFC 7662. "...the authorization server MAY respond with an "active"
+		// field set to false... to avoid leaking information about the token."
+		return client, goidc.TokenInfo{IsActive: false}, nil
+	}
+
+	return client, info, nil
+}
+
+// canIntrospect returns true if the client is allowed to learn about the token.
+// Clients can introspect their own tokens, whereas tokens issued to other
+// clients are only disclosed to the clients authorized by ResourceServerAuthzFunc.
+func canIntrospect(ctx *oidc.Context, client *goidc.Client, info goidc.TokenInfo) bool {
+	if !info.IsActive || info.ClientID == client.ID {
+		return true
 	}
 
-	return TokenIntrospectionInfo(ctx, req.Token), nil
+	return ctx.ResourceServerAuthzFunc != nil && ctx.ResourceServerAuthzFunc(ctx, client, info)
 }
 
 func validateTokenIntrospectionRequest(
@@ -106,18 +130,24 @@ func opaqueTokenIntrospectionInfo(
 	ctx *oidc.Context,
 	token string,
 ) goidc.TokenInfo {
-	return tokenIntrospectionInfoByID(ctx, token)
+	return tokenIntrospectionInfoByID(ctx, opaqueTokenID(ctx, token))
 }
 
 func tokenIntrospectionInfoByID(
 	ctx *oidc.Context,
 	tokenID string,
 ) goidc.TokenInfo {
+	if info, ok := ctx.CachedIntrospection(tokenID); ok {
+		return info
+	}
+
 	grantSession, err := ctx.GrantSessionByTokenID(tokenID)
 	if err != nil {
-		return goidc.TokenInfo{
+		info := goidc.TokenInfo{
 			IsActive: false,
 		}
+		ctx.CacheIntrospection(tokenID, "", info)
+		return info
 	}
 
 	if grantSession.HasLastTokenExpired() {
@@ -126,16 +156,77 @@ func tokenIntrospectionInfoByID(
 		}
 	}
 
-	return goidc.TokenInfo{
+	// The claims captured from the scopes are informed as well, so opaque
+	// tokens carry the same information as JWT ones.
+	additionalClaims := ctx.ScopeCapturedClaims(grantSession.ActiveScopes)
+	for k, v := range grantSession.AdditionalTokenClaims {
+		additionalClaims[k] = v
+	}
+	if len(additionalClaims) == 0 {
+		additionalClaims = nil
+	}
+
+	info := goidc.TokenInfo{
 		IsActive:                    true,
 		TokenUsage:                  goidc.TokenHintAccess,
 		Scopes:                      grantSession.ActiveScopes,
-		AuthorizationDetails:        grantSession.GrantedAuthorizationDetails,
+		AuthorizationDetails:        grantSession.ActiveAuthorizationDetails,
 		ClientID:                    grantSession.ClientID,
 		Subject:                     grantSession.Subject,
 		ExpiresAtTimestamp:          grantSession.LastTokenIssuedAtTimestamp + grantSession.TokenLifetimeSecs,
 		JWKThumbprint:               grantSession.JWKThumbprint,
 		ClientCertificateThumbprint: grantSession.ClientCertificateThumbprint,
-		AdditionalTokenClaims:       grantSession.AdditionalTokenClaims,
+		AdditionalTokenClaims:       additionalClaims,
+	}
+	ctx.CacheIntrospection(tokenID, grantSession.ID, info)
+	return info
+}
+
+// shouldReturnIntrospectionResponseJWT informs whether the client requested the
+// introspection response as a signed JWT as defined in RFC 9701.
+func shouldReturnIntrospectionResponseJWT(ctx *oidc.Context) bool {
+	if !ctx.IntrospectionJWTResponseIsEnabled {
+		return false
 	}
+
+	return strings.Contains(ctx.Request().Header.Get("Accept"), "application/"+introspectionResponseJWTType)
+}
+
+// introspectionResponseJWT signs the introspection response for the client that
+// requested it. The token information is placed in the "token_introspection"
+// claim as defined in RFC 9701.
+func introspectionResponseJWT(
+	ctx *oidc.Context,
+	client *goidc.Client,
+	tokenInfo goidc.TokenInfo,
+) (
+	string,
+	oidc.Error,
+) {
+	privateJWK, ok := ctx.PrivateKey(ctx.IntrospectionJWTResponseSignatureKeyID)
+	if !ok {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, "the introspection signature key was not found")
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
+		(&jose.SignerOptions{}).WithType(introspectionResponseJWTType).WithHeader("kid", privateJWK.KeyID),
+	)
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	claims := map[string]any{
+		goidc.ClaimIssuer:             ctx.Host,
+		goidc.ClaimAudience:           client.ID,
+		goidc.ClaimIssuedAt:           time.Now().Unix(),
+		goidc.ClaimTokenIntrospection: tokenInfo,
+	}
+
+	introspectionJWT, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	return introspectionJWT, nil
 }
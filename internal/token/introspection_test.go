@@ -1,9 +1,16 @@
 package token
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/authn"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/strutil"
@@ -40,7 +47,7 @@ func TestIntrospectToken_OpaqueToken(t *testing.T) {
 	}
 
 	// When.
-	tokenInfo, err := introspect(ctx, tokenReq)
+	_, tokenInfo, err := introspect(ctx, tokenReq)
 
 	// Then.
 	require.Nil(t, err)
@@ -52,6 +59,149 @@ func TestIntrospectToken_OpaqueToken(t *testing.T) {
 	assert.LessOrEqual(t, tokenInfo.ExpiresAtTimestamp, expiryTime+5)
 }
 
+func TestIntrospectToken_TokenIssuedToAnotherClient(t *testing.T) {
+	// Given.
+	ctx, req := setUpCrossClientIntrospection(t)
+
+	// When.
+	_, tokenInfo, err := introspect(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.False(t, tokenInfo.IsActive, "the token of another client should not be disclosed")
+}
+
+func TestIntrospectToken_TokenIssuedToAnotherClientWithResourceServerAuthz(t *testing.T) {
+	// Given.
+	ctx, req := setUpCrossClientIntrospection(t)
+	ctx.ResourceServerAuthzFunc = func(_ goidc.Context, client *goidc.Client, _ goidc.TokenInfo) bool {
+		return client.ID == "resource_server_id"
+	}
+
+	// When.
+	_, tokenInfo, err := introspect(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	require.True(t, tokenInfo.IsActive)
+	assert.Equal(t, oidc.TestClientID, tokenInfo.ClientID)
+	assert.Equal(t, "random_subject", tokenInfo.Subject)
+}
+
+// setUpCrossClientIntrospection creates a token for the test client and an
+// introspection request sent by another client.
+func setUpCrossClientIntrospection(t *testing.T) (*oidc.Context, tokenIntrospectionRequest) {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	resourceServer := oidc.NewTestClient(t)
+	resourceServer.ID = "resource_server_id"
+	resourceServer.GrantTypes = []goidc.GrantType{goidc.GrantIntrospection}
+	require.Nil(t, ctx.SaveClient(resourceServer))
+
+	token := "opaque_token"
+	grantSession := &goidc.GrantSession{
+		TokenID:                    token,
+		LastTokenIssuedAtTimestamp: time.Now().Unix(),
+		ActiveScopes:               goidc.ScopeOpenID.ID,
+		ClientID:                   oidc.TestClientID,
+		Subject:                    "random_subject",
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+	return ctx, tokenIntrospectionRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     resourceServer.ID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token: token,
+	}
+}
+
+func TestIntrospectToken_OpaqueTokenWithDynamicScope(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	paymentScope := goidc.NewDynamicScope("payment", func(requestedScope string) bool {
+		return strings.HasPrefix(requestedScope, "payment:")
+	})
+	paymentScope.Capture = func(requestedScope string) map[string]any {
+		amount, _ := strings.CutPrefix(requestedScope, "payment:")
+		return map[string]any{"payment_amount": amount}
+	}
+	ctx.Scopes = append(ctx.Scopes, paymentScope)
+	client := oidc.NewTestClient(t)
+	client.GrantTypes = append(client.GrantTypes, goidc.GrantIntrospection)
+	require.Nil(t, ctx.SaveClient(client))
+
+	token := "opaque_token"
+	grantSession := &goidc.GrantSession{
+		TokenID:                    token,
+		LastTokenIssuedAtTimestamp: time.Now().Unix(),
+		ActiveScopes:               "payment:30",
+		ClientID:                   oidc.TestClientID,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+	tokenReq := tokenIntrospectionRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token: token,
+	}
+
+	// When.
+	_, tokenInfo, err := introspect(ctx, tokenReq)
+
+	// Then.
+	require.Nil(t, err)
+	require.True(t, tokenInfo.IsActive)
+	assert.Equal(t, "payment:30", tokenInfo.Scopes)
+	assert.Equal(t, "30", tokenInfo.AdditionalTokenClaims["payment_amount"])
+}
+
+func TestIntrospectToken_HashedOpaqueToken(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.OpaqueTokenHashingIsEnabled = true
+	client := oidc.NewTestClient(t)
+	client.GrantTypes = append(client.GrantTypes, goidc.GrantIntrospection)
+	require.Nil(t, ctx.SaveClient(client))
+
+	grantOptions := GrantOptions{
+		ClientID:      oidc.TestClientID,
+		GrantedScopes: goidc.ScopeOpenID.ID,
+		TokenOptions:  goidc.NewOpaqueTokenOptions(10, 60),
+	}
+	token, err := Make(ctx, client, grantOptions)
+	require.Nil(t, err)
+	require.NotEqual(t, token.Value, token.ID, "the opaque token must not be stored in plain text")
+	require.Nil(t, ctx.SaveGrantSession(NewGrantSession(grantOptions, token)))
+
+	tokenReq := tokenIntrospectionRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token: token.Value,
+	}
+
+	// When.
+	_, tokenInfo, err := introspect(ctx, tokenReq)
+
+	// Then.
+	require.Nil(t, err)
+	require.True(t, tokenInfo.IsActive)
+	assert.Equal(t, goidc.ScopeOpenID.ID, tokenInfo.Scopes)
+	assert.Equal(t, oidc.TestClientID, tokenInfo.ClientID)
+}
+
 func TestIntrospectToken_RefreshToken(t *testing.T) {
 	// Given.
 	ctx := oidc.NewTestContext(t)
@@ -79,7 +229,7 @@ func TestIntrospectToken_RefreshToken(t *testing.T) {
 	}
 
 	// When.
-	tokenInfo, err := introspect(ctx, tokenReq)
+	_, tokenInfo, err := introspect(ctx, tokenReq)
 
 	// Then.
 	require.Nil(t, err)
@@ -89,3 +239,76 @@ func TestIntrospectToken_RefreshToken(t *testing.T) {
 	assert.GreaterOrEqual(t, tokenInfo.ExpiresAtTimestamp, expiryTime-5)
 	assert.LessOrEqual(t, tokenInfo.ExpiresAtTimestamp, expiryTime+5)
 }
+
+func TestHandlerIntrospect_JWTResponse(t *testing.T) {
+	testCases := []struct {
+		name            string
+		accept          string
+		shouldReturnJWT bool
+	}{
+		{"jwt requested", "application/token-introspection+jwt", true},
+		{"json requested", "application/json", false},
+		{"accept not informed", "", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.IntrospectionIsEnabled = true
+			ctx.IntrospectionJWTResponseIsEnabled = true
+			ctx.IntrospectionJWTResponseSignatureKeyID = oidc.TestServerPrivateJWK.KeyID
+			client := oidc.NewTestClient(t)
+			client.GrantTypes = append(client.GrantTypes, goidc.GrantIntrospection)
+			require.Nil(t, ctx.SaveClient(client))
+
+			token := "opaque_token"
+			require.Nil(t, ctx.SaveGrantSession(&goidc.GrantSession{
+				TokenID:                    token,
+				LastTokenIssuedAtTimestamp: time.Now().Unix(),
+				ActiveScopes:               goidc.ScopeOpenID.ID,
+				ClientID:                   oidc.TestClientID,
+				Subject:                    "random_subject",
+				TokenOptions: goidc.TokenOptions{
+					TokenLifetimeSecs: 60,
+				},
+			}))
+
+			form := url.Values{}
+			form.Set("client_id", oidc.TestClientID)
+			form.Set("client_secret", oidc.TestClientSecret)
+			form.Set("token", token)
+			req := httptest.NewRequest(http.MethodPost, goidc.EndpointTokenIntrospection, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept", testCase.accept)
+			resp := httptest.NewRecorder()
+
+			// When.
+			HandlerIntrospect(&ctx.Configuration)(resp, req)
+
+			// Then.
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			tokenInfo := make(map[string]any)
+			if testCase.shouldReturnJWT {
+				assert.Equal(t, "application/token-introspection+jwt", resp.Header().Get("Content-Type"))
+
+				parsedJWT, err := jwt.ParseSigned(resp.Body.String(), []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+				require.Nil(t, err)
+				assert.Equal(t, "token-introspection+jwt", parsedJWT.Headers[0].ExtraHeaders["typ"])
+
+				claims := oidc.SafeClaims(t, resp.Body.String(), oidc.TestServerPrivateJWK)
+				assert.Equal(t, ctx.Host, claims[goidc.ClaimIssuer])
+				assert.Equal(t, oidc.TestClientID, claims[goidc.ClaimAudience])
+				require.Contains(t, claims, goidc.ClaimTokenIntrospection)
+				tokenInfo = claims[goidc.ClaimTokenIntrospection].(map[string]any)
+			} else {
+				assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+				require.Nil(t, json.Unmarshal(resp.Body.Bytes(), &tokenInfo))
+			}
+
+			assert.Equal(t, true, tokenInfo["active"])
+			assert.Equal(t, "random_subject", tokenInfo[goidc.ClaimSubject])
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package token
+
+import (
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// handleJWTBearerGrantTokenCreation issues an access token in exchange for an
+// assertion signed by a trusted issuer as described in RFC 7523.
+func handleJWTBearerGrantTokenCreation(
+	ctx *oidc.Context,
+	req tokenRequest,
+) (
+	tokenResponse,
+	oidc.Error,
+) {
+	if !ctx.JWTBearerGrantIsEnabled {
+		return tokenResponse{}, oidc.NewError(oidc.ErrorCodeUnsupportedGrantType, "unsupported grant type")
+	}
+
+	if err := preValidateJWTBearerGrantRequest(req); err != nil {
+		return tokenResponse{}, err
+	}
+
+	client, err := authn.Client(ctx, req.ClientAuthnRequest)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	if err := validateJWTBearerGrantRequest(ctx, req, client); err != nil {
+		return tokenResponse{}, err
+	}
+
+	assertion, err := validJWTBearerAssertion(ctx, req.Assertion)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	grantOptions, err := newJWTBearerGrantOptions(ctx, req, client, assertion)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	token, err := Make(ctx, client, grantOptions)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	grantSession := NewGrantSession(grantOptions, token)
+	if err := ctx.SaveGrantSession(grantSession); err != nil {
+		return tokenResponse{}, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	tokenResp := tokenResponse{
+		AccessToken: token.Value,
+		ExpiresIn:   grantOptions.TokenLifetimeSecs,
+		TokenType:   token.Type,
+	}
+
+	if req.Scopes != grantOptions.GrantedScopes {
+		tokenResp.Scopes = grantOptions.GrantedScopes
+	}
+
+	customizeTokenResponse(ctx, client, grantSession, &tokenResp)
+	return tokenResp, nil
+}
+
+func preValidateJWTBearerGrantRequest(req tokenRequest) oidc.Error {
+	if req.Assertion == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "assertion is required")
+	}
+
+	if req.AuthorizationCode != "" || req.RedirectURI != "" || req.RefreshToken != "" ||
+		req.CodeVerifier != "" || req.DeviceCode != "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid parameter for jwt bearer grant")
+	}
+
+	return nil
+}
+
+func validateJWTBearerGrantRequest(
+	ctx *oidc.Context,
+	req tokenRequest,
+	client *goidc.Client,
+) oidc.Error {
+	if !client.IsGrantTypeAllowed(goidc.GrantJWTBearer) {
+		return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "invalid grant type")
+	}
+
+	scopes := req.Scopes
+	if ctx.IgnoreUnknownScopes {
+		scopes = client.AllowedScopes(ctx.Scopes, req.Scopes)
+		if req.Scopes != "" && scopes == "" {
+			return oidc.NewError(oidc.ErrorCodeInvalidScope, "invalid scope")
+		}
+	}
+
+	if !client.AreScopesAllowed(ctx.Scopes, scopes) {
+		return oidc.NewError(oidc.ErrorCodeInvalidScope, "invalid scope")
+	}
+
+	if ctx.ResourceIndicatorsIsEnabled {
+		if err := ValidateResources(ctx, client, req.Resources); err != nil {
+			return err
+		}
+	}
+
+	if err := validateTokenBindingRequestWithDPoP(ctx, req, client); err != nil {
+		return err
+	}
+
+	return validateTokenBindingIsRequired(ctx, client)
+}
+
+// validJWTBearerAssertion verifies the assertion with the keys of its issuer,
+// which must be trusted, and returns its claims.
+func validJWTBearerAssertion(ctx *oidc.Context, assertion string) (jwt.Claims, oidc.Error) {
+	parsedAssertion, err := jwt.ParseSigned(assertion, ctx.JWTBearerSignatureAlgorithms)
+	if err != nil {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid assertion")
+	}
+
+	var unsafeClaims jwt.Claims
+	if err := parsedAssertion.UnsafeClaimsWithoutVerification(&unsafeClaims); err != nil {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid assertion")
+	}
+
+	jwksURI, ok := ctx.JWTBearerTrustedIssuers[unsafeClaims.Issuer]
+	if !ok {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "the assertion issuer is not trusted")
+	}
+
+	keys, err := ctx.IssuerPublicKeys(jwksURI, parsedAssertion.Headers[0].KeyID)
+	if err != nil {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInternalError, "could not load the assertion issuer jwks")
+	}
+
+	var claims jwt.Claims
+	verified := false
+	for _, key := range keys {
+		if err := parsedAssertion.Claims(key.Key, &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid assertion signature")
+	}
+
+	// Validate that the "iat" and "exp" claims are present and their difference is not too great.
+	if claims.Expiry == nil || claims.IssuedAt == nil ||
+		int64(claims.Expiry.Time().Sub(claims.IssuedAt.Time()).Seconds()) > ctx.JWTBearerAssertionLifetimeSecs {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid time claim in the assertion")
+	}
+
+	if claims.Subject == "" {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "the assertion subject is required")
+	}
+
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      unsafeClaims.Issuer,
+		AnyAudience: ctx.AssertionAudiences(),
+	}, ctx.ClockSkewTolerance()); err != nil {
+		return jwt.Claims{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, "invalid assertion")
+	}
+
+	return claims, nil
+}
+
+func newJWTBearerGrantOptions(
+	ctx *oidc.Context,
+	req tokenRequest,
+	client *goidc.Client,
+	assertion jwt.Claims,
+) (
+	GrantOptions,
+	oidc.Error,
+) {
+	subject := assertion.Subject
+	if ctx.JWTBearerMappingFunc != nil {
+		var err error
+		subject, err = ctx.JWTBearerMappingFunc(ctx, assertion.Issuer, assertion.Subject)
+		if err != nil {
+			return GrantOptions{}, oidc.NewError(oidc.ErrorCodeInvalidGrant, err.Error())
+		}
+	}
+
+	scopes := req.Scopes
+	if ctx.IgnoreUnknownScopes {
+		scopes = client.AllowedScopes(ctx.Scopes, req.Scopes)
+	}
+
+	if scopes == "" {
+		scopes = client.Scopes
+	}
+	scopes, oauthErr := grantedScopes(ctx, goidc.GrantJWTBearer, client, scopes)
+	if oauthErr != nil {
+		return GrantOptions{}, oauthErr
+	}
+
+	tokenOptions, err := ctx.TokenOptions(client, goidc.GrantJWTBearer, scopes, req.Resources)
+	if err != nil {
+		return GrantOptions{}, oidc.NewError(oidc.ErrorCodeAccessDenied, err.Error())
+	}
+
+	grantOptions := GrantOptions{
+		GrantType:     goidc.GrantJWTBearer,
+		GrantedScopes: scopes,
+		Subject:       subject,
+		ClientID:      client.ID,
+		TokenOptions:  tokenOptions,
+	}
+	if ctx.ResourceIndicatorsIsEnabled {
+		grantOptions.GrantedResources = req.Resources
+	}
+	return grantOptions, nil
+}
@@ -0,0 +1,248 @@
+package token
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGrantCreation_JWTBearerGrantHappyPath(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpJWTBearerGrant(t)
+	ctx.JWTBearerMappingFunc = func(_ goidc.Context, issuer, subject string) (string, error) {
+		return issuer + "|" + subject, nil
+	}
+	now := time.Now().Unix()
+	assertion := newJWTBearerAssertion(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://trusted.issuer.com",
+		goidc.ClaimSubject:  "user_id",
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: now,
+		goidc.ClaimExpiry:   now + 60,
+	})
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, newJWTBearerTokenRequest(assertion))
+
+	// Then.
+	require.Nil(t, err)
+
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, oidc.TestClientID, claims["client_id"])
+	assert.Equal(t, "https://trusted.issuer.com|user_id", claims["sub"], "the subject should be mapped")
+	assert.Empty(t, tokenResp.RefreshToken)
+
+	grantSessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, grantSessions, 1)
+	assert.Equal(t, goidc.GrantJWTBearer, grantSessions[0].GrantType)
+}
+
+func TestHandleGrantCreation_JWTBearerGrantUntrustedIssuer(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpJWTBearerGrant(t)
+	now := time.Now().Unix()
+	assertion := newJWTBearerAssertion(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://untrusted.issuer.com",
+		goidc.ClaimSubject:  "user_id",
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: now,
+		goidc.ClaimExpiry:   now + 60,
+	})
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newJWTBearerTokenRequest(assertion))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidGrant, oauthErr.Code())
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+func TestHandleGrantCreation_JWTBearerGrantExpiredAssertion(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpJWTBearerGrant(t)
+	now := time.Now().Unix()
+	assertion := newJWTBearerAssertion(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://trusted.issuer.com",
+		goidc.ClaimSubject:  "user_id",
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: now - 120,
+		goidc.ClaimExpiry:   now - 60,
+	})
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newJWTBearerTokenRequest(assertion))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidGrant, oauthErr.Code())
+}
+
+func TestHandleGrantCreation_JWTBearerGrantMappingFails(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpJWTBearerGrant(t)
+	ctx.JWTBearerMappingFunc = func(_ goidc.Context, _, _ string) (string, error) {
+		return "", errors.New("unknown user")
+	}
+	now := time.Now().Unix()
+	assertion := newJWTBearerAssertion(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://trusted.issuer.com",
+		goidc.ClaimSubject:  "user_id",
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: now,
+		goidc.ClaimExpiry:   now + 60,
+	})
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newJWTBearerTokenRequest(assertion))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidGrant, oauthErr.Code())
+}
+
+func TestHandleGrantCreation_JWTBearerGrantWithoutScope(t *testing.T) {
+	// Given.
+	ctx, issuerJWK := setUpJWTBearerGrant(t)
+	var tokenOptionsScopes string
+	ctx.TokenOptions = func(
+		_ *goidc.Client,
+		_ goidc.GrantType,
+		scopes string,
+		_ goidc.Resources,
+	) (
+		goidc.TokenOptions,
+		error,
+	) {
+		tokenOptionsScopes = scopes
+		return goidc.NewJWTTokenOptions(oidc.TestKeyID, 60), nil
+	}
+	client, _ := ctx.Client(oidc.TestClientID)
+	now := time.Now().Unix()
+	assertion := newJWTBearerAssertion(t, issuerJWK, map[string]any{
+		goidc.ClaimIssuer:   "https://trusted.issuer.com",
+		goidc.ClaimSubject:  "user_id",
+		goidc.ClaimAudience: ctx.Host,
+		goidc.ClaimIssuedAt: now,
+		goidc.ClaimExpiry:   now + 60,
+	})
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newJWTBearerTokenRequest(assertion))
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, client.Scopes, tokenOptionsScopes,
+		"the token options should be computed for the scopes granted by default")
+}
+
+func TestHandleGrantCreation_JWTBearerGrantIssuerKeyRotation(t *testing.T) {
+	// Given.
+	oldIssuerJWK := oidc.PrivateRS256JWK(t, "old_issuer_key_id")
+	newIssuerJWK := oidc.PrivateRS256JWK(t, "new_issuer_key_id")
+	var mu sync.Mutex
+	publishedJWK := oldIssuerJWK.Public()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(oidc.RawJWKS(publishedJWK))
+	}))
+	defer server.Close()
+
+	ctx, _ := setUpJWTBearerGrant(t)
+	ctx.JWTBearerTrustedIssuers = map[string]string{
+		"https://trusted.issuer.com": server.URL,
+	}
+	newAssertion := func(jwk jose.JSONWebKey) string {
+		now := time.Now().Unix()
+		return newJWTBearerAssertion(t, jwk, map[string]any{
+			goidc.ClaimIssuer:   "https://trusted.issuer.com",
+			goidc.ClaimSubject:  "user_id",
+			goidc.ClaimAudience: ctx.Host,
+			goidc.ClaimIssuedAt: now,
+			goidc.ClaimExpiry:   now + 60,
+		})
+	}
+
+	_, err := HandleTokenCreation(ctx, newJWTBearerTokenRequest(newAssertion(oldIssuerJWK)))
+	require.Nil(t, err)
+
+	// The issuer rotates its keys while the old ones are still cached.
+	mu.Lock()
+	publishedJWK = newIssuerJWK.Public()
+	mu.Unlock()
+
+	// When.
+	_, err = HandleTokenCreation(ctx, newJWTBearerTokenRequest(newAssertion(newIssuerJWK)))
+
+	// Then.
+	assert.Nil(t, err, "the issuer keys should be fetched again for an unknown key id")
+}
+
+func setUpJWTBearerGrant(t *testing.T) (*oidc.Context, jose.JSONWebKey) {
+	t.Helper()
+
+	issuerJWK := oidc.PrivateRS256JWK(t, "issuer_key_id")
+	publicIssuerJWK := issuerJWK.Public()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(oidc.RawJWKS(publicIssuerJWK))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := oidc.NewTestContext(t)
+	ctx.JWTBearerGrantIsEnabled = true
+	ctx.JWTBearerSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256}
+	ctx.JWTBearerAssertionLifetimeSecs = 600
+	ctx.JWTBearerTrustedIssuers = map[string]string{
+		"https://trusted.issuer.com": server.URL,
+	}
+
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.GrantTypes = append(client.GrantTypes, goidc.GrantJWTBearer)
+	require.Nil(t, ctx.SaveClient(client))
+
+	return ctx, issuerJWK
+}
+
+func newJWTBearerAssertion(t *testing.T, jwk jose.JSONWebKey, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(jwk.Algorithm), Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", jwk.KeyID),
+	)
+	require.Nil(t, err)
+
+	assertion, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.Nil(t, err)
+
+	return assertion
+}
+
+func newJWTBearerTokenRequest(assertion string) tokenRequest {
+	return tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType: goidc.GrantJWTBearer,
+		Assertion: assertion,
+	}
+}
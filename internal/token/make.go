@@ -5,7 +5,9 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
+	"fmt"
 	"hash"
+	"slices"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
@@ -50,11 +52,28 @@ func Make(
 	Token,
 	oidc.Error,
 ) {
+	var token Token
+	var err oidc.Error
 	if grantOptions.TokenFormat == goidc.TokenFormatJWT {
-		return makeJWTToken(ctx, client, grantOptions)
+		token, err = makeJWTToken(ctx, client, grantOptions)
 	} else {
-		return makeOpaqueToken(ctx, client, grantOptions)
+		token, err = makeOpaqueToken(ctx, client, grantOptions)
 	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	ctx.NotifyEvent(goidc.Event{
+		Type:     goidc.EventTypeTokenIssued,
+		ClientID: client.ID,
+		Subject:  grantOptions.Subject,
+		Details: map[string]any{
+			"grant_type":   grantOptions.GrantType,
+			"scope":        grantOptions.GrantedScopes,
+			"token_format": grantOptions.TokenFormat,
+		},
+	})
+	return token, nil
 }
 
 func EncryptJWT(
@@ -88,6 +107,11 @@ func EncryptJWT(
 	return encryptedUserInfoString, nil
 }
 
+// shouldIssueIDToken returns whether an ID token should be issued for the scopes granted.
+func shouldIssueIDToken(ctx *oidc.Context, scopes string) bool {
+	return !ctx.IDTokenIsSuppressed && strutil.ContainsOpenID(scopes)
+}
+
 func makeIDToken(
 	ctx *oidc.Context,
 	client *goidc.Client,
@@ -96,8 +120,11 @@ func makeIDToken(
 	string,
 	oidc.Error,
 ) {
-	privateJWK := ctx.IDTokenSignatureKey(client)
-	signatureAlgorithm := jose.SignatureAlgorithm(privateJWK.Algorithm)
+	signingKey, oauthErr := ctx.IDTokenSigningKey(client)
+	if oauthErr != nil {
+		return "", oauthErr
+	}
+	signatureAlgorithm := jose.SignatureAlgorithm(signingKey.Algorithm)
 	timestampNow := time.Now().Unix()
 
 	// Set the token claims.
@@ -109,6 +136,13 @@ func makeIDToken(
 		goidc.ClaimExpiry:   timestampNow + ctx.IDTokenExpiresInSecs,
 	}
 
+	// When the ID token has multiple audiences, the client it was issued to
+	// is informed as the authorized party as described in OpenID Connect Core.
+	if audiences := mergeAudiences([]string{client.ID}, idTokenOpts.Audiences); len(audiences) > 1 {
+		claims[goidc.ClaimAudience] = audiences
+		claims[goidc.ClaimAuthorizedParty] = client.ID
+	}
+
 	if idTokenOpts.AccessToken != "" {
 		claims[goidc.ClaimAccessTokenHash] = halfHashIDTokenClaim(idTokenOpts.AccessToken, signatureAlgorithm)
 	}
@@ -121,14 +155,19 @@ func makeIDToken(
 		claims[goidc.ClaimStateHash] = halfHashIDTokenClaim(idTokenOpts.State, signatureAlgorithm)
 	}
 
+	if ctx.SIDClaimIsEnabled && idTokenOpts.SessionID != "" {
+		claims[goidc.ClaimSessionID] = idTokenOpts.SessionID
+	}
+
 	for k, v := range idTokenOpts.AdditionalIDTokenClaims {
 		claims[k] = v
 	}
 
-	signer, err := jose.NewSigner(
-		jose.SigningKey{Algorithm: signatureAlgorithm, Key: privateJWK.Key},
-		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", privateJWK.KeyID),
-	)
+	signerOpts := (&jose.SignerOptions{}).WithType("jwt")
+	if signingKey.KeyID != "" {
+		signerOpts = signerOpts.WithHeader("kid", signingKey.KeyID)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: signatureAlgorithm, Key: signingKey.Key}, signerOpts)
 	if err != nil {
 		return "", oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
 	}
@@ -149,12 +188,28 @@ func encryptIDToken(
 	string,
 	oidc.Error,
 ) {
+	if !ctx.UserInfoEncryptionIsEnabled || !slices.Contains(ctx.UserInfoKeyEncryptionAlgorithms, client.IDTokenKeyEncryptionAlgorithm) {
+		return "", oidc.NewError(oidc.ErrorCodeInvalidRequest,
+			fmt.Sprintf("the ID token key encryption algorithm %s is not supported", client.IDTokenKeyEncryptionAlgorithm))
+	}
+
+	contentEncryptionAlgorithm := client.IDTokenContentEncryptionAlgorithm
+	if contentEncryptionAlgorithm == "" {
+		contentEncryptionAlgorithm = jose.A128CBC_HS256
+	}
+
+	if !slices.Contains(ctx.UserInfoContentEncryptionAlgorithms, contentEncryptionAlgorithm) {
+		return "", oidc.NewError(oidc.ErrorCodeInvalidRequest,
+			fmt.Sprintf("the ID token content encryption algorithm %s is not supported", contentEncryptionAlgorithm))
+	}
+
 	jwk, err := client.IDTokenEncryptionJWK()
 	if err != nil {
-		return "", oidc.NewError(oidc.ErrorCodeInvalidRequest, err.Error())
+		return "", oidc.NewError(oidc.ErrorCodeInvalidRequest,
+			fmt.Sprintf("the client has no encryption key for the algorithm %s", client.IDTokenKeyEncryptionAlgorithm))
 	}
 
-	encryptedIDToken, err := EncryptJWT(ctx, userInfoJWT, jwk, client.IDTokenContentEncryptionAlgorithm)
+	encryptedIDToken, err := EncryptJWT(ctx, userInfoJWT, jwk, contentEncryptionAlgorithm)
 	if err != nil {
 		return "", oidc.NewError(oidc.ErrorCodeInvalidRequest, err.Error())
 	}
@@ -188,8 +243,24 @@ func makeJWTToken(
 		claims[goidc.ClaimAuthorizationDetails] = grantOptions.GrantedAuthorizationDetails
 	}
 
+	// RFC 8707. "...the authorization server should audience-restrict issued access tokens to the resource(s) indicated..."
+	resources := grantOptions.GrantedResources
+	if grantOptions.ActiveResources != nil {
+		resources = grantOptions.ActiveResources
+	}
+	audiences := mergeAudiences(resources, grantOptions.Audiences)
+	if len(audiences) == 1 {
+		claims[goidc.ClaimAudience] = audiences[0]
+	} else if len(audiences) > 1 {
+		claims[goidc.ClaimAudience] = audiences
+	}
+
+	if grantOptions.AuthorizedPartyIsEnabled {
+		claims[goidc.ClaimAuthorizedParty] = client.ID
+	}
+
 	tokenType := goidc.TokenTypeBearer
-	confirmation := make(map[string]string)
+	confirmation := make(map[string]any)
 	// DPoP token binding.
 	dpopJWT, ok := ctx.DPoPJWT()
 	jkt := ""
@@ -197,6 +268,10 @@ func makeJWTToken(
 		tokenType = goidc.TokenTypeDPoP
 		jkt = jwkThumbprint(dpopJWT, ctx.DPoPSignatureAlgorithms)
 		confirmation["jkt"] = jkt
+		// The full key is only added when requested so tokens are not bloated by default.
+		if grantOptions.ConfirmationJWKIsEnabled {
+			confirmation["jwk"] = dpopJWK(dpopJWT, ctx.DPoPSignatureAlgorithms)
+		}
 	}
 	// TLS token binding.
 	clientCert, ok := ctx.ClientCertificate()
@@ -209,15 +284,17 @@ func makeJWTToken(
 		claims["cnf"] = confirmation
 	}
 
+	for k, v := range ctx.ScopeCapturedClaims(grantOptions.GrantedScopes) {
+		claims[k] = v
+	}
+
 	for k, v := range grantOptions.AdditionalTokenClaims {
 		claims[k] = v
 	}
 
 	signer, err := jose.NewSigner(
 		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateJWK.Algorithm), Key: privateJWK.Key},
-		// RFC9068. "...This specification registers the "application/at+jwt" media type,
-		// which can be used to indicate that the content is a JWT access token."
-		(&jose.SignerOptions{}).WithType("at+jwt").WithHeader("kid", privateJWK.KeyID),
+		(&jose.SignerOptions{}).WithType(accessTokenJWTType).WithHeader("kid", privateJWK.KeyID),
 	)
 	if err != nil {
 		return Token{}, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
@@ -238,6 +315,19 @@ func makeJWTToken(
 	}, nil
 }
 
+// mergeAudiences joins the audiences informed without repeating them.
+func mergeAudiences(audiences ...[]string) []string {
+	var merged []string
+	for _, auds := range audiences {
+		for _, aud := range auds {
+			if !slices.Contains(merged, aud) {
+				merged = append(merged, aud)
+			}
+		}
+	}
+	return merged
+}
+
 func makeOpaqueToken(
 	ctx *oidc.Context,
 	_ *goidc.Client,
@@ -268,7 +358,7 @@ func makeOpaqueToken(
 	}
 
 	return Token{
-		ID:                    accessToken,
+		ID:                    opaqueTokenID(ctx, accessToken),
 		Format:                goidc.TokenFormatOpaque,
 		Value:                 accessToken,
 		Type:                  tokenType,
@@ -277,6 +367,15 @@ func makeOpaqueToken(
 	}, nil
 }
 
+// opaqueTokenID returns the value under which an opaque token is stored.
+// If hashing is enabled, only the hash of the token is kept, otherwise the token is its own ID.
+func opaqueTokenID(ctx *oidc.Context, token string) string {
+	if ctx.OpaqueTokenHashingIsEnabled {
+		return hashBase64URLSHA256(token)
+	}
+	return token
+}
+
 func halfHashIDTokenClaim(claimValue string, idTokenAlgorithm jose.SignatureAlgorithm) string {
 	var hash hash.Hash
 	switch idTokenAlgorithm {
@@ -302,6 +401,12 @@ func jwkThumbprint(dpopJWT string, dpopSigningAlgorithms []jose.SignatureAlgorit
 	return base64.RawURLEncoding.EncodeToString(jkt)
 }
 
+// dpopJWK returns the public key informed in the header of a valid DPoP JWT.
+func dpopJWK(dpopJWT string, dpopSigningAlgorithms []jose.SignatureAlgorithm) jose.JSONWebKey {
+	parsedDPoPJWT, _ := jwt.ParseSigned(dpopJWT, dpopSigningAlgorithms)
+	return parsedDPoPJWT.Headers[0].JSONWebKey.Public()
+}
+
 func hashBase64URLSHA256(s string) string {
 	hash := sha256.New()
 	hash.Write([]byte(s))
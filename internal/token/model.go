@@ -1,6 +1,7 @@
 package token
 
 import (
+	"encoding/json"
 	"net/http"
 	"time"
 
@@ -16,8 +17,13 @@ type GrantOptions struct {
 	ClientID                    string
 	GrantedScopes               string
 	GrantedAuthorizationDetails []goidc.AuthorizationDetail
-	AdditionalIDTokenClaims     map[string]any
-	AdditionalUserInfoClaims    map[string]any
+	GrantedResources            goidc.Resources
+	// ActiveResources, when informed, restricts the audience of the access
+	// token to a subset of the granted resources.
+	ActiveResources          goidc.Resources
+	SessionID                string
+	AdditionalIDTokenClaims  map[string]any
+	AdditionalUserInfoClaims map[string]any
 	goidc.TokenOptions
 }
 
@@ -28,6 +34,8 @@ func NewGrantOptions(grantSession goidc.GrantSession) GrantOptions {
 		ClientID:                    grantSession.ClientID,
 		GrantedScopes:               grantSession.GrantedScopes,
 		GrantedAuthorizationDetails: grantSession.GrantedAuthorizationDetails,
+		GrantedResources:            grantSession.GrantedResources,
+		SessionID:                   grantSession.SessionID,
 		AdditionalIDTokenClaims:     grantSession.AdditionalIDTokenClaims,
 		AdditionalUserInfoClaims:    grantSession.AdditionalUserInfoClaims,
 		TokenOptions:                grantSession.TokenOptions,
@@ -44,8 +52,12 @@ type Token struct {
 }
 
 type IDTokenOptions struct {
-	Subject                 string
+	Subject string
+	// SessionID is sent as the "sid" claim when enabled.
+	SessionID               string
 	AdditionalIDTokenClaims map[string]any
+	// Audiences are the audiences of the ID token besides the client.
+	Audiences []string
 	// These values here below are intended to be hashed and placed in the ID token.
 	// Then, the ID token can be used as a detached signature for the implicit grant.
 	AccessToken       string
@@ -56,7 +68,9 @@ type IDTokenOptions struct {
 func newIDTokenOptions(grantOpts GrantOptions) IDTokenOptions {
 	return IDTokenOptions{
 		Subject:                 grantOpts.Subject,
+		SessionID:               grantOpts.SessionID,
 		AdditionalIDTokenClaims: grantOpts.AdditionalIDTokenClaims,
+		Audiences:               grantOpts.IDTokenAudiences,
 	}
 }
 
@@ -64,26 +78,34 @@ type DPoPJWTValidationOptions struct {
 	// AccessToken should be filled when the DPoP "ath" claim is expected and should be validated.
 	AccessToken   string
 	JWKThumbprint string
+	// ClientID should be filled when the DPoP "nonce" claim is expected to
+	// have been issued to the client.
+	ClientID string
 }
 
 type dpopJWTClaims struct {
 	HTTPMethod      string `json:"htm"`
 	HTTPURI         string `json:"htu"`
 	AccessTokenHash string `json:"ath"`
+	Nonce           string `json:"nonce"`
 }
 
 type tokenRequest struct {
-	GrantType         goidc.GrantType
-	Scopes            string
-	AuthorizationCode string
-	RedirectURI       string
-	RefreshToken      string
-	CodeVerifier      string
+	GrantType            goidc.GrantType
+	Scopes               string
+	AuthorizationCode    string
+	RedirectURI          string
+	RefreshToken         string
+	CodeVerifier         string
+	DeviceCode           string
+	Assertion            string
+	AuthorizationDetails []goidc.AuthorizationDetail
+	Resources            goidc.Resources
 	authn.ClientAuthnRequest
 }
 
-func newTokenRequest(req *http.Request) tokenRequest {
-	return tokenRequest{
+func newTokenRequest(req *http.Request) (tokenRequest, oidc.Error) {
+	tokenReq := tokenRequest{
 		ClientAuthnRequest: authn.NewClientAuthnRequest(req),
 		GrantType:          goidc.GrantType(req.PostFormValue("grant_type")),
 		Scopes:             req.PostFormValue("scope"),
@@ -91,7 +113,26 @@ func newTokenRequest(req *http.Request) tokenRequest {
 		RedirectURI:        req.PostFormValue("redirect_uri"),
 		RefreshToken:       req.PostFormValue("refresh_token"),
 		CodeVerifier:       req.PostFormValue("code_verifier"),
+		DeviceCode:         req.PostFormValue("device_code"),
+		Assertion:          req.PostFormValue("assertion"),
+	}
+
+	if resources := req.PostForm["resource"]; len(resources) != 0 {
+		tokenReq.Resources = resources
 	}
+
+	// Authorization details narrow the ones granted, so a malformed value must
+	// not be treated as if it were absent.
+	authorizationDetails := req.PostFormValue("authorization_details")
+	if authorizationDetails != "" {
+		var authorizationDetailsObject []goidc.AuthorizationDetail
+		if err := json.Unmarshal([]byte(authorizationDetails), &authorizationDetailsObject); err != nil {
+			return tokenRequest{}, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid authorization_details")
+		}
+		tokenReq.AuthorizationDetails = authorizationDetailsObject
+	}
+
+	return tokenReq, nil
 }
 
 type tokenResponse struct {
@@ -102,6 +143,32 @@ type tokenResponse struct {
 	TokenType            goidc.TokenType             `json:"token_type"`
 	Scopes               string                      `json:"scope,omitempty"`
 	AuthorizationDetails []goidc.AuthorizationDetail `json:"authorization_details,omitempty"`
+	// CustomFields are added at the top level of the response.
+	CustomFields map[string]any `json:"-"`
+}
+
+func (resp tokenResponse) MarshalJSON() ([]byte, error) {
+	// The type alias prevents the custom marshaling from being called recursively.
+	type standardTokenResponse tokenResponse
+	standardResp, err := json.Marshal(standardTokenResponse(resp))
+	if err != nil || len(resp.CustomFields) == 0 {
+		return standardResp, err
+	}
+
+	var standardFields map[string]any
+	if err := json.Unmarshal(standardResp, &standardFields); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]any, len(resp.CustomFields)+len(standardFields))
+	for field, value := range resp.CustomFields {
+		fields[field] = value
+	}
+	// The standard fields have priority over the custom ones.
+	for field, value := range standardFields {
+		fields[field] = value
+	}
+	return json.Marshal(fields)
 }
 
 type resultChannel struct {
@@ -115,6 +182,20 @@ type tokenIntrospectionRequest struct {
 	TokenTypeHint goidc.TokenTypeHint
 }
 
+type tokenRevocationRequest struct {
+	authn.ClientAuthnRequest
+	Token         string
+	TokenTypeHint goidc.TokenTypeHint
+}
+
+func newTokenRevocationRequest(req *http.Request) tokenRevocationRequest {
+	return tokenRevocationRequest{
+		ClientAuthnRequest: authn.NewClientAuthnRequest(req),
+		Token:              req.PostFormValue("token"),
+		TokenTypeHint:      goidc.TokenTypeHint(req.PostFormValue("token_type_hint")),
+	}
+}
+
 func newTokenIntrospectionRequest(req *http.Request) tokenIntrospectionRequest {
 	return tokenIntrospectionRequest{
 		ClientAuthnRequest: authn.NewClientAuthnRequest(req),
@@ -139,11 +220,14 @@ func NewGrantSession(grantOptions GrantOptions, token Token) *goidc.GrantSession
 		LastTokenIssuedAtTimestamp:  timestampNow,
 		ExpiresAtTimestamp:          timestampNow + grantOptions.TokenLifetimeSecs,
 		ActiveScopes:                grantOptions.GrantedScopes,
+		ActiveAuthorizationDetails:  grantOptions.GrantedAuthorizationDetails,
 		GrantType:                   grantOptions.GrantType,
 		Subject:                     grantOptions.Subject,
 		ClientID:                    grantOptions.ClientID,
 		GrantedScopes:               grantOptions.GrantedScopes,
 		GrantedAuthorizationDetails: grantOptions.GrantedAuthorizationDetails,
+		GrantedResources:            grantOptions.GrantedResources,
+		SessionID:                   grantOptions.SessionID,
 		AdditionalIDTokenClaims:     grantOptions.AdditionalIDTokenClaims,
 		AdditionalUserInfoClaims:    grantOptions.AdditionalUserInfoClaims,
 		TokenOptions:                grantOptions.TokenOptions,
@@ -7,8 +7,10 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewTokenRequest(t *testing.T) {
@@ -22,14 +24,17 @@ func TestNewTokenRequest(t *testing.T) {
 	params.Set("redirect_uri", "https://example.com")
 	params.Set("refresh_token", "random_refresh_token")
 	params.Set("code_verifier", "random_code_verifier")
+	params.Add("resource", "https://resource1.com")
+	params.Add("resource", "https://resource2.com")
 
 	req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBufferString(params.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// When.
-	tokenReq := newTokenRequest(req)
+	tokenReq, err := newTokenRequest(req)
 
 	// Then.
+	require.Nil(t, err)
 	assert.Equal(t, "random_client_id", tokenReq.ClientID)
 	assert.Equal(t, "random_client_secret", tokenReq.ClientSecret)
 	assert.Equal(t, goidc.GrantAuthorizationCode, tokenReq.GrantType)
@@ -39,4 +44,23 @@ func TestNewTokenRequest(t *testing.T) {
 	assert.Equal(t, "random_refresh_token", tokenReq.RefreshToken)
 	assert.Equal(t, "random_client_secret", tokenReq.ClientSecret)
 	assert.Equal(t, "random_code_verifier", tokenReq.CodeVerifier)
+	assert.Equal(t, goidc.Resources{"https://resource1.com", "https://resource2.com"}, tokenReq.Resources)
+}
+
+func TestNewTokenRequest_MalformedAuthorizationDetails(t *testing.T) {
+	// Given.
+	params := url.Values{}
+	params.Set("grant_type", "refresh_token")
+	params.Set("refresh_token", "random_refresh_token")
+	params.Set("authorization_details", `{"type": "random_type"}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBufferString(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// When.
+	_, err := newTokenRequest(req)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
 }
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"crypto/x509"
 	"net/url"
 	"slices"
 	"strings"
@@ -38,7 +39,7 @@ func ValidateDPoPJWT(
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid dpop")
 	}
 
-	if parsedDPoPJWT.Headers[0].ExtraHeaders["typ"] != "dpop+jwt" {
+	if parsedDPoPJWT.Headers[0].ExtraHeaders["typ"] != dpopJWTType {
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid typ header. it should be dpop+jwt")
 	}
 
@@ -81,14 +82,78 @@ func ValidateDPoPJWT(
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid jwk thumbprint")
 	}
 
-	err = claims.ValidateWithLeeway(jwt.Expected{}, time.Duration(0))
+	err = claims.ValidateWithLeeway(jwt.Expected{}, ctx.ClockSkewTolerance())
 	if err != nil {
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid dpop")
 	}
 
+	if ctx.DPoPNonceIsRequired && expectedDPoPClaims.ClientID != "" {
+		return validateDPoPNonce(ctx, dpopClaims.Nonce, expectedDPoPClaims.ClientID)
+	}
+
 	return nil
 }
 
+// validateDPoPNonce consumes the nonce informed in the DPoP proof and provides
+// the client with a new one in the DPoP-Nonce header as described in RFC 9449,
+// section 8.
+// If the nonce is missing or invalid, the "use_dpop_nonce" error is returned, so
+// the client can retry the request with the new nonce.
+func validateDPoPNonce(ctx *oidc.Context, nonce, clientID string) oidc.Error {
+	nonceIsValid := false
+	if nonce != "" {
+		ok, err := ctx.ConsumeDPoPNonce(clientID, nonce)
+		if err != nil {
+			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		nonceIsValid = ok
+	}
+
+	newNonce, err := ctx.IssueDPoPNonce(clientID)
+	if err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+	ctx.Response().Header().Set(goidc.HeaderDPoPNonce, newNonce)
+
+	if !nonceIsValid {
+		return oidc.NewError(oidc.ErrorCodeUseDPoPNonce, "the dpop proof must contain a valid nonce")
+	}
+
+	return nil
+}
+
+// DPoPJWKThumbprint returns the thumbprint of the key in the header of the DPoP JWT.
+// The DPoP JWT must be validated beforehand.
+func DPoPJWKThumbprint(ctx *oidc.Context, dpopJWT string) string {
+	return jwkThumbprint(dpopJWT, ctx.DPoPSignatureAlgorithms)
+}
+
+// ClientCertificateThumbprint returns the thumbprint used to bind tokens to a client certificate.
+func ClientCertificateThumbprint(clientCert *x509.Certificate) string {
+	return goidc.CertificateThumbprint(clientCert)
+}
+
+// ValidateTokenBinding validates the token is presented with the proofs of
+// possession it's bound to, that is, the DPoP JWT sent in the request for the
+// "jkt" confirmation and the client certificate informed for the "x5t#S256" one.
+func ValidateTokenBinding(
+	ctx *oidc.Context,
+	token string,
+	tokenType goidc.TokenType,
+	confirmation Confirmation,
+	clientCert *x509.Certificate,
+) oidc.Error {
+	if err := validateDPoP(ctx, token, tokenType, confirmation); err != nil {
+		return err
+	}
+
+	if confirmation.ClientCertificateThumbprint == "" {
+		return nil
+	}
+
+	return validateTLSBinding(clientCert, confirmation)
+}
+
 func urlWithoutParams(u string) (string, error) {
 	parsedURL, err := url.Parse(u)
 	if err != nil {
@@ -141,8 +206,12 @@ func validateTLSPoP(
 		return oidc.NewError(oidc.ErrorCodeInvalidToken, "the client certificate is required")
 	}
 
-	if confirmation.ClientCertificateThumbprint != hashBase64URLSHA256(string(clientCert.Raw)) {
-		return oidc.NewError(oidc.ErrorCodeInvalidToken, "invalid client certificate")
+	return validateTLSBinding(clientCert, confirmation)
+}
+
+func validateTLSBinding(clientCert *x509.Certificate, confirmation Confirmation) oidc.Error {
+	if err := goidc.ValidateTLSCertificateBinding(clientCert, confirmation.ClientCertificateThumbprint); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInvalidToken, err.Error())
 	}
 
 	return nil
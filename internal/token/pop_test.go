@@ -1,13 +1,22 @@
 package token
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
+	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidateDPoPJWT(t *testing.T) {
@@ -66,3 +75,181 @@ func TestValidateDPoPJWT(t *testing.T) {
 		)
 	}
 }
+
+func TestValidateDPoPJWT_AccessToken(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.DPoPIsEnabled = true
+	ctx.DPoPSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.PS256}
+	ctx.DPoPLifetimeSecs = 60
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	accessToken, err := Make(ctx, client, GrantOptions{
+		Subject:      "random_subject",
+		TokenOptions: goidc.NewJWTTokenOptions("", 60),
+	})
+	require.Nil(t, err)
+
+	// When.
+	err = ValidateDPoPJWT(ctx, accessToken.Value, DPoPJWTValidationOptions{})
+
+	// Then.
+	require.NotNil(t, err, "an access token must not be accepted as a dpop jwt")
+	assert.Contains(t, err.Error(), "typ")
+}
+
+func TestValidateDPoPJWT_NonceIsRequired(t *testing.T) {
+	// Given.
+	ctx := setUpDPoPNonce(t)
+	nonce, err := ctx.IssueDPoPNonce(oidc.TestClientID)
+	require.Nil(t, err)
+
+	// When.
+	oauthErr := ValidateDPoPJWT(ctx, newDPoPJWT(t, ctx, nonce), DPoPJWTValidationOptions{
+		ClientID: oidc.TestClientID,
+	})
+
+	// Then.
+	require.Nil(t, oauthErr)
+	newNonce := ctx.Response().Header().Get(goidc.HeaderDPoPNonce)
+	assert.NotEmpty(t, newNonce, "the next nonce should be provided")
+	assert.NotEqual(t, nonce, newNonce)
+}
+
+func TestValidateDPoPJWT_NonceIsMissing(t *testing.T) {
+	// Given.
+	ctx := setUpDPoPNonce(t)
+
+	// When.
+	oauthErr := ValidateDPoPJWT(ctx, newDPoPJWT(t, ctx, ""), DPoPJWTValidationOptions{
+		ClientID: oidc.TestClientID,
+	})
+
+	// Then.
+	require.NotNil(t, oauthErr)
+	assert.Equal(t, oidc.ErrorCodeUseDPoPNonce, oauthErr.Code())
+	assert.Equal(t, http.StatusBadRequest, oauthErr.Code().StatusCode())
+	assert.NotEmpty(t, ctx.Response().Header().Get(goidc.HeaderDPoPNonce))
+}
+
+func TestValidateDPoPJWT_NonceIsReplayed(t *testing.T) {
+	// Given.
+	ctx := setUpDPoPNonce(t)
+	nonce, err := ctx.IssueDPoPNonce(oidc.TestClientID)
+	require.Nil(t, err)
+	dpopJWT := newDPoPJWT(t, ctx, nonce)
+	require.Nil(t, ValidateDPoPJWT(ctx, dpopJWT, DPoPJWTValidationOptions{
+		ClientID: oidc.TestClientID,
+	}))
+
+	// When.
+	oauthErr := ValidateDPoPJWT(ctx, dpopJWT, DPoPJWTValidationOptions{
+		ClientID: oidc.TestClientID,
+	})
+
+	// Then.
+	require.NotNil(t, oauthErr)
+	assert.Equal(t, oidc.ErrorCodeUseDPoPNonce, oauthErr.Code())
+}
+
+func TestValidateDPoPJWT_NonceIssuedToAnotherClient(t *testing.T) {
+	// Given.
+	ctx := setUpDPoPNonce(t)
+	nonce, err := ctx.IssueDPoPNonce("another_client_id")
+	require.Nil(t, err)
+
+	// When.
+	oauthErr := ValidateDPoPJWT(ctx, newDPoPJWT(t, ctx, nonce), DPoPJWTValidationOptions{
+		ClientID: oidc.TestClientID,
+	})
+
+	// Then.
+	require.NotNil(t, oauthErr)
+	assert.Equal(t, oidc.ErrorCodeUseDPoPNonce, oauthErr.Code())
+}
+
+func TestValidateTokenBinding_TLS(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	cert := &x509.Certificate{Raw: []byte("random_certificate")}
+	confirmation := Confirmation{
+		ClientCertificateThumbprint: ClientCertificateThumbprint(cert),
+	}
+
+	// When.
+	err := ValidateTokenBinding(ctx, "random_token", goidc.TokenTypeBearer, confirmation, cert)
+
+	// Then.
+	assert.Nil(t, err)
+
+	// When.
+	err = ValidateTokenBinding(ctx, "random_token", goidc.TokenTypeBearer, confirmation,
+		&x509.Certificate{Raw: []byte("another_certificate")})
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidToken, err.Code())
+
+	// When.
+	err = ValidateTokenBinding(ctx, "random_token", goidc.TokenTypeBearer, confirmation, nil)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidToken, err.Code())
+}
+
+func TestValidateTokenBinding_DPoPTokenWithoutBinding(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+
+	// When.
+	err := ValidateTokenBinding(ctx, "random_token", goidc.TokenTypeDPoP, Confirmation{}, nil)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, err.Code())
+}
+
+func setUpDPoPNonce(t *testing.T) *oidc.Context {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.Host = "https://example.com"
+	ctx.DPoPIsEnabled = true
+	ctx.DPoPLifetimeSecs = 60
+	ctx.DPoPSignatureAlgorithms = []jose.SignatureAlgorithm{jose.ES256}
+	ctx.DPoPNonceIsRequired = true
+	ctx.DPoPNonceStore = inmemory.NewDPoPNonceStore(60)
+	ctx.Request().Method = http.MethodPost
+	ctx.Request().RequestURI = goidc.EndpointToken
+
+	return ctx
+}
+
+func newDPoPJWT(t *testing.T, ctx *oidc.Context, nonce string) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{EmbedJWK: true}).WithType(dpopJWTType),
+	)
+	require.Nil(t, err)
+
+	claims := map[string]any{
+		"jti": "random_jti",
+		"htm": http.MethodPost,
+		"htu": ctx.Host + goidc.EndpointToken,
+		"iat": time.Now().Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	dpopJWT, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.Nil(t, err)
+
+	return dpopJWT
+}
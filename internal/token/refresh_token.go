@@ -1,6 +1,8 @@
 package token
 
 import (
+	"maps"
+	"reflect"
 	"slices"
 	"time"
 
@@ -18,7 +20,7 @@ func handleRefreshTokenGrantTokenCreation(
 	oidc.Error,
 ) {
 	if err := preValidateRefreshTokenGrantRequest(req); err != nil {
-		return tokenResponse{}, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid parameter for refresh token grant")
+		return tokenResponse{}, err
 	}
 
 	client, grantSession, err := getAuthenticatedClientAndGrantSession(ctx, req)
@@ -30,7 +32,8 @@ func handleRefreshTokenGrantTokenCreation(
 		return tokenResponse{}, err
 	}
 
-	token, err := Make(ctx, client, NewGrantOptions(*grantSession))
+	grantOptions := newRefreshTokenGrantOptions(ctx, req, grantSession)
+	token, err := Make(ctx, client, grantOptions)
 	if err != nil {
 		return tokenResponse{}, err
 	}
@@ -46,20 +49,66 @@ func handleRefreshTokenGrantTokenCreation(
 		RefreshToken: grantSession.RefreshToken,
 	}
 
-	if strutil.ContainsOpenID(grantSession.ActiveScopes) {
+	// A new ID token is issued as long as openid is among the scopes of the new access token.
+	// Since the ID token claims are kept in the grant session, claims such as auth_time keep
+	// their original values.
+	if shouldIssueIDToken(ctx, grantOptions.GrantedScopes) {
 		tokenResp.IDToken, err = MakeIDToken(
 			ctx,
 			client,
-			newIDTokenOptions(NewGrantOptions(*grantSession)),
+			newRefreshTokenIDTokenOptions(grantOptions),
 		)
 		if err != nil {
 			return tokenResponse{}, err
 		}
 	}
 
+	// The scopes are only informed if the client narrowed them, otherwise they
+	// are the same as the ones originally granted.
+	if grantOptions.GrantedScopes != grantSession.GrantedScopes {
+		tokenResp.Scopes = grantOptions.GrantedScopes
+	}
+
+	// The same applies to the authorization details.
+	if req.AuthorizationDetails != nil {
+		tokenResp.AuthorizationDetails = grantOptions.GrantedAuthorizationDetails
+	}
+
+	customizeTokenResponse(ctx, client, grantSession, &tokenResp)
 	return tokenResp, nil
 }
 
+// newRefreshTokenIDTokenOptions creates the options for ID tokens issued during
+// the refresh token grant.
+// As recommended by OpenID, the nonce is not included in refreshed ID tokens,
+// since it only makes sense for the authentication request that originated it.
+func newRefreshTokenIDTokenOptions(grantOptions GrantOptions) IDTokenOptions {
+	idTokenOptions := newIDTokenOptions(grantOptions)
+	if _, ok := idTokenOptions.AdditionalIDTokenClaims[goidc.ClaimNonce]; ok {
+		idTokenOptions.AdditionalIDTokenClaims = maps.Clone(idTokenOptions.AdditionalIDTokenClaims)
+		delete(idTokenOptions.AdditionalIDTokenClaims, goidc.ClaimNonce)
+	}
+	return idTokenOptions
+}
+
+func newRefreshTokenGrantOptions(
+	ctx *oidc.Context,
+	req tokenRequest,
+	grantSession *goidc.GrantSession,
+) GrantOptions {
+	grantOptions := NewGrantOptions(*grantSession)
+	if req.Scopes != "" {
+		grantOptions.GrantedScopes = req.Scopes
+	}
+	if req.AuthorizationDetails != nil {
+		grantOptions.GrantedAuthorizationDetails = req.AuthorizationDetails
+	}
+	if ctx.ResourceIndicatorsIsEnabled {
+		grantOptions.ActiveResources = req.Resources
+	}
+	return grantOptions
+}
+
 func updateRefreshTokenGrantSession(
 	ctx *oidc.Context,
 	grantSession *goidc.GrantSession,
@@ -69,17 +118,31 @@ func updateRefreshTokenGrantSession(
 
 	grantSession.LastTokenIssuedAtTimestamp = time.Now().Unix()
 	grantSession.TokenID = token.ID
+	// The new access token might be bound to a different key or certificate.
+	grantSession.JWKThumbprint = token.JWKThumbprint
+	grantSession.ClientCertificateThumbprint = token.CertificateThumbprint
 
 	if ctx.ShouldRotateRefreshTokens {
 		token, err := refreshToken()
 		if err != nil {
 			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
 		}
+		grantSession.PreviousRefreshTokens = append(grantSession.PreviousRefreshTokens, grantSession.RefreshToken)
 		grantSession.RefreshToken = token
 	}
 
+	// The scopes are narrowed only for the tokens issued in this request.
+	// A later refresh without "scope" is entitled to all the granted scopes.
 	if req.Scopes != "" {
 		grantSession.ActiveScopes = req.Scopes
+	} else {
+		grantSession.ActiveScopes = grantSession.GrantedScopes
+	}
+
+	if req.AuthorizationDetails != nil {
+		grantSession.ActiveAuthorizationDetails = req.AuthorizationDetails
+	} else {
+		grantSession.ActiveAuthorizationDetails = grantSession.GrantedAuthorizationDetails
 	}
 
 	if err := ctx.SaveGrantSession(grantSession); err != nil {
@@ -107,12 +170,38 @@ func getAuthenticatedClientAndGrantSession(
 	}
 
 	grantSessionResult := <-grantSessionResultCh
-	grantSession, err := grantSessionResult.Result.(*goidc.GrantSession), grantSessionResult.Err
-	if err != nil {
+	if err := grantSessionResult.Err; err != nil {
+		if ctx.RefreshTokenReuseDetectionIsEnabled {
+			if err := detectRefreshTokenReuse(ctx, authenticatedClient, req.RefreshToken); err != nil {
+				return nil, nil, err
+			}
+		}
 		return nil, nil, err
 	}
 
-	return authenticatedClient, grantSession, nil
+	return authenticatedClient, grantSessionResult.Result.(*goidc.GrantSession), nil
+}
+
+// detectRefreshTokenReuse checks if the refresh token was already rotated, no
+// matter how many rotations ago.
+// If so, either the client or an attacker is replaying it, and since there's
+// no way to tell which one, the grant session the token belongs to is revoked
+// along with all the tokens issued from it.
+func detectRefreshTokenReuse(
+	ctx *oidc.Context,
+	client *goidc.Client,
+	refreshToken string,
+) oidc.Error {
+	grantSession, err := ctx.GrantSessionByPreviousRefreshToken(refreshToken)
+	if err != nil || grantSession.ClientID != client.ID {
+		return nil
+	}
+
+	if err := ctx.DeleteGrantSession(grantSession.ID); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	return oidc.NewError(oidc.ErrorCodeInvalidGrant, "the refresh token was already used")
 }
 
 func getGrantSessionByRefreshToken(
@@ -126,6 +215,7 @@ func getGrantSessionByRefreshToken(
 			Result: nil,
 			Err:    oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid refresh_token"),
 		}
+		return
 	}
 
 	ch <- resultChannel{
@@ -141,6 +231,10 @@ func preValidateRefreshTokenGrantRequest(
 		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid refresh token")
 	}
 
+	if req.AuthorizationCode != "" || req.RedirectURI != "" || req.CodeVerifier != "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid parameter for refresh token grant")
+	}
+
 	return nil
 }
 
@@ -170,6 +264,24 @@ func validateRefreshTokenGrantRequest(
 		return oidc.NewError(oidc.ErrorCodeInvalidScope, "invalid scope")
 	}
 
+	if err := validateRefreshTokenAuthorizationDetails(ctx, req, grantSession); err != nil {
+		return err
+	}
+
+	// The new access token is bound the same way as the ones issued by the
+	// other grants.
+	if err := validateTokenBindingIsRequired(ctx, client); err != nil {
+		return err
+	}
+
+	if err := validateTokenBindingRequestWithDPoP(ctx, req, client); err != nil {
+		return err
+	}
+
+	if err := validateActiveResources(ctx, req, grantSession.GrantedResources); err != nil {
+		return err
+	}
+
 	return validateRefreshTokenProofOfPossesionForPublicClients(ctx, client, grantSession)
 }
 
@@ -181,19 +293,91 @@ func validateRefreshTokenProofOfPossesionForPublicClients(
 
 	// Refresh tokens are bound to the client. If the client is authenticated,
 	// then there's no need to validate proof of possesion.
-	if client.AuthnMethod != goidc.ClientAuthnNone || grantSession.JWKThumbprint == "" {
+	if client.AuthnMethod != goidc.ClientAuthnNone {
+		return nil
+	}
+
+	if grantSession.JWKThumbprint != "" {
+		dpopJWT, ok := ctx.DPoPJWT()
+		if !ok {
+			// The session was created with DPoP for a public client, then the DPoP header must be passed.
+			return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "invalid DPoP header")
+		}
+
+		if err := ValidateDPoPJWT(ctx, dpopJWT, DPoPJWTValidationOptions{
+			JWKThumbprint: grantSession.JWKThumbprint,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if grantSession.ClientCertificateThumbprint != "" {
+		// The same applies to sessions bound to the client certificate.
+		clientCert, ok := ctx.ClientCertificate()
+		if !ok || ClientCertificateThumbprint(clientCert) != grantSession.ClientCertificateThumbprint {
+			return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "invalid client certificate")
+		}
+	}
+
+	return nil
+}
+
+// validateRefreshTokenAuthorizationDetails makes sure the client is only narrowing
+// the authorization details originally granted.
+func validateRefreshTokenAuthorizationDetails(
+	ctx *oidc.Context,
+	req tokenRequest,
+	grantSession *goidc.GrantSession,
+) oidc.Error {
+	if req.AuthorizationDetails == nil {
 		return nil
 	}
 
-	dpopJWT, ok := ctx.DPoPJWT()
-	if !ok {
-		// The session was created with DPoP for a public client, then the DPoP header must be passed.
-		return oidc.NewError(oidc.ErrorCodeUnauthorizedClient, "invalid DPoP header")
+	if !ctx.AuthorizationDetailsParameterIsEnabled {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "authorization_details is not supported")
+	}
+
+	for _, detail := range req.AuthorizationDetails {
+		if !slices.ContainsFunc(grantSession.GrantedAuthorizationDetails, func(grantedDetail goidc.AuthorizationDetail) bool {
+			return isAuthorizationDetailGranted(detail, grantedDetail)
+		}) {
+			return oidc.NewError(oidc.ErrorCodeInvalidAuthorizationDetails, "authorization details not granted")
+		}
 	}
 
-	return ValidateDPoPJWT(ctx, dpopJWT, DPoPJWTValidationOptions{
-		JWKThumbprint: grantSession.JWKThumbprint,
-	})
+	return nil
+}
+
+// isAuthorizationDetailGranted informs whether the requested authorization detail
+// is covered by the granted one. Every field requested must have been granted with
+// the same value, except for arrays whose elements must be a subset of the granted ones.
+func isAuthorizationDetailGranted(
+	requestedDetail goidc.AuthorizationDetail,
+	grantedDetail goidc.AuthorizationDetail,
+) bool {
+	for field, requestedValue := range requestedDetail {
+		grantedValue, ok := grantedDetail[field]
+		if !ok {
+			return false
+		}
+
+		requestedValues, isRequestedArray := requestedValue.([]any)
+		grantedValues, isGrantedArray := grantedValue.([]any)
+		if !isRequestedArray || !isGrantedArray {
+			if !reflect.DeepEqual(requestedValue, grantedValue) {
+				return false
+			}
+			continue
+		}
+
+		for _, v := range requestedValues {
+			if !slices.ContainsFunc(grantedValues, func(gv any) bool { return reflect.DeepEqual(v, gv) }) {
+				return false
+			}
+		}
+	}
+
+	return true
 }
 
 func refreshToken() (string, error) {
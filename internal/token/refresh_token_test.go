@@ -59,6 +59,331 @@ func TestHandleTokenCreation_RefreshTokenGrant(t *testing.T) {
 	assert.Len(t, grantSessions, 1, "there should be only one grant session")
 }
 
+func TestHandleTokenCreation_RefreshTokenIssuedToAnotherClient(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	grantSession.ClientID = "another_client_id"
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newRefreshTokenRequest(grantSession.RefreshToken))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidGrant, oauthErr.Code())
+}
+
+func TestHandleTokenCreation_RefreshTokenGrantSenderConstrainedTokenRequired(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	ctx.SenderConstrainedTokenIsRequired = true
+	ctx.DPoPIsEnabled = true
+
+	// When.
+	_, err := HandleTokenCreation(ctx, newRefreshTokenRequest(grantSession.RefreshToken))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+}
+
+func TestHandleTokenCreation_RefreshTokenGrantPublicClientBoundToCertificate(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	grantSession.ClientCertificateThumbprint = "random_thumbprint"
+
+	client, err := ctx.Client(oidc.TestClientID)
+	require.Nil(t, err)
+	client.AuthnMethod = goidc.ClientAuthnNone
+	require.Nil(t, ctx.SaveClient(client))
+
+	req := newRefreshTokenRequest(grantSession.RefreshToken)
+	req.ClientSecret = ""
+
+	// When.
+	_, err = HandleTokenCreation(ctx, req)
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr, "the client certificate should be required")
+	assert.Equal(t, oidc.ErrorCodeUnauthorizedClient, oauthErr.Code())
+}
+
+func TestHandleTokenCreation_RefreshTokenRotation(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	oldRefreshToken := grantSession.RefreshToken
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, newRefreshTokenRequest(oldRefreshToken))
+
+	// Then.
+	require.Nil(t, err)
+	assert.NotEqual(t, oldRefreshToken, tokenResp.RefreshToken, "the refresh token should be rotated")
+
+	grantSessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, grantSessions, 1)
+	assert.Equal(t, tokenResp.RefreshToken, grantSessions[0].RefreshToken)
+	assert.Equal(t, []string{oldRefreshToken}, grantSessions[0].PreviousRefreshTokens)
+
+	// When.
+	_, err = HandleTokenCreation(ctx, newRefreshTokenRequest(oldRefreshToken))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr, "the rotated refresh token should not be accepted")
+	assert.Equal(t, oidc.ErrorCodeInvalidGrant, oauthErr.Code())
+	assert.Empty(t, oidc.GrantSessions(t, ctx), "the reuse should revoke the whole grant")
+
+	// When.
+	_, err = HandleTokenCreation(ctx, newRefreshTokenRequest(tokenResp.RefreshToken))
+
+	// Then.
+	require.NotNil(t, err, "the refresh token issued during the rotation should be revoked as well")
+}
+
+func TestHandleTokenCreation_RefreshTokenReuseAfterManyRotations(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	firstRefreshToken := grantSession.RefreshToken
+
+	tokenResp, err := HandleTokenCreation(ctx, newRefreshTokenRequest(firstRefreshToken))
+	require.Nil(t, err)
+	tokenResp, err = HandleTokenCreation(ctx, newRefreshTokenRequest(tokenResp.RefreshToken))
+	require.Nil(t, err)
+
+	// When.
+	_, err = HandleTokenCreation(ctx, newRefreshTokenRequest(firstRefreshToken))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr, "a refresh token rotated many times ago should not be accepted")
+	assert.Equal(t, oidc.ErrorCodeInvalidGrant, oauthErr.Code())
+	assert.Empty(t, oidc.GrantSessions(t, ctx), "the reuse should revoke the whole grant")
+}
+
+func TestHandleTokenCreation_RefreshTokenRotationWithoutReuseDetection(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	ctx.RefreshTokenReuseDetectionIsEnabled = false
+	oldRefreshToken := grantSession.RefreshToken
+
+	_, err := HandleTokenCreation(ctx, newRefreshTokenRequest(oldRefreshToken))
+	require.Nil(t, err)
+
+	// When.
+	_, err = HandleTokenCreation(ctx, newRefreshTokenRequest(oldRefreshToken))
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr, "the rotated refresh token should not be accepted")
+	assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1, "the grant should not be revoked")
+}
+
+func TestHandleTokenCreation_RefreshTokenGrantWithOpenID(t *testing.T) {
+
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.IDTokenExpiresInSecs = 60
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	refreshToken := "random_refresh_token"
+	now := time.Now().Unix()
+	authTime := now - 3600
+	grantSession := &goidc.GrantSession{
+		RefreshToken:       refreshToken,
+		ExpiresAtTimestamp: now + 60,
+		CreatedAtTimestamp: now - 3600,
+		Subject:            "user_id",
+		ClientID:           oidc.TestClientID,
+		GrantedScopes:      client.Scopes,
+		ActiveScopes:       client.Scopes,
+		AdditionalIDTokenClaims: map[string]any{
+			goidc.ClaimAuthenticationTime: authTime,
+			goidc.ClaimNonce:              "random_nonce",
+		},
+		TokenOptions: goidc.TokenOptions{
+			TokenFormat:       goidc.TokenFormatJWT,
+			TokenLifetimeSecs: 60,
+		},
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     client.ID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType:    goidc.GrantRefreshToken,
+		RefreshToken: refreshToken,
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Empty(t, tokenResp.Scopes, "the scopes should not be informed if they didn't change")
+	require.NotEmpty(t, tokenResp.IDToken, "an ID token should be issued when openid was granted")
+
+	claims := oidc.UnsafeClaims(t, tokenResp.IDToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, float64(authTime), claims[goidc.ClaimAuthenticationTime], "auth_time should be preserved")
+	assert.NotContains(t, claims, goidc.ClaimNonce, "refreshed ID tokens should not contain the nonce")
+	assert.GreaterOrEqual(t, claims[goidc.ClaimIssuedAt], float64(now))
+	assert.Greater(t, claims[goidc.ClaimExpiry], float64(now))
+
+	grantSessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, grantSessions, 1)
+	assert.Equal(t, "random_nonce", grantSessions[0].AdditionalIDTokenClaims[goidc.ClaimNonce],
+		"the original ID token claims should be kept in the grant session")
+}
+
+func TestHandleTokenCreation_RefreshTokenGrantWithNarrowedScopes(t *testing.T) {
+
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	refreshToken := "random_refresh_token"
+	now := time.Now().Unix()
+	grantSession := &goidc.GrantSession{
+		RefreshToken:       refreshToken,
+		ExpiresAtTimestamp: now + 60,
+		CreatedAtTimestamp: now,
+		Subject:            "user_id",
+		ClientID:           oidc.TestClientID,
+		GrantedScopes:      client.Scopes,
+		ActiveScopes:       client.Scopes,
+		TokenOptions: goidc.TokenOptions{
+			TokenFormat:       goidc.TokenFormatJWT,
+			TokenLifetimeSecs: 60,
+		},
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     client.ID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType:    goidc.GrantRefreshToken,
+		RefreshToken: refreshToken,
+		Scopes:       oidc.TestScope1.ID,
+	}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, oidc.TestScope1.ID, tokenResp.Scopes)
+
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, oidc.TestScope1.ID, claims[goidc.ClaimScope])
+}
+
+func TestHandleTokenCreation_RefreshTokenGrantWithoutScopesAfterNarrowing(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+
+	refreshToken := "random_refresh_token"
+	now := time.Now().Unix()
+	grantSession := &goidc.GrantSession{
+		RefreshToken:       refreshToken,
+		ExpiresAtTimestamp: now + 60,
+		CreatedAtTimestamp: now,
+		Subject:            "user_id",
+		ClientID:           oidc.TestClientID,
+		GrantedScopes:      client.Scopes,
+		// The scopes were narrowed during a previous refresh.
+		ActiveScopes: oidc.TestScope1.ID,
+		TokenOptions: goidc.TokenOptions{
+			TokenFormat:       goidc.TokenFormatJWT,
+			TokenLifetimeSecs: 60,
+		},
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     client.ID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType:    goidc.GrantRefreshToken,
+		RefreshToken: refreshToken,
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+
+	grantSessions := oidc.GrantSessions(t, ctx)
+	require.Len(t, grantSessions, 1)
+	assert.Equal(t, client.Scopes, grantSessions[0].ActiveScopes, "the active scopes must match the token issued")
+}
+
+func TestHandleTokenCreation_RefreshTokenGrantWithoutOpenID(t *testing.T) {
+	testCases := []struct {
+		name          string
+		grantedScopes string
+		scopes        string
+		isSuppressed  bool
+	}{
+		{"openid not granted", oidc.TestScope1.ID, "", false},
+		{"openid narrowed", goidc.ScopeOpenID.ID + " " + oidc.TestScope1.ID, oidc.TestScope1.ID, false},
+		{"id token suppressed", goidc.ScopeOpenID.ID + " " + oidc.TestScope1.ID, "", true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.IDTokenExpiresInSecs = 60
+			ctx.IDTokenIsSuppressed = testCase.isSuppressed
+
+			refreshToken := "random_refresh_token"
+			now := time.Now().Unix()
+			grantSession := &goidc.GrantSession{
+				RefreshToken:       refreshToken,
+				ExpiresAtTimestamp: now + 60,
+				CreatedAtTimestamp: now,
+				Subject:            "user_id",
+				ClientID:           oidc.TestClientID,
+				GrantedScopes:      testCase.grantedScopes,
+				ActiveScopes:       testCase.grantedScopes,
+				TokenOptions: goidc.TokenOptions{
+					TokenFormat:       goidc.TokenFormatJWT,
+					TokenLifetimeSecs: 60,
+				},
+			}
+			require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+			req := tokenRequest{
+				ClientAuthnRequest: authn.ClientAuthnRequest{
+					ClientID:     oidc.TestClientID,
+					ClientSecret: oidc.TestClientSecret,
+				},
+				GrantType:    goidc.GrantRefreshToken,
+				RefreshToken: refreshToken,
+				Scopes:       testCase.scopes,
+			}
+
+			// When.
+			tokenResp, err := HandleTokenCreation(ctx, req)
+
+			// Then.
+			require.Nil(t, err)
+			assert.NotEmpty(t, tokenResp.AccessToken)
+			assert.Empty(t, tokenResp.IDToken, "no id token should be issued")
+		})
+	}
+}
+
 func TestHandleGrantCreation_ShouldDenyExpiredRefreshToken(t *testing.T) {
 
 	// When
@@ -104,3 +429,179 @@ func TestGenerateRefreshToken(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Len(t, token, RefreshTokenLength)
 }
+
+func TestHandleTokenCreation_RefreshTokenGrantWithAuthorizationDetails(t *testing.T) {
+	grantedDetails := []goidc.AuthorizationDetail{
+		{
+			"type":      "payment_initiation",
+			"actions":   []any{"initiate", "status"},
+			"locations": []any{"https://bank.com/payments"},
+		},
+		{
+			"type": "account_information",
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		requestedDetails []goidc.AuthorizationDetail
+		shouldBeValid    bool
+	}{
+		{
+			"subset of details",
+			[]goidc.AuthorizationDetail{{"type": "payment_initiation", "actions": []any{"status"}}},
+			true,
+		},
+		{
+			"not granted type",
+			[]goidc.AuthorizationDetail{{"type": "customer_information"}},
+			false,
+		},
+		{
+			"not granted action",
+			[]goidc.AuthorizationDetail{{"type": "payment_initiation", "actions": []any{"cancel"}}},
+			false,
+		},
+		{
+			"not granted field",
+			[]goidc.AuthorizationDetail{{"type": "account_information", "actions": []any{"read"}}},
+			false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.AuthorizationDetailsParameterIsEnabled = true
+			client, _ := ctx.Client(oidc.TestClientID)
+
+			refreshToken := "random_refresh_token"
+			now := time.Now().Unix()
+			grantSession := &goidc.GrantSession{
+				RefreshToken:                refreshToken,
+				ExpiresAtTimestamp:          now + 60,
+				CreatedAtTimestamp:          now,
+				Subject:                     "user_id",
+				ClientID:                    oidc.TestClientID,
+				GrantedScopes:               client.Scopes,
+				ActiveScopes:                client.Scopes,
+				GrantedAuthorizationDetails: grantedDetails,
+				ActiveAuthorizationDetails:  grantedDetails,
+				TokenOptions: goidc.TokenOptions{
+					TokenFormat:       goidc.TokenFormatJWT,
+					TokenLifetimeSecs: 60,
+				},
+			}
+			require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+			req := tokenRequest{
+				ClientAuthnRequest: authn.ClientAuthnRequest{
+					ClientID:     client.ID,
+					ClientSecret: oidc.TestClientSecret,
+				},
+				GrantType:            goidc.GrantRefreshToken,
+				RefreshToken:         refreshToken,
+				AuthorizationDetails: testCase.requestedDetails,
+			}
+
+			// When.
+			tokenResp, err := HandleTokenCreation(ctx, req)
+
+			// Then.
+			if !testCase.shouldBeValid {
+				var oauthErr oidc.Error
+				require.ErrorAs(t, err, &oauthErr)
+				assert.Equal(t, oidc.ErrorCodeInvalidAuthorizationDetails, oauthErr.Code())
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, testCase.requestedDetails, tokenResp.AuthorizationDetails,
+				"the narrowed authorization details should be informed")
+
+			claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+			assert.Len(t, claims[goidc.ClaimAuthorizationDetails], 1)
+
+			grantSessions := oidc.GrantSessions(t, ctx)
+			require.Len(t, grantSessions, 1)
+			assert.Equal(t, grantedDetails, grantSessions[0].GrantedAuthorizationDetails,
+				"the granted authorization details should be kept")
+			assert.Equal(t, testCase.requestedDetails, grantSessions[0].ActiveAuthorizationDetails)
+		})
+	}
+}
+
+func TestHandleGrantCreation_RefreshTokenGrantNarrowsResources(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	ctx.ResourceIndicatorsIsEnabled = true
+	grantSession.GrantedResources = goidc.Resources{"https://resource1.com", "https://resource2.com"}
+
+	req := newRefreshTokenRequest(grantSession.RefreshToken)
+	req.Resources = goidc.Resources{"https://resource2.com"}
+
+	// When.
+	tokenResp, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	claims := oidc.UnsafeClaims(t, tokenResp.AccessToken, []jose.SignatureAlgorithm{jose.PS256, jose.RS256})
+	assert.Equal(t, "https://resource2.com", claims["aud"])
+	assert.Len(t, grantSession.GrantedResources, 2, "the granted resources should not change")
+}
+
+func TestHandleGrantCreation_RefreshTokenGrantWithResourceNotGranted(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRefreshTokenRotation(t)
+	ctx.ResourceIndicatorsIsEnabled = true
+	grantSession.GrantedResources = goidc.Resources{"https://resource1.com"}
+
+	req := newRefreshTokenRequest(grantSession.RefreshToken)
+	req.Resources = goidc.Resources{"https://resource2.com"}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidTarget, oauthErr.Code())
+}
+
+func setUpRefreshTokenRotation(t *testing.T) (*oidc.Context, *goidc.GrantSession) {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.ShouldRotateRefreshTokens = true
+	ctx.RefreshTokenReuseDetectionIsEnabled = true
+
+	now := time.Now().Unix()
+	grantSession := &goidc.GrantSession{
+		ID:                 "random_grant_session_id",
+		RefreshToken:       "random_refresh_token",
+		ExpiresAtTimestamp: now + 60,
+		CreatedAtTimestamp: now,
+		Subject:            "user_id",
+		ClientID:           oidc.TestClientID,
+		GrantedScopes:      goidc.ScopeOpenID.ID,
+		TokenOptions: goidc.TokenOptions{
+			TokenFormat:       goidc.TokenFormatJWT,
+			TokenLifetimeSecs: 60,
+		},
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+	return ctx, grantSession
+}
+
+func newRefreshTokenRequest(refreshToken string) tokenRequest {
+	return tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		GrantType:    goidc.GrantRefreshToken,
+		RefreshToken: refreshToken,
+	}
+}
@@ -0,0 +1,112 @@
+package token
+
+import (
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+)
+
+// revoke invalidates the token informed by the client as described in RFC 7009.
+// Revoking a refresh token invalidates the whole grant, whereas revoking an access
+// token only invalidates the access token, so the client can still refresh it.
+func revoke(
+	ctx *oidc.Context,
+	req tokenRevocationRequest,
+) oidc.Error {
+	client, err := authn.Client(ctx, req.ClientAuthnRequest)
+	if err != nil {
+		return err
+	}
+
+	if req.Token == "" {
+		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "token is required")
+	}
+
+	grantSession, tokenType, ok := grantSessionByToken(ctx, req)
+	// RFC 7009. "...The authorization server responds with HTTP status code 200
+	// if the token has been revoked successfully or if the client submitted an
+	// invalid token."
+	if !ok {
+		return nil
+	}
+
+	// RFC 7009. "...The authorization server first validates the client credentials...
+	// and then verifies whether the token was issued to the client making the
+	// revocation request. If this validation fails, the request is refused..."
+	// The response is still successful so the client cannot probe tokens issued
+	// to other clients.
+	if grantSession.ClientID != client.ID {
+		return nil
+	}
+
+	event := goidc.Event{
+		Type:     goidc.EventTypeTokenRevoked,
+		ClientID: client.ID,
+		Subject:  grantSession.Subject,
+		Details: map[string]any{
+			"token_type_hint": tokenType,
+		},
+	}
+
+	if tokenType == goidc.TokenHintRefresh || grantSession.RefreshToken == "" {
+		if err := ctx.DeleteGrantSession(grantSession.ID); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+		}
+		ctx.NotifyEvent(event)
+		return nil
+	}
+
+	grantSession.TokenID = ""
+	if err := ctx.SaveGrantSession(grantSession); err != nil {
+		return oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	ctx.NotifyEvent(event)
+	return nil
+}
+
+// grantSessionByToken finds the grant session associated to the token.
+// The token type hint only defines which type of token is looked up first.
+func grantSessionByToken(
+	ctx *oidc.Context,
+	req tokenRevocationRequest,
+) (
+	*goidc.GrantSession,
+	goidc.TokenTypeHint,
+	bool,
+) {
+	tokenTypes := []goidc.TokenTypeHint{goidc.TokenHintAccess, goidc.TokenHintRefresh}
+	if req.TokenTypeHint == goidc.TokenHintRefresh {
+		tokenTypes = []goidc.TokenTypeHint{goidc.TokenHintRefresh, goidc.TokenHintAccess}
+	}
+
+	for _, tokenType := range tokenTypes {
+		if grantSession, ok := grantSessionByTokenType(ctx, req.Token, tokenType); ok {
+			return grantSession, tokenType, true
+		}
+	}
+
+	return nil, "", false
+}
+
+func grantSessionByTokenType(
+	ctx *oidc.Context,
+	token string,
+	tokenType goidc.TokenTypeHint,
+) (
+	*goidc.GrantSession,
+	bool,
+) {
+	if tokenType == goidc.TokenHintRefresh {
+		grantSession, err := ctx.GrantSessionByRefreshToken(token)
+		return grantSession, err == nil
+	}
+
+	tokenID, oauthErr := TokenID(ctx, token)
+	if oauthErr != nil {
+		return nil, false
+	}
+
+	grantSession, err := ctx.GrantSessionByTokenID(tokenID)
+	return grantSession, err == nil
+}
@@ -0,0 +1,220 @@
+package token
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
+	"github.com/luikyv/go-oidc/internal/strutil"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevoke_RefreshToken(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRevocation(t, oidc.TestClientID)
+
+	req := tokenRevocationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token:         grantSession.RefreshToken,
+		TokenTypeHint: goidc.TokenHintRefresh,
+	}
+
+	// When.
+	err := revoke(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Empty(t, oidc.GrantSessions(t, ctx), "the whole grant should be revoked")
+}
+
+func TestRevoke_AccessToken(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRevocation(t, oidc.TestClientID)
+
+	req := tokenRevocationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token: grantSession.TokenID,
+	}
+
+	// When.
+	err := revoke(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.False(t, TokenIntrospectionInfo(ctx, req.Token).IsActive, "the access token should be revoked")
+	assert.True(t, TokenIntrospectionInfo(ctx, grantSession.RefreshToken).IsActive, "the refresh token should still be active")
+}
+
+func TestRevoke_AccessTokenWithIntrospectionCache(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRevocation(t, oidc.TestClientID)
+	ctx.IntrospectionCache = inmemory.NewIntrospectionCache(10, time.Minute)
+	require.True(t, TokenIntrospectionInfo(ctx, grantSession.TokenID).IsActive)
+	_, ok := ctx.CachedIntrospection(grantSession.TokenID)
+	require.True(t, ok, "the introspection result should be cached")
+
+	req := tokenRevocationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token: grantSession.TokenID,
+	}
+
+	// When.
+	err := revoke(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.False(t, TokenIntrospectionInfo(ctx, req.Token).IsActive, "the revoked token should not be served from the cache")
+}
+
+func TestRevoke_RefreshTokenWithIntrospectionCache(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRevocation(t, oidc.TestClientID)
+	ctx.IntrospectionCache = inmemory.NewIntrospectionCache(10, time.Minute)
+	require.True(t, TokenIntrospectionInfo(ctx, grantSession.TokenID).IsActive)
+
+	req := tokenRevocationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token:         grantSession.RefreshToken,
+		TokenTypeHint: goidc.TokenHintRefresh,
+	}
+
+	// When.
+	err := revoke(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.False(t, TokenIntrospectionInfo(ctx, grantSession.TokenID).IsActive,
+		"the access token of the revoked grant should not be served from the cache")
+}
+
+func TestRevoke_TokenIssuedToAnotherClient(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRevocation(t, "another_client_id")
+
+	req := tokenRevocationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token: grantSession.RefreshToken,
+	}
+
+	// When.
+	err := revoke(ctx, req)
+
+	// Then.
+	require.Nil(t, err, "the client must not be able to tell whether the token exists")
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1, "the token should not be revoked")
+}
+
+func TestRevoke_UnknownToken(t *testing.T) {
+	// Given.
+	ctx, _ := setUpRevocation(t, oidc.TestClientID)
+
+	req := tokenRevocationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: oidc.TestClientSecret,
+		},
+		Token: "unknown_token",
+	}
+
+	// When.
+	err := revoke(ctx, req)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1)
+}
+
+func TestRevoke_UnauthenticatedClient(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRevocation(t, oidc.TestClientID)
+
+	req := tokenRevocationRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: "invalid_secret",
+		},
+		Token: grantSession.RefreshToken,
+	}
+
+	// When.
+	err := revoke(ctx, req)
+
+	// Then.
+	var oauthErr oidc.Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oidc.ErrorCodeInvalidClient, oauthErr.Code())
+	assert.Len(t, oidc.GrantSessions(t, ctx), 1)
+}
+
+func TestHandlerRevoke(t *testing.T) {
+	// Given.
+	ctx, grantSession := setUpRevocation(t, oidc.TestClientID)
+
+	form := url.Values{
+		"client_id":     {oidc.TestClientID},
+		"client_secret": {oidc.TestClientSecret},
+		"token":         {grantSession.RefreshToken},
+	}
+	req := httptest.NewRequest(http.MethodPost, goidc.EndpointTokenRevocation, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// When.
+	HandlerRevoke(&ctx.Configuration)(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, oidc.GrantSessions(t, ctx))
+}
+
+func setUpRevocation(t *testing.T, clientID string) (*oidc.Context, *goidc.GrantSession) {
+	t.Helper()
+
+	ctx := oidc.NewTestContext(t)
+	ctx.TokenRevocationIsEnabled = true
+
+	refreshToken, err := strutil.Random(RefreshTokenLength)
+	require.Nil(t, err)
+
+	now := time.Now().Unix()
+	grantSession := &goidc.GrantSession{
+		ID:                         "random_grant_session_id",
+		TokenID:                    "random_opaque_token",
+		RefreshToken:               refreshToken,
+		ClientID:                   clientID,
+		ActiveScopes:               goidc.ScopeOpenID.ID,
+		GrantedScopes:              goidc.ScopeOpenID.ID,
+		LastTokenIssuedAtTimestamp: now,
+		CreatedAtTimestamp:         now,
+		ExpiresAtTimestamp:         now + 600,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+
+	return ctx, grantSession
+}
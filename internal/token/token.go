@@ -1,10 +1,13 @@
 package token
 
 import (
+	"errors"
+	"math"
 	"regexp"
-	"time"
+	"strconv"
 
 	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/authn"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
@@ -23,18 +26,109 @@ func HandleTokenCreation(
 		tokenResp, err = handleAuthorizationCodeGrantTokenCreation(ctx, req)
 	case goidc.GrantRefreshToken:
 		tokenResp, err = handleRefreshTokenGrantTokenCreation(ctx, req)
+	case goidc.GrantDeviceCode:
+		tokenResp, err = handleDeviceCodeGrantTokenCreation(ctx, req)
+	case goidc.GrantJWTBearer:
+		tokenResp, err = handleJWTBearerGrantTokenCreation(ctx, req)
+	case "":
+		tokenResp, err = tokenResponse{}, oidc.NewError(oidc.ErrorCodeInvalidRequest, "grant_type is required")
 	default:
 		tokenResp, err = tokenResponse{}, oidc.NewError(oidc.ErrorCodeUnsupportedGrantType, "unsupported grant type")
 	}
 
+	if ctx.MetricsCollector != nil {
+		ctx.MetricsCollector.ObserveGrant(req.GrantType, grantErrorCode(err))
+	}
 	return tokenResp, err
 }
 
+// grantErrorCode returns the error code to be recorded for the outcome of a
+// token request. It's empty if the request succeeded.
+func grantErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var oauthErr oidc.Error
+	if errors.As(err, &oauthErr) {
+		return string(oauthErr.Code())
+	}
+	return string(oidc.ErrorCodeInternalError)
+}
+
+// limitTokenRequestRate rejects the request if the client exceeded the rate
+// limit of the token endpoint, informing when it can retry.
+// Requests whose client cannot be identified are left for the client
+// authentication to reject.
+func limitTokenRequestRate(ctx *oidc.Context, req tokenRequest) oidc.Error {
+	if ctx.TokenRateLimiter == nil {
+		return nil
+	}
+
+	clientID, ok := authn.ClientID(ctx, req.ClientAuthnRequest)
+	if !ok {
+		return nil
+	}
+
+	// Only registered clients are rate limited, otherwise arbitrary client IDs
+	// could make the limiter keep a bucket for each of them.
+	// Requests from unknown clients are rejected during authentication.
+	if _, err := ctx.Client(clientID); err != nil {
+		return nil
+	}
+
+	allowed, retryAfter := ctx.TokenRateLimiter.Allow(ctx, clientID)
+	if allowed {
+		return nil
+	}
+
+	retryAfterSecs := int64(math.Ceil(retryAfter.Seconds()))
+	ctx.Response().Header().Set(goidc.HeaderRetryAfter, strconv.FormatInt(max(retryAfterSecs, 1), 10))
+	return oidc.NewError(oidc.ErrorCodeTooManyRequests, "too many token requests")
+}
+
+// customizeTokenResponse adds the custom fields defined by the developer to the
+// token response. It must be called after the standard fields are populated.
+func customizeTokenResponse(
+	ctx *oidc.Context,
+	client *goidc.Client,
+	grantSession *goidc.GrantSession,
+	tokenResp *tokenResponse,
+) {
+	if ctx.TokenResponseCustomizeFunc == nil {
+		return
+	}
+	tokenResp.CustomFields = ctx.TokenResponseCustomizeFunc(ctx, client, grantSession)
+}
+
+// grantedScopes applies the scope policy defined by the developer, if any, to
+// the scopes about to be granted.
+func grantedScopes(
+	ctx *oidc.Context,
+	grantType goidc.GrantType,
+	client *goidc.Client,
+	scopes string,
+) (
+	string,
+	oidc.Error,
+) {
+	if ctx.ScopePolicyFunc == nil {
+		return scopes, nil
+	}
+
+	scopes, err := ctx.ScopePolicyFunc(ctx, grantType, client, scopes)
+	if err != nil {
+		return "", oidc.NewError(oidc.ErrorCodeInvalidScope, err.Error())
+	}
+	return scopes, nil
+}
+
 // TokenID returns the ID of a token.
-// If it's a JWT, the ID is the the "jti" claim. Otherwise, the token is considered opaque and its ID is the token itself.
+// If it's a JWT, the ID is the the "jti" claim. Otherwise, the token is considered opaque and its ID is the token itself
+// or its hash if opaque token hashing is enabled.
 func TokenID(ctx *oidc.Context, token string) (string, oidc.Error) {
 	if !IsJWS(token) {
-		return token, nil
+		return opaqueTokenID(ctx, token), nil
 	}
 
 	claims, err := ValidClaims(ctx, token)
@@ -68,6 +162,10 @@ func ValidClaims(
 		return nil, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid header kid")
 	}
 
+	if ctx.AccessTokenTypeIsRequired && parsedToken.Headers[0].ExtraHeaders["typ"] != accessTokenJWTType {
+		return nil, oidc.NewError(oidc.ErrorCodeAccessDenied, "invalid typ header. it should be at+jwt")
+	}
+
 	keyID := parsedToken.Headers[0].KeyID
 	publicKey, ok := ctx.PublicKey(keyID)
 	if !ok || publicKey.Use != string(goidc.KeyUsageSignature) {
@@ -82,7 +180,7 @@ func ValidClaims(
 
 	if err := claims.ValidateWithLeeway(jwt.Expected{
 		Issuer: ctx.Host,
-	}, time.Duration(0)); err != nil {
+	}, ctx.ClockSkewTolerance()); err != nil {
 		return nil, oidc.NewError(oidc.ErrorCodeAccessDenied, "invalid token")
 	}
 
@@ -1,13 +1,22 @@
 package token
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/authn"
+	"github.com/luikyv/go-oidc/internal/metrics"
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/storage/inmemory"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,6 +39,220 @@ func TestHandleGrantCreationShouldNotFindClient(t *testing.T) {
 	assert.NotNil(t, err, "the client should not be found")
 }
 
+func TestHandleGrantCreation_InvalidGrantType(t *testing.T) {
+	testCases := []struct {
+		grantType goidc.GrantType
+		errorCode oidc.ErrorCode
+	}{
+		{"", oidc.ErrorCodeInvalidRequest},
+		{"invalid_grant_type", oidc.ErrorCodeUnsupportedGrantType},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.grantType), func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+
+			// When.
+			_, err := HandleTokenCreation(ctx, tokenRequest{
+				ClientAuthnRequest: authn.ClientAuthnRequest{
+					ClientID:     oidc.TestClientID,
+					ClientSecret: oidc.TestClientSecret,
+				},
+				GrantType: testCase.grantType,
+			})
+
+			// Then.
+			require.NotNil(t, err)
+
+			var oauthErr oidc.Error
+			require.ErrorAs(t, err, &oauthErr)
+			assert.Equal(t, testCase.errorCode, oauthErr.Code())
+		})
+	}
+}
+
+func TestHandleGrantCreation_ConflictingParameters(t *testing.T) {
+	testCases := []struct {
+		name string
+		req  tokenRequest
+	}{
+		{
+			"authorization code with refresh token",
+			tokenRequest{
+				GrantType:         goidc.GrantAuthorizationCode,
+				AuthorizationCode: "random_authz_code",
+				RedirectURI:       oidc.TestClientRedirectURI,
+				RefreshToken:      "random_refresh_token",
+			},
+		},
+		{
+			"refresh token with authorization code",
+			tokenRequest{
+				GrantType:         goidc.GrantRefreshToken,
+				RefreshToken:      "random_refresh_token",
+				AuthorizationCode: "random_authz_code",
+			},
+		},
+		{
+			"refresh token with code verifier",
+			tokenRequest{
+				GrantType:    goidc.GrantRefreshToken,
+				RefreshToken: "random_refresh_token",
+				CodeVerifier: "random_code_verifier",
+			},
+		},
+		{
+			"client credentials with authorization code",
+			tokenRequest{
+				GrantType:         goidc.GrantClientCredentials,
+				AuthorizationCode: "random_authz_code",
+			},
+		},
+		{
+			"client credentials with refresh token",
+			tokenRequest{
+				GrantType:    goidc.GrantClientCredentials,
+				RefreshToken: "random_refresh_token",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			req := testCase.req
+			req.ClientAuthnRequest = authn.ClientAuthnRequest{
+				ClientID:     oidc.TestClientID,
+				ClientSecret: oidc.TestClientSecret,
+			}
+
+			// When.
+			_, err := HandleTokenCreation(ctx, req)
+
+			// Then.
+			require.NotNil(t, err)
+
+			var oauthErr oidc.Error
+			require.ErrorAs(t, err, &oauthErr)
+			assert.Equal(t, oidc.ErrorCodeInvalidRequest, oauthErr.Code())
+			assert.Empty(t, oidc.GrantSessions(t, ctx))
+		})
+	}
+}
+
+func TestHandler_MissingGrantType(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	handler := Handler(&ctx.Configuration)
+
+	form := url.Values{}
+	form.Set("client_id", oidc.TestClientID)
+	form.Set("client_secret", oidc.TestClientSecret)
+	req := httptest.NewRequest(http.MethodPost, goidc.EndpointToken, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp := httptest.NewRecorder()
+
+	// When.
+	handler(resp, req)
+
+	// Then.
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var body map[string]any
+	require.Nil(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, string(oidc.ErrorCodeInvalidRequest), body["error"])
+	assert.Equal(t, "grant_type is required", body["error_description"])
+}
+
+func TestHandler_RateLimitExceeded(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.TokenRateLimiter = inmemory.NewRateLimiter(1, 2)
+	handler := Handler(&ctx.Configuration)
+
+	requestToken := func() *httptest.ResponseRecorder {
+		form := url.Values{}
+		form.Set("client_id", oidc.TestClientID)
+		form.Set("client_secret", oidc.TestClientSecret)
+		form.Set("grant_type", string(goidc.GrantClientCredentials))
+		req := httptest.NewRequest(http.MethodPost, goidc.EndpointToken, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		return resp
+	}
+
+	// When.
+	for i := 0; i < 2; i++ {
+		resp := requestToken()
+		require.Equal(t, http.StatusOK, resp.Code, "requests within the limit should succeed")
+	}
+	resp := requestToken()
+
+	// Then.
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get(goidc.HeaderRetryAfter))
+	var body map[string]any
+	require.Nil(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, string(oidc.ErrorCodeTooManyRequests), body["error"])
+
+	// When.
+	time.Sleep(time.Second)
+	resp = requestToken()
+
+	// Then.
+	assert.Equal(t, http.StatusOK, resp.Code, "the request should succeed once the bucket is refilled")
+}
+
+func TestLimitTokenRequestRate_UnknownClientIsNotLimited(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.TokenRateLimiter = rateLimiterFunc(func(_ context.Context, key string) (bool, time.Duration) {
+		t.Errorf("the rate limiter should not be called for unknown clients, got %s", key)
+		return true, 0
+	})
+
+	// When.
+	err := limitTokenRequestRate(ctx, tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID: "unknown_client_id",
+		},
+	})
+
+	// Then.
+	assert.Nil(t, err)
+}
+
+type rateLimiterFunc func(ctx context.Context, key string) (bool, time.Duration)
+
+func (f rateLimiterFunc) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	return f(ctx, key)
+}
+
+func TestHandleGrantCreation_FailureIsRecordedInMetrics(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	collector := metrics.NewPrometheusCollector()
+	ctx.MetricsCollector = collector
+	req := tokenRequest{
+		ClientAuthnRequest: authn.ClientAuthnRequest{
+			ClientID:     oidc.TestClientID,
+			ClientSecret: "invalid_secret",
+		},
+		GrantType: goidc.GrantClientCredentials,
+	}
+
+	// When.
+	_, err := HandleTokenCreation(ctx, req)
+
+	// Then.
+	require.NotNil(t, err)
+	assert.Equal(t, int64(1), collector.GrantCount(goidc.GrantClientCredentials, string(oidc.ErrorCodeInvalidClient)))
+	assert.Zero(t, collector.GrantCount(goidc.GrantClientCredentials, ""))
+}
+
 func TestHandleGrantCreationShouldRejectUnauthenticatedClient(t *testing.T) {
 	// Given.
 	client := oidc.NewTestClient(t)
@@ -120,3 +343,91 @@ func TestIsJWE(t *testing.T) {
 		})
 	}
 }
+
+func TestValidClaims_AfterSignatureKeyRotation(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	client, _ := ctx.Client(oidc.TestClientID)
+	grantOptions := GrantOptions{
+		Subject:      "random_subject",
+		TokenOptions: goidc.NewJWTTokenOptions("", 60),
+	}
+
+	oldToken, err := Make(ctx, client, grantOptions)
+	require.Nil(t, err)
+
+	newKey := oidc.PrivatePS256JWK(t, "new_key")
+
+	// When.
+	oldKeyID, rotationErr := ctx.RotateSignatureKey(goidc.SignatureKeyPurposeToken, newKey)
+
+	// Then.
+	require.Nil(t, rotationErr)
+	assert.Equal(t, oidc.TestKeyID, oldKeyID)
+
+	_, err = ValidClaims(ctx, oldToken.Value)
+	assert.Nil(t, err, "tokens issued with the previous key should be valid during the overlap")
+
+	newToken, err := Make(ctx, client, grantOptions)
+	require.Nil(t, err)
+	claims := oidc.SafeClaims(t, newToken.Value, newKey)
+	assert.Equal(t, "random_subject", claims[goidc.ClaimSubject])
+
+	// When.
+	// The previous key is only retired once it's no longer used by default
+	// for any purpose.
+	_, rotationErr = ctx.RotateSignatureKey(goidc.SignatureKeyPurposeUserInfo, newKey)
+	require.Nil(t, rotationErr)
+	ctx.RetireKey(oldKeyID)
+
+	// Then.
+	_, err = ValidClaims(ctx, oldToken.Value)
+	assert.NotNil(t, err, "tokens issued with a retired key should not be valid")
+
+	_, err = ValidClaims(ctx, newToken.Value)
+	assert.Nil(t, err)
+}
+
+func TestValidClaims_TokenType(t *testing.T) {
+	testCases := []struct {
+		name          string
+		typ           string
+		isRequired    bool
+		shouldBeValid bool
+	}{
+		{"access token", "at+jwt", true, true},
+		{"dpop jwt", "dpop+jwt", true, false},
+		{"id token", "JWT", true, false},
+		{"dpop jwt when typ is not required", "dpop+jwt", false, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			ctx := oidc.NewTestContext(t)
+			ctx.AccessTokenTypeIsRequired = testCase.isRequired
+
+			signer, err := jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.RS256, Key: oidc.TestServerPrivateJWK.Key},
+				(&jose.SignerOptions{}).WithType(jose.ContentType(testCase.typ)).WithHeader("kid", oidc.TestKeyID),
+			)
+			require.Nil(t, err)
+
+			now := time.Now().Unix()
+			token, err := jwt.Signed(signer).Claims(map[string]any{
+				goidc.ClaimTokenID:  "random_token_id",
+				goidc.ClaimIssuer:   ctx.Host,
+				goidc.ClaimSubject:  "random_subject",
+				goidc.ClaimIssuedAt: now,
+				goidc.ClaimExpiry:   now + 60,
+			}).Serialize()
+			require.Nil(t, err)
+
+			// When.
+			_, err = ValidClaims(ctx, token)
+
+			// Then.
+			assert.Equal(t, testCase.shouldBeValid, err == nil)
+		})
+	}
+}
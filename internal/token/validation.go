@@ -1,14 +1,74 @@
 package token
 
 import (
+	"slices"
+
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/internal/strutil"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
+// ValidateResources validates the resources requested by the client as
+// described in RFC 8707. Each resource must be an absolute URI without a
+// fragment and must be one of the resources known by the server.
+func ValidateResources(
+	ctx *oidc.Context,
+	client *goidc.Client,
+	resources goidc.Resources,
+) oidc.Error {
+	for _, resource := range resources {
+		if !strutil.IsAbsoluteURIWithoutFragment(resource) {
+			return oidc.NewError(oidc.ErrorCodeInvalidTarget, "the resource "+resource+" must be an absolute uri without fragment")
+		}
+
+		if !slices.Contains(ctx.Resources, resource) {
+			return oidc.NewError(oidc.ErrorCodeInvalidTarget, "the resource "+resource+" is not allowed")
+		}
+	}
+
+	if ctx.ResourceValidationFunc != nil && len(resources) != 0 {
+		if err := ctx.ResourceValidationFunc(ctx, client, resources); err != nil {
+			return oidc.NewError(oidc.ErrorCodeInvalidTarget, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validateActiveResources makes sure the resources requested at the token
+// endpoint only narrow the ones granted, so the access token can be
+// audience-restricted to them.
+func validateActiveResources(
+	ctx *oidc.Context,
+	req tokenRequest,
+	grantedResources goidc.Resources,
+) oidc.Error {
+	if !ctx.ResourceIndicatorsIsEnabled || req.Resources == nil {
+		return nil
+	}
+
+	if !grantedResources.ContainsAll(req.Resources) {
+		return oidc.NewError(oidc.ErrorCodeInvalidTarget, "the resources requested were not granted")
+	}
+
+	return nil
+}
+
+// tokenResources returns the resources the access token will be issued for,
+// which are the ones requested at the token endpoint, if any, or the granted ones.
+func tokenResources(req tokenRequest, grantedResources goidc.Resources) goidc.Resources {
+	if req.Resources != nil {
+		return req.Resources
+	}
+	return grantedResources
+}
+
 func validateTokenBindingIsRequired(
 	ctx *oidc.Context,
+	client *goidc.Client,
 ) oidc.Error {
-	if !ctx.SenderConstrainedTokenIsRequired {
+	// FAPI 2.0 requires sender-constrained access tokens.
+	if !ctx.SenderConstrainedTokenIsRequired && ctx.ClientProfile(client) != goidc.ProfileFAPI2 {
 		return nil
 	}
 
@@ -48,5 +108,7 @@ func validateTokenBindingRequestWithDPoP(
 		return nil
 	}
 
-	return ValidateDPoPJWT(ctx, dpopJWT, DPoPJWTValidationOptions{})
+	return ValidateDPoPJWT(ctx, dpopJWT, DPoPJWTValidationOptions{
+		ClientID: client.ID,
+	})
 }
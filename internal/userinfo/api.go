@@ -1,19 +1,22 @@
 package userinfo
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
 func Handler(config *oidc.Configuration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := oidc.NewContext(*config, r, w)
+		ctx := oidc.NewRequestContext(config, r, w)
 
 		var err error
 		userInfoResponse, err := handleUserInfoRequest(ctx)
 		if err != nil {
-			ctx.WriteError(err)
+			writeError(ctx, err)
 			return
 		}
 
@@ -28,3 +31,42 @@ func Handler(config *oidc.Configuration) http.HandlerFunc {
 		}
 	}
 }
+
+// writeError informs the client in the WWW-Authenticate header why its access
+// token was rejected as required by RFC 6750 and RFC 9449.
+func writeError(ctx *oidc.Context, err error) {
+	scheme := goidc.TokenTypeBearer
+	_, tokenType, ok := ctx.AuthorizationToken()
+	if ok && tokenType == goidc.TokenTypeDPoP {
+		scheme = goidc.TokenTypeDPoP
+	}
+
+	params := map[string]string{}
+	if scheme == goidc.TokenTypeDPoP {
+		var algs []string
+		for _, alg := range ctx.DPoPSignatureAlgorithms {
+			algs = append(algs, string(alg))
+		}
+		params["algs"] = strings.Join(algs, " ")
+	}
+
+	var oauthErr oidc.Error
+	// When the request has no token, the challenge must not contain an error.
+	if ok && errors.As(err, &oauthErr) {
+		switch oauthErr.Code() {
+		case oidc.ErrorCodeInvalidToken, oidc.ErrorCodeUseDPoPNonce:
+			params["error"] = string(oauthErr.Code())
+			params["error_description"] = oauthErr.Error()
+		case oidc.ErrorCodeInsufficientScope:
+			params["error"] = string(oauthErr.Code())
+			params["error_description"] = oauthErr.Error()
+			params["scope"] = goidc.ScopeOpenID.ID
+		default:
+			ctx.WriteError(err)
+			return
+		}
+	}
+
+	ctx.Response().Header().Set(goidc.HeaderWWWAuthenticate, oidc.BuildWWWAuthenticate(string(scheme), params))
+	ctx.WriteError(err)
+}
@@ -1,6 +1,8 @@
 package userinfo
 
 import (
+	"slices"
+
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/luikyv/go-oidc/internal/oidc"
@@ -23,7 +25,7 @@ func handleUserInfoRequest(ctx *oidc.Context) (userInfoResponse, oidc.Error) {
 
 	grantSession, err := ctx.GrantSessionByTokenID(tokenID)
 	if err != nil {
-		return userInfoResponse{}, oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid token")
+		return userInfoResponse{}, oidc.NewError(oidc.ErrorCodeInvalidToken, "invalid token")
 	}
 
 	if err := validateUserInfoRequest(ctx, grantSession, accessToken, tokenType); err != nil {
@@ -55,6 +57,16 @@ func getUserInfoResponse(
 	userInfoClaims := map[string]any{
 		goidc.ClaimSubject: grantSession.Subject,
 	}
+	if ctx.ClaimResolverFunc != nil {
+		scopeClaims, err := resolveScopeClaims(ctx, grantSession)
+		if err != nil {
+			return userInfoResponse{}, err
+		}
+		for k, v := range scopeClaims {
+			userInfoClaims[k] = v
+		}
+	}
+	// The claims set during authentication take precedence over the resolved ones.
 	for k, v := range grantSession.AdditionalUserInfoClaims {
 		userInfoClaims[k] = v
 	}
@@ -89,6 +101,42 @@ func getUserInfoResponse(
 	return resp, nil
 }
 
+// resolveScopeClaims fetches the values of the claims associated to the scopes
+// granted. Only the claims requested through the scopes are returned.
+func resolveScopeClaims(
+	ctx *oidc.Context,
+	grantSession *goidc.GrantSession,
+) (
+	map[string]any,
+	oidc.Error,
+) {
+	var claims []string
+	for _, scope := range strutil.SplitWithSpaces(grantSession.ActiveScopes) {
+		for _, claim := range ctx.ScopeClaims[scope] {
+			if !slices.Contains(claims, claim) {
+				claims = append(claims, claim)
+			}
+		}
+	}
+
+	if len(claims) == 0 {
+		return nil, nil
+	}
+
+	values, err := ctx.ClaimResolverFunc(ctx, grantSession.Subject, claims)
+	if err != nil {
+		return nil, oidc.NewError(oidc.ErrorCodeInternalError, err.Error())
+	}
+
+	resolvedClaims := make(map[string]any, len(claims))
+	for _, claim := range claims {
+		if v, ok := values[claim]; ok {
+			resolvedClaims[claim] = v
+		}
+	}
+	return resolvedClaims, nil
+}
+
 func signUserInfoClaims(
 	ctx *oidc.Context,
 	client *goidc.Client,
@@ -143,11 +191,11 @@ func validateUserInfoRequest(
 	tokenType goidc.TokenType,
 ) oidc.Error {
 	if grantSession.HasLastTokenExpired() {
-		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "token expired")
+		return oidc.NewError(oidc.ErrorCodeInvalidToken, "token expired")
 	}
 
 	if !strutil.ContainsOpenID(grantSession.ActiveScopes) {
-		return oidc.NewError(oidc.ErrorCodeInvalidRequest, "invalid scope")
+		return oidc.NewError(oidc.ErrorCodeInsufficientScope, "the token was not granted the scope openid")
 	}
 
 	confirmation := token.Confirmation{
@@ -2,6 +2,8 @@ package userinfo_test
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -47,6 +49,85 @@ func TestHandleUserInfoRequest_HappyPath(t *testing.T) {
 	assert.Equal(t, "random_value", userInfo.Claims["random_claim"])
 }
 
+func TestHandleUserInfoRequest_WithScopeClaims(t *testing.T) {
+	// Given.
+	token := "opaque_token"
+	now := time.Now().Unix()
+	grantSession := &goidc.GrantSession{
+		TokenID:                    token,
+		LastTokenIssuedAtTimestamp: now,
+		CreatedAtTimestamp:         now,
+		ExpiresAtTimestamp:         now + 60,
+		ActiveScopes:               fmt.Sprintf("%s %s", goidc.ScopeOpenID.ID, goidc.ScopeProfile.ID),
+		Subject:                    "random_subject",
+		ClientID:                   oidc.TestClientID,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}
+
+	ctx := oidc.NewTestContext(t)
+	ctx.ScopeClaims = goidc.DefaultScopeClaims()
+	var requestedClaims []string
+	ctx.ClaimResolverFunc = func(_ goidc.Context, subject string, claims []string) (map[string]any, error) {
+		requestedClaims = claims
+		return map[string]any{
+			goidc.ClaimName:  "John Doe",
+			goidc.ClaimEmail: "john@example.com",
+		}, nil
+	}
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+	ctx.Request().Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	// When.
+	userInfo, err := userinfo.HandleUserInfoRequest(ctx)
+
+	// Then.
+	require.Nil(t, err)
+	assert.ElementsMatch(t, goidc.DefaultScopeClaims()[goidc.ScopeProfile.ID], requestedClaims,
+		"only the profile claims should be requested")
+	assert.Equal(t, "John Doe", userInfo.Claims[goidc.ClaimName])
+	assert.NotContains(t, userInfo.Claims, goidc.ClaimEmail, "the email scope was not granted")
+}
+
+func TestHandleUserInfoRequest_WithDistributedClaims(t *testing.T) {
+	// Given.
+	token := "opaque_token"
+	now := time.Now().Unix()
+	session := &goidc.AuthnSession{}
+	session.SetDistributedClaimUserInfo("src1", "https://claims.com", "random_token", "address")
+	grantSession := &goidc.GrantSession{
+		TokenID:                    token,
+		LastTokenIssuedAtTimestamp: now,
+		CreatedAtTimestamp:         now,
+		ExpiresAtTimestamp:         now + 60,
+		ActiveScopes:               goidc.ScopeOpenID.ID,
+		Subject:                    "random_subject",
+		ClientID:                   oidc.TestClientID,
+		AdditionalUserInfoClaims:   session.AdditionalUserInfoClaims,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}
+
+	ctx := oidc.NewTestContext(t)
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+	ctx.Request().Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	// When.
+	userInfo, err := userinfo.HandleUserInfoRequest(ctx)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{"address": "src1"}, userInfo.Claims[goidc.ClaimNames])
+	assert.Equal(t, map[string]any{
+		"src1": map[string]any{
+			"endpoint":     "https://claims.com",
+			"access_token": "random_token",
+		},
+	}, userInfo.Claims[goidc.ClaimSources])
+}
+
 func TestHandleUserInfoRequest_SignedResponse(t *testing.T) {
 	// Given.
 	token := "opaque_token"
@@ -83,3 +164,105 @@ func TestHandleUserInfoRequest_SignedResponse(t *testing.T) {
 	assert.Equal(t, "random_subject", claims[goidc.ClaimSubject])
 	assert.Equal(t, "random_value", claims["random_claim"])
 }
+
+func TestHandler_ExpiredToken(t *testing.T) {
+	// Given.
+	token := "opaque_token"
+	now := time.Now().Unix()
+	grantSession := &goidc.GrantSession{
+		TokenID:                    token,
+		LastTokenIssuedAtTimestamp: now - 120,
+		CreatedAtTimestamp:         now - 120,
+		ExpiresAtTimestamp:         now + 60,
+		ActiveScopes:               goidc.ScopeOpenID.ID,
+		Subject:                    "random_subject",
+		ClientID:                   oidc.TestClientID,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}
+
+	ctx := oidc.NewTestContext(t)
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+	handler := userinfo.Handler(&ctx.Configuration)
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	w := httptest.NewRecorder()
+
+	// When.
+	handler(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_token", error_description="token expired"`,
+		w.Header().Get(goidc.HeaderWWWAuthenticate))
+}
+
+func TestHandler_InsufficientScope(t *testing.T) {
+	// Given.
+	token := "opaque_token"
+	now := time.Now().Unix()
+	grantSession := &goidc.GrantSession{
+		TokenID:                    token,
+		LastTokenIssuedAtTimestamp: now,
+		CreatedAtTimestamp:         now,
+		ExpiresAtTimestamp:         now + 60,
+		ActiveScopes:               oidc.TestScope1.ID,
+		Subject:                    "random_subject",
+		ClientID:                   oidc.TestClientID,
+		TokenOptions: goidc.TokenOptions{
+			TokenLifetimeSecs: 60,
+		},
+	}
+
+	ctx := oidc.NewTestContext(t)
+	require.Nil(t, ctx.SaveGrantSession(grantSession))
+	handler := userinfo.Handler(&ctx.Configuration)
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	w := httptest.NewRecorder()
+
+	// When.
+	handler(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t,
+		`Bearer error="insufficient_scope", error_description="the token was not granted the scope openid", scope="openid"`,
+		w.Header().Get(goidc.HeaderWWWAuthenticate))
+}
+
+func TestHandler_MissingToken(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	handler := userinfo.Handler(&ctx.Configuration)
+	w := httptest.NewRecorder()
+
+	// When.
+	handler(w, httptest.NewRequest(http.MethodGet, "/userinfo", nil))
+
+	// Then.
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get(goidc.HeaderWWWAuthenticate), "no error should be informed")
+}
+
+func TestHandler_InvalidDPoPToken(t *testing.T) {
+	// Given.
+	ctx := oidc.NewTestContext(t)
+	ctx.DPoPSignatureAlgorithms = []jose.SignatureAlgorithm{jose.ES256, jose.PS256}
+	handler := userinfo.Handler(&ctx.Configuration)
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "DPoP unknown_token")
+	w := httptest.NewRecorder()
+
+	// When.
+	handler(w, req)
+
+	// Then.
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `DPoP algs="ES256 PS256", error="invalid_token", error_description="invalid token"`,
+		w.Header().Get(goidc.HeaderWWWAuthenticate))
+}
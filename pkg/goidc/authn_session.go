@@ -2,6 +2,9 @@ package goidc
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 )
 
@@ -23,8 +26,26 @@ type AuthnSession struct {
 	ClientID                    string                `json:"client_id"`
 	GrantedScopes               string                `json:"granted_scopes"`
 	GrantedAuthorizationDetails []AuthorizationDetail `json:"granted_authorization_details,omitempty"`
+	GrantedResources            Resources             `json:"granted_resources,omitempty"`
 	AuthorizationCode           string                `json:"authorization_code,omitempty"`
-	// ProtectedParameters contains custom parameters sent by PAR.
+	// SessionID identifies the session of the user at the server.
+	// It is sent in the ID token as the "sid" claim when enabled.
+	SessionID string `json:"sid,omitempty"`
+	// ClientCertificateThumbprint is the thumbprint of the certificate presented by the
+	// client during PAR. When set, the authorization code can only be redeemed with it.
+	ClientCertificateThumbprint string `json:"client_cert_thumbprint,omitempty"`
+	// RedirectURIIsDefault indicates the redirect_uri was not informed during the
+	// authorization request and the one registered by the client was used instead.
+	RedirectURIIsDefault bool `json:"redirect_uri_is_default,omitempty"`
+	// DeviceCode is set when the user is authenticating to approve a device
+	// authorization request instead of a regular authorization request.
+	DeviceCode string `json:"device_code,omitempty"`
+	// AccountCreationIsRequested indicates the client requested the user to
+	// register an account with "prompt=create".
+	AccountCreationIsRequested bool `json:"account_creation_is_requested,omitempty"`
+	// ProtectedParameters contains the custom parameters prefixed with "p_"
+	// sent during the authorization request, directly, inside a request
+	// object or through PAR.
 	ProtectedParameters map[string]any `json:"protected_params,omitempty"`
 	// Store allows developers to store information between user interactions.
 	Store                    map[string]any `json:"store,omitempty"`
@@ -33,18 +54,111 @@ type AuthnSession struct {
 	AdditionalUserInfoClaims map[string]any `json:"additional_user_info_claims,omitempty"`
 	AuthorizationParameters
 	Error error `json:"-"`
+	// ACR is the authentication context reference satisfied by the user.
+	ACR ACR `json:"acr,omitempty"`
+	// AMRs are the authentication methods used by the user.
+	AMRs []AMR `json:"amr,omitempty"`
+	// AuthTime is the time in seconds when the user last authenticated.
+	AuthTime int64 `json:"auth_time,omitempty"`
+	// ReauthenticationIsRequired indicates the user must authenticate again,
+	// since the last authentication is older than the max age requested by the client.
+	ReauthenticationIsRequired bool `json:"reauthentication_is_required,omitempty"`
+	// RequestURIExpiresAtTimestamp is when the request_uri issued during PAR
+	// expires. It remains valid during the authorization flow, so the user can
+	// refresh the authorization page, but not after the authorization code is
+	// issued.
+	RequestURIExpiresAtTimestamp int64 `json:"request_uri_expires_at,omitempty"`
+	// OfflineAccessIsGranted indicates the user consented to offline access.
+	// See [AuthnSession.GrantOfflineAccess].
+	OfflineAccessIsGranted bool `json:"offline_access_is_granted,omitempty"`
 }
 
+// The errors below can be set to [AuthnSession.Error] when a policy fails
+// because it cannot proceed without interacting with the user, e.g. when the
+// client requested "prompt=none".
+var (
+	ErrLoginRequired       = errors.New("the user must authenticate")
+	ErrConsentRequired     = errors.New("the user must consent")
+	ErrInteractionRequired = errors.New("the user must interact with the server")
+)
+
+// ErrUnmetAuthenticationRequirements can be set to [AuthnSession.Error] when
+// a policy fails because it cannot satisfy the authentication requirements of
+// the request, e.g. an essential "acr" claim.
+var ErrUnmetAuthenticationRequirements = errors.New("unmet authentication requirements")
+
 // UpdateParams updates the session with the parameters from an authorization request.
 // The parameters already present in the session have priority.
 func (s *AuthnSession) UpdateParams(params AuthorizationParameters) {
 	s.AuthorizationParameters = s.AuthorizationParameters.Merge(params)
 }
 
+// LoginHint returns the hint about the login identifier the user might use,
+// e.g. to pre-fill the username.
+func (s *AuthnSession) LoginHint() string {
+	return s.AuthorizationParameters.LoginHint
+}
+
+// UILocales returns the languages preferred by the user for the pages rendered
+// during authentication, ordered by preference.
+func (s *AuthnSession) UILocales() []string {
+	return strings.Fields(s.AuthorizationParameters.UILocales)
+}
+
+// ClaimsLocales returns the languages preferred by the user for the values of
+// the claims returned, ordered by preference, e.g. to resolve "name#ja".
+func (s *AuthnSession) ClaimsLocales() []string {
+	return strings.Fields(s.AuthorizationParameters.ClaimsLocales)
+}
+
+// AuthorizationParam returns the value of a parameter sent during the
+// authorization request, either a standard one, e.g. "login_hint", or a
+// protected one prefixed with "p_".
+// Parameters that are not strings, e.g. "claims", are returned as JSON.
+func (s *AuthnSession) AuthorizationParam(name string) (string, bool) {
+	if value, ok := s.ProtectedParameters[name]; ok {
+		return paramString(value)
+	}
+
+	rawParams, err := json.Marshal(s.AuthorizationParameters)
+	if err != nil {
+		return "", false
+	}
+
+	var params map[string]any
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return "", false
+	}
+
+	value, ok := params[name]
+	if !ok {
+		return "", false
+	}
+	return paramString(value)
+}
+
+func paramString(value any) (string, bool) {
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(rawValue), true
+}
+
 func (s *AuthnSession) SetUserID(userID string) {
 	s.Subject = userID
 }
 
+// SetSessionID overrides the session ID generated by the server, so it can be linked
+// to the session the user has with the server, e.g. the one kept in a cookie.
+func (s *AuthnSession) SetSessionID(sid string) {
+	s.SessionID = sid
+}
+
 func (s *AuthnSession) StoreParameter(key string, value any) {
 	if s.Store == nil {
 		s.Store = make(map[string]any)
@@ -63,6 +177,25 @@ func (s *AuthnSession) SetClaimToken(claim string, value any) {
 	s.AdditionalTokenClaims[claim] = value
 }
 
+// SetAchievedACR records the authentication context reference satisfied by
+// the user. It is returned as the "acr" claim of the ID token.
+func (s *AuthnSession) SetAchievedACR(acr ACR) {
+	s.ACR = acr
+}
+
+// SetAMR records the authentication methods used by the user. They are
+// returned as the "amr" claim of the ID token.
+func (s *AuthnSession) SetAMR(amrs ...AMR) {
+	s.AMRs = amrs
+}
+
+// SetAuthTime records when the user last authenticated. It is compared
+// against the max age requested by the client and returned as the
+// "auth_time" claim of the ID token when the max age is requested.
+func (s *AuthnSession) SetAuthTime(authTime int64) {
+	s.AuthTime = authTime
+}
+
 func (s *AuthnSession) SetACRClaimIDToken(acr ACR) {
 	s.SetClaimIDToken(ClaimAuthenticationContextReference, acr)
 }
@@ -101,16 +234,129 @@ func (s *AuthnSession) SetClaimUserInfo(claim string, value any) {
 	s.AdditionalUserInfoClaims[claim] = value
 }
 
+// SetDistributedClaimIDToken informs the client that the claims can be
+// retrieved from the endpoint using the access token provided.
+// The source ID identifies the claim source and should be unique per session.
+func (s *AuthnSession) SetDistributedClaimIDToken(
+	sourceID string,
+	endpoint string,
+	accessToken string,
+	claims ...string,
+) {
+	if s.AdditionalIDTokenClaims == nil {
+		s.AdditionalIDTokenClaims = make(map[string]any)
+	}
+	setClaimSource(s.AdditionalIDTokenClaims, sourceID, distributedClaimSource(endpoint, accessToken), claims)
+}
+
+// SetAggregatedClaimIDToken informs the client that the claims are contained
+// in the JWT provided which was signed by another claims provider.
+func (s *AuthnSession) SetAggregatedClaimIDToken(sourceID string, jwt string, claims ...string) {
+	if s.AdditionalIDTokenClaims == nil {
+		s.AdditionalIDTokenClaims = make(map[string]any)
+	}
+	setClaimSource(s.AdditionalIDTokenClaims, sourceID, aggregatedClaimSource(jwt), claims)
+}
+
+// SetDistributedClaimUserInfo is the same as [AuthnSession.SetDistributedClaimIDToken],
+// but for the user info response.
+func (s *AuthnSession) SetDistributedClaimUserInfo(
+	sourceID string,
+	endpoint string,
+	accessToken string,
+	claims ...string,
+) {
+	if s.AdditionalUserInfoClaims == nil {
+		s.AdditionalUserInfoClaims = make(map[string]any)
+	}
+	setClaimSource(s.AdditionalUserInfoClaims, sourceID, distributedClaimSource(endpoint, accessToken), claims)
+}
+
+// SetAggregatedClaimUserInfo is the same as [AuthnSession.SetAggregatedClaimIDToken],
+// but for the user info response.
+func (s *AuthnSession) SetAggregatedClaimUserInfo(sourceID string, jwt string, claims ...string) {
+	if s.AdditionalUserInfoClaims == nil {
+		s.AdditionalUserInfoClaims = make(map[string]any)
+	}
+	setClaimSource(s.AdditionalUserInfoClaims, sourceID, aggregatedClaimSource(jwt), claims)
+}
+
 func (s *AuthnSession) GrantScopes(scopes string) {
 	s.GrantedScopes = scopes
 }
 
+// GrantOfflineAccess confirms the user consented to offline access.
+// When the server requires consent for offline access, the "offline_access"
+// scope is only granted, and a refresh token only issued, if this is called
+// during the authentication flow.
+func (s *AuthnSession) GrantOfflineAccess() {
+	s.OfflineAccessIsGranted = true
+}
+
 // GrantAuthorizationDetails sets the authorization details the client will have permissions to use.
 // This will only have effect if support for authorization details was enabled.
 func (s *AuthnSession) GrantAuthorizationDetails(authDetails []AuthorizationDetail) {
 	s.GrantedAuthorizationDetails = authDetails
 }
 
+// RequestedAuthorizationDetails returns the authorization details requested by the client.
+// Policies can present them to the user and grant only the ones approved, possibly
+// with fewer permissions, with [AuthnSession.GrantAuthorizationDetails].
+func (s *AuthnSession) RequestedAuthorizationDetails() []AuthorizationDetail {
+	return s.AuthorizationDetails
+}
+
+// GrantResources sets the resources the access token will be issued for.
+// By default, the resources requested are granted.
+func (s *AuthnSession) GrantResources(resources Resources) {
+	s.GrantedResources = resources
+}
+
 func (s *AuthnSession) IsExpired() bool {
 	return time.Now().Unix() > s.ExpiresAtTimestamp
 }
+
+func (s *AuthnSession) RequestURIIsExpired() bool {
+	return time.Now().Unix() > s.RequestURIExpiresAtTimestamp
+}
+
+func distributedClaimSource(endpoint string, accessToken string) map[string]any {
+	source := map[string]any{
+		"endpoint": endpoint,
+	}
+	if accessToken != "" {
+		source["access_token"] = accessToken
+	}
+	return source
+}
+
+func aggregatedClaimSource(jwt string) map[string]any {
+	return map[string]any{
+		"JWT": jwt,
+	}
+}
+
+// setClaimSource registers the source under the "_claim_sources" claim and
+// points each one of the claims to it under the "_claim_names" claim.
+func setClaimSource(
+	claims map[string]any,
+	sourceID string,
+	source map[string]any,
+	claimNames []string,
+) {
+	names, ok := claims[ClaimNames].(map[string]any)
+	if !ok {
+		names = make(map[string]any)
+	}
+	for _, name := range claimNames {
+		names[name] = sourceID
+	}
+	claims[ClaimNames] = names
+
+	sources, ok := claims[ClaimSources].(map[string]any)
+	if !ok {
+		sources = make(map[string]any)
+	}
+	sources[sourceID] = source
+	claims[ClaimSources] = sources
+}
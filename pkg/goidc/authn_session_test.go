@@ -20,6 +20,44 @@ func TestSaveAndGetParameter_HappyPath(t *testing.T) {
 	}
 }
 
+func TestAuthorizationParam(t *testing.T) {
+	// Given.
+	maxAge := 600
+	session := goidc.AuthnSession{
+		AuthorizationParameters: goidc.AuthorizationParameters{
+			LoginHint:       "user@example.com",
+			MaxAuthnAgeSecs: &maxAge,
+		},
+		ProtectedParameters: map[string]any{
+			"p_tenant": "random_tenant",
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		expectedValue string
+		expectedOK    bool
+	}{
+		{"login_hint", "user@example.com", true},
+		{"max_age", "600", true},
+		{"p_tenant", "random_tenant", true},
+		{"state", "", false},
+		{"p_unknown", "", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			value, ok := session.AuthorizationParam(testCase.name)
+
+			// Then.
+			assert.Equal(t, testCase.expectedOK, ok)
+			assert.Equal(t, testCase.expectedValue, value)
+		})
+	}
+	assert.Equal(t, "user@example.com", session.LoginHint())
+}
+
 func TestAddTokenClaim(t *testing.T) {
 	for i := 0; i < 2; i++ {
 		// Given.
@@ -62,3 +100,44 @@ func TestIsExpired(t *testing.T) {
 	// Then.
 	assert.True(t, session.IsExpired())
 }
+
+func TestSetDistributedClaimIDToken(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{}
+
+	// When.
+	session.SetDistributedClaimIDToken("src1", "https://claims.com", "random_token", "address", "phone_number")
+	session.SetAggregatedClaimIDToken("src2", "random_jwt", "birthdate")
+
+	// Then.
+	assert.Equal(t, map[string]any{
+		"address":      "src1",
+		"phone_number": "src1",
+		"birthdate":    "src2",
+	}, session.AdditionalIDTokenClaims[goidc.ClaimNames])
+	assert.Equal(t, map[string]any{
+		"src1": map[string]any{
+			"endpoint":     "https://claims.com",
+			"access_token": "random_token",
+		},
+		"src2": map[string]any{
+			"JWT": "random_jwt",
+		},
+	}, session.AdditionalIDTokenClaims[goidc.ClaimSources])
+}
+
+func TestSetDistributedClaimUserInfo_WithoutAccessToken(t *testing.T) {
+	// Given.
+	session := goidc.AuthnSession{}
+
+	// When.
+	session.SetDistributedClaimUserInfo("src1", "https://claims.com", "", "address")
+
+	// Then.
+	assert.Equal(t, map[string]any{"address": "src1"}, session.AdditionalUserInfoClaims[goidc.ClaimNames])
+	assert.Equal(t, map[string]any{
+		"src1": map[string]any{
+			"endpoint": "https://claims.com",
+		},
+	}, session.AdditionalUserInfoClaims[goidc.ClaimSources])
+}
@@ -6,12 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type ClientManager interface {
@@ -30,6 +32,12 @@ type Client struct {
 	HashedSecret                  string `json:"hashed_secret,omitempty" bson:"hashed_secret,omitempty"`
 	HashedRegistrationAccessToken string `json:"hashed_registration_access_token" bson:"hashed_registration_access_token"`
 	ClientMetaInfo                `bson:"inline"`
+	// PreviousSecret and PreviousHashedSecret keep the secret replaced during
+	// a rotation, so the client can still authenticate with it until
+	// PreviousSecretExpiresAtTimestamp.
+	PreviousSecret                   string `json:"previous_client_secret,omitempty" bson:"previous_client_secret,omitempty"`
+	PreviousHashedSecret             string `json:"previous_hashed_secret,omitempty" bson:"previous_hashed_secret,omitempty"`
+	PreviousSecretExpiresAtTimestamp int64  `json:"previous_secret_expires_at,omitempty" bson:"previous_secret_expires_at,omitempty"`
 }
 
 func (c *Client) SetAttribute(key string, value any) {
@@ -89,29 +97,56 @@ func (c *Client) AreScopesAllowed(
 		return true
 	}
 
-	// Filter the client scopes that are available.
+	clientScopes := c.availableScopes(availableScopes)
+	// For each scope requested, make sure it matches one of the available client scopes.
+	for _, requestedScope := range strings.Split(requestedScopes, " ") {
+		if !matchesAny(clientScopes, requestedScope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AllowedScopes returns the scopes requested, space separated, that match one
+// of the available scopes of the client. The other ones are discarded.
+func (c *Client) AllowedScopes(
+	availableScopes []Scope,
+	requestedScopes string,
+) string {
+	if requestedScopes == "" {
+		return ""
+	}
+
+	clientScopes := c.availableScopes(availableScopes)
+	var allowedScopes []string
+	for _, requestedScope := range strings.Split(requestedScopes, " ") {
+		if matchesAny(clientScopes, requestedScope) {
+			allowedScopes = append(allowedScopes, requestedScope)
+		}
+	}
+
+	return strings.Join(allowedScopes, " ")
+}
+
+// availableScopes filters the client scopes that are available.
+func (c *Client) availableScopes(availableScopes []Scope) []Scope {
 	var clientScopes []Scope
 	for _, scope := range availableScopes {
 		if strings.Contains(c.Scopes, scope.ID) {
 			clientScopes = append(clientScopes, scope)
 		}
 	}
+	return clientScopes
+}
 
-	// For each scope requested, make sure it matches one of the available client scopes.
-	for _, requestedScope := range strings.Split(requestedScopes, " ") {
-		matches := false
-		for _, scope := range clientScopes {
-			if scope.Matches(requestedScope) {
-				matches = true
-				break
-			}
-		}
-		if !matches {
-			return false
+func matchesAny(scopes []Scope, requestedScope string) bool {
+	for _, scope := range scopes {
+		if scope.Matches(requestedScope) {
+			return true
 		}
 	}
-
-	return true
+	return false
 }
 
 func (c *Client) IsResponseTypeAllowed(responseType ResponseType) bool {
@@ -122,7 +157,25 @@ func (c *Client) IsGrantTypeAllowed(grantType GrantType) bool {
 	return slices.Contains(c.GrantTypes, grantType)
 }
 
+// IsRedirectURIAllowed returns whether redirectURI exactly matches one of the
+// URIs registered by the client.
+// For loopback redirect URIs, e.g. "http://127.0.0.1/callback", any port is
+// accepted, since native apps can't know in advance which port will be
+// available as described in RFC 8252.
 func (c *Client) IsRedirectURIAllowed(redirectURI string) bool {
+	for _, ru := range c.RedirectURIS {
+		if redirectURI == ru || isLoopbackRedirectURIMatch(ru, redirectURI) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRedirectURIPrefixAllowed returns whether redirectURI starts with one of the
+// URIs registered by the client.
+// This is less secure than IsRedirectURIAllowed and is only meant for legacy
+// clients.
+func (c *Client) IsRedirectURIPrefixAllowed(redirectURI string) bool {
 	for _, ru := range c.RedirectURIS {
 		if strings.HasPrefix(redirectURI, ru) {
 			return true
@@ -131,6 +184,45 @@ func (c *Client) IsRedirectURIAllowed(redirectURI string) bool {
 	return false
 }
 
+// isLoopbackRedirectURIMatch returns whether the registered URI is a loopback
+// redirect URI and the redirect URI only differs from it by the port.
+func isLoopbackRedirectURIMatch(registeredURI, redirectURI string) bool {
+	registeredURL, err := url.Parse(registeredURI)
+	if err != nil || !isLoopbackURL(registeredURL) {
+		return false
+	}
+
+	redirectURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return false
+	}
+
+	return redirectURL.Scheme == registeredURL.Scheme &&
+		redirectURL.User == nil &&
+		redirectURL.Hostname() == registeredURL.Hostname() &&
+		redirectURL.Path == registeredURL.Path &&
+		redirectURL.RawQuery == registeredURL.RawQuery &&
+		redirectURL.Fragment == registeredURL.Fragment
+}
+
+// isLoopbackURL returns whether the URL uses the http scheme and a loopback
+// IP literal as its host.
+// "localhost" is not considered, since RFC 8252 recommends against it.
+func isLoopbackURL(u *url.URL) bool {
+	if u.Scheme != "http" || u.User != nil {
+		return false
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// IsPostLogoutRedirectURIAllowed returns whether postLogoutRedirectURI exactly
+// matches one of the URIs registered by the client.
+func (c *Client) IsPostLogoutRedirectURIAllowed(postLogoutRedirectURI string) bool {
+	return slices.Contains(c.PostLogoutRedirectURIS, postLogoutRedirectURI)
+}
+
 func (c *Client) AllowRedirectURI(redirectURI string) {
 	c.RedirectURIS = append(c.RedirectURIS, redirectURI)
 }
@@ -144,9 +236,17 @@ func (c *Client) IsAuthorizationDetailTypeAllowed(authDetailType string) bool {
 	return slices.Contains(c.AuthorizationDetailTypes, authDetailType)
 }
 
-func (c *Client) IsRegistrationAccessTokenValid(token string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(c.HashedRegistrationAccessToken), []byte(token))
-	return err == nil
+// PreviousSecretIsActive returns true if the secret replaced during the last
+// rotation can still be used to authenticate the client.
+func (c *Client) PreviousSecretIsActive() bool {
+	return (c.PreviousSecret != "" || c.PreviousHashedSecret != "") &&
+		time.Now().Unix() < c.PreviousSecretExpiresAtTimestamp
+}
+
+// IsRegistrationAccessTokenValid returns true if the token matches the hashed
+// registration access token according to the hasher used to hash it.
+func (c *Client) IsRegistrationAccessTokenValid(hasher SecretHasher, token string) bool {
+	return hasher.Compare(c.HashedRegistrationAccessToken, token)
 }
 
 // FetchPublicJWKS fetches the client public JWKS either directly from the jwks attribute or using jwks_uri.
@@ -217,4 +317,32 @@ type ClientMetaInfo struct {
 	DefaultMaxAgeSecs           *int           `json:"default_max_age,omitempty" bson:"default_max_age,omitempty"`
 	DefaultACRValues            string         `json:"default_acr_values,omitempty" bson:"default_acr_values,omitempty"`
 	CustomAttributes            map[string]any `json:"custom_attributes,omitempty" bson:"custom_attributes,omitempty"`
+	// PostLogoutRedirectURIS are the URIs where the user can be redirected to
+	// after logging out at the end session endpoint.
+	PostLogoutRedirectURIS []string `json:"post_logout_redirect_uris,omitempty" bson:"post_logout_redirect_uris,omitempty"`
+	// BackChannelLogoutURI is where the server sends logout tokens when the
+	// session of a user ends.
+	BackChannelLogoutURI string `json:"backchannel_logout_uri,omitempty" bson:"backchannel_logout_uri,omitempty"`
+	// BackChannelLogoutSessionIsRequired indicates the client requires the
+	// "sid" claim in logout tokens.
+	BackChannelLogoutSessionIsRequired bool `json:"backchannel_logout_session_required,omitempty" bson:"backchannel_logout_session_required,omitempty"`
+	// Profile allows applying a stricter profile to the client than the one used by the server,
+	// e.g. FAPI 2.0 for a banking client while the others use OpenID.
+	Profile Profile `json:"profile,omitempty" bson:"profile,omitempty"`
+	// RequestURIS are the URIs where the client hosts the request objects it
+	// passes by reference with the "request_uri" parameter.
+	RequestURIS []string `json:"request_uris,omitempty" bson:"request_uris,omitempty"`
+	// TLSSubjectAlternativeNameURI and TLSSubjectAlternativeNameEmail identify
+	// the certificate of clients using tls_client_auth by a URI or an email
+	// subject alternative name respectively as defined in RFC 8705.
+	TLSSubjectAlternativeNameURI   string `json:"tls_client_auth_san_uri,omitempty" bson:"tls_client_auth_san_uri,omitempty"`
+	TLSSubjectAlternativeNameEmail string `json:"tls_client_auth_san_email,omitempty" bson:"tls_client_auth_san_email,omitempty"`
+	// PARIsRequired, when informed, overrides the server policy for pushed
+	// authorization requests for this client only.
+	PARIsRequired *bool `json:"require_pushed_authorization_requests,omitempty" bson:"require_pushed_authorization_requests,omitempty"`
+	// SoftwareStatement is a JWT asserting metadata about the client software
+	// signed by a trusted issuer as described in RFC 7591.
+	SoftwareStatement string `json:"software_statement,omitempty" bson:"software_statement,omitempty"`
+	SoftwareID        string `json:"software_id,omitempty" bson:"software_id,omitempty"`
+	SoftwareVersion   string `json:"software_version,omitempty" bson:"software_version,omitempty"`
 }
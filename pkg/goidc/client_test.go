@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -48,6 +50,41 @@ func TestAreScopesAllowed(t *testing.T) {
 	}
 }
 
+func TestAllowedScopes(t *testing.T) {
+	// Given.
+	scopes := []goidc.Scope{
+		goidc.NewScope("scope1"),
+		goidc.NewScope("scope2"),
+		goidc.NewScope("scope3"),
+	}
+
+	client := goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			Scopes: "scope1 scope2",
+		},
+	}
+
+	testCases := []struct {
+		requestedScopes string
+		expectedScopes  string
+	}{
+		{"scope1 scope2", "scope1 scope2"},
+		{"invalid_scope scope2", "scope2"},
+		{"scope1 scope3", "scope1"},
+		{"invalid_scope", ""},
+		{"", ""},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(
+			fmt.Sprintf("case %d", i),
+			func(t *testing.T) {
+				assert.Equal(t, testCase.expectedScopes, client.AllowedScopes(scopes, testCase.requestedScopes))
+			},
+		)
+	}
+}
+
 func TestIsResponseTypeAllowed(t *testing.T) {
 	client := goidc.Client{
 		ClientMetaInfo: goidc.ClientMetaInfo{
@@ -99,7 +136,12 @@ func TestIsGrantTypeAllowed(t *testing.T) {
 func TestIsRedirectURIAllowed(t *testing.T) {
 	client := goidc.Client{
 		ClientMetaInfo: goidc.ClientMetaInfo{
-			RedirectURIS: []string{"https://example.com/callback", "http://example.com?param=value"},
+			RedirectURIS: []string{
+				"https://example.com/callback",
+				"http://example.com?param=value",
+				"http://127.0.0.1/callback",
+				"http://[::1]:8080/callback",
+			},
 		},
 	}
 	testCases := []struct {
@@ -107,17 +149,50 @@ func TestIsRedirectURIAllowed(t *testing.T) {
 		expectedResult bool
 	}{
 		{"https://example.com/callback", true},
-		{"https://example.com/callback?param=value", true},
+		{"http://example.com?param=value", true},
+		{"https://example.com/callback?param=value", false},
+		{"https://example.com/callback.evil.com", false},
 		{"https://example.com/invalid", false},
+		{"http://127.0.0.1:51004/callback", true},
+		{"http://127.0.0.1/callback", true},
+		{"http://[::1]:9090/callback", true},
+		{"http://127.0.0.1:51004/another_callback", false},
+		{"https://127.0.0.1:51004/callback", false},
+		{"http://localhost:51004/callback", false},
 	}
 
-	for i, testCase := range testCases {
-		t.Run(
-			fmt.Sprintf("case %v", i),
-			func(t *testing.T) {
-				assert.Equal(t, testCase.expectedResult, client.IsRedirectURIAllowed(testCase.redirectURI))
-			},
-		)
+	for _, testCase := range testCases {
+		t.Run(testCase.redirectURI, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedResult, client.IsRedirectURIAllowed(testCase.redirectURI))
+		})
+	}
+}
+
+func TestIsRedirectURIPrefixAllowed(t *testing.T) {
+	client := goidc.Client{
+		ClientMetaInfo: goidc.ClientMetaInfo{
+			RedirectURIS: []string{"https://good.com"},
+		},
+	}
+	testCases := []struct {
+		redirectURI    string
+		expectedResult bool
+	}{
+		{"https://good.com", true},
+		{"https://good.com/callback?param=value", true},
+		// The prefix matching is vulnerable to this bypass, which is why it's
+		// not the default.
+		{"https://good.com.evil.com", true},
+		{"https://evil.com", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.redirectURI, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedResult, client.IsRedirectURIPrefixAllowed(testCase.redirectURI))
+			if testCase.redirectURI != "https://good.com" {
+				assert.False(t, client.IsRedirectURIAllowed(testCase.redirectURI), "exact matching should reject the URI")
+			}
+		})
 	}
 }
 
@@ -151,8 +226,71 @@ func TestIsRegistrationAccessTokenValid(t *testing.T) {
 	}
 
 	// Then.
-	assert.True(t, client.IsRegistrationAccessTokenValid(registrationAccessToken))
-	assert.False(t, client.IsRegistrationAccessTokenValid("invalid_token"))
+	assert.True(t, client.IsRegistrationAccessTokenValid(goidc.BCryptHasher{}, registrationAccessToken))
+	assert.False(t, client.IsRegistrationAccessTokenValid(goidc.BCryptHasher{}, "invalid_token"))
+}
+
+func TestIsRegistrationAccessTokenValid_Argon2id(t *testing.T) {
+	// Given.
+	registrationAccessToken := "random_token"
+	hashedRegistrationAccessToken, err := goidc.NewArgon2idHasher().Hash(registrationAccessToken)
+	require.Nil(t, err)
+	client := goidc.Client{
+		HashedRegistrationAccessToken: hashedRegistrationAccessToken,
+	}
+
+	// Then.
+	assert.True(t, client.IsRegistrationAccessTokenValid(goidc.NewArgon2idHasher(), registrationAccessToken))
+	assert.False(t, client.IsRegistrationAccessTokenValid(goidc.NewArgon2idHasher(), "invalid_token"))
+}
+
+func TestCompareSecret(t *testing.T) {
+	testCases := []struct {
+		name   string
+		hasher goidc.SecretHasher
+	}{
+		{"bcrypt", goidc.BCryptHasher{}},
+		{"argon2id", goidc.NewArgon2idHasher()},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			secret := "random_secret"
+
+			// When.
+			hashedSecret, err := testCase.hasher.Hash(secret)
+
+			// Then.
+			require.Nil(t, err)
+			assert.True(t, goidc.CompareSecret(hashedSecret, secret))
+			assert.False(t, goidc.CompareSecret(hashedSecret, "invalid_secret"))
+			// Both hashers accept hashes generated by either algorithm.
+			assert.True(t, goidc.BCryptHasher{}.Compare(hashedSecret, secret))
+			assert.True(t, goidc.NewArgon2idHasher().Compare(hashedSecret, secret))
+		})
+	}
+}
+
+func TestCompareSecret_Argon2idDoesNotTruncate(t *testing.T) {
+	// Given.
+	secret := strings.Repeat("a", 80)
+	hashedSecret, err := goidc.NewArgon2idHasher().Hash(secret)
+	require.Nil(t, err)
+
+	// Then.
+	assert.False(t, goidc.CompareSecret(hashedSecret, strings.Repeat("a", 72)))
+}
+
+func TestArgon2idHasher_ZeroValueUsesDefaults(t *testing.T) {
+	// When.
+	hashedSecret, err := goidc.Argon2idHasher{}.Hash("random_secret")
+
+	// Then.
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hashedSecret, "$argon2id$v=19$m=65536,t=1,p=4$"))
+	assert.True(t, goidc.Argon2idHasher{}.Compare(hashedSecret, "random_secret"))
+	assert.False(t, goidc.Argon2idHasher{}.Compare(hashedSecret, "invalid_secret"))
 }
 
 func TestGetPublicJWKS(t *testing.T) {
@@ -14,6 +14,54 @@ const (
 	EndpointUserInfo                   = "/userinfo"
 	EndpointDynamicClient              = "/register"
 	EndpointTokenIntrospection         = "/introspect"
+	EndpointTokenRevocation            = "/revoke"
+	EndpointDeviceAuthorization        = "/device_authorization"
+	EndpointDevice                     = "/device"
+	EndpointEndSession                 = "/end_session"
+	EndpointFederation                 = "/.well-known/openid-federation"
+	EndpointProtectedResource          = "/.well-known/oauth-protected-resource"
+	EndpointHealth                     = "/health"
+	EndpointReady                      = "/ready"
+)
+
+// DCRMode defines how clients are allowed to register themselves dynamically.
+type DCRMode string
+
+const (
+	// DCRModeOpen allows any client to register without an initial access token.
+	DCRModeOpen DCRMode = "open"
+	// DCRModeProtected requires a valid initial access token to register a client.
+	// Requests with a missing or invalid token are rejected with 401.
+	DCRModeProtected DCRMode = "protected"
+	// DCRModeClosed denies the registration of new clients.
+	DCRModeClosed DCRMode = "closed"
+)
+
+// RedirectURIMatchingMode defines how the redirect URI informed in
+// authorization requests is compared to the ones registered by the client.
+type RedirectURIMatchingMode string
+
+const (
+	// RedirectURIMatchingExact requires the redirect URI to be exactly one of
+	// the registered URIs, as recommended by OAuth 2.1. The only exception is
+	// the port of loopback redirect URIs, which can vary as described in
+	// RFC 8252.
+	RedirectURIMatchingExact RedirectURIMatchingMode = "exact"
+	// RedirectURIMatchingPrefix accepts redirect URIs that start with one of
+	// the registered URIs. It's only intended for legacy clients, since e.g.
+	// "https://good.com.evil.com" would match "https://good.com".
+	RedirectURIMatchingPrefix RedirectURIMatchingMode = "prefix"
+)
+
+// AssertionAudienceMode defines which audiences are accepted in client assertions.
+type AssertionAudienceMode string
+
+const (
+	// AssertionAudienceModeIssuer accepts only the issuer as the audience as defined by OpenID.
+	AssertionAudienceModeIssuer AssertionAudienceMode = "issuer"
+	// AssertionAudienceModeEndpoint accepts the URL of the endpoint being requested
+	// as the audience as defined by OAuth, as well as the URL of the token endpoint.
+	AssertionAudienceModeEndpoint AssertionAudienceMode = "endpoint"
 )
 
 type Profile string
@@ -30,6 +78,8 @@ const (
 	GrantAuthorizationCode GrantType = "authorization_code"
 	GrantRefreshToken      GrantType = "refresh_token"
 	GrantImplicit          GrantType = "implicit"
+	GrantDeviceCode        GrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantJWTBearer         GrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
 	GrantIntrospection     GrantType = "urn:goidc:oauth2:grant_type:token_intropection"
 )
 
@@ -110,6 +160,7 @@ const (
 	ClaimSubject                        string = "sub"
 	ClaimAudience                       string = "aud"
 	ClaimClientID                       string = "client_id"
+	ClaimAuthorizedParty                string = "azp"
 	ClaimExpiry                         string = "exp"
 	ClaimIssuedAt                       string = "iat"
 	ClaimScope                          string = "scope"
@@ -121,10 +172,41 @@ const (
 	ClaimEmail                          string = "email"
 	ClaimEmailVerified                  string = "email_verified"
 	ClaimAddress                        string = "address"
+	ClaimName                           string = "name"
+	ClaimFamilyName                     string = "family_name"
+	ClaimGivenName                      string = "given_name"
+	ClaimMiddleName                     string = "middle_name"
+	ClaimNickname                       string = "nickname"
+	ClaimPreferredUsername              string = "preferred_username"
+	ClaimPicture                        string = "picture"
+	ClaimWebsite                        string = "website"
+	ClaimGender                         string = "gender"
+	ClaimBirthdate                      string = "birthdate"
+	ClaimZoneInfo                       string = "zoneinfo"
+	ClaimLocale                         string = "locale"
+	ClaimUpdatedAt                      string = "updated_at"
+	ClaimPhoneNumber                    string = "phone_number"
+	ClaimPhoneNumberVerified            string = "phone_number_verified"
 	ClaimAuthorizationDetails           string = "authorization_details"
 	ClaimAccessTokenHash                string = "at_hash"
 	ClaimAuthorizationCodeHash          string = "c_hash"
 	ClaimStateHash                      string = "s_hash"
+	ClaimSessionID                      string = "sid"
+	ClaimNames                          string = "_claim_names"
+	ClaimSources                        string = "_claim_sources"
+	ClaimEvents                         string = "events"
+	ClaimTokenIntrospection             string = "token_introspection"
+)
+
+// SignatureKeyPurpose identifies what a server signing key is used for.
+type SignatureKeyPurpose string
+
+const (
+	SignatureKeyPurposeToken SignatureKeyPurpose = "token"
+	// SignatureKeyPurposeUserInfo covers both the userinfo responses and the
+	// ID tokens.
+	SignatureKeyPurposeUserInfo SignatureKeyPurpose = "userinfo"
+	SignatureKeyPurposeJARM     SignatureKeyPurpose = "jarm"
 )
 
 type KeyUsage string
@@ -152,9 +234,17 @@ const (
 
 const (
 	HeaderDPoP string = "DPoP"
+	// HeaderDPoPNonce is the header used by the server to provide clients with a nonce to be used in DPoP proofs.
+	HeaderDPoPNonce  string = "DPoP-Nonce"
+	HeaderRetryAfter string = "Retry-After"
 	// HeaderClientCertificate is the header used to transmit a client certificate that was validated by a trusted source.
 	// The value in this header is expected to be the URL encoding of the client's certificate in PEM format.
 	HeaderClientCertificate string = "X-Client-Cert"
+	// HeaderWWWAuthenticate is used by protected resources to indicate why a request
+	// with an access token was rejected as described in RFC 6750.
+	HeaderWWWAuthenticate string = "WWW-Authenticate"
+	// HeaderCorrelationID is the default header used to correlate the logs of a request.
+	HeaderCorrelationID string = "X-Correlation-ID"
 )
 
 type AuthnStatus string
@@ -201,11 +291,20 @@ const (
 
 type PromptType string
 
+// Contains returns whether the prompt value, which can contain multiple values
+// separated by spaces, includes the one informed.
+func (p PromptType) Contains(prompt PromptType) bool {
+	return slices.Contains(strings.Fields(string(p)), string(prompt))
+}
+
 const (
 	PromptTypeNone          PromptType = "none"
 	PromptTypeLogin         PromptType = "login"
 	PromptTypeConsent       PromptType = "consent"
 	PromptTypeSelectAccount PromptType = "select_account"
+	// PromptTypeCreate asks the server to show the account registration
+	// experience as defined by Initiating User Registration via OpenID Connect.
+	PromptTypeCreate PromptType = "create"
 )
 
 type ClaimType string
@@ -216,6 +315,19 @@ const (
 	ClaimTypeDistributed ClaimType = "distributed"
 )
 
+// EssentialClaimsPolicy defines what happens when an essential claim
+// requested with the "claims" parameter is not provided.
+type EssentialClaimsPolicy string
+
+const (
+	// EssentialClaimsPolicyBestEffort issues the tokens with the claims
+	// available even if some essential claims are missing.
+	EssentialClaimsPolicyBestEffort EssentialClaimsPolicy = "best_effort"
+	// EssentialClaimsPolicyError fails the authorization request if an
+	// essential claim is missing.
+	EssentialClaimsPolicyError EssentialClaimsPolicy = "error"
+)
+
 type TokenTypeHint string
 
 const (
@@ -231,3 +343,7 @@ const (
 	ACRMaceIncommonIAPSilver ACR = "urn:mace:incommon:iap:silver"
 	ACRMaceIncommonIAPBronze ACR = "urn:mace:incommon:iap:bronze"
 )
+
+// EventBackChannelLogout identifies logout tokens in the "events" claim as
+// defined by OpenID Connect Back-Channel Logout 1.0.
+const EventBackChannelLogout string = "http://schemas.openid.net/event/backchannel-logout"
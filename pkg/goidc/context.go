@@ -9,6 +9,8 @@ type Context interface {
 	Request() *http.Request
 	Response() http.ResponseWriter
 	Client(clientID string) (*Client, error)
+	// RenderHTML writes the HTML template to the response with status 200.
+	RenderHTML(html string, params any) error
 	// context.Context is embedded here as a shortcut to access the context in the request.
 	context.Context
 }
@@ -0,0 +1,69 @@
+package goidc
+
+import (
+	"context"
+	"time"
+)
+
+type DeviceSessionManager interface {
+	Save(ctx context.Context, session *DeviceSession) error
+	GetByDeviceCode(ctx context.Context, deviceCode string) (*DeviceSession, error)
+	GetByUserCode(ctx context.Context, userCode string) (*DeviceSession, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type DeviceSessionStatus string
+
+const (
+	// DeviceSessionStatusPending means the user didn't approve nor deny the request yet.
+	DeviceSessionStatusPending    DeviceSessionStatus = "pending"
+	DeviceSessionStatusAuthorized DeviceSessionStatus = "authorized"
+	DeviceSessionStatusDenied     DeviceSessionStatus = "denied"
+)
+
+// DeviceSession holds the information of a device authorization request
+// as defined in RFC 8628.
+// It's created at the device authorization endpoint and is updated once the
+// user authenticates at the verification URI.
+type DeviceSession struct {
+	ID         string              `json:"id"`
+	DeviceCode string              `json:"device_code"`
+	UserCode   string              `json:"user_code"`
+	ClientID   string              `json:"client_id"`
+	Scopes     string              `json:"scope,omitempty"`
+	Status     DeviceSessionStatus `json:"status"`
+	// PollingIntervalSecs is the minimum amount of time the client must wait
+	// between token requests.
+	PollingIntervalSecs   int64  `json:"polling_interval_secs"`
+	LastPolledAtTimestamp int64  `json:"last_polled_at,omitempty"`
+	CreatedAtTimestamp    int64  `json:"created_at"`
+	ExpiresAtTimestamp    int64  `json:"expires_at"`
+	Subject               string `json:"sub,omitempty"`
+	SessionID             string `json:"sid,omitempty"`
+	GrantedScopes         string `json:"granted_scopes,omitempty"`
+	// The fields below are copied from the authentication session once the
+	// user approves the request.
+	GrantedAuthorizationDetails []AuthorizationDetail `json:"granted_authorization_details,omitempty"`
+	GrantedResources            Resources             `json:"granted_resources,omitempty"`
+	AdditionalTokenClaims       map[string]any        `json:"additional_token_claims,omitempty"`
+	AdditionalIDTokenClaims     map[string]any        `json:"additional_id_token_claims,omitempty"`
+	AdditionalUserInfoClaims    map[string]any        `json:"additional_user_info_claims,omitempty"`
+}
+
+func (s *DeviceSession) IsExpired() bool {
+	return time.Now().Unix() > s.ExpiresAtTimestamp
+}
+
+// Authorize marks the session as approved by the user with the information
+// granted during the authentication.
+func (s *DeviceSession) Authorize(authnSession *AuthnSession) {
+	s.Status = DeviceSessionStatusAuthorized
+	s.Subject = authnSession.Subject
+	s.SessionID = authnSession.SessionID
+	s.GrantedScopes = authnSession.GrantedScopes
+	s.GrantedAuthorizationDetails = authnSession.GrantedAuthorizationDetails
+	s.GrantedResources = authnSession.GrantedResources
+	s.AdditionalTokenClaims = authnSession.AdditionalTokenClaims
+	s.AdditionalIDTokenClaims = authnSession.AdditionalIDTokenClaims
+	s.AdditionalUserInfoClaims = authnSession.AdditionalUserInfoClaims
+}
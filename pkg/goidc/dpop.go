@@ -0,0 +1,14 @@
+package goidc
+
+import "context"
+
+// DPoPNonceStore issues and validates the nonces provided by the server to be
+// used in DPoP proofs as described in RFC 9449, section 8.
+// Nonces are bound to the client they were issued to.
+type DPoPNonceStore interface {
+	// Issue creates a new nonce for the client.
+	Issue(ctx context.Context, clientID string) (string, error)
+	// Consume reports whether the nonce was issued to the client and is not
+	// expired. The nonce must be invalidated, so it cannot be replayed.
+	Consume(ctx context.Context, clientID, nonce string) (bool, error)
+}
@@ -0,0 +1,31 @@
+package goidc
+
+import "context"
+
+// EventType identifies a lifecycle event of the server.
+type EventType string
+
+const (
+	EventTypeTokenIssued          EventType = "token_issued"
+	EventTypeTokenRevoked         EventType = "token_revoked"
+	EventTypeAuthorizationGranted EventType = "authorization_granted"
+	EventTypeClientAuthenticated  EventType = "client_authenticated"
+	EventTypeClientRegistered     EventType = "client_registered"
+)
+
+// Event describes something that happened in the server, e.g. a token was
+// issued to a client. It can be used for auditing.
+type Event struct {
+	Type     EventType
+	ClientID string
+	// Subject is the user the event refers to, if any.
+	Subject string
+	// Details holds additional information specific to the type of the event.
+	Details map[string]any
+}
+
+// EventHook is notified of the events happening in the server.
+// Hooks are called synchronously, so they should not block the request.
+type EventHook interface {
+	OnEvent(ctx context.Context, event Event)
+}
@@ -9,27 +9,61 @@ type GrantSessionManager interface {
 	Save(ctx context.Context, grantSession *GrantSession) error
 	GetByTokenID(ctx context.Context, tokenID string) (*GrantSession, error)
 	GetByRefreshToken(ctx context.Context, refreshToken string) (*GrantSession, error)
+	// GetByPreviousRefreshToken returns the grant session in which refreshToken
+	// was consumed by a rotation, i.e. the grant session whose
+	// PreviousRefreshTokens contains it. It is used to detect refresh token reuse.
+	GetByPreviousRefreshToken(ctx context.Context, refreshToken string) (*GrantSession, error)
 	Delete(ctx context.Context, id string) error
 }
 
+// GrantSessionSubjectFinder can be implemented by a grant session manager that
+// is able to find the grant sessions of a user.
+// It is required for ending the grant sessions of a user at logout and for
+// back-channel logout.
+type GrantSessionSubjectFinder interface {
+	// GetBySubject returns all the grant sessions issued for the user.
+	GetBySubject(ctx context.Context, subject string) ([]*GrantSession, error)
+}
+
+// IntrospectionCache keeps the introspection results of access tokens indexed
+// by token ID, so introspecting the same token repeatedly doesn't require
+// loading its grant session every time.
+type IntrospectionCache interface {
+	Get(ctx context.Context, tokenID string) (TokenInfo, bool)
+	// Set caches the introspection result of the token issued for the grant
+	// session identified by grantSessionID.
+	Set(ctx context.Context, tokenID, grantSessionID string, info TokenInfo)
+	// InvalidateGrantSession removes the results of all the tokens issued for
+	// the grant session. It is called whenever the grant session changes or is
+	// deleted, e.g. when a token is revoked.
+	InvalidateGrantSession(ctx context.Context, grantSessionID string)
+}
+
 type GrantSession struct {
-	ID                          string                `json:"id"`
-	JWKThumbprint               string                `json:"jwk_thumbprint,omitempty"`
-	ClientCertificateThumbprint string                `json:"certificate_thumbprint,omitempty"`
-	TokenID                     string                `json:"token_id"`
-	RefreshToken                string                `json:"refresh_token,omitempty"`
-	LastTokenIssuedAtTimestamp  int64                 `json:"last_token_issued_at"`
-	CreatedAtTimestamp          int64                 `json:"created_at"`
-	ExpiresAtTimestamp          int64                 `json:"expires_at"`
-	ActiveScopes                string                `json:"active_scopes"`
-	GrantType                   GrantType             `json:"grant_type"`
-	Subject                     string                `json:"sub"`
-	ClientID                    string                `json:"client_id"`
-	GrantedScopes               string                `json:"granted_scopes"`
-	GrantedAuthorizationDetails []AuthorizationDetail `json:"granted_authorization_details,omitempty"`
-	AdditionalIDTokenClaims     map[string]any        `json:"additional_id_token_claims,omitempty"`
-	AdditionalUserInfoClaims    map[string]any        `json:"additional_user_info_claims,omitempty"`
-	TokenOptions
+	ID                          string `json:"id" bson:"_id"`
+	JWKThumbprint               string `json:"jwk_thumbprint,omitempty" bson:"jwk_thumbprint,omitempty"`
+	ClientCertificateThumbprint string `json:"certificate_thumbprint,omitempty" bson:"certificate_thumbprint,omitempty"`
+	TokenID                     string `json:"token_id" bson:"token_id,omitempty"`
+	RefreshToken                string `json:"refresh_token,omitempty" bson:"refresh_token,omitempty"`
+	// PreviousRefreshTokens are the refresh tokens consumed by rotations.
+	// Since the grant session is kept across rotations, its ID identifies the
+	// whole family of refresh tokens.
+	PreviousRefreshTokens       []string              `json:"previous_refresh_tokens,omitempty" bson:"previous_refresh_tokens,omitempty"`
+	LastTokenIssuedAtTimestamp  int64                 `json:"last_token_issued_at" bson:"last_token_issued_at"`
+	CreatedAtTimestamp          int64                 `json:"created_at" bson:"created_at"`
+	ExpiresAtTimestamp          int64                 `json:"expires_at" bson:"expires_at"`
+	ActiveScopes                string                `json:"active_scopes" bson:"active_scopes"`
+	ActiveAuthorizationDetails  []AuthorizationDetail `json:"active_authorization_details,omitempty" bson:"active_authorization_details,omitempty"`
+	GrantType                   GrantType             `json:"grant_type" bson:"grant_type"`
+	Subject                     string                `json:"sub" bson:"sub"`
+	ClientID                    string                `json:"client_id" bson:"client_id"`
+	GrantedScopes               string                `json:"granted_scopes" bson:"granted_scopes"`
+	GrantedAuthorizationDetails []AuthorizationDetail `json:"granted_authorization_details,omitempty" bson:"granted_authorization_details,omitempty"`
+	GrantedResources            Resources             `json:"granted_resources,omitempty" bson:"granted_resources,omitempty"`
+	SessionID                   string                `json:"sid,omitempty" bson:"sid,omitempty"`
+	AdditionalIDTokenClaims     map[string]any        `json:"additional_id_token_claims,omitempty" bson:"additional_id_token_claims,omitempty"`
+	AdditionalUserInfoClaims    map[string]any        `json:"additional_user_info_claims,omitempty" bson:"additional_user_info_claims,omitempty"`
+	TokenOptions                `bson:",inline"`
 }
 
 func (g *GrantSession) IsExpired() bool {
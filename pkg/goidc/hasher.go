@@ -0,0 +1,165 @@
+package goidc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SecretHasher hashes the client secrets and registration access tokens
+// before they are stored.
+type SecretHasher interface {
+	Hash(secret string) (string, error)
+	// Compare returns true if the secret matches the hashed secret.
+	Compare(hashedSecret, secret string) bool
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// BCryptHasher hashes secrets with bcrypt.
+// Note that bcrypt only considers the first 72 bytes of a secret.
+type BCryptHasher struct {
+	// Cost is the bcrypt cost. If not informed, bcrypt.DefaultCost is used.
+	Cost int
+}
+
+func (h BCryptHasher) Hash(secret string) (string, error) {
+	cost := h.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	hashedSecret, err := bcrypt.GenerateFromPassword([]byte(secret), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedSecret), nil
+}
+
+func (BCryptHasher) Compare(hashedSecret, secret string) bool {
+	return CompareSecret(hashedSecret, secret)
+}
+
+// Argon2idHasher hashes secrets with argon2id and encodes them in the PHC
+// string format, e.g. "$argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>".
+// Secrets hashed with bcrypt are still accepted by Compare, so the hasher can
+// be adopted without rotating the secrets already stored.
+// The parameters not informed default to the ones of NewArgon2idHasher.
+type Argon2idHasher struct {
+	// MemoryKiB is the amount of memory used in kibibytes.
+	MemoryKiB uint32
+	// Iterations is the number of passes over the memory.
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2idHasher returns an argon2id hasher with the parameters
+// recommended by RFC 9106.
+func NewArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{
+		MemoryKiB:   64 * 1024,
+		Iterations:  1,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func (h Argon2idHasher) Hash(secret string) (string, error) {
+	defaults := NewArgon2idHasher()
+	if h.MemoryKiB == 0 {
+		h.MemoryKiB = defaults.MemoryKiB
+	}
+	if h.Iterations == 0 {
+		h.Iterations = defaults.Iterations
+	}
+	if h.Parallelism == 0 {
+		h.Parallelism = defaults.Parallelism
+	}
+	if h.SaltLength == 0 {
+		h.SaltLength = defaults.SaltLength
+	}
+	if h.KeyLength == 0 {
+		h.KeyLength = defaults.KeyLength
+	}
+
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(secret), salt, h.Iterations, h.MemoryKiB, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		h.MemoryKiB,
+		h.Iterations,
+		h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (Argon2idHasher) Compare(hashedSecret, secret string) bool {
+	return CompareSecret(hashedSecret, secret)
+}
+
+// CompareSecret returns true if the secret matches the hashed secret.
+// The hashing algorithm is detected from the hash prefix, so both argon2id and
+// bcrypt hashes are supported.
+func CompareSecret(hashedSecret, secret string) bool {
+	if strings.HasPrefix(hashedSecret, argon2idPrefix) {
+		return compareArgon2id(hashedSecret, secret)
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hashedSecret), []byte(secret)) == nil
+}
+
+func compareArgon2id(hashedSecret, secret string) bool {
+	params, salt, key, err := parseArgon2id(hashedSecret)
+	if err != nil {
+		return false
+	}
+
+	otherKey := argon2.IDKey([]byte(secret), salt, params.Iterations, params.MemoryKiB, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1
+}
+
+func parseArgon2id(hashedSecret string) (Argon2idHasher, []byte, []byte, error) {
+	// The hash is split as ["", "argon2id", "v=19", "m=...,t=...,p=...", salt, key].
+	parts := strings.Split(hashedSecret, "$")
+	if len(parts) != 6 {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id version")
+	}
+
+	var params Argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id salt")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id key")
+	}
+
+	return params, salt, key, nil
+}
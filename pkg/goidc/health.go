@@ -0,0 +1,11 @@
+package goidc
+
+import "context"
+
+// Pinger is implemented by the stores that can report whether the service
+// backing them, e.g. a database, is reachable.
+// When health endpoints are enabled, the readiness endpoint pings the stores
+// implementing it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
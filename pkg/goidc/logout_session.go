@@ -0,0 +1,47 @@
+package goidc
+
+import (
+	"context"
+	"time"
+)
+
+type LogoutSessionManager interface {
+	Save(ctx context.Context, session *LogoutSession) error
+	GetByCallbackID(ctx context.Context, callbackID string) (*LogoutSession, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// LogoutSession holds the information of a logout request received at the
+// end session endpoint.
+// It's only saved if the logout is interrupted to interact with the user, so
+// it can be resumed at the logout callback endpoint.
+type LogoutSession struct {
+	ID string `json:"id"`
+	// CallbackID identifies the session when the logout is resumed.
+	CallbackID         string `json:"callback_id"`
+	ExpiresAtTimestamp int64  `json:"expires_at"`
+	// ClientID identifies the client that requested the logout, if known.
+	ClientID string `json:"client_id,omitempty"`
+	// Subject and SessionID are taken from the "id_token_hint", if informed.
+	Subject               string `json:"sub,omitempty"`
+	SessionID             string `json:"sid,omitempty"`
+	PostLogoutRedirectURI string `json:"post_logout_redirect_uri,omitempty"`
+	State                 string `json:"state,omitempty"`
+	// Store allows developers to store information between user interactions.
+	Store map[string]any `json:"store,omitempty"`
+}
+
+func (s *LogoutSession) IsExpired() bool {
+	return time.Now().Unix() > s.ExpiresAtTimestamp
+}
+
+func (s *LogoutSession) StoreParameter(key string, value any) {
+	if s.Store == nil {
+		s.Store = make(map[string]any)
+	}
+	s.Store[key] = value
+}
+
+func (s *LogoutSession) Parameter(key string) any {
+	return s.Store[key]
+}
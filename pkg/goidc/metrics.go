@@ -0,0 +1,13 @@
+package goidc
+
+import "time"
+
+// MetricsCollector records metrics about the requests handled by the server.
+type MetricsCollector interface {
+	// ObserveRequest records a request handled by the endpoint informed, e.g.
+	// "/token", along with the status code returned and how long it took.
+	ObserveRequest(endpoint string, statusCode int, duration time.Duration)
+	// ObserveGrant records the outcome of a token request. errorCode is empty
+	// when the tokens were issued.
+	ObserveGrant(grantType GrantType, errorCode string)
+}
@@ -2,9 +2,13 @@ package goidc
 
 import (
 	"encoding/json"
+	"fmt"
 	"maps"
 	"net/http"
 	"reflect"
+	"slices"
+
+	"github.com/go-jose/go-jose/v4"
 )
 
 type WrapHandlerFunc func(nextHandler http.Handler) http.Handler
@@ -13,6 +17,10 @@ type WrapHandlerFunc func(nextHandler http.Handler) http.Handler
 // It can be used to modify the client and perform custom validations.
 type DCRPluginFunc func(ctx Context, clientInfo *ClientMetaInfo)
 
+// InitialAccessTokenValidatorFunc defines a function that decides whether an initial access token
+// is valid to register a client when DCR is protected.
+type InitialAccessTokenValidatorFunc func(ctx Context, token string) bool
+
 type AuthorizeErrorPluginFunc func(ctx Context, err error) error
 
 var (
@@ -20,17 +28,47 @@ var (
 	ScopeProfile       = NewScope("profile")
 	ScopeEmail         = NewScope("email")
 	ScopeAddress       = NewScope("address")
+	ScopePhone         = NewScope("phone")
 	ScopeOfflineAccess = NewScope("offline_access")
 )
 
+// DefaultScopeClaims maps the standard OpenID scopes to the claims they
+// request as defined in OpenID Connect Core 1.0, Section 5.4.
+func DefaultScopeClaims() map[string][]string {
+	return map[string][]string{
+		ScopeProfile.ID: {
+			ClaimName, ClaimFamilyName, ClaimGivenName, ClaimMiddleName,
+			ClaimNickname, ClaimPreferredUsername, ClaimProfile, ClaimPicture,
+			ClaimWebsite, ClaimGender, ClaimBirthdate, ClaimZoneInfo,
+			ClaimLocale, ClaimUpdatedAt,
+		},
+		ScopeEmail.ID:   {ClaimEmail, ClaimEmailVerified},
+		ScopeAddress.ID: {ClaimAddress},
+		ScopePhone.ID:   {ClaimPhoneNumber, ClaimPhoneNumberVerified},
+	}
+}
+
+// ClaimResolverFunc returns the values of the claims informed for the user
+// identified by subject.
+// Claims without a value can be left out of the result.
+type ClaimResolverFunc func(ctx Context, subject string, claims []string) (map[string]any, error)
+
 type ScopeMatchingFunc func(requestedScope string) bool
 
+// ScopeCaptureFunc extracts structured data from a requested scope, e.g. the
+// amount 30 from "payment:30". The claims returned are added to the access
+// tokens issued for the scope.
+type ScopeCaptureFunc func(requestedScope string) map[string]any
+
 type Scope struct {
 	// ID is the string representation of the scope.
 	// Its value will be exported as is.
 	ID string
 	// Matches validates if a requested scope is valid.
 	Matches ScopeMatchingFunc
+	// Capture is optional and is called with the exact value requested by the
+	// client whenever it matches the scope.
+	Capture ScopeCaptureFunc
 }
 
 // NewScope creates a scope where the validation logic is simple string comparison.
@@ -55,6 +93,14 @@ func NewScope(scope string) Scope {
 //
 //	// This results in true.
 //	dynamicScope.Matches("payment:30")
+//
+// The value requested, "payment:30", is the one granted and set in the tokens.
+// Set Capture to also add the data it carries as token claims.
+//
+//	dynamicScope.Capture = func(requestedScope string) map[string]any {
+//		amount, _ := strings.CutPrefix(requestedScope, "payment:")
+//		return map[string]any{"payment_amount": amount}
+//	}
 func NewDynamicScope(
 	scope string,
 	matchingFunc ScopeMatchingFunc,
@@ -67,12 +113,54 @@ func NewDynamicScope(
 
 type TokenOptionsFunc func(client *Client, scopes string) (TokenOptions, error)
 
+// JWTBearerMappingFunc maps the subject of an assertion presented during the
+// JWT bearer grant to the subject the access token will be issued for.
+// If an error is returned, the request is rejected with "invalid_grant".
+type JWTBearerMappingFunc func(ctx Context, issuer, subject string) (string, error)
+
+// ScopePolicyFunc defines the scopes granted for the grant type based on the
+// ones requested, e.g. to never issue offline_access with client_credentials.
+// The scopes removed are informed back to the client in the token response.
+// If an error is returned, the request is rejected with invalid_scope.
+type ScopePolicyFunc func(ctx Context, grantType GrantType, client *Client, scopes string) (string, error)
+
+// ACRMatchFunc defines whether the authentication context reference achieved
+// by the user satisfies the one requested by the client, e.g. a server can
+// consider higher levels of assurance to satisfy lower ones.
+type ACRMatchFunc func(requested, achieved ACR) bool
+
+// TokenOptionsFuncV2 defines how access tokens are issued based on the grant
+// type and on the scopes and resources they will be issued for, so the token
+// format and lifetime can vary by audience and grant.
+//
+//	func(client *Client, grantType GrantType, scopes string, resources Resources) (TokenOptions, error) {
+//		if slices.Contains(resources, "https://internal.example.com") {
+//			return NewOpaqueTokenOptions(30, 60), nil
+//		}
+//		return NewJWTTokenOptions("signing_key", 600), nil
+//	}
+type TokenOptionsFuncV2 func(client *Client, grantType GrantType, scopes string, resources Resources) (TokenOptions, error)
+
 type TokenOptions struct {
-	TokenFormat           TokenFormat    `json:"token_format"`
-	TokenLifetimeSecs     int64          `json:"token_lifetime_secs"`
-	JWTSignatureKeyID     string         `json:"token_signature_key_id,omitempty"`
-	OpaqueTokenLength     int            `json:"opaque_token_length,omitempty"`
-	AdditionalTokenClaims map[string]any `json:"additional_token_claims,omitempty"`
+	TokenFormat           TokenFormat    `json:"token_format" bson:"token_format"`
+	TokenLifetimeSecs     int64          `json:"token_lifetime_secs" bson:"token_lifetime_secs"`
+	JWTSignatureKeyID     string         `json:"token_signature_key_id,omitempty" bson:"token_signature_key_id,omitempty"`
+	OpaqueTokenLength     int            `json:"opaque_token_length,omitempty" bson:"opaque_token_length,omitempty"`
+	AdditionalTokenClaims map[string]any `json:"additional_token_claims,omitempty" bson:"additional_token_claims,omitempty"`
+	// ConfirmationJWKIsEnabled makes DPoP bound JWT access tokens also carry the
+	// full public key of the client in the confirmation claim ("cnf.jwk"), besides
+	// its thumbprint ("cnf.jkt").
+	ConfirmationJWKIsEnabled bool `json:"confirmation_jwk_is_enabled,omitempty" bson:"confirmation_jwk_is_enabled,omitempty"`
+	// Audiences are added to the "aud" claim of JWT access tokens together
+	// with the resources granted, e.g. the client ID.
+	Audiences []string `json:"audiences,omitempty" bson:"audiences,omitempty"`
+	// AuthorizedPartyIsEnabled makes JWT access tokens carry the "azp" claim
+	// with the ID of the client the token was issued to.
+	AuthorizedPartyIsEnabled bool `json:"authorized_party_is_enabled,omitempty" bson:"authorized_party_is_enabled,omitempty"`
+	// IDTokenAudiences are added to the "aud" claim of ID tokens besides the
+	// client ID. When informed, the ID token carries the "azp" claim as
+	// required by OpenID Connect.
+	IDTokenAudiences []string `json:"id_token_audiences,omitempty" bson:"id_token_audiences,omitempty"`
 }
 
 func (to *TokenOptions) AddTokenClaims(claims map[string]any) {
@@ -82,6 +170,38 @@ func (to *TokenOptions) AddTokenClaims(claims map[string]any) {
 	maps.Copy(to.AdditionalTokenClaims, claims)
 }
 
+// WithOpaqueFormat returns a copy of the options issuing opaque tokens of the
+// length informed instead.
+func (to TokenOptions) WithOpaqueFormat(tokenLength int) TokenOptions {
+	to.TokenFormat = TokenFormatOpaque
+	to.OpaqueTokenLength = tokenLength
+	to.JWTSignatureKeyID = ""
+	return to
+}
+
+// WithJWTFormat returns a copy of the options issuing JWT tokens signed with
+// the key informed instead.
+func (to TokenOptions) WithJWTFormat(signatureKeyID string) TokenOptions {
+	to.TokenFormat = TokenFormatJWT
+	to.JWTSignatureKeyID = signatureKeyID
+	to.OpaqueTokenLength = 0
+	return to
+}
+
+// WithAudiences returns a copy of the options adding the audiences informed to
+// the "aud" claim of JWT access tokens and enabling the "azp" claim.
+func (to TokenOptions) WithAudiences(audiences ...string) TokenOptions {
+	to.Audiences = audiences
+	to.AuthorizedPartyIsEnabled = true
+	return to
+}
+
+// WithLifetime returns a copy of the options with the token lifetime informed.
+func (to TokenOptions) WithLifetime(tokenLifetimeSecs int64) TokenOptions {
+	to.TokenLifetimeSecs = tokenLifetimeSecs
+	return to
+}
+
 func NewJWTTokenOptions(
 	// signatureKeyID is the ID of a signing key present in the server JWKS.
 	signatureKeyID string,
@@ -105,6 +225,44 @@ func NewOpaqueTokenOptions(
 	}
 }
 
+// UserSessionFunc informs whether the user identified by subject still has an
+// active session with the server, e.g. by checking a session cookie.
+// It is used to re-authenticate users silently when "prompt=none" is requested
+// along with an "id_token_hint".
+type UserSessionFunc func(ctx Context, subject string) bool
+
+// LogoutFunc is executed at the end session endpoint before the sessions of
+// the user are ended, e.g. to ask the user to confirm the logout.
+// If it returns StatusSuccess, the logout proceeds. If it returns
+// StatusInProgress, the logout is interrupted, the session is saved and the
+// function is responsible for writing the response, e.g. with
+// Context.RenderHTML. The user can then be sent to the end session endpoint
+// followed by "/" and the session callback ID to resume the logout, in which
+// case the function is executed again with the saved session. If it returns
+// StatusFailure, the logout is denied.
+type LogoutFunc func(ctx Context, session *LogoutSession) AuthnStatus
+
+// TokenResponseCustomizeFunc returns custom fields to be added at the top level
+// of the token response, e.g. vendor extensions.
+// It is executed for all grant types after the token is issued. The standard
+// fields of the response such as "access_token" cannot be overridden.
+type TokenResponseCustomizeFunc func(ctx Context, client *Client, grantSession *GrantSession) map[string]any
+
+// TrustAnchor is an entity trusted by the server to vouch for the clients
+// participating in an OpenID federation.
+type TrustAnchor struct {
+	// EntityID is the identifier of the trust anchor, e.g. "https://federation.com".
+	EntityID string
+	// JWKS contains the public keys used by the trust anchor to sign its statements.
+	JWKS jose.JSONWebKeySet
+}
+
+// TrustChainFunc fetches the trust chain of the entity identified by entityID.
+// The chain must start with the entity configuration of the entity, followed by
+// the statements issued by its superiors and finish with the statement issued
+// by a trust anchor.
+type TrustChainFunc func(ctx Context, entityID string) ([]string, error)
+
 // AuthnFunc executes the user authentication logic.
 type AuthnFunc func(Context, *AuthnSession) AuthnStatus
 
@@ -145,6 +303,11 @@ type TokenInfo struct {
 	AdditionalTokenClaims       map[string]any
 }
 
+// ResourceServerAuthzFunc defines whether the client, usually a resource
+// server, can introspect a token issued to another client.
+// Clients can always introspect their own tokens.
+type ResourceServerAuthzFunc func(ctx Context, client *Client, info TokenInfo) bool
+
 func (info TokenInfo) MarshalJSON() ([]byte, error) {
 	if !info.IsActive {
 		return json.Marshal(map[string]any{
@@ -195,11 +358,17 @@ type AuthorizationParameters struct {
 	CodeChallenge        string                `json:"code_challenge,omitempty" bson:"code_challenge,omitempty"`
 	CodeChallengeMethod  CodeChallengeMethod   `json:"code_challenge_method,omitempty" bson:"code_challenge_method,omitempty"`
 	Prompt               PromptType            `json:"prompt,omitempty" bson:"prompt,omitempty"`
+	IDTokenHint          string                `json:"id_token_hint,omitempty" bson:"id_token_hint,omitempty"`
+	LoginHint            string                `json:"login_hint,omitempty" bson:"login_hint,omitempty"`
+	DPoPJWKThumbprint    string                `json:"dpop_jkt,omitempty" bson:"dpop_jkt,omitempty"`
 	MaxAuthnAgeSecs      *int                  `json:"max_age,omitempty" bson:"max_age,omitempty"`
 	Display              DisplayValue          `json:"display,omitempty" bson:"display,omitempty"`
 	ACRValues            string                `json:"acr_values,omitempty" bson:"acr_values,omitempty"`
+	UILocales            string                `json:"ui_locales,omitempty" bson:"ui_locales,omitempty"`
+	ClaimsLocales        string                `json:"claims_locales,omitempty" bson:"claims_locales,omitempty"`
 	Claims               *ClaimsObject         `json:"claims,omitempty" bson:"claims,omitempty"`
 	AuthorizationDetails []AuthorizationDetail `json:"authorization_details,omitempty" bson:"authorization_details,omitempty"`
+	Resources            Resources             `json:"resource,omitempty" bson:"resource,omitempty"`
 }
 
 func (insideParams AuthorizationParameters) Merge(outsideParams AuthorizationParameters) AuthorizationParameters {
@@ -213,16 +382,58 @@ func (insideParams AuthorizationParameters) Merge(outsideParams AuthorizationPar
 		CodeChallenge:        nonEmptyOrDefault(insideParams.CodeChallenge, outsideParams.CodeChallenge),
 		CodeChallengeMethod:  nonEmptyOrDefault(insideParams.CodeChallengeMethod, outsideParams.CodeChallengeMethod),
 		Prompt:               nonEmptyOrDefault(insideParams.Prompt, outsideParams.Prompt),
+		IDTokenHint:          nonEmptyOrDefault(insideParams.IDTokenHint, outsideParams.IDTokenHint),
+		LoginHint:            nonEmptyOrDefault(insideParams.LoginHint, outsideParams.LoginHint),
+		DPoPJWKThumbprint:    nonEmptyOrDefault(insideParams.DPoPJWKThumbprint, outsideParams.DPoPJWKThumbprint),
 		MaxAuthnAgeSecs:      nonEmptyOrDefault(insideParams.MaxAuthnAgeSecs, outsideParams.MaxAuthnAgeSecs),
 		Display:              nonEmptyOrDefault(insideParams.Display, outsideParams.Display),
 		ACRValues:            nonEmptyOrDefault(insideParams.ACRValues, outsideParams.ACRValues),
-		Claims:               nonNilOrDefault(insideParams.Claims, outsideParams.Claims),
+		UILocales:            nonEmptyOrDefault(insideParams.UILocales, outsideParams.UILocales),
+		ClaimsLocales:        nonEmptyOrDefault(insideParams.ClaimsLocales, outsideParams.ClaimsLocales),
+		Claims:               mergeClaims(insideParams.Claims, outsideParams.Claims),
 		AuthorizationDetails: nonNilOrDefault(insideParams.AuthorizationDetails, outsideParams.AuthorizationDetails),
+		Resources:            nonNilOrDefault(insideParams.Resources, outsideParams.Resources),
 	}
 
 	return params
 }
 
+// mergeClaims unions the claims requested inside and outside a request object
+// or a pushed authorization request.
+// When the same claim is requested in both, the inside request prevails.
+func mergeClaims(insideClaims *ClaimsObject, outsideClaims *ClaimsObject) *ClaimsObject {
+	if insideClaims == nil {
+		return outsideClaims
+	}
+
+	if outsideClaims == nil {
+		return insideClaims
+	}
+
+	return &ClaimsObject{
+		UserInfo: mergeClaimMaps(insideClaims.UserInfo, outsideClaims.UserInfo),
+		IDToken:  mergeClaimMaps(insideClaims.IDToken, outsideClaims.IDToken),
+	}
+}
+
+func mergeClaimMaps(
+	insideClaims map[string]ClaimObjectInfo,
+	outsideClaims map[string]ClaimObjectInfo,
+) map[string]ClaimObjectInfo {
+	if insideClaims == nil && outsideClaims == nil {
+		return nil
+	}
+
+	claims := make(map[string]ClaimObjectInfo, len(insideClaims)+len(outsideClaims))
+	for claim, info := range outsideClaims {
+		claims[claim] = info
+	}
+	for claim, info := range insideClaims {
+		claims[claim] = info
+	}
+	return claims
+}
+
 func nonEmptyOrDefault[T any](s1 T, s2 T) T {
 	if reflect.ValueOf(s1).String() == "" {
 		return s2
@@ -239,6 +450,39 @@ func nonNilOrDefault[T any](s1 T, s2 T) T {
 	return s1
 }
 
+// Resources represents the resource indicators a client can request as defined in RFC 8707.
+// When informed as JSON, e.g. inside a request object, the value can be either a single string or an array of strings.
+type Resources []string
+
+func (resources *Resources) UnmarshalJSON(data []byte) error {
+	var resource string
+	if err := json.Unmarshal(data, &resource); err == nil {
+		*resources = []string{resource}
+		return nil
+	}
+
+	var resourceSlice []string
+	if err := json.Unmarshal(data, &resourceSlice); err != nil {
+		return err
+	}
+	*resources = resourceSlice
+	return nil
+}
+
+// ResourceValidationFunc defines whether the client can request the resources.
+// If an error is returned, the request is rejected with "invalid_target".
+type ResourceValidationFunc func(ctx Context, client *Client, resources Resources) error
+
+// ContainsAll returns true if all the resources informed are present.
+func (resources Resources) ContainsAll(other Resources) bool {
+	for _, resource := range other {
+		if !slices.Contains(resources, resource) {
+			return false
+		}
+	}
+	return true
+}
+
 type ClaimsObject struct {
 	UserInfo map[string]ClaimObjectInfo `json:"userinfo"`
 	IDToken  map[string]ClaimObjectInfo `json:"id_token"`
@@ -289,10 +533,30 @@ type ClaimObjectInfo struct {
 	Values      []string `json:"values"`
 }
 
+// Allows returns whether the value honors the "value" and "values" constraints
+// requested for the claim, if any.
+func (info ClaimObjectInfo) Allows(value any) bool {
+	v := fmt.Sprint(value)
+	if info.Value != "" && v != info.Value {
+		return false
+	}
+
+	if len(info.Values) != 0 && !slices.Contains(info.Values, v) {
+		return false
+	}
+
+	return true
+}
+
 // Authorization details is a map instead of a struct, because its fields vary a lot depending on the use case.
 // Some fields are well know so they are accessible as methods.
 type AuthorizationDetail map[string]any
 
+// AuthorizationDetailValidationFunc validates the fields of an authorization
+// detail of a given type, e.g. a payment detail may require an amount.
+// If an error is returned, the request is rejected with "invalid_authorization_details".
+type AuthorizationDetailValidationFunc func(ctx Context, detail AuthorizationDetail) error
+
 func (detail AuthorizationDetail) Type() string {
 	return detail.string("type")
 }
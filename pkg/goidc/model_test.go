@@ -1,10 +1,12 @@
 package goidc_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/luikyv/go-oidc/pkg/goidc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAddTokenClaims_HappyPath(t *testing.T) {
@@ -26,6 +28,50 @@ func TestAddTokenClaims_HappyPath(t *testing.T) {
 	assert.Equal(t, "value", tokenOptions.AdditionalTokenClaims["claim"], "the claim was not added")
 }
 
+func TestTokenOptions_FormatHelpers(t *testing.T) {
+	// Given.
+	jwtOpts := goidc.NewJWTTokenOptions("signing_key", 600)
+
+	// When.
+	opaqueOpts := jwtOpts.WithOpaqueFormat(30).WithLifetime(60)
+
+	// Then.
+	assert.Equal(t, goidc.TokenFormatOpaque, opaqueOpts.TokenFormat)
+	assert.Equal(t, 30, opaqueOpts.OpaqueTokenLength)
+	assert.Empty(t, opaqueOpts.JWTSignatureKeyID)
+	assert.Equal(t, int64(60), opaqueOpts.TokenLifetimeSecs)
+	assert.Equal(t, goidc.TokenFormatJWT, jwtOpts.TokenFormat, "the original options should not be modified")
+
+	// When.
+	jwtOpts = opaqueOpts.WithJWTFormat("another_signing_key")
+
+	// Then.
+	assert.Equal(t, goidc.TokenFormatJWT, jwtOpts.TokenFormat)
+	assert.Equal(t, "another_signing_key", jwtOpts.JWTSignatureKeyID)
+	assert.Zero(t, jwtOpts.OpaqueTokenLength)
+	assert.Equal(t, int64(60), jwtOpts.TokenLifetimeSecs)
+}
+
+func TestClaimObjectInfo_Allows(t *testing.T) {
+	testCases := []struct {
+		info    goidc.ClaimObjectInfo
+		value   any
+		allowed bool
+	}{
+		{goidc.ClaimObjectInfo{}, "random_value", true},
+		{goidc.ClaimObjectInfo{Value: "random_value"}, "random_value", true},
+		{goidc.ClaimObjectInfo{Value: "random_value"}, "another_value", false},
+		{goidc.ClaimObjectInfo{Values: []string{"acr1", "acr2"}}, goidc.ACR("acr2"), true},
+		{goidc.ClaimObjectInfo{Values: []string{"acr1", "acr2"}}, goidc.ACR("acr3"), false},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			assert.Equal(t, testCase.allowed, testCase.info.Allows(testCase.value))
+		})
+	}
+}
+
 func TestAuthorizationParameters_Merge_HappyPath(t *testing.T) {
 	// Given.
 	insideParams := goidc.AuthorizationParameters{
@@ -50,6 +96,59 @@ func TestAuthorizationParameters_Merge_HappyPath(t *testing.T) {
 	assert.NotNil(t, mergedParams.Claims, "the claims are not as expected")
 }
 
+func TestAuthorizationParameters_Merge_Claims(t *testing.T) {
+	// Given.
+	insideParams := goidc.AuthorizationParameters{
+		Claims: &goidc.ClaimsObject{
+			UserInfo: map[string]goidc.ClaimObjectInfo{
+				"email": {IsEssential: true},
+			},
+			IDToken: map[string]goidc.ClaimObjectInfo{
+				goidc.ClaimAuthenticationContextReference: {Value: "acr1"},
+			},
+		},
+	}
+	outsideParams := goidc.AuthorizationParameters{
+		Claims: &goidc.ClaimsObject{
+			UserInfo: map[string]goidc.ClaimObjectInfo{
+				"email":        {},
+				"phone_number": {},
+			},
+			IDToken: map[string]goidc.ClaimObjectInfo{
+				goidc.ClaimAuthenticationContextReference: {Value: "acr2"},
+				"name": {IsEssential: true},
+			},
+		},
+	}
+
+	// When.
+	mergedParams := insideParams.Merge(outsideParams)
+
+	// Then.
+	require.NotNil(t, mergedParams.Claims)
+	assert.Equal(t, map[string]goidc.ClaimObjectInfo{
+		"email":        {IsEssential: true},
+		"phone_number": {},
+	}, mergedParams.Claims.UserInfo)
+	assert.Equal(t, map[string]goidc.ClaimObjectInfo{
+		goidc.ClaimAuthenticationContextReference: {Value: "acr1"},
+		"name": {IsEssential: true},
+	}, mergedParams.Claims.IDToken)
+}
+
+func TestAuthorizationParameters_Merge_ClaimsInformedOnlyOutside(t *testing.T) {
+	// Given.
+	outsideClaims := &goidc.ClaimsObject{
+		UserInfo: map[string]goidc.ClaimObjectInfo{"email": {}},
+	}
+
+	// When.
+	mergedParams := goidc.AuthorizationParameters{}.Merge(goidc.AuthorizationParameters{Claims: outsideClaims})
+
+	// Then.
+	assert.Equal(t, outsideClaims, mergedParams.Claims)
+}
+
 func TestAuthorizationDetail_GetProperties_HappyPath(t *testing.T) {
 	// Given.
 	authDetails := goidc.AuthorizationDetail{
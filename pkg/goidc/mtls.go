@@ -0,0 +1,32 @@
+package goidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+)
+
+// CertificateThumbprint returns the base64url-encoded SHA-256 hash of the DER
+// encoding of the certificate, which is the value of the "x5t#S256"
+// confirmation of certificate-bound tokens as defined in RFC 8705.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// ValidateTLSCertificateBinding validates the certificate presented matches
+// the "x5t#S256" thumbprint a token is bound to.
+// This allows resource servers to enforce certificate-bound tokens.
+func ValidateTLSCertificateBinding(cert *x509.Certificate, thumbprint string) error {
+	if cert == nil {
+		return errors.New("the client certificate is required")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(CertificateThumbprint(cert)), []byte(thumbprint)) != 1 {
+		return errors.New("the client certificate doesn't match the token binding")
+	}
+
+	return nil
+}
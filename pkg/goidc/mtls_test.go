@@ -0,0 +1,22 @@
+package goidc_test
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTLSCertificateBinding(t *testing.T) {
+	// Given.
+	cert := &x509.Certificate{Raw: []byte("random_certificate")}
+	thumbprint := goidc.CertificateThumbprint(cert)
+
+	// Then.
+	assert.Nil(t, goidc.ValidateTLSCertificateBinding(cert, thumbprint))
+	assert.NotNil(t, goidc.ValidateTLSCertificateBinding(cert, "invalid_thumbprint"))
+	assert.NotNil(t, goidc.ValidateTLSCertificateBinding(nil, thumbprint),
+		"the certificate is required")
+	assert.NotNil(t, goidc.ValidateTLSCertificateBinding(&x509.Certificate{Raw: []byte("another_certificate")}, thumbprint))
+}
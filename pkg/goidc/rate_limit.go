@@ -0,0 +1,13 @@
+package goidc
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimiter throttles requests identified by a key, e.g. a client ID.
+type RateLimiter interface {
+	// Allow reports whether a request identified by key can be processed now.
+	// If not, it also returns how long the caller should wait before retrying.
+	Allow(ctx context.Context, key string) (bool, time.Duration)
+}
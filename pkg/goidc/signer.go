@@ -0,0 +1,107 @@
+package goidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Signer signs payloads with a private key that is never exposed to the
+// provider, e.g. a key kept in a HSM or in a KMS.
+// The key ID and the public key published in the JWKS are taken from Public.
+type Signer interface {
+	jose.OpaqueSigner
+}
+
+// NewSigner adapts a crypto.Signer, which most HSM and KMS libraries implement,
+// to a Signer that signs with the algorithm informed.
+// Only the RS, PS and ES families of algorithms are supported.
+func NewSigner(keyID string, alg jose.SignatureAlgorithm, signer crypto.Signer) Signer {
+	return cryptoSigner{
+		publicJWK: jose.JSONWebKey{
+			Key:       signer.Public(),
+			KeyID:     keyID,
+			Algorithm: string(alg),
+			Use:       string(KeyUsageSignature),
+		},
+		alg:    alg,
+		signer: signer,
+	}
+}
+
+type cryptoSigner struct {
+	publicJWK jose.JSONWebKey
+	alg       jose.SignatureAlgorithm
+	signer    crypto.Signer
+}
+
+func (s cryptoSigner) Public() *jose.JSONWebKey {
+	jwk := s.publicJWK
+	return &jwk
+}
+
+func (s cryptoSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{s.alg}
+}
+
+func (s cryptoSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if alg != s.alg {
+		return nil, jose.ErrUnsupportedAlgorithm
+	}
+
+	var hash crypto.Hash
+	switch alg {
+	case jose.RS256, jose.PS256, jose.ES256:
+		hash = crypto.SHA256
+	case jose.RS384, jose.PS384, jose.ES384:
+		hash = crypto.SHA384
+	case jose.RS512, jose.PS512, jose.ES512:
+		hash = crypto.SHA512
+	default:
+		return nil, jose.ErrUnsupportedAlgorithm
+	}
+
+	hasher := hash.New()
+	_, _ = hasher.Write(payload)
+	digest := hasher.Sum(nil)
+
+	var opts crypto.SignerOpts = hash
+	if alg == jose.PS256 || alg == jose.PS384 || alg == jose.PS512 {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+
+	signature, err := s.signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := s.signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return signature, nil
+	}
+
+	// crypto.Signer implementations return ECDSA signatures ASN.1 encoded,
+	// but JWS requires the concatenation of r and s as described in RFC 7518.
+	return ecdsaSignatureToJWS(signature, publicKey)
+}
+
+func ecdsaSignatureToJWS(signature []byte, publicKey *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		return nil, errors.New("invalid ecdsa signature")
+	}
+
+	keySize := (publicKey.Curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*keySize)
+	sig.R.FillBytes(out[:keySize])
+	sig.S.FillBytes(out[keySize:])
+	return out, nil
+}
@@ -1,7 +1,21 @@
 package provider
 
+import (
+	"crypto/tls"
+	"time"
+)
+
 const (
+	defaultTLSMinVersion                    = tls.VersionTLS12
 	defaultAuthenticationSessionTimeoutSecs = 30 * 60
 	defaultIDTokenLifetimeSecs              = 600
 	defaultTokenLifetimeSecs                = 300
+	defaultEntityStatementLifetimeSecs      = 24 * 60 * 60
+	defaultClockSkewToleranceSecs           = 10
+	// defaultIssuerJWKSCacheTTL is how long the keys of a trusted issuer are
+	// kept before being fetched again.
+	defaultIssuerJWKSCacheTTL = 5 * time.Minute
+	// defaultIssuerJWKSRefetchInterval limits how often the keys of a trusted
+	// issuer are fetched again when a JWT is signed with an unknown key.
+	defaultIssuerJWKSRefetchInterval = 30 * time.Second
 )
@@ -3,7 +3,11 @@ package provider
 import (
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/pkg/goidc"
 )
 
@@ -46,3 +50,89 @@ func (handler clientCertificateMiddleware) ServeHTTP(w http.ResponseWriter, r *h
 	r.Header.Set(goidc.HeaderClientCertificate, string(r.TLS.PeerCertificates[0].Raw)) // TODO: Must encode it. Generate pem version.
 	handler.nextHandler.ServeHTTP(w, r)
 }
+
+// correlationIDMiddleware makes sure every request carries a correlation ID
+// in the header informed, so it can be attached to the logs, and echoes it in
+// the response.
+type correlationIDMiddleware struct {
+	nextHandler http.Handler
+	header      string
+}
+
+func newCorrelationIDMiddleware(next http.Handler, header string) http.Handler {
+	if header == "" {
+		return next
+	}
+
+	return correlationIDMiddleware{
+		nextHandler: next,
+		header:      header,
+	}
+}
+
+func (handler correlationIDMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	correlationID := r.Header.Get(handler.header)
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+		r.Header.Set(handler.header, correlationID)
+	}
+	w.Header().Set(handler.header, correlationID)
+	handler.nextHandler.ServeHTTP(w, r)
+}
+
+// configSnapshotMiddleware makes each request be handled with a copy of the
+// configuration taken when it arrives, so the configuration can be updated
+// while requests are in flight.
+// The lock is only held while copying, so handlers can update the
+// configuration themselves without deadlocking.
+type configSnapshotMiddleware struct {
+	nextHandler http.Handler
+	config      *oidc.Configuration
+	mu          *sync.RWMutex
+}
+
+func newConfigSnapshotMiddleware(
+	next http.Handler,
+	config *oidc.Configuration,
+	mu *sync.RWMutex,
+) configSnapshotMiddleware {
+	return configSnapshotMiddleware{
+		nextHandler: next,
+		config:      config,
+		mu:          mu,
+	}
+}
+
+func (handler configSnapshotMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler.mu.RLock()
+	config := *handler.config
+	handler.mu.RUnlock()
+	handler.nextHandler.ServeHTTP(w, oidc.WithConfiguration(r, config))
+}
+
+// instrument records the latency and the status code of the requests handled
+// by handler if metrics are enabled.
+func (p *Provider) instrument(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	collector := p.config.MetricsCollector
+	if collector == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		handler(recorder, r)
+		collector.ObserveRequest(endpoint, recorder.statusCode, time.Since(start))
+	}
+}
+
+// statusRecorder keeps the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
@@ -1,15 +1,24 @@
 package provider
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"log/slog"
 	"net/http"
 	"slices"
+	"sync"
+	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/luikyv/go-oidc/internal/authorize"
 	"github.com/luikyv/go-oidc/internal/dcr"
 	"github.com/luikyv/go-oidc/internal/discovery"
+	"github.com/luikyv/go-oidc/internal/health"
+	"github.com/luikyv/go-oidc/internal/jwksutil"
+	"github.com/luikyv/go-oidc/internal/logout"
+	"github.com/luikyv/go-oidc/internal/metrics"
 	"github.com/luikyv/go-oidc/internal/oidc"
 	"github.com/luikyv/go-oidc/internal/token"
 	"github.com/luikyv/go-oidc/internal/userinfo"
@@ -20,6 +29,9 @@ type ProviderOption func(p *Provider)
 
 type Provider struct {
 	config oidc.Configuration
+	// mu protects the configuration from being read while it's modified
+	// during runtime, e.g. when keys are rotated.
+	mu *sync.RWMutex
 }
 
 // New creates a new openid provider.
@@ -37,14 +49,17 @@ func New(
 	// TODO: Get the first signing key as the default key.
 
 	p := &Provider{
+		mu: &sync.RWMutex{},
 		config: oidc.Configuration{
-			Host:                issuer,
-			Profile:             goidc.ProfileOpenID,
-			ClientManager:       NewInMemoryClientManager(),
-			AuthnSessionManager: NewInMemoryAuthnSessionManager(),
-			GrantSessionManager: NewInMemoryGrantSessionManager(),
-			Scopes:              []goidc.Scope{goidc.ScopeOpenID},
-			TokenOptions: func(client *goidc.Client, scopes string) (goidc.TokenOptions, error) {
+			Host:                 issuer,
+			Profile:              goidc.ProfileOpenID,
+			ClientManager:        NewInMemoryClientManager(),
+			AuthnSessionManager:  NewInMemoryAuthnSessionManager(),
+			GrantSessionManager:  NewInMemoryGrantSessionManager(),
+			DeviceSessionManager: NewInMemoryDeviceSessionManager(),
+			LogoutSessionManager: NewInMemoryLogoutSessionManager(),
+			Scopes:               []goidc.Scope{goidc.ScopeOpenID},
+			TokenOptions: func(client *goidc.Client, grantType goidc.GrantType, scopes string, resources goidc.Resources) (goidc.TokenOptions, error) {
 				return goidc.NewJWTTokenOptions(defaultSignatureKeyID, defaultTokenLifetimeSecs), nil
 			},
 			PrivateJWKS:                   privateJWKS,
@@ -62,10 +77,23 @@ func New(
 				goidc.ResponseModeFragment,
 				goidc.ResponseModeFormPost,
 			},
+			PromptValues: []goidc.PromptType{
+				goidc.PromptTypeNone,
+				goidc.PromptTypeLogin,
+				goidc.PromptTypeConsent,
+				goidc.PromptTypeSelectAccount,
+			},
 			ClientAuthnMethods:               []goidc.ClientAuthnType{},
 			SubjectIdentifierTypes:           []goidc.SubjectIdentifierType{goidc.SubjectIdentifierPublic},
 			ClaimTypes:                       []goidc.ClaimType{goidc.ClaimTypeNormal},
+			EssentialClaimsPolicy:            goidc.EssentialClaimsPolicyBestEffort,
 			AuthenticationSessionTimeoutSecs: defaultAuthenticationSessionTimeoutSecs,
+			DCRMode:                          goidc.DCRModeOpen,
+			ClockSkewToleranceSecs:           defaultClockSkewToleranceSecs,
+			SecretHasher:                     goidc.BCryptHasher{},
+			RedirectURIMatchingMode:          goidc.RedirectURIMatchingExact,
+			CorrelationIDHeader:              goidc.HeaderCorrelationID,
+			IssuerJWKSCache:                  jwksutil.NewCache(defaultIssuerJWKSCacheTTL, defaultIssuerJWKSRefetchInterval),
 		},
 	}
 
@@ -73,6 +101,13 @@ func New(
 		opt(p)
 	}
 
+	// The code challenge methods depend on more than one PKCE option, so they
+	// are only defined once all the options are applied.
+	if p.config.PkceS256IsRequired ||
+		(p.config.PkceIsRequiredForPublicClients && len(p.config.CodeChallengeMethods) == 0) {
+		p.config.CodeChallengeMethods = []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256}
+	}
+
 	if err := p.validateConfiguration(); err != nil {
 		return nil, err
 	}
@@ -92,18 +127,79 @@ func WithStorage(
 	}
 }
 
+// WithDeviceSessionStorage defines where device sessions are stored when the
+// device grant is enabled. By default, they are stored in memory.
+func WithDeviceSessionStorage(deviceSessionManager goidc.DeviceSessionManager) ProviderOption {
+	return func(p *Provider) {
+		p.config.DeviceSessionManager = deviceSessionManager
+	}
+}
+
+// WithLogoutSessionStorage defines where logout sessions are stored when
+// RP-Initiated Logout is enabled. By default, they are stored in memory.
+func WithLogoutSessionStorage(logoutSessionManager goidc.LogoutSessionManager) ProviderOption {
+	return func(p *Provider) {
+		p.config.LogoutSessionManager = logoutSessionManager
+	}
+}
+
 func WithPathPrefix(prefix string) ProviderOption {
 	return func(p *Provider) {
 		p.config.PathPrefix = prefix
 	}
 }
 
+// WithClaimResolver makes the userinfo endpoint return the claims associated
+// to the scopes granted, whose values are fetched with resolverFunc.
+// The standard OpenID scopes are mapped to their claims by default and other
+// scopes can be mapped with WithScopeClaims.
+func WithClaimResolver(resolverFunc goidc.ClaimResolverFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.ClaimResolverFunc = resolverFunc
+		if p.config.ScopeClaims == nil {
+			p.config.ScopeClaims = goidc.DefaultScopeClaims()
+		}
+	}
+}
+
+// WithScopeClaims maps scope to the user claims it grants access to,
+// overriding the default mapping if the scope is a standard one.
+func WithScopeClaims(scope string, claims ...string) ProviderOption {
+	return func(p *Provider) {
+		if p.config.ScopeClaims == nil {
+			p.config.ScopeClaims = goidc.DefaultScopeClaims()
+		}
+		p.config.ScopeClaims[scope] = claims
+	}
+}
+
 func WithUserClaims(claims ...string) ProviderOption {
 	return func(p *Provider) {
 		p.config.UserClaims = claims
 	}
 }
 
+// WithSigners adds signing keys whose private part is kept outside the
+// provider, e.g. in a HSM or in a KMS. The signers are referenced by the key ID
+// of their public key just like the keys in the private JWKS, so one of them
+// can be the default signature key informed to New.
+// Only the public keys of the signers are published in the JWKS endpoint.
+func WithSigners(signers ...goidc.Signer) ProviderOption {
+	return func(p *Provider) {
+		keys := slices.Clone(p.config.PrivateJWKS.Keys)
+		for _, signer := range signers {
+			publicJWK := signer.Public()
+			keys = append(keys, jose.JSONWebKey{
+				Key:       signer,
+				KeyID:     publicJWK.KeyID,
+				Algorithm: publicJWK.Algorithm,
+				Use:       string(goidc.KeyUsageSignature),
+			})
+		}
+		p.config.PrivateJWKS = jose.JSONWebKeySet{Keys: keys}
+	}
+}
+
 // WithUserInfoSignatureKeyIDs makes more keys available to sign the user info endpoint response and ID tokens.
 // There should be at most one per algorithm, in other words, there shouldn't be two key IDs that point to two keys that have the same algorithm.
 // This is because clients can choose signing keys per algorithm, e.g. a client can choose the key to sign its ID tokens with the attribute "id_token_signed_response_alg".
@@ -116,12 +212,99 @@ func WithUserInfoSignatureKeyIDs(userInfoSignatureKeyIDs ...string) ProviderOpti
 	}
 }
 
+// WithIDTokenSymmetricSignature allows clients that authenticate with a secret to
+// request their ID tokens to be signed with it using one of the HMAC algorithms
+// informed. If no algorithm is informed, HS256 is used.
+func WithIDTokenSymmetricSignature(signatureAlgorithms ...jose.SignatureAlgorithm) ProviderOption {
+	return func(p *Provider) {
+		if len(signatureAlgorithms) == 0 {
+			signatureAlgorithms = []jose.SignatureAlgorithm{jose.HS256}
+		}
+		p.config.IDTokenSymmetricSignatureAlgorithms = signatureAlgorithms
+	}
+}
+
+// WithSilentAuthn makes the server answer authorization requests with "prompt=none"
+// and a valid "id_token_hint" without user interaction.
+// userSessionFunc decides whether the user identified by the hint still has an active
+// session. If not, the request is rejected with "login_required".
+// The authentication policy is still executed with the subject already set, so
+// it decides what is granted, but it must not require user interaction.
+func WithSilentAuthn(userSessionFunc goidc.UserSessionFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.SilentAuthnIsEnabled = true
+		p.config.UserSessionFunc = userSessionFunc
+	}
+}
+
+// WithAccessTokenTypeRequired makes the server reject JWT access tokens whose
+// header "typ" is not "at+jwt" as defined in RFC 9068.
+// This prevents other JWTs signed by the server from being accepted as access tokens.
+func WithAccessTokenTypeRequired() ProviderOption {
+	return func(p *Provider) {
+		p.config.AccessTokenTypeIsRequired = true
+	}
+}
+
+// WithTokenResponseCustomization allows adding custom fields to the token
+// response of all grant types.
+// The standard fields are always kept and cannot be overridden.
+func WithTokenResponseCustomization(customizeFunc goidc.TokenResponseCustomizeFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.TokenResponseCustomizeFunc = customizeFunc
+	}
+}
+
 func WithIDTokenLifetime(idTokenLifetimeSecs int64) ProviderOption {
 	return func(p *Provider) {
 		p.config.IDTokenExpiresInSecs = idTokenLifetimeSecs
 	}
 }
 
+// WithSIDClaim adds the "sid" claim to ID tokens identifying the session of the user.
+// By default, a random session ID is generated for each authentication, but policies can
+// set their own with AuthnSession.SetSessionID.
+func WithSIDClaim() ProviderOption {
+	return func(p *Provider) {
+		p.config.SIDClaimIsEnabled = true
+	}
+}
+
+// WithBackChannelLogout allows the server to notify clients when the session
+// of a user ends as described in OpenID Connect Back-Channel Logout 1.0.
+// Clients are notified with Provider.NotifyLogout at the URI they registered
+// as "backchannel_logout_uri".
+// When used along with WithSIDClaim, logout tokens can target a single session
+// of the user.
+// The grant session manager must implement goidc.GrantSessionSubjectFinder.
+func WithBackChannelLogout() ProviderOption {
+	return func(p *Provider) {
+		p.config.BackChannelLogoutIsEnabled = true
+	}
+}
+
+// WithRPInitiatedLogout enables the end session endpoint, so clients can log
+// users out as described in OpenID Connect RP-Initiated Logout 1.0.
+// Users are sent back to the client only if the "post_logout_redirect_uri"
+// matches one of the URIs the client registered.
+// logoutFunc is optional and can be used to ask the user to confirm the logout
+// before their sessions are ended.
+func WithRPInitiatedLogout(logoutFunc goidc.LogoutFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.EndSessionIsEnabled = true
+		p.config.LogoutFunc = logoutFunc
+	}
+}
+
+// WithIDTokenSuppressed prevents ID tokens from being issued, even when the openid scope is granted.
+// This is useful when the server is used as a pure OAuth authorization server.
+// Response types containing "id_token" cannot be used along with this option.
+func WithIDTokenSuppressed() ProviderOption {
+	return func(p *Provider) {
+		p.config.IDTokenIsSuppressed = true
+	}
+}
+
 // WithUserInfoEncryption allows encryption of ID tokens and of the user info endpoint response.
 func WithUserInfoEncryption(
 	keyEncryptionAlgorithms []jose.KeyAlgorithm,
@@ -160,6 +343,81 @@ func WithDCR(
 	}
 }
 
+// WithClientAuthnSignatureAlgorithmRequired makes clients authenticating with private_key_jwt or
+// client_secret_jwt register the algorithm they use to sign assertions ("token_endpoint_auth_signing_alg").
+// Assertions signed with any other algorithm are rejected.
+func WithClientAuthnSignatureAlgorithmRequired() ProviderOption {
+	return func(p *Provider) {
+		p.config.ClientAuthnSignatureAlgorithmIsRequired = true
+	}
+}
+
+// WithAssertionAudienceMode restricts the audiences accepted in client assertions
+// during private_key_jwt and client_secret_jwt.
+// By default, both the issuer and the URL of the endpoint requested are accepted.
+func WithAssertionAudienceMode(mode goidc.AssertionAudienceMode) ProviderOption {
+	return func(p *Provider) {
+		p.config.AssertionAudienceMode = mode
+	}
+}
+
+// WithClockSkewTolerance defines how many seconds the clocks of clients can be
+// ahead or behind the server's when validating the time claims of request
+// objects, DPoP proofs and assertions. The default is 10 seconds.
+func WithClockSkewTolerance(toleranceSecs int64) ProviderOption {
+	return func(p *Provider) {
+		p.config.ClockSkewToleranceSecs = toleranceSecs
+	}
+}
+
+// WithDCRMode defines how clients can register themselves when DCR is enabled.
+// By default, the registration is open and no initial access token is required.
+// When the mode is protected, the initial access tokens must be informed with
+// either WithDCRInitialAccessTokens or WithDCRInitialAccessTokenValidator.
+func WithDCRMode(mode goidc.DCRMode) ProviderOption {
+	return func(p *Provider) {
+		p.config.DCRMode = mode
+	}
+}
+
+// WithDCRProfile allows clients to choose a profile stricter than the server's
+// during DCR, e.g. FAPI 2.0 on a server that uses OpenID.
+// By default, clients can only register the profile of the server, so the
+// profile of the others must be set by the operator, e.g. with the DCR plugin.
+func WithDCRProfile() ProviderOption {
+	return func(p *Provider) {
+		p.config.DCRProfileIsEnabled = true
+	}
+}
+
+// WithDCRInitialAccessTokens protects the registration of clients with a set of initial access tokens.
+func WithDCRInitialAccessTokens(tokens ...string) ProviderOption {
+	return func(p *Provider) {
+		p.config.DCRMode = goidc.DCRModeProtected
+		p.config.DCRInitialAccessTokens = tokens
+	}
+}
+
+// WithDCRInitialAccessTokenValidator protects the registration of clients with initial access tokens
+// that are validated by the function informed.
+func WithDCRInitialAccessTokenValidator(validator goidc.InitialAccessTokenValidatorFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.DCRMode = goidc.DCRModeProtected
+		p.config.DCRInitialAccessTokenValidator = validator
+	}
+}
+
+// WithDCRMetadataLimits limits how many values clients can register for some of their metadata.
+// Limits set to zero are not enforced.
+func WithDCRMetadataLimits(limits DCRMetadataLimits) ProviderOption {
+	return func(p *Provider) {
+		p.config.DCRMaxRedirectURIs = limits.RedirectURIs
+		p.config.DCRMaxGrantTypes = limits.GrantTypes
+		p.config.DCRMaxResponseTypes = limits.ResponseTypes
+		p.config.DCRMaxAuthorizationDetailTypes = limits.AuthorizationDetailTypes
+	}
+}
+
 // WithRefreshTokenGrant makes available the refresh token grant.
 // If set to true, shouldRotateTokens will cause a new refresh token to be issued each time
 // one is used. The one used during the request then becomes invalid.
@@ -174,6 +432,126 @@ func WithRefreshTokenGrant(
 	}
 }
 
+// WithRefreshTokenRotation makes a new refresh token to be issued each time one
+// is used. The one used during the request then becomes invalid.
+// If detectReuse is true, presenting a refresh token that was already rotated
+// is treated as a replay and revokes the grant it belongs to, i.e. the current
+// refresh token and the access tokens issued with it.
+// This option must be used along with WithRefreshTokenGrant.
+func WithRefreshTokenRotation(detectReuse bool) ProviderOption {
+	return func(p *Provider) {
+		p.config.ShouldRotateRefreshTokens = true
+		p.config.RefreshTokenReuseDetectionIsEnabled = detectReuse
+	}
+}
+
+// WithDeviceGrant makes available the device authorization grant as defined in RFC 8628.
+// Devices start the flow at the /device_authorization endpoint and users approve them at
+// the /device endpoint, where they are authenticated with the same policies used by the
+// authorization endpoint.
+// Device codes expire after lifetimeSecs and devices must wait at least pollingIntervalSecs
+// between token requests.
+func WithDeviceGrant(lifetimeSecs int64, pollingIntervalSecs int64) ProviderOption {
+	return func(p *Provider) {
+		p.config.GrantTypes = append(p.config.GrantTypes, goidc.GrantDeviceCode)
+		p.config.DeviceGrantIsEnabled = true
+		p.config.DeviceCodeLifetimeSecs = lifetimeSecs
+		p.config.DevicePollingIntervalSecs = pollingIntervalSecs
+	}
+}
+
+// WithJWTBearerGrant allows clients to exchange assertions signed by trusted
+// issuers for access tokens as defined in RFC 7523.
+// The issuers must be registered with WithTrustedAssertionIssuer.
+// mappingFunc maps the subject of the assertion to the subject of the access
+// token. If it's nil, the subject of the assertion is used as is.
+func WithJWTBearerGrant(
+	mappingFunc goidc.JWTBearerMappingFunc,
+	assertionLifetimeSecs int64,
+	signatureAlgorithms ...jose.SignatureAlgorithm,
+) ProviderOption {
+	return func(p *Provider) {
+		p.config.GrantTypes = append(p.config.GrantTypes, goidc.GrantJWTBearer)
+		p.config.JWTBearerGrantIsEnabled = true
+		p.config.JWTBearerMappingFunc = mappingFunc
+		p.config.JWTBearerAssertionLifetimeSecs = assertionLifetimeSecs
+		p.config.JWTBearerSignatureAlgorithms = signatureAlgorithms
+	}
+}
+
+// WithTrustedAssertionIssuer trusts the assertions signed by issuer with the
+// keys published at jwksURI during the JWT bearer grant.
+func WithTrustedAssertionIssuer(issuer, jwksURI string) ProviderOption {
+	return func(p *Provider) {
+		if p.config.JWTBearerTrustedIssuers == nil {
+			p.config.JWTBearerTrustedIssuers = make(map[string]string)
+		}
+		p.config.JWTBearerTrustedIssuers[issuer] = jwksURI
+	}
+}
+
+// WithHealthEndpoints exposes the liveness probe at /health and the readiness
+// probe at /ready, e.g. for Kubernetes deployments. The probes require no
+// authentication.
+// The readiness probe responds with 503 if any of the stores implementing
+// [goidc.Pinger] or any of the checkers informed fails to respond to a ping.
+func WithHealthEndpoints(checkers ...goidc.Pinger) ProviderOption {
+	return func(p *Provider) {
+		p.config.HealthEndpointsAreEnabled = true
+		p.config.ReadinessCheckers = append(p.config.ReadinessCheckers, checkers...)
+	}
+}
+
+// WithOfflineAccessConsentRequired only grants the "offline_access" scope, and
+// therefore only issues refresh tokens, when the authentication policy
+// confirms the user consented to it with [goidc.AuthnSession.GrantOfflineAccess].
+// If promptConsentIsRequired is true, authorization requests must also be
+// sent with "prompt=consent" as recommended by OpenID Connect.
+// Otherwise, "offline_access" is removed from the scopes granted.
+func WithOfflineAccessConsentRequired(promptConsentIsRequired bool) ProviderOption {
+	return func(p *Provider) {
+		p.config.OfflineAccessConsentIsRequired = true
+		p.config.OfflineAccessPromptConsentIsRequired = promptConsentIsRequired
+	}
+}
+
+// WithScopePolicy restricts the scopes issued per grant type, e.g. to only
+// issue offline_access with the authorization code grant.
+// The policy is applied to the authorization code, client credentials, device
+// code and JWT bearer grants. When it removes a scope, the granted scopes are
+// informed in the token response.
+func WithScopePolicy(f goidc.ScopePolicyFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.ScopePolicyFunc = f
+	}
+}
+
+// WithSoftwareStatementIssuer trusts the software statements signed by issuer
+// with the keys published at jwksURI during dynamic client registration.
+// The metadata asserted by a software statement take precedence over the ones
+// informed in the registration request.
+// If no signature algorithms were defined with
+// [WithSoftwareStatementSignatureAlgorithms], RS256, PS256 and ES256 are accepted.
+func WithSoftwareStatementIssuer(issuer, jwksURI string) ProviderOption {
+	return func(p *Provider) {
+		if p.config.SoftwareStatementTrustedIssuers == nil {
+			p.config.SoftwareStatementTrustedIssuers = make(map[string]string)
+		}
+		p.config.SoftwareStatementTrustedIssuers[issuer] = jwksURI
+		if p.config.SoftwareStatementSignatureAlgorithms == nil {
+			p.config.SoftwareStatementSignatureAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.PS256, jose.ES256}
+		}
+	}
+}
+
+// WithSoftwareStatementSignatureAlgorithms defines the algorithms accepted to
+// sign software statements.
+func WithSoftwareStatementSignatureAlgorithms(signatureAlgorithms ...jose.SignatureAlgorithm) ProviderOption {
+	return func(p *Provider) {
+		p.config.SoftwareStatementSignatureAlgorithms = signatureAlgorithms
+	}
+}
+
 // WithOpenIDScopeRequired forces the openid scope in all requests.
 func WithOpenIDScopeRequired() ProviderOption {
 	return func(p *Provider) {
@@ -181,11 +559,40 @@ func WithOpenIDScopeRequired() ProviderOption {
 	}
 }
 
+// WithOptionalRedirectURI allows clients with a single registered redirect URI
+// to omit the redirect_uri parameter during authorization requests, in which
+// case the registered one is used.
+// Clients with multiple redirect URIs must always inform it.
+func WithOptionalRedirectURI() ProviderOption {
+	return func(p *Provider) {
+		p.config.RedirectURIIsOptional = true
+	}
+}
+
 // WithTokenOptions defines how access tokens are issued.
 func WithTokenOptions(getTokenOpts goidc.TokenOptionsFunc) ProviderOption {
+	return WithTokenOptionsV2(
+		func(client *goidc.Client, _ goidc.GrantType, scopes string, _ goidc.Resources) (goidc.TokenOptions, error) {
+			return getTokenOpts(client, scopes)
+		},
+	)
+}
+
+// WithTokenOptionsV2 defines how access tokens are issued based also on the
+// grant type and on the resources requested, e.g. tokens for some audiences
+// can be opaque and short-lived while others are JWTs.
+func WithTokenOptionsV2(getTokenOpts goidc.TokenOptionsFuncV2) ProviderOption {
 	return func(p *Provider) {
-		p.config.TokenOptions = func(client *goidc.Client, scopes string) (goidc.TokenOptions, error) {
-			opts, err := getTokenOpts(client, scopes)
+		p.config.TokenOptions = func(
+			client *goidc.Client,
+			grantType goidc.GrantType,
+			scopes string,
+			resources goidc.Resources,
+		) (
+			goidc.TokenOptions,
+			error,
+		) {
+			opts, err := getTokenOpts(client, grantType, scopes, resources)
 			if err != nil {
 				return goidc.TokenOptions{}, err
 			}
@@ -199,6 +606,24 @@ func WithTokenOptions(getTokenOpts goidc.TokenOptionsFunc) ProviderOption {
 	}
 }
 
+// WithSecretHasher defines how client secrets and registration access tokens
+// are hashed before they are stored, e.g. goidc.NewArgon2idHasher().
+// The default is bcrypt. Secrets already hashed with bcrypt keep working after
+// switching to argon2id, since the algorithm is detected from the stored hash.
+func WithSecretHasher(hasher goidc.SecretHasher) ProviderOption {
+	return func(p *Provider) {
+		p.config.SecretHasher = hasher
+	}
+}
+
+// WithOpaqueTokenHashing makes the server store only the hash of opaque access tokens,
+// so a leak of the storage doesn't expose tokens that are still valid.
+func WithOpaqueTokenHashing() ProviderOption {
+	return func(p *Provider) {
+		p.config.OpaqueTokenHashingIsEnabled = true
+	}
+}
+
 // WithImplicitGrant allows the implicit grant type and the associated response types.
 func WithImplicitGrant() ProviderOption {
 	return func(p *Provider) {
@@ -215,6 +640,30 @@ func WithImplicitGrant() ProviderOption {
 	}
 }
 
+// WithResponseTypes defines explicitly the response types enabled server-wide,
+// e.g. "code" and "code id_token" can be kept while "token" and "id_token token" are disabled.
+// It overrides the response types derived from the grant types, so it must be informed after
+// options such as WithImplicitGrant.
+// The response types are advertised in the discovery document and requests using any other
+// response type are rejected.
+func WithResponseTypes(responseTypes ...goidc.ResponseType) ProviderOption {
+	return func(p *Provider) {
+		p.config.ResponseTypes = responseTypes
+	}
+}
+
+// WithResponseModes defines explicitly the response modes enabled server-wide,
+// e.g. only "form_post" can be kept so authorization responses never travel in the URL.
+// It overrides the response modes derived from other options, so it must be informed after
+// options such as WithJARM.
+// The response modes are advertised in the discovery document and requests using any other
+// response mode are rejected.
+func WithResponseModes(responseModes ...goidc.ResponseMode) ProviderOption {
+	return func(p *Provider) {
+		p.config.ResponseModes = responseModes
+	}
+}
+
 func WithScopes(scopes ...goidc.Scope) ProviderOption {
 	return func(p *Provider) {
 		p.config.Scopes = scopes
@@ -228,6 +677,14 @@ func WithScopes(scopes ...goidc.Scope) ProviderOption {
 	}
 }
 
+// WithUnknownScopesIgnored makes the server discard the scopes requested that it doesn't
+// recognize and grant the remaining ones. By default, requests with unknown scopes are rejected.
+func WithUnknownScopesIgnored() ProviderOption {
+	return func(p *Provider) {
+		p.config.IgnoreUnknownScopes = true
+	}
+}
+
 // WithPAR allows authorization flows to start at the /par endpoint.
 func WithPAR(parLifetimeSecs int64) ProviderOption {
 	return func(p *Provider) {
@@ -239,11 +696,23 @@ func WithPAR(parLifetimeSecs int64) ProviderOption {
 // WithPARRequired forces authorization flows to start at the /par endpoint.
 func WithPARRequired(parLifetimeSecs int64) ProviderOption {
 	return func(p *Provider) {
-		WithPAR(parLifetimeSecs)
+		WithPAR(parLifetimeSecs)(p)
 		p.config.PARIsRequired = true
 	}
 }
 
+// WithPARLimits limits the size of pushed authorization requests, which are
+// stored by the server until their request_uri expires.
+// Requests exceeding the limits are rejected with "invalid_request".
+// Limits set to zero are not enforced.
+func WithPARLimits(limits PARLimits) ProviderOption {
+	return func(p *Provider) {
+		p.config.PARMaxRequestBodyBytes = limits.RequestBodyBytes
+		p.config.PARMaxAuthorizationDetails = limits.AuthorizationDetails
+		p.config.PARMaxScopeLength = limits.ScopeLength
+	}
+}
+
 func WithJAR(
 	jarLifetimeSecs int64,
 	jarAlgorithms ...jose.SignatureAlgorithm,
@@ -260,17 +729,39 @@ func WithJAR(
 	}
 }
 
+// WithJARByReference allows clients to pass request objects by reference
+// with the "request_uri" parameter as defined in OpenID Connect Core 1.0.
+// The request objects are fetched from the request URIs registered by the
+// client and cached for cacheLifetimeSecs. Zero disables the cache.
+// JAR must be enabled for this option to take effect.
+func WithJARByReference(cacheLifetimeSecs int64) ProviderOption {
+	return func(p *Provider) {
+		p.config.JARByReferenceIsEnabled = true
+		p.config.JARByReferenceCacheLifetimeSecs = cacheLifetimeSecs
+	}
+}
+
 // WithJARRequired makes JAR required.
 func WithJARRequired(
 	jarLifetimeSecs int64,
 	jarAlgorithms ...jose.SignatureAlgorithm,
 ) ProviderOption {
 	return func(p *Provider) {
-		WithJAR(jarLifetimeSecs, jarAlgorithms...)
+		WithJAR(jarLifetimeSecs, jarAlgorithms...)(p)
 		p.config.JARIsRequired = true
 	}
 }
 
+// WithJARTypeRequired makes the server reject request objects whose header
+// "typ" is not "oauth-authz-req+jwt" as recommended by RFC 9101.
+// This prevents other JWTs issued to or by the client from being replayed as
+// request objects.
+func WithJARTypeRequired() ProviderOption {
+	return func(p *Provider) {
+		p.config.JARTypeIsRequired = true
+	}
+}
+
 func WithJAREncryption(
 	keyEncryptionIDs []string,
 	contentEncryptionAlgorithms []jose.ContentEncryption,
@@ -423,6 +914,23 @@ func WithClaimsParameter() ProviderOption {
 	}
 }
 
+// WithEssentialClaimsPolicy defines what happens when an essential claim
+// requested with the "claims" parameter is not set by the policy.
+// By default, the server follows [goidc.EssentialClaimsPolicyBestEffort].
+func WithEssentialClaimsPolicy(policy goidc.EssentialClaimsPolicy) ProviderOption {
+	return func(p *Provider) {
+		p.config.EssentialClaimsPolicy = policy
+	}
+}
+
+// WithUnsupportedClaimsParameterRejected makes the server reject requests containing the "claims"
+// parameter when it is not enabled with WithClaimsParameter. By default, the parameter is ignored.
+func WithUnsupportedClaimsParameterRejected() ProviderOption {
+	return func(p *Provider) {
+		p.config.UnsupportedClaimsParameterIsRejected = true
+	}
+}
+
 func WithAuthorizationDetails(types ...string) ProviderOption {
 	return func(p *Provider) {
 		p.config.AuthorizationDetailsParameterIsEnabled = true
@@ -430,6 +938,39 @@ func WithAuthorizationDetails(types ...string) ProviderOption {
 	}
 }
 
+// WithAuthorizationDetailValidator registers a function to validate the fields
+// of the authorization details of the type informed, both at the authorization
+// and PAR endpoints. The type must be one of the ones informed in WithAuthorizationDetails.
+func WithAuthorizationDetailValidator(
+	detailType string,
+	f goidc.AuthorizationDetailValidationFunc,
+) ProviderOption {
+	return func(p *Provider) {
+		if p.config.AuthorizationDetailValidators == nil {
+			p.config.AuthorizationDetailValidators = make(map[string]goidc.AuthorizationDetailValidationFunc)
+		}
+		p.config.AuthorizationDetailValidators[detailType] = f
+	}
+}
+
+// WithResourceIndicators allows clients to request access tokens for the resources informed
+// using the "resource" parameter. The granted resources are set as the audience of access tokens.
+func WithResourceIndicators(resources ...string) ProviderOption {
+	return func(p *Provider) {
+		p.config.ResourceIndicatorsIsEnabled = true
+		p.config.Resources = resources
+	}
+}
+
+// WithResourceValidation defines a function to restrict the resources each client
+// can request, e.g. based on its metadata. The resources must still be among the
+// ones informed in WithResourceIndicators.
+func WithResourceValidation(f goidc.ResourceValidationFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.ResourceValidationFunc = f
+	}
+}
+
 func WithDPoP(
 	dpopLifetimeSecs int,
 	dpopSigningAlgorithms ...jose.SignatureAlgorithm,
@@ -451,11 +992,55 @@ func WithDPoPRequired(
 	dpopSigningAlgorithms ...jose.SignatureAlgorithm,
 ) ProviderOption {
 	return func(p *Provider) {
-		WithDPoP(dpopLifetimeSecs, dpopSigningAlgorithms...)
+		WithDPoP(dpopLifetimeSecs, dpopSigningAlgorithms...)(p)
 		p.config.DPoPIsRequired = true
 	}
 }
 
+// WithDPoPNonceRequired makes DPoP proofs sent to the token endpoint contain a
+// nonce provided by the server as described in RFC 9449, section 8.
+// Requests without a valid nonce are rejected with the "use_dpop_nonce" error
+// and a new nonce is returned in the DPoP-Nonce header.
+// The store defines how nonces are issued and validated, e.g. NewInMemoryDPoPNonceStore.
+func WithDPoPNonceRequired(store goidc.DPoPNonceStore) ProviderOption {
+	return func(p *Provider) {
+		p.config.DPoPNonceIsRequired = true
+		p.config.DPoPNonceStore = store
+	}
+}
+
+// WithTokenEndpointRateLimit throttles the requests each client can make to the
+// token endpoint using an in memory token bucket that holds up to burst
+// requests and is refilled at requestsPerSec.
+// Requests exceeding the limit are rejected with status 429 and the
+// Retry-After header.
+func WithTokenEndpointRateLimit(requestsPerSec float64, burst int) ProviderOption {
+	return WithTokenEndpointRateLimiter(NewInMemoryRateLimiter(requestsPerSec, burst))
+}
+
+// WithTokenEndpointRateLimiter throttles the requests each client can make to
+// the token endpoint with the limiter informed, which is keyed by client ID.
+func WithTokenEndpointRateLimiter(limiter goidc.RateLimiter) ProviderOption {
+	return func(p *Provider) {
+		p.config.TokenRateLimiter = limiter
+	}
+}
+
+// WithMetrics records the latency and outcome of the requests handled by the
+// server with collector, e.g. NewPrometheusMetrics.
+func WithMetrics(collector goidc.MetricsCollector) ProviderOption {
+	return func(p *Provider) {
+		p.config.MetricsCollector = collector
+	}
+}
+
+// NewPrometheusMetrics creates a metrics collector that keeps the metrics in
+// memory. It must be served as an HTTP handler, e.g. at "/metrics", so the
+// metrics can be scraped in the Prometheus text format.
+func NewPrometheusMetrics() *metrics.PrometheusCollector {
+	return metrics.NewPrometheusCollector()
+}
+
 // WithSenderConstrainedTokensRequired will make at least one sender constraining mechanism (TLS or DPoP) be required,
 // in order to issue an access token to a client.
 func WithSenderConstrainedTokensRequired() ProviderOption {
@@ -464,6 +1049,15 @@ func WithSenderConstrainedTokensRequired() ProviderOption {
 	}
 }
 
+// WithPublicClientRestriction rejects public clients, i.e. the ones that
+// authenticate with "none", at the client_credentials grant, since they cannot
+// keep credentials confidential.
+func WithPublicClientRestriction() ProviderOption {
+	return func(p *Provider) {
+		p.config.PublicClientRestrictionIsEnabled = true
+	}
+}
+
 func WithIntrospection(
 	clientAuthnMethods ...goidc.ClientAuthnType,
 ) ProviderOption {
@@ -474,6 +1068,139 @@ func WithIntrospection(
 	}
 }
 
+// WithJWKSCacheMaxAge allows clients to cache the JWKS for maxAgeSecs.
+// The JWKS is also returned with an ETag, so clients can revalidate it with
+// the If-None-Match header and receive a 304 response if the keys didn't change.
+// Only the JWKS endpoint is affected, the other endpoints remain not cacheable.
+func WithJWKSCacheMaxAge(maxAgeSecs int64) ProviderOption {
+	return func(p *Provider) {
+		p.config.JWKSCacheMaxAgeSecs = maxAgeSecs
+	}
+}
+
+// WithRedirectURIMatching defines how the redirect URIs informed in
+// authorization requests are compared to the ones registered by clients.
+// The default is goidc.RedirectURIMatchingExact.
+func WithRedirectURIMatching(mode goidc.RedirectURIMatchingMode) ProviderOption {
+	return func(p *Provider) {
+		p.config.RedirectURIMatchingMode = mode
+	}
+}
+
+// WithResourceServerAuthz defines which clients, usually resource servers, can
+// introspect tokens issued to other clients.
+// By default, clients can only introspect their own tokens and the tokens of
+// other clients are reported as inactive.
+func WithResourceServerAuthz(f goidc.ResourceServerAuthzFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.ResourceServerAuthzFunc = f
+	}
+}
+
+// WithIntrospectionCache caches in memory the introspection results of up to
+// size access tokens for at most ttl, so introspecting the same token again
+// doesn't require loading its grant session.
+// Cached results are invalidated when the grant session changes or is deleted,
+// e.g. when the token is revoked, and inactive results are cached only briefly.
+// Since the cache is kept in memory, the invalidation only reaches the instance
+// that handled the change. For deployments with multiple instances, use
+// WithIntrospectionCacheStore or keep ttl short.
+func WithIntrospectionCache(size int, ttl time.Duration) ProviderOption {
+	return WithIntrospectionCacheStore(NewInMemoryIntrospectionCache(size, ttl))
+}
+
+// WithIntrospectionCacheStore caches the introspection results of access
+// tokens with the cache informed.
+func WithIntrospectionCacheStore(cache goidc.IntrospectionCache) ProviderOption {
+	return func(p *Provider) {
+		p.config.IntrospectionCache = cache
+	}
+}
+
+// WithTokenRevocation enables the revocation endpoint, so clients can invalidate
+// the access and refresh tokens issued to them as described in RFC 7009.
+// Clients authenticate at the revocation endpoint the same way they do at the
+// token endpoint.
+func WithTokenRevocation() ProviderOption {
+	return func(p *Provider) {
+		p.config.TokenRevocationIsEnabled = true
+	}
+}
+
+// WithJWTIntrospectionResponse allows clients to receive introspection responses
+// signed as JWTs by sending the header "Accept: application/token-introspection+jwt"
+// as defined in RFC 9701. The response is signed with the key identified by
+// signatureKeyID and is addressed to the client that requested it.
+// Plain JSON is still returned when the header is not informed.
+// This option requires introspection to be enabled.
+func WithJWTIntrospectionResponse(signatureKeyID string) ProviderOption {
+	return func(p *Provider) {
+		p.config.IntrospectionJWTResponseIsEnabled = true
+		p.config.IntrospectionJWTResponseSignatureKeyID = signatureKeyID
+	}
+}
+
+// WithSignedMetadata makes the discovery endpoint also return the metadata
+// claims as a JWT in the "signed_metadata" field as defined in RFC 8414.
+// The JWT is signed with the key identified by signatureKeyID.
+// The plain JSON fields are still returned alongside it.
+func WithSignedMetadata(signatureKeyID string) ProviderOption {
+	return func(p *Provider) {
+		p.config.SignedMetadataIsEnabled = true
+		p.config.SignedMetadataSignatureKeyID = signatureKeyID
+	}
+}
+
+// WithProtectedResourceMetadata publishes at /.well-known/oauth-protected-resource
+// the metadata of the resource server identified by resource as described in
+// RFC 9728, so its clients can discover how to obtain tokens to access it.
+// The metadata is filled based on the configuration of the server, e.g. the
+// scopes and the token binding mechanisms enabled.
+// signatureAlgorithms are the algorithms the resource uses to sign its responses,
+// if any.
+func WithProtectedResourceMetadata(
+	resource string,
+	signatureAlgorithms ...jose.SignatureAlgorithm,
+) ProviderOption {
+	return func(p *Provider) {
+		p.config.ProtectedResourceMetadataIsEnabled = true
+		p.config.ProtectedResource = resource
+		p.config.ProtectedResourceSignatureAlgorithms = signatureAlgorithms
+	}
+}
+
+// WithFederation makes the server take part in an OpenID federation.
+// The server publishes its entity configuration at /.well-known/openid-federation
+// and trusts clients that were not registered, as long as their client ID is an
+// entity identifier whose trust chain resolves to one of the trust anchors.
+// trustChainFunc is responsible for fetching the trust chain of the clients.
+func WithFederation(
+	trustAnchors []goidc.TrustAnchor,
+	trustChainFunc goidc.TrustChainFunc,
+	authorityHints ...string,
+) ProviderOption {
+	return func(p *Provider) {
+		p.config.FederationIsEnabled = true
+		p.config.FederationTrustAnchors = trustAnchors
+		p.config.FederationTrustChainFunc = trustChainFunc
+		p.config.FederationAuthorityHints = authorityHints
+		if p.config.FederationEntityStatementLifetimeSecs == 0 {
+			p.config.FederationEntityStatementLifetimeSecs = defaultEntityStatementLifetimeSecs
+		}
+	}
+}
+
+// WithAuthorizationCodeBinding binds authorization codes to the DPoP key or the client
+// certificate established when the authorization was requested, so a stolen code cannot be
+// redeemed without the key.
+// The DPoP key can be informed with the "dpop_jkt" parameter or with the DPoP header during PAR.
+// The client certificate is only taken into account during PAR.
+func WithAuthorizationCodeBinding() ProviderOption {
+	return func(p *Provider) {
+		p.config.AuthorizationCodeBindingIsEnabled = true
+	}
+}
+
 // WithPKCE makes PKCE available to clients.
 func WithPKCE(
 	codeChallengeMethods ...goidc.CodeChallengeMethod,
@@ -489,11 +1216,33 @@ func WithPKCERequired(
 	codeChallengeMethods ...goidc.CodeChallengeMethod,
 ) ProviderOption {
 	return func(p *Provider) {
-		WithPKCE(codeChallengeMethods...)
+		WithPKCE(codeChallengeMethods...)(p)
 		p.config.PkceIsRequired = true
 	}
 }
 
+// WithPKCERequiredForPublicClients makes PKCE required for the clients that
+// don't authenticate, i.e. whose authentication method is "none", as
+// recommended by OAuth 2.1. The other clients are not affected unless PKCE is
+// enabled with WithPKCE.
+// Public clients can use the code challenge methods informed to WithPKCE or
+// S256 if none is informed.
+func WithPKCERequiredForPublicClients() ProviderOption {
+	return func(p *Provider) {
+		p.config.PkceIsRequiredForPublicClients = true
+	}
+}
+
+// WithPKCES256Required makes S256 the only code challenge method accepted.
+// Requests using the plain method, whether explicitly or by omitting the
+// method, are rejected.
+// It overrides the code challenge methods informed to the other PKCE options.
+func WithPKCES256Required() ProviderOption {
+	return func(p *Provider) {
+		p.config.PkceS256IsRequired = true
+	}
+}
+
 func WithACRs(
 	acrValues ...goidc.ACR,
 ) ProviderOption {
@@ -502,12 +1251,41 @@ func WithACRs(
 	}
 }
 
+// WithACRMatch defines how the authentication context reference achieved by
+// the user is compared to the ones requested by the client.
+// By default, they must be equal.
+func WithACRMatch(f goidc.ACRMatchFunc) ProviderOption {
+	return func(p *Provider) {
+		p.config.ACRMatchFunc = f
+	}
+}
+
 func WithDisplayValues(values ...goidc.DisplayValue) ProviderOption {
 	return func(p *Provider) {
 		p.config.DisplayValues = values
 	}
 }
 
+// WithUILocales defines the languages supported for the user interface.
+// They are published in the discovery document and clients can inform their
+// preference with the "ui_locales" parameter, which is available to the
+// policies through [goidc.AuthnSession.UILocales].
+func WithUILocales(locales ...string) ProviderOption {
+	return func(p *Provider) {
+		p.config.UILocales = locales
+	}
+}
+
+// WithClaimsLocales defines the languages supported for the claim values.
+// They are published in the discovery document and clients can inform their
+// preference with the "claims_locales" parameter, which is available to the
+// policies through [goidc.AuthnSession.ClaimsLocales].
+func WithClaimsLocales(locales ...string) ProviderOption {
+	return func(p *Provider) {
+		p.config.ClaimsLocales = locales
+	}
+}
+
 func WithClaimTypes(types ...goidc.ClaimType) ProviderOption {
 	return func(p *Provider) {
 		p.config.ClaimTypes = types
@@ -515,6 +1293,8 @@ func WithClaimTypes(types ...goidc.ClaimType) ProviderOption {
 }
 
 // WithAuthenticationSessionTimeout sets the user authentication session lifetime.
+// It also limits for how long a logout interrupted to interact with the user
+// can be resumed.
 func WithAuthenticationSessionTimeout(timeoutSecs int64) ProviderOption {
 	return func(p *Provider) {
 		p.config.AuthenticationSessionTimeoutSecs = timeoutSecs
@@ -545,6 +1325,58 @@ func WithPolicy(policy goidc.AuthnPolicy) ProviderOption {
 	}
 }
 
+// WithEventHook adds a hook that is notified of the lifecycle events of the
+// server, e.g. when tokens are issued or clients are registered.
+func WithEventHook(hook goidc.EventHook) ProviderOption {
+	return func(p *Provider) {
+		p.config.EventHooks = append(p.config.EventHooks, hook)
+	}
+}
+
+// WithLogger defines the logger used by the server.
+// By default, [slog.Default] is used.
+func WithLogger(logger *slog.Logger) ProviderOption {
+	return func(p *Provider) {
+		p.config.Logger = logger
+	}
+}
+
+// WithCorrelationIDHeader defines the header that carries the ID used to
+// correlate the logs of a request. The default is [goidc.HeaderCorrelationID].
+// Requests without the header are assigned a new ID, which is echoed in the
+// response.
+func WithCorrelationIDHeader(header string) ProviderOption {
+	return func(p *Provider) {
+		p.config.CorrelationIDHeader = header
+	}
+}
+
+// WithAccountCreation enables the "create" value for the "prompt" parameter as
+// defined by Initiating User Registration via OpenID Connect.
+// Requests with "prompt=create" are handled by the policy informed, which
+// should drive the user through the account registration.
+func WithAccountCreation(policy goidc.AuthnPolicy) ProviderOption {
+	return func(p *Provider) {
+		p.config.AccountCreationIsEnabled = true
+		p.config.AccountCreationPolicy = policy
+		p.config.PromptValues = append(p.config.PromptValues, goidc.PromptTypeCreate)
+	}
+}
+
+// WithErrorURIs makes the server inform the "error_uri" parameter defined in
+// RFC 6749 with the errors it returns, so clients can find documentation about
+// them. paths maps error codes to paths relative to baseURL, e.g.
+//
+//	WithErrorURIs("https://docs.example.com", map[string]string{"invalid_scope": "/errors#invalid_scope"})
+//
+// Errors whose codes are not mapped are returned without an error URI.
+func WithErrorURIs(baseURL string, paths map[string]string) ProviderOption {
+	return func(p *Provider) {
+		p.config.ErrorURIBase = baseURL
+		p.config.ErrorURIs = paths
+	}
+}
+
 // WithAuthorizeErrorPlugin defines a handler to be executed when the authorization request results in error,
 // but the error can't be redirected. This can be used to display a page with the error.
 // The default behavior is to display a JSON with the error information to the user.
@@ -557,6 +1389,9 @@ func WithAuthorizeErrorPlugin(plugin goidc.AuthorizeErrorPluginFunc) ProviderOpt
 // TokenInfo returns information about the token sent in the request.
 // It also validates token binding (DPoP or TLS).
 func (p *Provider) TokenInfo(req *http.Request, resp http.ResponseWriter) goidc.TokenInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	ctx := oidc.NewContext(p.config, req, resp)
 	accessToken, tokenType, ok := ctx.AuthorizationToken()
 	if !ok {
@@ -575,11 +1410,117 @@ func (p *Provider) TokenInfo(req *http.Request, resp http.ResponseWriter) goidc.
 	return tokenInfo
 }
 
+// ValidateTokenBinding validates the access token sent in the request is
+// presented with the proofs of possession described by the token information,
+// that is, the DPoP JWT of the request for the "jkt" confirmation and the
+// client certificate informed for the "x5t#S256" one.
+// This is useful for resource servers that terminate TLS themselves and
+// obtain the token information through introspection.
+func (p *Provider) ValidateTokenBinding(
+	req *http.Request,
+	resp http.ResponseWriter,
+	tokenInfo goidc.TokenInfo,
+	clientCert *x509.Certificate,
+) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ctx := oidc.NewContext(p.config, req, resp)
+	accessToken, tokenType, ok := ctx.AuthorizationToken()
+	if !ok {
+		return errors.New("the access token is required")
+	}
+
+	confirmation := token.Confirmation{
+		JWKThumbprint:               tokenInfo.JWKThumbprint,
+		ClientCertificateThumbprint: tokenInfo.ClientCertificateThumbprint,
+	}
+	if err := token.ValidateTokenBinding(ctx, accessToken, tokenType, confirmation, clientCert); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (p *Provider) Client(req *http.Request, resp http.ResponseWriter, clientID string) (*goidc.Client, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	ctx := oidc.NewContext(p.config, req, resp)
 	return p.config.ClientManager.Get(ctx, clientID)
 }
 
+// NotifyLogout informs the clients the user authorized that their session
+// ended by sending logout tokens to their back-channel logout URIs.
+// If sid is informed, only the clients that took part in that session are
+// notified, otherwise all the clients with grants for the user are.
+// A failure to notify a client doesn't prevent the others from being notified,
+// the errors are returned together once all the clients are handled.
+func (p *Provider) NotifyLogout(ctx context.Context, subject string, sid string) error {
+	p.mu.RLock()
+	config := p.config
+	p.mu.RUnlock()
+
+	if !config.BackChannelLogoutIsEnabled {
+		return errors.New("back-channel logout is not enabled")
+	}
+
+	// The notifications are not triggered by a request to the server, but the
+	// context still needs one to carry ctx.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Host, nil)
+	if err != nil {
+		return err
+	}
+
+	return logout.NotifyClients(oidc.NewContext(config, req, nil), subject, sid)
+}
+
+// RotateClientSecret issues a new secret to the client and returns it.
+// The previous secret still authenticates the client during overlapSecs, so
+// the client can switch to the new secret without downtime. After that, only
+// the new secret is accepted.
+func (p *Provider) RotateClientSecret(ctx context.Context, clientID string, overlapSecs int64) (string, error) {
+	p.mu.RLock()
+	config := p.config
+	p.mu.RUnlock()
+
+	// The rotation is not triggered by a request to the server, but the
+	// context still needs one to carry ctx.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Host, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return dcr.RotateClientSecret(oidc.NewContext(config, req, nil), clientID, overlapSecs)
+}
+
+// RotateSignatureKey makes key the default key to sign new tokens for purpose.
+// The key is added to the server JWKS, unless a key with the same ID is
+// already published, in which case the published key is activated.
+// The key previously used for purpose remains published during overlapSecs,
+// so tokens issued with it can still be verified. After that, it is removed
+// from the JWKS, unless it's still used by default for another purpose.
+func (p *Provider) RotateSignatureKey(purpose goidc.SignatureKeyPurpose, key jose.JSONWebKey, overlapSecs int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	oldKeyID, err := p.config.RotateSignatureKey(purpose, key)
+	if err != nil {
+		return err
+	}
+
+	if oldKeyID == "" || oldKeyID == key.KeyID {
+		return nil
+	}
+
+	time.AfterFunc(time.Duration(overlapSecs)*time.Second, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.config.RetireKey(oldKeyID)
+	})
+	return nil
+}
+
 func (p *Provider) Run(
 	address string,
 	middlewares ...goidc.WrapHandlerFunc,
@@ -613,11 +1554,9 @@ func (p *Provider) RunTLS(
 	}
 	handler = newCacheControlMiddleware(handler)
 	server := &http.Server{
-		Addr:    config.TLSAddress,
-		Handler: handler,
-		TLSConfig: &tls.Config{
-			CipherSuites: config.CipherSuites,
-		},
+		Addr:      config.TLSAddress,
+		Handler:   handler,
+		TLSConfig: newTLSConfig(config),
 	}
 	return server.ListenAndServeTLS(config.ServerCertificate, config.ServerKey)
 }
@@ -628,99 +1567,198 @@ func (p *Provider) runMTLS(config TLSOptions) error {
 	handler = newCacheControlMiddleware(handler)
 	handler = NewClientCertificateMiddleware(handler)
 
-	tlsClientAuthnType := tls.RequireAndVerifyClientCert
-	if config.CaCertificatePool == nil || config.UnsecureCertificatesAreAllowed {
-		tlsClientAuthnType = tls.RequireAnyClientCert
-	}
-
 	server := &http.Server{
-		Addr:    config.MTLSAddress,
-		Handler: handler,
-		TLSConfig: &tls.Config{
-			ClientCAs:    config.CaCertificatePool,
-			ClientAuth:   tlsClientAuthnType,
-			CipherSuites: config.CipherSuites,
-		},
+		Addr:      config.MTLSAddress,
+		Handler:   handler,
+		TLSConfig: newMTLSConfig(config),
 	}
 	return server.ListenAndServeTLS(config.ServerCertificate, config.ServerKey)
 }
 
+// newTLSConfig builds the TLS configuration of the server based on the options informed.
+// If no minimum version is defined, TLS 1.2 is enforced.
+func newTLSConfig(config TLSOptions) *tls.Config {
+	tlsConfig := &tls.Config{}
+	if config.TLSConfig != nil {
+		tlsConfig = config.TLSConfig.Clone()
+	}
+
+	if config.MinVersion != 0 {
+		tlsConfig.MinVersion = config.MinVersion
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = defaultTLSMinVersion
+	}
+
+	if config.CipherSuites != nil {
+		tlsConfig.CipherSuites = config.CipherSuites
+	}
+
+	return tlsConfig
+}
+
+// newMTLSConfig builds the TLS configuration of the mTLS server which always
+// requires client certificates.
+func newMTLSConfig(config TLSOptions) *tls.Config {
+	tlsConfig := newTLSConfig(config)
+
+	if config.CaCertificatePool != nil {
+		tlsConfig.ClientCAs = config.CaCertificatePool
+	}
+
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if tlsConfig.ClientCAs == nil || config.UnsecureCertificatesAreAllowed {
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	}
+
+	return tlsConfig
+}
+
 func (p *Provider) Handler() http.Handler {
 
 	handler := http.NewServeMux()
 
 	handler.HandleFunc(
 		"GET "+p.config.PathPrefix+goidc.EndpointJSONWebKeySet,
-		discovery.HandlerJWKS(&p.config),
+		p.instrument(goidc.EndpointJSONWebKeySet, discovery.HandlerJWKS(&p.config)),
 	)
 
 	if p.config.PARIsEnabled {
 		handler.HandleFunc(
 			"POST "+p.config.PathPrefix+goidc.EndpointPushedAuthorizationRequest,
-			authorize.HandlerPush(&p.config),
+			p.instrument(goidc.EndpointPushedAuthorizationRequest, authorize.HandlerPush(&p.config)),
 		)
 	}
 
 	handler.HandleFunc(
 		"GET "+p.config.PathPrefix+goidc.EndpointAuthorization,
-		authorize.Handler(&p.config),
+		p.instrument(goidc.EndpointAuthorization, authorize.Handler(&p.config)),
 	)
 
 	handler.HandleFunc(
 		"POST "+p.config.PathPrefix+goidc.EndpointAuthorization+"/{callback}",
-		authorize.HandlerCallback(&p.config),
+		p.instrument(goidc.EndpointAuthorization+"/{callback}", authorize.HandlerCallback(&p.config)),
 	)
 
 	handler.HandleFunc(
 		"POST "+p.config.PathPrefix+goidc.EndpointToken,
-		token.Handler(&p.config),
+		p.instrument(goidc.EndpointToken, token.Handler(&p.config)),
 	)
 
 	handler.HandleFunc(
 		"GET "+p.config.PathPrefix+goidc.EndpointWellKnown,
-		discovery.HandlerWellKnown(&p.config),
+		p.instrument(goidc.EndpointWellKnown, discovery.HandlerWellKnown(&p.config)),
 	)
 
 	handler.HandleFunc(
 		"GET "+p.config.PathPrefix+goidc.EndpointUserInfo,
-		userinfo.Handler(&p.config),
+		p.instrument(goidc.EndpointUserInfo, userinfo.Handler(&p.config)),
 	)
 
 	handler.HandleFunc(
 		"POST "+p.config.PathPrefix+goidc.EndpointUserInfo,
-		userinfo.Handler(&p.config),
+		p.instrument(goidc.EndpointUserInfo, userinfo.Handler(&p.config)),
 	)
 
 	if p.config.DCRIsEnabled {
 		handler.HandleFunc(
 			"POST "+p.config.PathPrefix+goidc.EndpointDynamicClient,
-			dcr.HandlerCreate(p.config),
+			p.instrument(goidc.EndpointDynamicClient, dcr.HandlerCreate(p.config)),
 		)
 
 		handler.HandleFunc(
 			"PUT "+p.config.PathPrefix+goidc.EndpointDynamicClient+"/{client_id}",
-			dcr.HandlerUpdate(p.config),
+			p.instrument(goidc.EndpointDynamicClient+"/{client_id}", dcr.HandlerUpdate(p.config)),
 		)
 
 		handler.HandleFunc(
 			"GET "+p.config.PathPrefix+goidc.EndpointDynamicClient+"/{client_id}",
-			dcr.HandlerGet(p.config),
+			p.instrument(goidc.EndpointDynamicClient+"/{client_id}", dcr.HandlerGet(p.config)),
 		)
 
 		handler.HandleFunc(
 			"DELETE "+p.config.PathPrefix+goidc.EndpointDynamicClient+"/{client_id}",
-			dcr.HandlerDelete(p.config),
+			p.instrument(goidc.EndpointDynamicClient+"/{client_id}", dcr.HandlerDelete(p.config)),
 		)
 	}
 
 	if p.config.IntrospectionIsEnabled {
 		handler.HandleFunc(
 			"POST "+p.config.PathPrefix+goidc.EndpointTokenIntrospection,
-			token.HandlerIntrospect(&p.config),
+			p.instrument(goidc.EndpointTokenIntrospection, token.HandlerIntrospect(&p.config)),
 		)
 	}
 
-	return handler
+	if p.config.TokenRevocationIsEnabled {
+		handler.HandleFunc(
+			"POST "+p.config.PathPrefix+goidc.EndpointTokenRevocation,
+			p.instrument(goidc.EndpointTokenRevocation, token.HandlerRevoke(&p.config)),
+		)
+	}
+
+	if p.config.DeviceGrantIsEnabled {
+		handler.HandleFunc(
+			"POST "+p.config.PathPrefix+goidc.EndpointDeviceAuthorization,
+			p.instrument(goidc.EndpointDeviceAuthorization, authorize.HandlerDeviceAuthorization(&p.config)),
+		)
+
+		handler.HandleFunc(
+			"GET "+p.config.PathPrefix+goidc.EndpointDevice,
+			p.instrument(goidc.EndpointDevice, authorize.HandlerDevice(&p.config)),
+		)
+	}
+
+	if p.config.EndSessionIsEnabled {
+		handler.HandleFunc(
+			"GET "+p.config.PathPrefix+goidc.EndpointEndSession,
+			p.instrument(goidc.EndpointEndSession, logout.HandlerEndSession(&p.config)),
+		)
+
+		handler.HandleFunc(
+			"POST "+p.config.PathPrefix+goidc.EndpointEndSession,
+			p.instrument(goidc.EndpointEndSession, logout.HandlerEndSession(&p.config)),
+		)
+
+		handler.HandleFunc(
+			"GET "+p.config.PathPrefix+goidc.EndpointEndSession+"/{callback}",
+			p.instrument(goidc.EndpointEndSession+"/{callback}", logout.HandlerCallback(&p.config)),
+		)
+
+		handler.HandleFunc(
+			"POST "+p.config.PathPrefix+goidc.EndpointEndSession+"/{callback}",
+			p.instrument(goidc.EndpointEndSession+"/{callback}", logout.HandlerCallback(&p.config)),
+		)
+	}
+
+	if p.config.FederationIsEnabled {
+		handler.HandleFunc(
+			"GET "+p.config.PathPrefix+goidc.EndpointFederation,
+			p.instrument(goidc.EndpointFederation, discovery.HandlerFederation(&p.config)),
+		)
+	}
+
+	if p.config.ProtectedResourceMetadataIsEnabled {
+		handler.HandleFunc(
+			"GET "+p.config.PathPrefix+goidc.EndpointProtectedResource,
+			p.instrument(goidc.EndpointProtectedResource, discovery.HandlerProtectedResource(&p.config)),
+		)
+	}
+
+	// The probes are not served under the path prefix, since they are not
+	// part of the OAuth API.
+	if p.config.HealthEndpointsAreEnabled {
+		handler.HandleFunc(
+			"GET "+goidc.EndpointHealth,
+			health.HandlerLiveness(&p.config),
+		)
+
+		handler.HandleFunc(
+			"GET "+goidc.EndpointReady,
+			health.HandlerReadiness(&p.config),
+		)
+	}
+
+	return newConfigSnapshotMiddleware(newCorrelationIDMiddleware(handler, p.config.CorrelationIDHeader), &p.config, p.mu)
 }
 
 func (p *Provider) mtlsHandler() http.Handler {
@@ -728,34 +1766,48 @@ func (p *Provider) mtlsHandler() http.Handler {
 
 	serverHandler.HandleFunc(
 		"POST "+p.config.PathPrefix+goidc.EndpointToken,
-		token.Handler(&p.config),
+		p.instrument(goidc.EndpointToken, token.Handler(&p.config)),
 	)
 
 	serverHandler.HandleFunc(
 		"GET "+p.config.PathPrefix+goidc.EndpointUserInfo,
-		userinfo.Handler(&p.config),
+		p.instrument(goidc.EndpointUserInfo, userinfo.Handler(&p.config)),
 	)
 
 	serverHandler.HandleFunc(
 		"POST "+p.config.PathPrefix+goidc.EndpointUserInfo,
-		userinfo.Handler(&p.config),
+		p.instrument(goidc.EndpointUserInfo, userinfo.Handler(&p.config)),
 	)
 
 	if p.config.PARIsEnabled {
 		serverHandler.HandleFunc(
 			"POST "+p.config.PathPrefix+goidc.EndpointPushedAuthorizationRequest,
-			authorize.HandlerPush(&p.config),
+			p.instrument(goidc.EndpointPushedAuthorizationRequest, authorize.HandlerPush(&p.config)),
 		)
 	}
 
 	if p.config.IntrospectionIsEnabled {
 		serverHandler.HandleFunc(
 			"POST "+p.config.PathPrefix+goidc.EndpointTokenIntrospection,
-			token.HandlerIntrospect(&p.config),
+			p.instrument(goidc.EndpointTokenIntrospection, token.HandlerIntrospect(&p.config)),
 		)
 	}
 
-	return serverHandler
+	if p.config.TokenRevocationIsEnabled {
+		serverHandler.HandleFunc(
+			"POST "+p.config.PathPrefix+goidc.EndpointTokenRevocation,
+			p.instrument(goidc.EndpointTokenRevocation, token.HandlerRevoke(&p.config)),
+		)
+	}
+
+	if p.config.DeviceGrantIsEnabled {
+		serverHandler.HandleFunc(
+			"POST "+p.config.PathPrefix+goidc.EndpointDeviceAuthorization,
+			p.instrument(goidc.EndpointDeviceAuthorization, authorize.HandlerDeviceAuthorization(&p.config)),
+		)
+	}
+
+	return newConfigSnapshotMiddleware(newCorrelationIDMiddleware(serverHandler, p.config.CorrelationIDHeader), &p.config, p.mu)
 }
 
 // TODO: Add more validations.
@@ -768,20 +1820,59 @@ func (p *Provider) validateConfiguration() error {
 		validateEncryptionKeys,
 		validatePrivateKeyJWTSignatureAlgorithms,
 		validateClientSecretJWTSignatureAlgorithms,
+		validateIDTokenSymmetricSignatureAlgorithms,
+		validateJWTBearerGrant,
+		validateBackChannelLogout,
 		validateIntrospectionClientAuthnMethods,
+		validateJWTIntrospectionResponse,
+		validateSignedMetadata,
+		validateResponseTypes,
+		validateResponseModes,
 		validateUserInfoEncryption,
 		validateJAREncryption,
+		validateJARByReference,
 		validateJARMEncryption,
 		validateTokenBinding,
+		validateDCRMode,
+		validateAssertionAudienceMode,
+		validateRedirectURIMatchingMode,
+		validateIDTokenSuppression,
 		validateOpenIDProfile,
 		validateFAPI2Profile,
+		validateFederation,
+		validateDeviceGrant,
+		validateRefreshTokenRotation,
+		validateDPoPNonce,
 	)
 }
 
+// DCRMetadataLimits defines the maximum number of values a client can register for each metadata.
+type DCRMetadataLimits struct {
+	RedirectURIs             int
+	GrantTypes               int
+	ResponseTypes            int
+	AuthorizationDetailTypes int
+}
+
+// PARLimits defines the maximum size of pushed authorization requests.
+type PARLimits struct {
+	// RequestBodyBytes is the maximum size of the request body in bytes.
+	RequestBodyBytes int64
+	// AuthorizationDetails is the maximum number of authorization_details entries.
+	AuthorizationDetails int
+	// ScopeLength is the maximum length of the scope parameter.
+	ScopeLength int
+}
+
 type TLSOptions struct {
-	TLSAddress                     string
-	ServerCertificate              string
-	ServerKey                      string
+	TLSAddress        string
+	ServerCertificate string
+	ServerKey         string
+	// TLSConfig is used as the base configuration for both the TLS and mTLS servers.
+	// The other options informed here take precedence over it.
+	TLSConfig *tls.Config
+	// MinVersion is the minimum TLS version accepted. The default is TLS 1.2.
+	MinVersion                     uint16
 	CipherSuites                   []uint16
 	MTLSAddress                    string
 	CaCertificatePool              *x509.CertPool
@@ -0,0 +1,404 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTLSConfig_DefaultMinVersion(t *testing.T) {
+	// When.
+	tlsConfig := newTLSConfig(TLSOptions{})
+
+	// Then.
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+}
+
+func TestNewTLSConfig_CustomConfig(t *testing.T) {
+	// Given.
+	baseConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS13,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+	}
+
+	// When.
+	tlsConfig := newTLSConfig(TLSOptions{
+		TLSConfig: baseConfig,
+	})
+
+	// Then.
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, baseConfig.CipherSuites, tlsConfig.CipherSuites)
+	assert.NotSame(t, baseConfig, tlsConfig, "the base config should not be modified")
+}
+
+func TestNewTLSConfig_OptionsOverrideCustomConfig(t *testing.T) {
+	// Given.
+	baseConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	cipherSuites := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+
+	// When.
+	tlsConfig := newTLSConfig(TLSOptions{
+		TLSConfig:    baseConfig,
+		MinVersion:   tls.VersionTLS13,
+		CipherSuites: cipherSuites,
+	})
+
+	// Then.
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, cipherSuites, tlsConfig.CipherSuites)
+	assert.Equal(t, uint16(tls.VersionTLS12), baseConfig.MinVersion, "the base config should not be modified")
+}
+
+func TestNewMTLSConfig(t *testing.T) {
+	// Given.
+	pool := x509.NewCertPool()
+
+	// When.
+	tlsConfig := newMTLSConfig(TLSOptions{
+		CaCertificatePool: pool,
+	})
+
+	// Then.
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	assert.Same(t, pool, tlsConfig.ClientCAs)
+}
+
+func TestNewMTLSConfig_WithoutCertificatePool(t *testing.T) {
+	// When.
+	tlsConfig := newMTLSConfig(TLSOptions{})
+
+	// Then.
+	assert.Equal(t, tls.RequireAnyClientCert, tlsConfig.ClientAuth)
+}
+
+func TestValidateResponseTypes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		grantTypes    []goidc.GrantType
+		responseTypes []goidc.ResponseType
+		shouldBeValid bool
+	}{
+		{
+			"code and hybrid without token",
+			[]goidc.GrantType{goidc.GrantAuthorizationCode, goidc.GrantImplicit},
+			[]goidc.ResponseType{goidc.ResponseTypeCode, goidc.ResponseTypeCodeAndIDToken},
+			true,
+		},
+		{
+			"implicit response type without implicit grant",
+			[]goidc.GrantType{goidc.GrantAuthorizationCode},
+			[]goidc.ResponseType{goidc.ResponseTypeCode, goidc.ResponseTypeToken},
+			false,
+		},
+		{
+			"code without authorization code grant",
+			[]goidc.GrantType{goidc.GrantImplicit},
+			[]goidc.ResponseType{goidc.ResponseTypeCode},
+			false,
+		},
+		{
+			"no response types",
+			[]goidc.GrantType{goidc.GrantAuthorizationCode},
+			nil,
+			false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			p := Provider{
+				config: oidc.Configuration{
+					GrantTypes:    testCase.grantTypes,
+					ResponseTypes: testCase.responseTypes,
+				},
+			}
+
+			// When.
+			err := validateResponseTypes(p)
+
+			// Then.
+			if testCase.shouldBeValid {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateResponseModes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		jarmIsEnabled bool
+		responseModes []goidc.ResponseMode
+		shouldBeValid bool
+	}{
+		{"plain response modes", false, []goidc.ResponseMode{goidc.ResponseModeQuery, goidc.ResponseModeFormPost}, true},
+		{"jarm response mode with jarm", true, []goidc.ResponseMode{goidc.ResponseModeFormPostJWT}, true},
+		{"jarm response mode without jarm", false, []goidc.ResponseMode{goidc.ResponseModeJWT}, false},
+		{"no response modes", false, nil, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			p := Provider{
+				config: oidc.Configuration{
+					JARMIsEnabled: testCase.jarmIsEnabled,
+					ResponseModes: testCase.responseModes,
+				},
+			}
+
+			// When.
+			err := validateResponseModes(p)
+
+			// Then.
+			if testCase.shouldBeValid {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestCorrelationIDMiddleware(t *testing.T) {
+	// Given.
+	var receivedID string
+	handler := newCorrelationIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedID = r.Header.Get(goidc.HeaderCorrelationID)
+	}), goidc.HeaderCorrelationID)
+
+	req := httptest.NewRequest(http.MethodGet, "/token", nil)
+	req.Header.Set(goidc.HeaderCorrelationID, "random_id")
+	w := httptest.NewRecorder()
+
+	// When.
+	handler.ServeHTTP(w, req)
+
+	// Then.
+	assert.Equal(t, "random_id", receivedID)
+	assert.Equal(t, "random_id", w.Header().Get(goidc.HeaderCorrelationID))
+}
+
+func TestCorrelationIDMiddleware_GeneratesID(t *testing.T) {
+	// Given.
+	var receivedID string
+	handler := newCorrelationIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedID = r.Header.Get(goidc.HeaderCorrelationID)
+	}), goidc.HeaderCorrelationID)
+
+	req := httptest.NewRequest(http.MethodGet, "/token", nil)
+	w := httptest.NewRecorder()
+
+	// When.
+	handler.ServeHTTP(w, req)
+
+	// Then.
+	assert.NotEmpty(t, receivedID)
+	assert.Equal(t, receivedID, w.Header().Get(goidc.HeaderCorrelationID))
+}
+
+func TestConfigSnapshotMiddleware_ConfigurationUpdatedDuringRequest(t *testing.T) {
+	// Given.
+	mu := &sync.RWMutex{}
+	config := &oidc.Configuration{Host: "https://old.example.com"}
+	var requestHost string
+	handler := newConfigSnapshotMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Updating the configuration while a request is in flight must not
+		// block, since the lock is not held by the middleware anymore.
+		mu.Lock()
+		config.Host = "https://new.example.com"
+		mu.Unlock()
+
+		requestHost = oidc.NewRequestContext(config, r, w).Host
+	}), config, mu)
+
+	req := httptest.NewRequest(http.MethodGet, "/token", nil)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+
+	// When.
+	go func() {
+		handler.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	// Then.
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request should not deadlock")
+	}
+	assert.Equal(t, "https://old.example.com", requestHost, "the request should use the configuration it started with")
+}
+
+func TestRotateSignatureKey_OverlapRetirement(t *testing.T) {
+	// Given.
+	oldKey := oidc.PrivateRS256JWK(t, "old_key")
+	newKey := oidc.PrivateRS256JWK(t, "new_key")
+	p, err := New(oidc.TestHost, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{oldKey}}, oldKey.KeyID)
+	require.Nil(t, err)
+
+	isPublished := func(keyID string) bool {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return len(p.config.PrivateJWKS.Key(keyID)) != 0
+	}
+
+	// When.
+	err = p.RotateSignatureKey(goidc.SignatureKeyPurposeToken, newKey, 60)
+
+	// Then.
+	require.Nil(t, err)
+	assert.True(t, isPublished(oldKey.KeyID), "the previous key should be published during the overlap")
+	assert.True(t, isPublished(newKey.KeyID))
+
+	// When.
+	err = p.RotateSignatureKey(goidc.SignatureKeyPurposeUserInfo, newKey, 0)
+
+	// Then.
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool { return !isPublished(oldKey.KeyID) }, 5*time.Second, 10*time.Millisecond,
+		"the previous key should be retired after the overlap")
+	assert.True(t, isPublished(newKey.KeyID))
+}
+
+func TestNewInMemoryAuthnSessionManagerWithSweeper(t *testing.T) {
+	// Given.
+	manager, stop := NewInMemoryAuthnSessionManagerWithSweeper(10 * time.Millisecond)
+	defer stop()
+
+	ctx := context.Background()
+	require.Nil(t, manager.Save(ctx, &goidc.AuthnSession{
+		ID:                 "random_session_id",
+		CallbackID:         "random_callback_id",
+		ExpiresAtTimestamp: time.Now().Unix() - 10,
+	}))
+
+	// Then.
+	assert.Eventually(t, func() bool {
+		_, err := manager.GetByCallbackID(ctx, "random_callback_id")
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "the expired session should be removed")
+}
+
+func TestValidateBackChannelLogout(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: oidc.Configuration{
+			BackChannelLogoutIsEnabled: true,
+			GrantSessionManager:        NewInMemoryGrantSessionManager(),
+		},
+	}
+
+	// When.
+	err := validateBackChannelLogout(p)
+
+	// Then.
+	assert.Nil(t, err)
+}
+
+func TestValidateBackChannelLogout_ManagerCannotFindBySubject(t *testing.T) {
+	// Given.
+	p := Provider{
+		config: oidc.Configuration{
+			BackChannelLogoutIsEnabled: true,
+			GrantSessionManager:        struct{ goidc.GrantSessionManager }{NewInMemoryGrantSessionManager()},
+		},
+	}
+
+	// When.
+	err := validateBackChannelLogout(p)
+
+	// Then.
+	assert.NotNil(t, err)
+}
+
+func TestNew_PKCEOptionsInAnyOrder(t *testing.T) {
+	key := oidc.PrivateRS256JWK(t, "random_key_id")
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key}}
+
+	testCases := []struct {
+		name                 string
+		opts                 []ProviderOption
+		codeChallengeMethods []goidc.CodeChallengeMethod
+		pkceIsEnabled        bool
+	}{
+		{
+			"s256 required before pkce",
+			[]ProviderOption{WithPKCES256Required(), WithPKCE(goidc.CodeChallengeMethodSHA256, goidc.CodeChallengeMethodPlain)},
+			[]goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256},
+			true,
+		},
+		{
+			"s256 required after pkce",
+			[]ProviderOption{WithPKCE(goidc.CodeChallengeMethodSHA256, goidc.CodeChallengeMethodPlain), WithPKCES256Required()},
+			[]goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256},
+			true,
+		},
+		{
+			"pkce required for public clients only",
+			[]ProviderOption{WithPKCERequiredForPublicClients()},
+			[]goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256},
+			false,
+		},
+		{
+			"pkce required for public clients with methods",
+			[]ProviderOption{WithPKCERequiredForPublicClients(), WithPKCE(goidc.CodeChallengeMethodPlain)},
+			[]goidc.CodeChallengeMethod{goidc.CodeChallengeMethodPlain},
+			true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			p, err := New(oidc.TestHost, jwks, key.KeyID, testCase.opts...)
+
+			// Then.
+			require.Nil(t, err)
+			assert.Equal(t, testCase.codeChallengeMethods, p.config.CodeChallengeMethods)
+			assert.Equal(t, testCase.pkceIsEnabled, p.config.PkceIsEnabled)
+		})
+	}
+}
+
+func TestRequiredOptionsEnableTheFeature(t *testing.T) {
+	// Given.
+	p := &Provider{}
+
+	// When.
+	WithDPoPRequired(600, jose.ES256)(p)
+	WithPKCERequired(goidc.CodeChallengeMethodSHA256)(p)
+	WithJARRequired(600, jose.PS256)(p)
+
+	// Then.
+	assert.True(t, p.config.DPoPIsEnabled)
+	assert.True(t, p.config.DPoPIsRequired)
+	assert.Equal(t, []jose.SignatureAlgorithm{jose.ES256}, p.config.DPoPSignatureAlgorithms)
+
+	assert.True(t, p.config.PkceIsEnabled)
+	assert.True(t, p.config.PkceIsRequired)
+	assert.Equal(t, []goidc.CodeChallengeMethod{goidc.CodeChallengeMethodSHA256}, p.config.CodeChallengeMethods)
+
+	assert.True(t, p.config.JARIsEnabled)
+	assert.True(t, p.config.JARIsRequired)
+	assert.Equal(t, []jose.SignatureAlgorithm{jose.PS256}, p.config.JARSignatureAlgorithms)
+}
@@ -1,9 +1,16 @@
 package provider
 
 import (
+	"context"
+	"database/sql"
+	"time"
+
 	"github.com/luikyv/go-oidc/internal/storage/inmemory"
 	"github.com/luikyv/go-oidc/internal/storage/mongodb"
+	"github.com/luikyv/go-oidc/internal/storage/postgres"
+	"github.com/luikyv/go-oidc/internal/storage/redis"
 	"github.com/luikyv/go-oidc/pkg/goidc"
+	goredis "github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -22,6 +29,50 @@ func NewInMemoryGrantSessionManager() goidc.GrantSessionManager {
 	return inmemory.NewGrantSessionManager()
 }
 
+func NewInMemoryDeviceSessionManager() goidc.DeviceSessionManager {
+	return inmemory.NewDeviceSessionManager()
+}
+
+func NewInMemoryLogoutSessionManager() goidc.LogoutSessionManager {
+	return inmemory.NewLogoutSessionManager()
+}
+
+// NewInMemoryDPoPNonceStore creates an in memory store whose nonces can be
+// used only once and expire after lifetimeSecs.
+func NewInMemoryDPoPNonceStore(lifetimeSecs int64) goidc.DPoPNonceStore {
+	return inmemory.NewDPoPNonceStore(lifetimeSecs)
+}
+
+// NewInMemoryRateLimiter creates a token bucket rate limiter that allows up to
+// burst requests at once per key and is refilled at requestsPerSec.
+func NewInMemoryRateLimiter(requestsPerSec float64, burst int) goidc.RateLimiter {
+	return inmemory.NewRateLimiter(requestsPerSec, burst)
+}
+
+// NewInMemoryIntrospectionCache creates a least recently used cache that keeps
+// up to size introspection results for at most ttl.
+func NewInMemoryIntrospectionCache(size int, ttl time.Duration) goidc.IntrospectionCache {
+	return inmemory.NewIntrospectionCache(size, ttl)
+}
+
+// NewInMemoryAuthnSessionManagerWithSweeper creates an in memory manager that
+// removes the expired authentication sessions every interval.
+// The function returned stops the sweeper and must be called once the manager
+// is no longer used.
+func NewInMemoryAuthnSessionManagerWithSweeper(interval time.Duration) (goidc.AuthnSessionManager, func()) {
+	manager := inmemory.NewAuthnSessionManagerWithSweeper(interval)
+	return manager, manager.Close
+}
+
+// NewInMemoryGrantSessionManagerWithSweeper creates an in memory manager that
+// removes the expired grant sessions every interval.
+// The function returned stops the sweeper and must be called once the manager
+// is no longer used.
+func NewInMemoryGrantSessionManagerWithSweeper(interval time.Duration) (goidc.GrantSessionManager, func()) {
+	manager := inmemory.NewGrantSessionManagerWithSweeper(interval)
+	return manager, manager.Close
+}
+
 //---------------------------------------- MongoDB ----------------------------------------//
 
 func NewMongoDBClientManager(database *mongo.Database) goidc.ClientManager {
@@ -35,3 +86,47 @@ func NewMongoDBAuthnSessionManager(database *mongo.Database) goidc.AuthnSessionM
 func NewMongoDBGrantSessionManager(database *mongo.Database) goidc.GrantSessionManager {
 	return mongodb.NewGrantSessionManager(database)
 }
+
+// NewMongoDBGrantSessionManagerWithCollection creates a manager that stores
+// grant sessions in the collection informed.
+// The indexes to look up and expire the grant sessions are created with
+// CreateMongoDBGrantSessionIndexes.
+func NewMongoDBGrantSessionManagerWithCollection(collection *mongo.Collection) goidc.GrantSessionManager {
+	return mongodb.NewGrantSessionManagerWithCollection(collection)
+}
+
+// CreateMongoDBGrantSessionIndexes creates the indexes on token_id and
+// refresh_token used to look up grant sessions and a TTL index that evicts
+// them once they expire.
+func CreateMongoDBGrantSessionIndexes(ctx context.Context, collection *mongo.Collection) error {
+	return mongodb.NewGrantSessionManagerWithCollection(collection).CreateIndexes(ctx)
+}
+
+//---------------------------------------- PostgreSQL ----------------------------------------//
+
+// NewPostgresClientManager creates a manager that stores clients in PostgreSQL.
+// The database can be opened with any PostgreSQL driver for database/sql, e.g. pgx.
+// MigratePostgres must be called beforehand to create the clients table.
+func NewPostgresClientManager(db *sql.DB) goidc.ClientManager {
+	return postgres.NewClientManager(db)
+}
+
+// MigratePostgres creates the tables used by the PostgreSQL managers if they
+// don't exist yet.
+func MigratePostgres(ctx context.Context, db *sql.DB) error {
+	return postgres.Migrate(ctx, db)
+}
+
+//---------------------------------------- Redis ----------------------------------------//
+
+// NewRedisAuthnSessionManager creates a manager that stores authentication sessions
+// in Redis. The sessions expire in Redis along with ExpiresAtTimestamp.
+func NewRedisAuthnSessionManager(client *goredis.Client) goidc.AuthnSessionManager {
+	return redis.NewAuthnSessionManager(client)
+}
+
+// NewRedisGrantSessionManager creates a manager that stores grant sessions in Redis.
+// The sessions expire in Redis along with ExpiresAtTimestamp.
+func NewRedisGrantSessionManager(client *goredis.Client) goidc.GrantSessionManager {
+	return redis.NewGrantSessionManager(client)
+}
@@ -24,6 +24,13 @@ func runValidations(
 
 func validateJWKS(provider Provider) error {
 	for _, key := range provider.config.PrivateJWKS.Keys {
+		if signer, ok := key.Key.(goidc.Signer); ok {
+			if publicJWK := signer.Public(); !publicJWK.Valid() || !publicJWK.IsPublic() {
+				return fmt.Errorf("the signer with key ID: %s has an invalid public key", key.KeyID)
+			}
+			continue
+		}
+
 		if !key.Valid() {
 			return fmt.Errorf("the key with ID: %s is not valid", key.KeyID)
 		}
@@ -95,6 +102,44 @@ func validateClientSecretJWTSignatureAlgorithms(provider Provider) error {
 	return nil
 }
 
+func validateIDTokenSymmetricSignatureAlgorithms(provider Provider) error {
+	for _, signatureAlgorithm := range provider.config.IDTokenSymmetricSignatureAlgorithms {
+		if !strings.HasPrefix(string(signatureAlgorithm), "HS") {
+			return errors.New("only HMAC algorithms are allowed for symmetric ID token signatures")
+		}
+	}
+
+	return nil
+}
+
+func validateJWTBearerGrant(provider Provider) error {
+	if !provider.config.JWTBearerGrantIsEnabled {
+		return nil
+	}
+
+	if len(provider.config.JWTBearerSignatureAlgorithms) == 0 {
+		return errors.New("at least one signature algorithm must be informed for the jwt bearer grant")
+	}
+
+	if len(provider.config.JWTBearerTrustedIssuers) == 0 {
+		return errors.New("at least one trusted assertion issuer must be informed for the jwt bearer grant")
+	}
+
+	return nil
+}
+
+func validateBackChannelLogout(provider Provider) error {
+	if !provider.config.BackChannelLogoutIsEnabled {
+		return nil
+	}
+
+	if _, ok := provider.config.GrantSessionManager.(goidc.GrantSessionSubjectFinder); !ok {
+		return errors.New("the grant session manager must be able to find grant sessions by subject for back-channel logout")
+	}
+
+	return nil
+}
+
 func validateIntrospectionClientAuthnMethods(provider Provider) error {
 
 	if !provider.config.IntrospectionIsEnabled {
@@ -114,6 +159,96 @@ func validateIntrospectionClientAuthnMethods(provider Provider) error {
 	return nil
 }
 
+func validateFederation(provider Provider) error {
+	if !provider.config.FederationIsEnabled {
+		return nil
+	}
+
+	if len(provider.config.FederationTrustAnchors) == 0 {
+		return errors.New("at least one trust anchor must be informed for federation")
+	}
+
+	if provider.config.FederationTrustChainFunc == nil {
+		return errors.New("the trust chain function must be informed for federation")
+	}
+
+	return nil
+}
+
+func validateDeviceGrant(provider Provider) error {
+	if !provider.config.DeviceGrantIsEnabled {
+		return nil
+	}
+
+	if provider.config.DeviceCodeLifetimeSecs <= 0 {
+		return errors.New("the device code lifetime must be positive")
+	}
+
+	if provider.config.DevicePollingIntervalSecs <= 0 {
+		return errors.New("the device polling interval must be positive")
+	}
+
+	return nil
+}
+
+func validateRefreshTokenRotation(provider Provider) error {
+	if !provider.config.ShouldRotateRefreshTokens {
+		return nil
+	}
+
+	if !slices.Contains(provider.config.GrantTypes, goidc.GrantRefreshToken) {
+		return errors.New("the refresh token grant must be enabled to rotate refresh tokens")
+	}
+
+	return nil
+}
+
+func validateDPoPNonce(provider Provider) error {
+	if !provider.config.DPoPNonceIsRequired {
+		return nil
+	}
+
+	if !provider.config.DPoPIsEnabled {
+		return errors.New("dpop must be enabled to require dpop nonces")
+	}
+
+	if provider.config.DPoPNonceStore == nil {
+		return errors.New("a dpop nonce store must be informed to require dpop nonces")
+	}
+
+	return nil
+}
+
+func validateJWTIntrospectionResponse(provider Provider) error {
+	if !provider.config.IntrospectionJWTResponseIsEnabled {
+		return nil
+	}
+
+	if !provider.config.IntrospectionIsEnabled {
+		return errors.New("introspection must be enabled to return introspection responses as JWTs")
+	}
+
+	keys := provider.config.PrivateJWKS.Key(provider.config.IntrospectionJWTResponseSignatureKeyID)
+	if len(keys) == 0 || keys[0].Use != string(goidc.KeyUsageSignature) {
+		return errors.New("the introspection signature key must be a signing key present in the JWKS")
+	}
+
+	return nil
+}
+
+func validateSignedMetadata(provider Provider) error {
+	if !provider.config.SignedMetadataIsEnabled {
+		return nil
+	}
+
+	keys := provider.config.PrivateJWKS.Key(provider.config.SignedMetadataSignatureKeyID)
+	if len(keys) == 0 || keys[0].Use != string(goidc.KeyUsageSignature) {
+		return errors.New("the metadata signature key must be a signing key present in the JWKS")
+	}
+
+	return nil
+}
+
 func validateUserInfoEncryption(provider Provider) error {
 	if provider.config.UserInfoEncryptionIsEnabled && !slices.Contains(provider.config.UserInfoContentEncryptionAlgorithms, jose.A128CBC_HS256) {
 		return errors.New("A128CBC-HS256 should be supported as a content key encryption algorithm for user information")
@@ -134,6 +269,14 @@ func validateJAREncryption(provider Provider) error {
 	return nil
 }
 
+func validateJARByReference(provider Provider) error {
+	if provider.config.JARByReferenceIsEnabled && !provider.config.JARIsEnabled {
+		return errors.New("JAR must be enabled if request objects can be passed by reference")
+	}
+
+	return nil
+}
+
 func validateJARMEncryption(provider Provider) error {
 	if provider.config.JARMEncryptionIsEnabled && !provider.config.JARMIsEnabled {
 		return errors.New("JARM must be enabled if JARM encryption is enabled")
@@ -154,6 +297,89 @@ func validateTokenBinding(provider Provider) error {
 	return nil
 }
 
+func validateDCRMode(provider Provider) error {
+	if !provider.config.DCRIsEnabled {
+		return nil
+	}
+
+	switch provider.config.DCRMode {
+	case goidc.DCRModeOpen, goidc.DCRModeClosed:
+		return nil
+	case goidc.DCRModeProtected:
+		if len(provider.config.DCRInitialAccessTokens) == 0 && provider.config.DCRInitialAccessTokenValidator == nil {
+			return errors.New("initial access tokens or a validator must be informed when DCR is protected")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid DCR mode: %s", provider.config.DCRMode)
+	}
+}
+
+func validateAssertionAudienceMode(provider Provider) error {
+	switch provider.config.AssertionAudienceMode {
+	case "", goidc.AssertionAudienceModeIssuer, goidc.AssertionAudienceModeEndpoint:
+		return nil
+	default:
+		return fmt.Errorf("invalid assertion audience mode: %s", provider.config.AssertionAudienceMode)
+	}
+}
+
+func validateRedirectURIMatchingMode(provider Provider) error {
+	switch provider.config.RedirectURIMatchingMode {
+	case "", goidc.RedirectURIMatchingExact, goidc.RedirectURIMatchingPrefix:
+		return nil
+	default:
+		return fmt.Errorf("invalid redirect uri matching mode: %s", provider.config.RedirectURIMatchingMode)
+	}
+}
+
+func validateResponseTypes(provider Provider) error {
+	if len(provider.config.ResponseTypes) == 0 {
+		return errors.New("at least one response type must be enabled")
+	}
+
+	for _, responseType := range provider.config.ResponseTypes {
+		if responseType.Contains(goidc.ResponseTypeCode) &&
+			!slices.Contains(provider.config.GrantTypes, goidc.GrantAuthorizationCode) {
+			return fmt.Errorf("the response type %s requires the authorization code grant", responseType)
+		}
+
+		if responseType.IsImplicit() && !slices.Contains(provider.config.GrantTypes, goidc.GrantImplicit) {
+			return fmt.Errorf("the response type %s requires the implicit grant", responseType)
+		}
+	}
+
+	return nil
+}
+
+func validateResponseModes(provider Provider) error {
+	if len(provider.config.ResponseModes) == 0 {
+		return errors.New("at least one response mode must be enabled")
+	}
+
+	for _, responseMode := range provider.config.ResponseModes {
+		if responseMode.IsJARM() && !provider.config.JARMIsEnabled {
+			return fmt.Errorf("the response mode %s requires JARM", responseMode)
+		}
+	}
+
+	return nil
+}
+
+func validateIDTokenSuppression(provider Provider) error {
+	if !provider.config.IDTokenIsSuppressed {
+		return nil
+	}
+
+	for _, responseType := range provider.config.ResponseTypes {
+		if responseType.Contains(goidc.ResponseTypeIDToken) {
+			return errors.New("response types containing id_token cannot be used when ID tokens are suppressed")
+		}
+	}
+
+	return nil
+}
+
 func validateOpenIDProfile(provider Provider) error {
 	if provider.config.Profile != goidc.ProfileOpenID {
 		return nil
@@ -0,0 +1,107 @@
+// Package rp contains helpers for relying parties consuming the responses
+// issued by an OpenID provider.
+package rp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+var (
+	// ErrIssuerMissing is returned when the authorization response doesn't
+	// contain the "iss" parameter, but the provider is known to send it.
+	ErrIssuerMissing = errors.New("the iss parameter is missing from the authorization response")
+	// ErrIssuerMismatch is returned when the authorization response was issued
+	// by a different provider than the one the request was sent to.
+	ErrIssuerMismatch = errors.New("the iss parameter doesn't match the expected issuer")
+)
+
+// ValidateAuthorizationResponseIssuer verifies the "iss" parameter of an
+// authorization response as described in RFC 9207 to detect mix-up attacks.
+// params are the parameters received at the redirect URI, e.g. the query
+// parameters or the fragment parameters depending on the response mode.
+// isRequired must be true when the provider advertises
+// "authorization_response_iss_parameter_supported" in its discovery document,
+// in which case responses without "iss" are rejected.
+func ValidateAuthorizationResponseIssuer(
+	params url.Values,
+	expectedIssuer string,
+	isRequired bool,
+) error {
+	// RFC 9207. "Clients MUST compare the iss parameter value with the issuer
+	// identifier... using simple string comparison."
+	if !params.Has("iss") {
+		if isRequired {
+			return ErrIssuerMissing
+		}
+		return nil
+	}
+
+	if params.Get("iss") != expectedIssuer {
+		return ErrIssuerMismatch
+	}
+
+	return nil
+}
+
+// ValidateIDToken verifies the signature of the ID token against the
+// provider's JWKS and validates its issuer, audience and expiration.
+// The claims of the ID token are returned if it is valid.
+func ValidateIDToken(
+	idToken string,
+	expectedIssuer string,
+	clientID string,
+	providerJWKS jose.JSONWebKeySet,
+) (
+	map[string]any,
+	error,
+) {
+	parsedIDToken, err := jwt.ParseSigned(idToken, signatureAlgorithms(providerJWKS))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the id token: %w", err)
+	}
+
+	if len(parsedIDToken.Headers) != 1 {
+		return nil, errors.New("the id token must have exactly one signature")
+	}
+
+	keys := providerJWKS.Key(parsedIDToken.Headers[0].KeyID)
+	if len(keys) == 0 {
+		return nil, errors.New("the id token was signed with an unknown key")
+	}
+
+	var claims jwt.Claims
+	var rawClaims map[string]any
+	if err := parsedIDToken.Claims(keys[0].Public().Key, &claims, &rawClaims); err != nil {
+		return nil, fmt.Errorf("invalid id token signature: %w", err)
+	}
+
+	if claims.Expiry == nil {
+		return nil, errors.New("the id token must have an expiry")
+	}
+
+	if err := claims.Validate(jwt.Expected{
+		Issuer:      expectedIssuer,
+		AnyAudience: []string{clientID},
+		Time:        time.Now(),
+	}); err != nil {
+		return nil, fmt.Errorf("invalid id token claims: %w", err)
+	}
+
+	return rawClaims, nil
+}
+
+func signatureAlgorithms(jwks jose.JSONWebKeySet) []jose.SignatureAlgorithm {
+	var algorithms []jose.SignatureAlgorithm
+	for _, key := range jwks.Keys {
+		if key.Algorithm != "" {
+			algorithms = append(algorithms, jose.SignatureAlgorithm(key.Algorithm))
+		}
+	}
+	return algorithms
+}
@@ -0,0 +1,116 @@
+package rp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/luikyv/go-oidc/internal/oidc"
+	"github.com/luikyv/go-oidc/pkg/goidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAuthorizationResponseIssuer(t *testing.T) {
+	testCases := []struct {
+		name        string
+		params      url.Values
+		isRequired  bool
+		expectedErr error
+	}{
+		{"matching issuer", url.Values{"iss": {"https://server.com"}}, true, nil},
+		{"mismatched issuer", url.Values{"iss": {"https://attacker.com"}}, false, ErrIssuerMismatch},
+		{"issuer with trailing slash", url.Values{"iss": {"https://server.com/"}}, false, ErrIssuerMismatch},
+		{"missing required issuer", url.Values{"code": {"random_code"}}, true, ErrIssuerMissing},
+		{"missing optional issuer", url.Values{"code": {"random_code"}}, false, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// When.
+			err := ValidateAuthorizationResponseIssuer(testCase.params, "https://server.com", testCase.isRequired)
+
+			// Then.
+			assert.ErrorIs(t, err, testCase.expectedErr)
+		})
+	}
+}
+
+func TestValidateIDToken(t *testing.T) {
+	jwk := oidc.PrivateRS256JWK(t, "random_key_id")
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}}
+	now := time.Now().Unix()
+
+	testCases := []struct {
+		name          string
+		issuer        string
+		audience      string
+		expiry        int64
+		shouldBeValid bool
+	}{
+		{"valid id token", "https://server.com", "random_client_id", now + 60, true},
+		{"mismatched issuer", "https://attacker.com", "random_client_id", now + 60, false},
+		{"mismatched audience", "https://server.com", "another_client_id", now + 60, false},
+		{"expired id token", "https://server.com", "random_client_id", now - 120, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given.
+			idToken := signedIDToken(t, jwk, map[string]any{
+				goidc.ClaimIssuer:   testCase.issuer,
+				goidc.ClaimSubject:  "random_subject",
+				goidc.ClaimAudience: testCase.audience,
+				goidc.ClaimIssuedAt: now,
+				goidc.ClaimExpiry:   testCase.expiry,
+			})
+
+			// When.
+			claims, err := ValidateIDToken(idToken, "https://server.com", "random_client_id", jwks)
+
+			// Then.
+			if !testCase.shouldBeValid {
+				assert.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, "random_subject", claims[goidc.ClaimSubject])
+		})
+	}
+}
+
+func TestValidateIDToken_UnknownKey(t *testing.T) {
+	// Given.
+	jwk := oidc.PrivateRS256JWK(t, "random_key_id")
+	anotherJWK := oidc.PrivateRS256JWK(t, "another_key_id")
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{anotherJWK.Public()}}
+	idToken := signedIDToken(t, jwk, map[string]any{
+		goidc.ClaimIssuer:   "https://server.com",
+		goidc.ClaimAudience: "random_client_id",
+		goidc.ClaimExpiry:   time.Now().Unix() + 60,
+	})
+
+	// When.
+	_, err := ValidateIDToken(idToken, "https://server.com", "random_client_id", jwks)
+
+	// Then.
+	assert.NotNil(t, err)
+}
+
+func signedIDToken(t *testing.T, jwk jose.JSONWebKey, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(jwk.Algorithm), Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType("jwt").WithHeader("kid", jwk.KeyID),
+	)
+	require.Nil(t, err)
+
+	idToken, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.Nil(t, err)
+
+	return idToken
+}